@@ -2,19 +2,23 @@ package main
 
 import (
 	"context"
+	"flag"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/greenac/chaching/internal/calendar"
 	"github.com/greenac/chaching/internal/consts"
 	"github.com/greenac/chaching/internal/controller"
 	"github.com/greenac/chaching/internal/database/helpers"
 	"github.com/greenac/chaching/internal/database/models"
 	"github.com/greenac/chaching/internal/database/service"
 	"github.com/greenac/chaching/internal/env"
+	"github.com/greenac/chaching/internal/service/adjustment"
 	"github.com/greenac/chaching/internal/service/analysis"
 	"github.com/greenac/chaching/internal/service/database"
 	"github.com/greenac/chaching/internal/service/logger"
 	"github.com/rs/zerolog"
 	"github.com/spf13/viper"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -45,36 +49,72 @@ func main() {
 		Index2:    "ChachingIndex2",
 	}
 
-	startDate, err := time.Parse(time.RFC3339, "2022-01-01T06:29:00-07:00")
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	tickers := fs.String("tickers", "", "comma separated tickers to analyze, defaults to "+consts.Apple)
+	watchlist := fs.String("watchlist", "", "name of a stored watchlist to analyze instead of tickers")
+	start := fs.String("start", "2022-01-01T06:29:00-07:00", "first time to analyze, RFC3339")
+	end := fs.String("end", "2023-12-31T15:01:00-07:00", "last time to analyze, RFC3339")
+	_ = fs.Parse(os.Args[1:])
+
+	startDate, err := time.Parse(time.RFC3339, *start)
 	if err != nil {
 		panic(err)
 	}
 
-	endDate, err := time.Parse(time.RFC3339, "2023-12-31T15:01:00-07:00")
+	endDate, err := time.Parse(time.RFC3339, *end)
 	if err != nil {
 		panic(err)
 	}
 
+	marketCalendar, ge := calendar.CalendarFromFile(envVars.GetString("MARKET_CALENDAR_FILE"))
+	if ge != nil {
+		panic(ge)
+	}
+
 	client, ge := helpers.DynamoClient(context.Background(), config)
 	if ge != nil {
 		panic(ge)
 	}
 
 	db := database.NewDatabase[models.DbDataPoint](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap)
+	actionsDb := database.NewDatabase[models.DbCorporateAction](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap)
 
-	analysisController := controller.NewAnalysisController(log, analysis.NewAnalysisService(), service.NewDatabaseService(db))
-	tippingPoints, err := analysisController.InflectionPointsInRange(consts.Apple, startDate, endDate)
-	if err != nil {
-		panic(err)
+	// analyze split adjusted prices so splits don't show up as cliffs in the slopes
+	dbs := &service.AdjustingDatabaseService{
+		DatabaseService:   service.NewDatabaseService(db),
+		CorporateActions:  service.NewCorporateActionService(actionsDb),
+		AdjustmentService: adjustment.NewAdjustmentService(marketCalendar.Location()),
+		Options:           adjustment.AdjustOptions{Dividends: envVars.GetBool("ANALYZE_ADJUST_FOR_DIVIDENDS")},
 	}
 
-	tippingPointTotal := 0.0
-	for i, tp := range tippingPoints {
-		log.InfoFmt("%d tp: %f", i, tp)
-		tippingPointTotal += tp
+	var targets []string
+	if *tickers != "" {
+		targets = strings.Split(*tickers, ",")
+	} else if *watchlist == "" {
+		targets = []string{consts.Apple}
 	}
 
-	log.InfoFmt("tipping point = %f", tippingPointTotal/float64(len(tippingPoints)))
+	watchlists := service.NewWatchlistService(database.NewDatabase[models.DbWatchlist](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap), nil, time.Now)
+	targets, ge = service.ResolveTargets(context.Background(), watchlists, targets, *watchlist)
+	if ge != nil {
+		panic(ge)
+	}
+
+	analysisController := controller.NewAnalysisController(log, analysis.NewAnalysisService(), dbs, marketCalendar)
+	for _, target := range targets {
+		tippingPoints, err := analysisController.InflectionPointsInRange(target, startDate, endDate)
+		if err != nil {
+			panic(err)
+		}
+
+		tippingPointTotal := 0.0
+		for i, tp := range tippingPoints {
+			log.InfoFmt("%s %d tp: %f", target, i, tp)
+			tippingPointTotal += tp
+		}
+
+		log.InfoFmt("%s tipping point = %f", target, tippingPointTotal/float64(len(tippingPoints)))
+	}
 
 	log.Info("main:finished analysis")
 }
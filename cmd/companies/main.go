@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/greenac/chaching/internal/controller"
+	"github.com/greenac/chaching/internal/database/helpers"
+	dbModels "github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	"github.com/greenac/chaching/internal/env"
+	rest "github.com/greenac/chaching/internal/rest/client"
+	"github.com/greenac/chaching/internal/rest/limiter"
+	"github.com/greenac/chaching/internal/rest/models"
+	"github.com/greenac/chaching/internal/service/database"
+	"github.com/greenac/chaching/internal/service/fetch"
+	"github.com/greenac/chaching/internal/service/logger"
+	"github.com/greenac/chaching/internal/utils"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const defaultReferenceUrl = "https://api.polygon.io/v3/reference"
+
+func main() {
+	// -list writes tickers to stdout, so logs go to stderr
+	var zeroLogger zerolog.Logger
+	if os.Getenv("GO_ENV") == string(env.GoEnvLocal) {
+		zeroLogger = zerolog.New(os.Stderr).With().Logger().Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	} else {
+		zeroLogger = zerolog.New(os.Stderr).With().Logger()
+	}
+
+	log := logger.NewZeroLogWrapper(zeroLogger, logger.LogLevelForLogLevelName(os.Getenv("LOG_LEVEL")))
+
+	market := flag.String("market", "stocks", "polygon market to sync")
+	exchange := flag.String("exchange", "", "primary exchange MIC to sync or list, for example: XNAS")
+	tickerType := flag.String("type", "", "polygon ticker type to sync or list, for example: CS or ETF")
+	inactive := flag.Bool("inactive", false, "sync delisted tickers instead of active ones")
+	details := flag.Bool("details", false, "fetch each ticker's details for its listing date, one extra request per ticker")
+	list := flag.Bool("list", false, "print the stored tickers matching -exchange and -type instead of syncing")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	envVars, err := env.NewEnv(".env", viper.New())
+	if err != nil {
+		log.Error("main:failed to read env file with error: " + err.Error())
+		panic(err)
+	}
+
+	config := helpers.GetDynamoConfig(helpers.GetDynamoConfigInput{
+		MainTable:  envVars.GetString("DYNAMO_MAIN_TABLE_NAME"),
+		Env:        env.GoEnv(envVars.GetString("GO_ENV")),
+		AwsRegion:  envVars.GetString("AWS_REGION"),
+		DynamoUrl:  envVars.GetString("DYNAMO_URL"),
+		AwsProfile: os.Getenv("AWS_PROFILE"),
+	})
+
+	client, ge := helpers.DynamoClient(ctx, config)
+	if ge != nil {
+		log.Error("main:failed to create dynamo client with error: " + ge.Error())
+		panic(ge)
+	}
+
+	companies := service.NewCompanyService(
+		database.NewDatabase[dbModels.DbCompany](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap),
+		config.Index1,
+		time.Now,
+	)
+
+	if *list {
+		tickers, ge := companies.ListTickers(ctx, service.CompanyFilter{Exchange: *exchange, Type: *tickerType, ActiveOnly: !*inactive})
+		if ge != nil {
+			log.Error("main:failed to list tickers with error: " + ge.Error())
+			os.Exit(1)
+		}
+
+		for _, t := range tickers {
+			fmt.Println(t)
+		}
+
+		return
+	}
+
+	referenceUrl := defaultReferenceUrl
+	if envVars.IsSet("POLYGON_REFERENCE_URL") {
+		referenceUrl = envVars.GetString("POLYGON_REFERENCE_URL")
+	}
+
+	retryPolicy := models.DefaultRetryPolicy()
+
+	cc := controller.CompaniesController{
+		Companies:   companies,
+		Logger:      log,
+		Unmarshaler: json.Unmarshal,
+		FetchService: &fetch.FetchService{
+			Url: referenceUrl,
+			RestClient: &rest.Client{
+				BaseHeaders: &models.Headers{"Authorization": models.HeaderValue{"Bearer " + envVars.GetString("POLYGON_API_KEY")}},
+				HttpClient:  &http.Client{Timeout: 30 * time.Second},
+				BodyReader:  io.ReadAll,
+				GetRequest:  http.NewRequestWithContext,
+				Limiter: limiter.NewLimiter(limiter.Config{
+					Rate:           limiter.PerMinute(envVars.GetInt("POLYGON_REQUESTS_PER_MINUTE")),
+					Burst:          envVars.GetInt("POLYGON_REQUEST_BURST"),
+					DailyBudget:    envVars.GetInt("POLYGON_DAILY_REQUEST_BUDGET"),
+					BudgetBehavior: limiter.BudgetBehavior(envVars.GetString("POLYGON_BUDGET_BEHAVIOR")),
+				}),
+				RetryPolicy: &retryPolicy,
+				Logger:      log,
+			},
+			PathJoiner: utils.JoinUrl,
+		},
+	}
+
+	saved, errs := cc.SyncCompanies(ctx, controller.CompanyQuery{Market: *market, Exchange: *exchange, Type: *tickerType, Inactive: *inactive, Details: *details})
+	for _, e := range errs {
+		log.Error("main:syncing companies got error: " + e.Error())
+	}
+
+	log.InfoFmt("main:saved %d companies", saved)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/greenac/chaching/internal/controller"
+	"github.com/greenac/chaching/internal/database/helpers"
+	dbModels "github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	"github.com/greenac/chaching/internal/env"
+	rest "github.com/greenac/chaching/internal/rest/client"
+	"github.com/greenac/chaching/internal/rest/limiter"
+	"github.com/greenac/chaching/internal/rest/models"
+	"github.com/greenac/chaching/internal/service/database"
+	"github.com/greenac/chaching/internal/service/fetch"
+	"github.com/greenac/chaching/internal/service/logger"
+	"github.com/greenac/chaching/internal/utils"
+	"github.com/spf13/viper"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const defaultReferenceUrl = "https://api.polygon.io/v3/reference"
+
+func main() {
+	log := logger.NewLogger(logger.LogLevelForLogLevelName(os.Getenv("LOG_LEVEL")), os.Getenv("GO_ENV") != string(env.GoEnvLocal))
+
+	log.Info("Running corporate actions...")
+
+	tickers := flag.String("tickers", "", "comma separated tickers to fetch splits and dividends for")
+	flag.Parse()
+
+	var targets []string
+	for _, t := range strings.Split(*tickers, ",") {
+		if t = strings.ToUpper(strings.TrimSpace(t)); t != "" {
+			targets = append(targets, t)
+		}
+	}
+
+	if len(targets) == 0 {
+		log.Error("main:at least one ticker is required")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	envVars, err := env.NewEnv(".env", viper.New())
+	if err != nil {
+		log.Error("main:failed to read env file with error: " + err.Error())
+		panic(err)
+	}
+
+	config := helpers.GetDynamoConfig(helpers.GetDynamoConfigInput{
+		MainTable:  envVars.GetString("DYNAMO_MAIN_TABLE_NAME"),
+		Env:        env.GoEnv(envVars.GetString("GO_ENV")),
+		AwsRegion:  envVars.GetString("AWS_REGION"),
+		DynamoUrl:  envVars.GetString("DYNAMO_URL"),
+		AwsProfile: os.Getenv("AWS_PROFILE"),
+	})
+
+	client, ge := helpers.DynamoClient(ctx, config)
+	if ge != nil {
+		log.Error("main:failed to create dynamo client with error: " + ge.Error())
+		panic(ge)
+	}
+
+	referenceUrl := defaultReferenceUrl
+	if envVars.IsSet("POLYGON_REFERENCE_URL") {
+		referenceUrl = envVars.GetString("POLYGON_REFERENCE_URL")
+	}
+
+	retryPolicy := models.DefaultRetryPolicy()
+
+	cc := controller.CorporateActionsController{
+		CorporateActions: service.NewCorporateActionService(database.NewDatabase[dbModels.DbCorporateAction](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap)),
+		Logger:           log,
+		Unmarshaler:      json.Unmarshal,
+		FetchService: &fetch.FetchService{
+			Url: referenceUrl,
+			RestClient: &rest.Client{
+				BaseHeaders: &models.Headers{"Authorization": models.HeaderValue{"Bearer " + envVars.GetString("POLYGON_API_KEY")}},
+				HttpClient:  &http.Client{Timeout: 30 * time.Second},
+				BodyReader:  io.ReadAll,
+				GetRequest:  http.NewRequestWithContext,
+				Limiter: limiter.NewLimiter(limiter.Config{
+					Rate:           limiter.PerMinute(envVars.GetInt("POLYGON_REQUESTS_PER_MINUTE")),
+					Burst:          envVars.GetInt("POLYGON_REQUEST_BURST"),
+					DailyBudget:    envVars.GetInt("POLYGON_DAILY_REQUEST_BUDGET"),
+					BudgetBehavior: limiter.BudgetBehavior(envVars.GetString("POLYGON_BUDGET_BEHAVIOR")),
+				}),
+				RetryPolicy: &retryPolicy,
+				Logger:      log,
+			},
+			PathJoiner: utils.JoinUrl,
+		},
+	}
+
+	saved, errs := cc.SyncCorporateActions(ctx, targets)
+	for _, e := range errs {
+		log.Error("main:syncing corporate actions got error: " + e.Error())
+	}
+
+	log.InfoFmt("main:saved %d corporate actions for %d tickers", saved, len(targets))
+}
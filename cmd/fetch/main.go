@@ -3,24 +3,33 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/greenac/chaching/internal/consts"
+	"github.com/greenac/chaching/internal/calendar"
 	"github.com/greenac/chaching/internal/controller"
 	"github.com/greenac/chaching/internal/database/helpers"
 	dbModels "github.com/greenac/chaching/internal/database/models"
 	"github.com/greenac/chaching/internal/database/service"
 	"github.com/greenac/chaching/internal/env"
+	"github.com/greenac/chaching/internal/job"
 	rest "github.com/greenac/chaching/internal/rest/client"
+	"github.com/greenac/chaching/internal/rest/limiter"
 	"github.com/greenac/chaching/internal/rest/models"
-	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/breaker"
 	"github.com/greenac/chaching/internal/service/database"
 	"github.com/greenac/chaching/internal/service/fetch"
 	"github.com/greenac/chaching/internal/service/logger"
+	"github.com/greenac/chaching/internal/service/provider"
+	"github.com/greenac/chaching/internal/service/quality"
 	"github.com/greenac/chaching/internal/utils"
 	"github.com/spf13/viper"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -29,6 +38,9 @@ func main() {
 
 	log.Info("Running fetch...")
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	envVars, err := env.NewEnv(".env", viper.New())
 	if err != nil {
 		log.Error("main:failed to read env file with error: " + err.Error())
@@ -43,56 +55,164 @@ func main() {
 		AwsProfile: os.Getenv("AWS_PROFILE"),
 	})
 
-	start, err := time.Parse(time.RFC3339, "2023-03-01T09:30:00-04:00")
-	if err != nil {
-		log.Error("main:failed to parse start time with error: " + err.Error())
-		panic(err)
+	marketCalendar, ge := calendar.CalendarFromFile(envVars.GetString("MARKET_CALENDAR_FILE"))
+	if ge != nil {
+		log.Error("main:failed to load market calendar with error: " + ge.Error())
+		panic(ge)
+	}
+
+	spec, err := job.ParseFetchFlags(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
 
-	end, err := time.Parse(time.RFC3339, "2023-08-01T16:00:00-04:00")
 	if err != nil {
-		log.Error("main:failed to parse end time with error: " + err.Error())
-		panic(err)
+		log.Error("main:failed to parse flags with error: " + err.Error())
+		os.Exit(2)
+	}
+
+	fetchJob, ge := spec.Resolve(marketCalendar.Location(), time.Now())
+	if ge != nil {
+		log.Error("main:invalid fetch job with error: " + ge.Error())
+		os.Exit(2)
 	}
 
-	endOfDay := time.Date(start.Year(), start.Month(), start.Day(), 16, 0, 0, 0, start.Location())
+	log.Info("main:fetch job " + fetchJob.String())
 
-	client, ge := helpers.DynamoClient(context.Background(), config)
+	client, ge := helpers.DynamoClient(ctx, config)
 	if ge != nil {
 		log.Error("main:failed to create dynamo table with error: " + ge.Error())
 		panic(ge)
 	}
 
-	db := database.NewDatabase[dbModels.DbDataPoint](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap)
+	if fetchJob.Watchlist != "" {
+		watchlists := service.NewWatchlistService(database.NewDatabase[dbModels.DbWatchlist](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap), nil, time.Now)
+		fetchJob.Tickers, ge = service.ResolveTargets(ctx, watchlists, nil, fetchJob.Watchlist)
+		if ge != nil {
+			log.Error("main:failed to read watchlist with error: " + ge.Error())
+			os.Exit(2)
+		}
+
+		log.InfoFmt("main:watchlist %s has %d tickers", fetchJob.Watchlist, len(fetchJob.Tickers))
+	}
+
+	fetchParams := controller.FetchParams{TimespanMultiplier: fetchJob.Multiplier, Limit: fetchJob.Limit, Timespan: fetchJob.Timespan, Adjusted: fetchJob.Adjusted}
+
+	if fetchJob.DryRun {
+		fc := controller.FetchController{Calendar: marketCalendar, StartDate: fetchJob.StartDate, EndDate: fetchJob.EndDate, PartitionValue: fetchJob.Partition}
+		plan := fc.FetchPlan(fetchParams)
+		if fetchJob.Incremental {
+			log.Info("main:dry run does not read watermarks, the windows below start at the job's start")
+		}
+		log.InfoFmt("main:dry run would make %d requests for %d tickers", len(plan.Windows)*len(fetchJob.Tickers), len(fetchJob.Tickers))
+		fmt.Println(plan.String())
+		return
+	}
+
+	if envVars.IsSet("FETCH_TIMEOUT") {
+		timeout, err := time.ParseDuration(envVars.GetString("FETCH_TIMEOUT"))
+		if err != nil {
+			log.Error("main:failed to parse fetch timeout with error: " + err.Error())
+			panic(err)
+		}
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	batchConfig := database.DefaultBatchConfig()
+	if envVars.IsSet("DYNAMO_BATCH_MAX_ATTEMPTS") {
+		batchConfig.Retry.MaxAttempts = envVars.GetInt("DYNAMO_BATCH_MAX_ATTEMPTS")
+	}
+
+	if envVars.IsSet("DYNAMO_BATCH_CONCURRENCY") {
+		batchConfig.Concurrency = envVars.GetInt("DYNAMO_BATCH_CONCURRENCY")
+	}
+
+	// write capacity units per second shared by every batch the fetch writes, one second of them can be spent at once
+	if wcu := envVars.GetInt("DYNAMO_WRITE_CAPACITY_UNITS"); wcu > 0 {
+		batchConfig.Limiter = limiter.NewLimiter(limiter.Config{Rate: float64(wcu), Burst: wcu})
+	}
+
+	db := database.NewDatabaseWithBatchConfig[dbModels.DbDataPoint](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap, batchConfig)
+
+	var watermarks service.IWatermarkService
+	if fetchJob.Incremental {
+		watermarks = service.NewWatermarkService(database.NewDatabase[dbModels.DbWatermark](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap), time.Now)
+	}
+
+	rateLimiter := limiter.NewLimiter(limiter.Config{
+		Rate:           limiter.PerMinute(envVars.GetInt("POLYGON_REQUESTS_PER_MINUTE")),
+		Burst:          envVars.GetInt("POLYGON_REQUEST_BURST"),
+		DailyBudget:    envVars.GetInt("POLYGON_DAILY_REQUEST_BUDGET"),
+		BudgetBehavior: limiter.BudgetBehavior(envVars.GetString("POLYGON_BUDGET_BEHAVIOR")),
+	})
+
+	retryPolicy := models.DefaultRetryPolicy()
+	if envVars.IsSet("POLYGON_MAX_ATTEMPTS") {
+		retryPolicy.MaxAttempts = envVars.GetInt("POLYGON_MAX_ATTEMPTS")
+	}
+
+	failureThreshold := 5
+	if envVars.IsSet("FETCH_BREAKER_FAILURE_THRESHOLD") {
+		failureThreshold = envVars.GetInt("FETCH_BREAKER_FAILURE_THRESHOLD")
+	}
+
+	circuitBreaker := breaker.NewCircuitBreaker(breaker.Config{
+		FailureThreshold: failureThreshold,
+		SuccessThreshold: 1,
+		CoolDown:         time.Minute,
+		OnStateChange: func(t breaker.Transition) {
+			log.Warn("main:fetch circuit breaker changed state: " + t.String())
+		},
+	})
 
 	fc := controller.FetchController{
-		Targets:         []string{consts.Apple, consts.Amazon},
-		StartDate:       start,
-		EndDate:         end,
-		StartOfDay:      start,
-		EndOfDay:        endOfDay,
-		PartitionValue:  time.Minute,
+		Targets:         fetchJob.Tickers,
+		StartDate:       fetchJob.StartDate,
+		EndDate:         fetchJob.EndDate,
+		Calendar:        marketCalendar,
+		PartitionValue:  fetchJob.Partition,
+		Concurrency:     fetchJob.Concurrency,
 		DatabaseService: service.NewDatabaseService(db),
+		Watermarks:      watermarks,
 		Logger:          log,
 		Unmarshaler:     json.Unmarshal,
-		FetchService: fetch.FetchService{
+		FetchService: fetch.NewCircuitBreakerFetchService(&fetch.FetchService{
 			Url: envVars.GetString("POLYGON_BASE_URL"),
 			RestClient: &rest.Client{
 				BaseHeaders: &models.Headers{"Authorization": models.HeaderValue{"Bearer " + envVars.GetString("POLYGON_API_KEY")}},
 				HttpClient:  &http.Client{Timeout: 30 * time.Second},
 				BodyReader:  io.ReadAll,
-				GetRequest:  http.NewRequest,
+				GetRequest:  http.NewRequestWithContext,
+				Limiter:     rateLimiter,
+				RetryPolicy: &retryPolicy,
+				Logger:      log,
 			},
 			PathJoiner: utils.JoinUrl,
-		},
+		}, circuitBreaker),
 	}
 
-	errs := fc.RunFetch(controller.FetchParams{TimespanMultiplier: 1, Limit: 100, Timespan: model.PolygonAggregateTimespanMinute})
+	if fetchJob.Provider == provider.ProviderNameCsv {
+		fc.Provider = provider.NewCsvProvider(os.DirFS(fetchJob.CsvDir), marketCalendar.Location())
+	}
+
+	if fetchJob.Validate {
+		fc.Validator = quality.NewValidator(fetchJob.Quality, marketCalendar)
+		fc.Quarantine = service.NewQuarantineService(database.NewDatabase[dbModels.DbQuarantinedDataPoint](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap), time.Now)
+	}
+
+	report, errs := fc.RunFetch(ctx, fetchParams)
 	if errs != nil {
 		for _, e := range errs {
 			log.Error("main:fetching datapoint got error: " + e.Error())
 		}
 	}
 
+	log.Info("main:fetch " + report.String())
+	log.InfoFmt("main:fetch circuit breaker finished %s after %d transitions", circuitBreaker.State(), len(circuitBreaker.Transitions()))
+
+	log.InfoFmt("main:made %d requests against today's budget", rateLimiter.Used())
 	log.Info("main:all done!!!")
 }
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/greenac/chaching/internal/calendar"
+	"github.com/greenac/chaching/internal/consts"
+	"github.com/greenac/chaching/internal/controller"
+	"github.com/greenac/chaching/internal/database/helpers"
+	dbModels "github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	"github.com/greenac/chaching/internal/env"
+	"github.com/greenac/chaching/internal/job"
+	"github.com/greenac/chaching/internal/service/database"
+	"github.com/greenac/chaching/internal/service/logger"
+	"github.com/segmentio/kafka-go"
+	"github.com/spf13/viper"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	log := logger.NewLogger(logger.LogLevelForLogLevelName(os.Getenv("LogLevel")), os.Getenv("GO_ENV") != string(env.GoEnvLocal))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	envVars, err := env.NewEnv(".env", viper.New())
+	if err != nil {
+		log.Error("main:failed to read env file with error: " + err.Error())
+		panic(err)
+	}
+
+	marketCalendar, ge := calendar.CalendarFromFile(envVars.GetString("MARKET_CALENDAR_FILE"))
+	if ge != nil {
+		log.Error("main:failed to load market calendar with error: " + ge.Error())
+		panic(ge)
+	}
+
+	// the fetch flags describe the windows to publish, which are sized for the job's timespan, multiplier and limit
+	spec, err := job.ParseFetchFlags(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		log.Error("main:failed to parse flags with error: " + err.Error())
+		os.Exit(2)
+	}
+
+	fetchJob, ge := spec.Resolve(marketCalendar.Location(), time.Now())
+	if ge != nil {
+		log.Error("main:invalid fetch job with error: " + ge.Error())
+		os.Exit(2)
+	}
+
+	if fetchJob.Watchlist != "" {
+		config := helpers.GetDynamoConfig(helpers.GetDynamoConfigInput{
+			MainTable:  envVars.GetString("DYNAMO_MAIN_TABLE_NAME"),
+			Env:        env.GoEnv(envVars.GetString("GO_ENV")),
+			AwsRegion:  envVars.GetString("AWS_REGION"),
+			DynamoUrl:  envVars.GetString("DYNAMO_URL"),
+			AwsProfile: os.Getenv("AWS_PROFILE"),
+		})
+
+		client, ge := helpers.DynamoClient(ctx, config)
+		if ge != nil {
+			log.Error("main:failed to create dynamo client with error: " + ge.Error())
+			panic(ge)
+		}
+
+		watchlists := service.NewWatchlistService(database.NewDatabase[dbModels.DbWatchlist](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap), nil, time.Now)
+		fetchJob.Tickers, ge = service.ResolveTargets(ctx, watchlists, nil, fetchJob.Watchlist)
+		if ge != nil {
+			log.Error("main:failed to read watchlist with error: " + ge.Error())
+			os.Exit(2)
+		}
+	}
+
+	log.Info("main:publishing fetch job " + fetchJob.String())
+
+	fetchParams := controller.FetchParams{TimespanMultiplier: fetchJob.Multiplier, Limit: fetchJob.Limit, Timespan: fetchJob.Timespan, Adjusted: fetchJob.Adjusted}
+	fp := controller.FetchProducer{Calendar: marketCalendar, Params: fetchParams, PartitionValue: fetchJob.Partition, Logger: log}
+
+	if fetchJob.DryRun {
+		fc := controller.FetchController{Calendar: marketCalendar, StartDate: fetchJob.StartDate, EndDate: fetchJob.EndDate, PartitionValue: fetchJob.Partition}
+		plan := fc.FetchPlan(fetchParams)
+		log.InfoFmt("main:dry run would publish %d windows for each of %d tickers", len(plan.Windows), len(fetchJob.Tickers))
+		fmt.Println(plan.String())
+		return
+	}
+
+	writer := &kafka.Writer{
+		Addr:     kafka.TCP(strings.Split(envVars.GetString("KAFKA_BROKERS"), ",")...),
+		Topic:    consts.TopicNameFetch.String(),
+		Balancer: &kafka.Hash{},
+	}
+	defer func() {
+		if err := writer.Close(); err != nil {
+			log.Error("main:failed to close kafka writer with error: " + err.Error())
+		}
+	}()
+
+	fp.Producer = writer
+
+	published, ge := fp.Publish(ctx, fetchJob.Tickers, fetchJob.StartDate, fetchJob.EndDate)
+	if ge != nil {
+		log.Error("main:publishing fetch messages failed with error: " + ge.Error())
+	}
+
+	log.InfoFmt("main:published %d fetch messages to %s", published, consts.TopicNameFetch)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/greenac/chaching/internal/calendar"
+	"github.com/greenac/chaching/internal/controller"
+	"github.com/greenac/chaching/internal/database/helpers"
+	dbModels "github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	"github.com/greenac/chaching/internal/env"
+	"github.com/greenac/chaching/internal/job"
+	rest "github.com/greenac/chaching/internal/rest/client"
+	"github.com/greenac/chaching/internal/rest/limiter"
+	"github.com/greenac/chaching/internal/rest/models"
+	"github.com/greenac/chaching/internal/service/database"
+	"github.com/greenac/chaching/internal/service/fetch"
+	"github.com/greenac/chaching/internal/service/gaps"
+	"github.com/greenac/chaching/internal/service/logger"
+	"github.com/greenac/chaching/internal/utils"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	// the report is written to stdout, so logs go to stderr
+	var zeroLogger zerolog.Logger
+	if os.Getenv("GO_ENV") == string(env.GoEnvLocal) {
+		zeroLogger = zerolog.New(os.Stderr).With().Logger().Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	} else {
+		zeroLogger = zerolog.New(os.Stderr).With().Logger()
+	}
+
+	log := logger.NewZeroLogWrapper(zeroLogger, logger.LogLevelForLogLevelName(os.Getenv("LOG_LEVEL")))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	envVars, err := env.NewEnv(".env", viper.New())
+	if err != nil {
+		log.Error("main:failed to read env file with error: " + err.Error())
+		panic(err)
+	}
+
+	marketCalendar, ge := calendar.CalendarFromFile(envVars.GetString("MARKET_CALENDAR_FILE"))
+	if ge != nil {
+		log.Error("main:failed to load market calendar with error: " + ge.Error())
+		panic(ge)
+	}
+
+	spec, err := job.ParseGapsFlags(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		log.Error("main:failed to parse flags with error: " + err.Error())
+		os.Exit(2)
+	}
+
+	gapsJob, ge := spec.Resolve(marketCalendar.Location(), time.Now())
+	if ge != nil {
+		log.Error("main:invalid gaps job with error: " + ge.Error())
+		os.Exit(2)
+	}
+
+	config := helpers.GetDynamoConfig(helpers.GetDynamoConfigInput{
+		MainTable:  envVars.GetString("DYNAMO_MAIN_TABLE_NAME"),
+		Env:        env.GoEnv(envVars.GetString("GO_ENV")),
+		AwsRegion:  envVars.GetString("AWS_REGION"),
+		DynamoUrl:  envVars.GetString("DYNAMO_URL"),
+		AwsProfile: os.Getenv("AWS_PROFILE"),
+	})
+
+	client, ge := helpers.DynamoClient(ctx, config)
+	if ge != nil {
+		log.Error("main:failed to create dynamo client with error: " + ge.Error())
+		panic(ge)
+	}
+
+	db := database.NewDatabase[dbModels.DbDataPoint](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap)
+	dbs := service.NewTimespanDatabaseService(db, gapsJob.Timespan, gapsJob.Multiplier, false)
+
+	retryPolicy := models.DefaultRetryPolicy()
+	if envVars.IsSet("POLYGON_MAX_ATTEMPTS") {
+		retryPolicy.MaxAttempts = envVars.GetInt("POLYGON_MAX_ATTEMPTS")
+	}
+
+	gc := controller.GapsController{
+		Calendar:        marketCalendar,
+		DatabaseService: dbs,
+		Watermarks:      service.NewWatermarkService(database.NewDatabase[dbModels.DbWatermark](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap), time.Now),
+		Logger:          log,
+		FetchController: &controller.FetchController{
+			Calendar:        marketCalendar,
+			Concurrency:     gapsJob.Concurrency,
+			DatabaseService: dbs,
+			Logger:          log,
+			Unmarshaler:     json.Unmarshal,
+			FetchService: &fetch.FetchService{
+				Url: envVars.GetString("POLYGON_BASE_URL"),
+				RestClient: &rest.Client{
+					BaseHeaders: &models.Headers{"Authorization": models.HeaderValue{"Bearer " + envVars.GetString("POLYGON_API_KEY")}},
+					HttpClient:  &http.Client{Timeout: 30 * time.Second},
+					BodyReader:  io.ReadAll,
+					GetRequest:  http.NewRequestWithContext,
+					Limiter: limiter.NewLimiter(limiter.Config{
+						Rate:           limiter.PerMinute(envVars.GetInt("POLYGON_REQUESTS_PER_MINUTE")),
+						Burst:          envVars.GetInt("POLYGON_REQUEST_BURST"),
+						DailyBudget:    envVars.GetInt("POLYGON_DAILY_REQUEST_BUDGET"),
+						BudgetBehavior: limiter.BudgetBehavior(envVars.GetString("POLYGON_BUDGET_BEHAVIOR")),
+					}),
+					RetryPolicy: &retryPolicy,
+					Logger:      log,
+				},
+				PathJoiner: utils.JoinUrl,
+			},
+		},
+	}
+
+	fetchParams := controller.FetchParams{TimespanMultiplier: gapsJob.Multiplier, Limit: gapsJob.Limit, Timespan: gapsJob.Timespan}
+	days, errs := gc.FindGaps(ctx, fetchParams, gapsJob.Tickers, gapsJob.StartDate, gapsJob.EndDate, gapsJob.Step)
+	for _, e := range errs {
+		log.Error("main:finding gaps got error: " + e.Error())
+	}
+
+	if gapsJob.Format == job.GapsOutputFormatJson {
+		err = gaps.WriteJSON(os.Stdout, days)
+	} else {
+		err = gaps.WriteTable(os.Stdout, days)
+	}
+
+	if err != nil {
+		log.Error("main:failed to write gaps with error: " + err.Error())
+		panic(err)
+	}
+
+	missing := 0
+	for _, dg := range days {
+		missing += dg.Missing()
+	}
+
+	log.InfoFmt("main:found %d missing bars on %d ticker days", missing, len(days))
+
+	if !gapsJob.Backfill || len(days) == 0 {
+		return
+	}
+
+	report, errs := gc.Backfill(ctx, fetchParams, days)
+	for _, e := range errs {
+		log.Error("main:backfilling gaps got error: " + e.Error())
+	}
+
+	log.Info("main:backfill " + report.String())
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/greenac/chaching/internal/calendar"
+	"github.com/greenac/chaching/internal/consts"
+	"github.com/greenac/chaching/internal/database/helpers"
+	dbModels "github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	"github.com/greenac/chaching/internal/env"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/database"
+	"github.com/greenac/chaching/internal/service/logger"
+	"github.com/greenac/chaching/internal/service/stream"
+	"github.com/segmentio/kafka-go"
+	"github.com/spf13/viper"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const defaultStreamUrl = "wss://socket.polygon.io/stocks"
+
+func main() {
+	log := logger.NewLogger(logger.LogLevelForLogLevelName(os.Getenv("LogLevel")), os.Getenv("GO_ENV") != string(env.GoEnvLocal))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fs := flag.NewFlagSet("stream", flag.ContinueOnError)
+	tickers := fs.String("tickers", "", "comma separated tickers to stream, for example: AAPL,AMZN")
+	watchlist := fs.String("watchlist", "", "name of a stored watchlist to stream instead of tickers")
+	channels := fs.String("channels", string(model.PolygonStreamEventMinuteAggregate), "comma separated channels, any of: AM (minute bars), A (second bars), T (trades)")
+	sinks := fs.String("sinks", "dynamo", "comma separated sinks events are handed to, any of: dynamo, kafka")
+
+	err := fs.Parse(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		log.Error("main:failed to parse flags with error: " + err.Error())
+		os.Exit(2)
+	}
+
+	var streamChannels []model.PolygonStreamEvent
+	for _, c := range strings.Split(*channels, ",") {
+		channel, err := model.ParsePolygonStreamChannel(c)
+		if err != nil {
+			log.Error("main:" + err.Error())
+			os.Exit(2)
+		}
+
+		streamChannels = append(streamChannels, channel)
+	}
+
+	envVars, err := env.NewEnv(".env", viper.New())
+	if err != nil {
+		log.Error("main:failed to read env file with error: " + err.Error())
+		panic(err)
+	}
+
+	marketCalendar, ge := calendar.CalendarFromFile(envVars.GetString("MARKET_CALENDAR_FILE"))
+	if ge != nil {
+		log.Error("main:failed to load market calendar with error: " + ge.Error())
+		panic(ge)
+	}
+
+	config := helpers.GetDynamoConfig(helpers.GetDynamoConfigInput{
+		MainTable:  envVars.GetString("DYNAMO_MAIN_TABLE_NAME"),
+		Env:        env.GoEnv(envVars.GetString("GO_ENV")),
+		AwsRegion:  envVars.GetString("AWS_REGION"),
+		DynamoUrl:  envVars.GetString("DYNAMO_URL"),
+		AwsProfile: os.Getenv("AWS_PROFILE"),
+	})
+
+	client, ge := helpers.DynamoClient(ctx, config)
+	if ge != nil {
+		log.Error("main:failed to create dynamo client with error: " + ge.Error())
+		panic(ge)
+	}
+
+	var targets []string
+	if *tickers != "" {
+		targets = strings.Split(*tickers, ",")
+	}
+
+	watchlists := service.NewWatchlistService(database.NewDatabase[dbModels.DbWatchlist](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap), nil, time.Now)
+	targets, ge = service.ResolveTargets(ctx, watchlists, targets, *watchlist)
+	if ge != nil {
+		log.Error("main:failed to resolve tickers with error: " + ge.Error())
+		os.Exit(2)
+	}
+
+	if len(targets) == 0 {
+		log.Error("main:at least one ticker or a watchlist is required")
+		os.Exit(2)
+	}
+
+	var sink stream.Sinks
+	for _, name := range strings.Split(*sinks, ",") {
+		switch strings.TrimSpace(name) {
+		case "dynamo":
+			sink = append(sink, &stream.DatabaseSink{
+				DatabaseService: service.NewDatabaseService(database.NewDatabase[dbModels.DbDataPoint](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap)),
+				TickService: service.NewTickService(
+					database.NewDatabase[dbModels.DbTrade](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap),
+					database.NewDatabase[dbModels.DbQuote](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap),
+				),
+			})
+		case "kafka":
+			// the sink sets the topic of each message, so the writer has none of its own
+			writer := &kafka.Writer{
+				Addr:     kafka.TCP(strings.Split(envVars.GetString("KAFKA_BROKERS"), ",")...),
+				Balancer: &kafka.Hash{},
+			}
+			defer func() {
+				if err := writer.Close(); err != nil {
+					log.Error("main:failed to close kafka writer with error: " + err.Error())
+				}
+			}()
+
+			sink = append(sink, &stream.KafkaSink{Producer: writer, BarsTopic: consts.TopicNameBars, TradesTopic: consts.TopicNameTrades})
+		default:
+			log.Error("main:unknown sink: " + name + ", expected dynamo or kafka")
+			os.Exit(2)
+		}
+	}
+
+	url := defaultStreamUrl
+	if envVars.IsSet("POLYGON_WS_URL") {
+		url = envVars.GetString("POLYGON_WS_URL")
+	}
+
+	sc := stream.NewClient(stream.Config{
+		Url:               url,
+		ApiKey:            envVars.GetString("POLYGON_API_KEY"),
+		Location:          marketCalendar.Location(),
+		ReconnectDelay:    time.Second,
+		MaxReconnectDelay: time.Minute,
+	}, sink, log)
+
+	for _, channel := range streamChannels {
+		if ge := sc.Subscribe(channel, targets...); ge != nil {
+			log.Error("main:failed to subscribe with error: " + ge.Error())
+			panic(ge)
+		}
+	}
+
+	log.InfoFmt("main:streaming %s for %d tickers", *channels, len(targets))
+
+	if ge := sc.Run(ctx); ge != nil {
+		log.Error("main:stream stopped with error: " + ge.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/greenac/chaching/internal/calendar"
+	"github.com/greenac/chaching/internal/controller"
+	"github.com/greenac/chaching/internal/database/helpers"
+	dbModels "github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	"github.com/greenac/chaching/internal/env"
+	"github.com/greenac/chaching/internal/job"
+	rest "github.com/greenac/chaching/internal/rest/client"
+	"github.com/greenac/chaching/internal/rest/limiter"
+	"github.com/greenac/chaching/internal/rest/models"
+	"github.com/greenac/chaching/internal/service/database"
+	"github.com/greenac/chaching/internal/service/fetch"
+	"github.com/greenac/chaching/internal/service/logger"
+	"github.com/greenac/chaching/internal/utils"
+	"github.com/spf13/viper"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const defaultV3Url = "https://api.polygon.io/v3"
+
+func main() {
+	log := logger.NewLogger(logger.LogLevelForLogLevelName(os.Getenv("LOG_LEVEL")), os.Getenv("GO_ENV") != string(env.GoEnvLocal))
+
+	log.Info("Running ticks...")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	envVars, err := env.NewEnv(".env", viper.New())
+	if err != nil {
+		log.Error("main:failed to read env file with error: " + err.Error())
+		panic(err)
+	}
+
+	marketCalendar, ge := calendar.CalendarFromFile(envVars.GetString("MARKET_CALENDAR_FILE"))
+	if ge != nil {
+		log.Error("main:failed to load market calendar with error: " + ge.Error())
+		panic(ge)
+	}
+
+	spec, err := job.ParseTicksFlags(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		log.Error("main:failed to parse flags with error: " + err.Error())
+		os.Exit(2)
+	}
+
+	ticksJob, ge := spec.Resolve(marketCalendar.Location(), time.Now())
+	if ge != nil {
+		log.Error("main:invalid ticks job with error: " + ge.Error())
+		os.Exit(2)
+	}
+
+	config := helpers.GetDynamoConfig(helpers.GetDynamoConfigInput{
+		MainTable:  envVars.GetString("DYNAMO_MAIN_TABLE_NAME"),
+		Env:        env.GoEnv(envVars.GetString("GO_ENV")),
+		AwsRegion:  envVars.GetString("AWS_REGION"),
+		DynamoUrl:  envVars.GetString("DYNAMO_URL"),
+		AwsProfile: os.Getenv("AWS_PROFILE"),
+	})
+
+	client, ge := helpers.DynamoClient(ctx, config)
+	if ge != nil {
+		log.Error("main:failed to create dynamo client with error: " + ge.Error())
+		panic(ge)
+	}
+
+	v3Url := defaultV3Url
+	if envVars.IsSet("POLYGON_V3_URL") {
+		v3Url = envVars.GetString("POLYGON_V3_URL")
+	}
+
+	retryPolicy := models.DefaultRetryPolicy()
+	if envVars.IsSet("POLYGON_MAX_ATTEMPTS") {
+		retryPolicy.MaxAttempts = envVars.GetInt("POLYGON_MAX_ATTEMPTS")
+	}
+
+	tc := controller.TicksController{
+		Calendar: marketCalendar,
+		TickService: service.NewTickService(
+			database.NewDatabase[dbModels.DbTrade](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap),
+			database.NewDatabase[dbModels.DbQuote](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap),
+		),
+		Logger:      log,
+		Unmarshaler: json.Unmarshal,
+		FetchService: &fetch.FetchService{
+			Url: v3Url,
+			RestClient: &rest.Client{
+				BaseHeaders: &models.Headers{"Authorization": models.HeaderValue{"Bearer " + envVars.GetString("POLYGON_API_KEY")}},
+				HttpClient:  &http.Client{Timeout: 30 * time.Second},
+				BodyReader:  io.ReadAll,
+				GetRequest:  http.NewRequestWithContext,
+				Limiter: limiter.NewLimiter(limiter.Config{
+					Rate:           limiter.PerMinute(envVars.GetInt("POLYGON_REQUESTS_PER_MINUTE")),
+					Burst:          envVars.GetInt("POLYGON_REQUEST_BURST"),
+					DailyBudget:    envVars.GetInt("POLYGON_DAILY_REQUEST_BUDGET"),
+					BudgetBehavior: limiter.BudgetBehavior(envVars.GetString("POLYGON_BUDGET_BEHAVIOR")),
+				}),
+				RetryPolicy: &retryPolicy,
+				Logger:      log,
+			},
+			PathJoiner: utils.JoinUrl,
+		},
+	}
+
+	report, errs := tc.SyncTicks(ctx, ticksJob.Tickers, ticksJob.StartDate, ticksJob.EndDate, controller.TickSyncParams{Trades: ticksJob.Trades, Quotes: ticksJob.Quotes, Limit: ticksJob.Limit})
+	for _, e := range errs {
+		log.Error("main:syncing ticks got error: " + e.Error())
+	}
+
+	log.Info("main:ticks " + report.String())
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/greenac/chaching/internal/database/helpers"
+	dbModels "github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	"github.com/greenac/chaching/internal/env"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/job"
+	"github.com/greenac/chaching/internal/service/database"
+	"github.com/greenac/chaching/internal/service/logger"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	// watchlists are printed to stdout, so logs go to stderr
+	var zeroLogger zerolog.Logger
+	if os.Getenv("GO_ENV") == string(env.GoEnvLocal) {
+		zeroLogger = zerolog.New(os.Stderr).With().Logger().Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	} else {
+		zeroLogger = zerolog.New(os.Stderr).With().Logger()
+	}
+
+	log := logger.NewZeroLogWrapper(zeroLogger, logger.LogLevelForLogLevelName(os.Getenv("LOG_LEVEL")))
+
+	cmd, err := job.ParseWatchlistCommand(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		log.Error("main:failed to parse watchlist command with error: " + err.Error())
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	envVars, err := env.NewEnv(".env", viper.New())
+	if err != nil {
+		log.Error("main:failed to read env file with error: " + err.Error())
+		panic(err)
+	}
+
+	config := helpers.GetDynamoConfig(helpers.GetDynamoConfigInput{
+		MainTable:  envVars.GetString("DYNAMO_MAIN_TABLE_NAME"),
+		Env:        env.GoEnv(envVars.GetString("GO_ENV")),
+		AwsRegion:  envVars.GetString("AWS_REGION"),
+		DynamoUrl:  envVars.GetString("DYNAMO_URL"),
+		AwsProfile: os.Getenv("AWS_PROFILE"),
+	})
+
+	client, ge := helpers.DynamoClient(ctx, config)
+	if ge != nil {
+		log.Error("main:failed to create dynamo client with error: " + ge.Error())
+		panic(ge)
+	}
+
+	// tickers are checked against the companies synced by cmd/companies unless -no-validate is given
+	var companies service.ICompanyService
+	if !cmd.NoValidate {
+		companies = service.NewCompanyService(
+			database.NewDatabase[dbModels.DbCompany](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap),
+			config.Index1,
+			time.Now,
+		)
+	}
+
+	watchlists := service.NewWatchlistService(
+		database.NewDatabase[dbModels.DbWatchlist](client, 25, envVars.GetString("DYNAMO_MAIN_TABLE_NAME"), attributevalue.MarshalMap, attributevalue.UnmarshalMap),
+		companies,
+		time.Now,
+	)
+
+	var wl dbModels.Watchlist
+	switch cmd.Action {
+	case job.WatchlistActionCreate:
+		wl, ge = watchlists.CreateWatchlist(ctx, cmd.Name, cmd.Tickers)
+	case job.WatchlistActionAdd:
+		wl, ge = watchlists.AddTickers(ctx, cmd.Name, cmd.Tickers)
+	case job.WatchlistActionRemove:
+		wl, ge = watchlists.RemoveTickers(ctx, cmd.Name, cmd.Tickers)
+	case job.WatchlistActionShow:
+		var ok bool
+		wl, ok, ge = watchlists.GetWatchlist(ctx, cmd.Name)
+		if ge == nil && !ok {
+			ge = &genErr.GenError{Messages: []string{"main:watchlist: " + cmd.Name + " does not exist"}}
+		}
+	case job.WatchlistActionList:
+		wls, ge := watchlists.ListWatchlists(ctx)
+		if ge != nil {
+			log.Error("main:failed to list watchlists with error: " + ge.Error())
+			os.Exit(1)
+		}
+
+		for _, wl := range wls {
+			printWatchlist(wl)
+		}
+
+		return
+	}
+
+	if ge != nil {
+		log.Error("main:watchlist " + string(cmd.Action) + " failed with error: " + ge.Error())
+		os.Exit(1)
+	}
+
+	printWatchlist(wl)
+}
+
+func printWatchlist(wl dbModels.Watchlist) {
+	fmt.Printf("%s\t%s\n", wl.Name, strings.Join(wl.Tickers, ","))
+}
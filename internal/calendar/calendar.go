@@ -0,0 +1,212 @@
+package calendar
+
+import (
+	_ "embed"
+	"encoding/json"
+	genErr "github.com/greenac/chaching/internal/error"
+	"os"
+	"sync"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+const clockLayout = "15:04"
+
+//go:embed nyse.json
+var nyseData []byte
+
+var nyseOnce sync.Once
+var nyse *Calendar
+
+type Session struct {
+	Date       time.Time // midnight of the trading day in the exchange's time zone
+	Open       time.Time
+	Close      time.Time
+	EarlyClose bool
+}
+
+func (s Session) Contains(t time.Time) bool {
+	return !t.Before(s.Open) && t.Before(s.Close)
+}
+
+func (s Session) Duration() time.Duration {
+	return s.Close.Sub(s.Open)
+}
+
+type ICalendar interface {
+	IsTradingDay(day time.Time) bool
+	Session(day time.Time) (Session, bool)
+	Sessions(start time.Time, end time.Time) []Session
+	Location() *time.Location
+}
+
+type CalendarDay struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// CalendarData is the format of a calendar data file. Dates are `YYYY-MM-DD` and times are `HH:MM`
+// in the calendar's time zone. Days in years the file does not list holidays for only skip weekends
+type CalendarData struct {
+	TimeZone    string        `json:"timeZone"`
+	Open        string        `json:"open"`
+	Close       string        `json:"close"`
+	EarlyClose  string        `json:"earlyClose"`
+	Holidays    []CalendarDay `json:"holidays"`
+	EarlyCloses []CalendarDay `json:"earlyCloses"`
+}
+
+// NewNyseCalendar returns the NYSE calendar built into the binary
+func NewNyseCalendar() *Calendar {
+	nyseOnce.Do(func() {
+		var data CalendarData
+		err := json.Unmarshal(nyseData, &data)
+		if err != nil {
+			panic("calendar:failed to unmarshal built in nyse calendar with error: " + err.Error())
+		}
+
+		cal, ge := NewCalendar(data)
+		if ge != nil {
+			panic(ge.Error())
+		}
+
+		nyse = cal
+	})
+
+	return nyse
+}
+
+// CalendarFromFile loads the calendar at path, falling back to the built in NYSE calendar when path is empty
+func CalendarFromFile(path string) (*Calendar, genErr.IGenError) {
+	if path == "" {
+		return NewNyseCalendar(), nil
+	}
+
+	return LoadCalendar(path)
+}
+
+// LoadCalendar reads a calendar data file, for example to add holidays for years the built in calendar does not cover
+func LoadCalendar(path string) (*Calendar, genErr.IGenError) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &genErr.GenError{Messages: []string{"LoadCalendar:failed to read calendar file: " + path + " with error: " + err.Error()}}
+	}
+
+	var data CalendarData
+	err = json.Unmarshal(b, &data)
+	if err != nil {
+		return nil, &genErr.GenError{Messages: []string{"LoadCalendar:failed to unmarshal calendar file: " + path + " with error: " + err.Error()}}
+	}
+
+	return NewCalendar(data)
+}
+
+func NewCalendar(data CalendarData) (*Calendar, genErr.IGenError) {
+	loc, err := time.LoadLocation(data.TimeZone)
+	if err != nil {
+		return nil, &genErr.GenError{Messages: []string{"NewCalendar:failed to load time zone: " + data.TimeZone + " with error: " + err.Error()}}
+	}
+
+	cal := Calendar{
+		location:    loc,
+		holidays:    map[string]string{},
+		earlyCloses: map[string]string{},
+	}
+
+	for _, c := range []struct {
+		value string
+		dest  *time.Duration
+	}{{data.Open, &cal.open}, {data.Close, &cal.close}, {data.EarlyClose, &cal.earlyClose}} {
+		t, err := time.Parse(clockLayout, c.value)
+		if err != nil {
+			return nil, &genErr.GenError{Messages: []string{"NewCalendar:failed to parse session time: " + c.value + " with error: " + err.Error()}}
+		}
+		*c.dest = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+
+	for _, d := range data.Holidays {
+		if _, err := time.Parse(dateLayout, d.Date); err != nil {
+			return nil, &genErr.GenError{Messages: []string{"NewCalendar:failed to parse holiday: " + d.Date + " with error: " + err.Error()}}
+		}
+		cal.holidays[d.Date] = d.Name
+	}
+
+	for _, d := range data.EarlyCloses {
+		if _, err := time.Parse(dateLayout, d.Date); err != nil {
+			return nil, &genErr.GenError{Messages: []string{"NewCalendar:failed to parse early close: " + d.Date + " with error: " + err.Error()}}
+		}
+		cal.earlyCloses[d.Date] = d.Name
+	}
+
+	return &cal, nil
+}
+
+var _ ICalendar = (*Calendar)(nil)
+
+type Calendar struct {
+	location    *time.Location
+	open        time.Duration
+	close       time.Duration
+	earlyClose  time.Duration
+	holidays    map[string]string
+	earlyCloses map[string]string
+}
+
+func (c *Calendar) Location() *time.Location {
+	return c.location
+}
+
+func (c *Calendar) IsTradingDay(day time.Time) bool {
+	_, ok := c.Session(day)
+	return ok
+}
+
+// Session returns the trading session on the exchange's calendar day that contains the given time
+func (c *Calendar) Session(day time.Time) (Session, bool) {
+	local := day.In(c.location)
+	date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.location)
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return Session{}, false
+	}
+
+	key := date.Format(dateLayout)
+	if _, ok := c.holidays[key]; ok {
+		return Session{}, false
+	}
+
+	_, early := c.earlyCloses[key]
+	closeAt := c.close
+	if early {
+		closeAt = c.earlyClose
+	}
+
+	return Session{Date: date, Open: c.atTime(date, c.open), Close: c.atTime(date, closeAt), EarlyClose: early}, true
+}
+
+// Sessions returns the sessions for every trading day from start's day through end's day, inclusive
+func (c *Calendar) Sessions(start time.Time, end time.Time) []Session {
+	var sessions []Session
+	s := start.In(c.location)
+	e := end.In(c.location)
+	day := time.Date(s.Year(), s.Month(), s.Day(), 0, 0, 0, 0, c.location)
+	last := time.Date(e.Year(), e.Month(), e.Day(), 0, 0, 0, 0, c.location)
+	for !day.After(last) {
+		if session, ok := c.Session(day); ok {
+			sessions = append(sessions, session)
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return sessions
+}
+
+// HolidayName returns the name of the holiday on the given day, if there is one
+func (c *Calendar) HolidayName(day time.Time) (string, bool) {
+	name, ok := c.holidays[day.In(c.location).Format(dateLayout)]
+	return name, ok
+}
+
+// atTime builds the wall clock time on a date so sessions stay at 09:30 local across daylight saving changes
+func (c *Calendar) atTime(date time.Time, offset time.Duration) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), int(offset/time.Hour), int((offset%time.Hour)/time.Minute), 0, 0, c.location)
+}
@@ -0,0 +1,126 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCalendar_Session(t *testing.T) {
+	Convey("TestCalendar_Session", t, func() {
+		cal := NewNyseCalendar()
+		ny := cal.Location()
+
+		Convey("TestCalendar_Session should open at 09:30 and close at 16:00 new york time", func() {
+			s, ok := cal.Session(time.Date(2023, 3, 1, 12, 0, 0, 0, ny))
+			So(ok, ShouldBeTrue)
+			So(s.Date, ShouldEqual, time.Date(2023, 3, 1, 0, 0, 0, 0, ny))
+			So(s.Open, ShouldEqual, time.Date(2023, 3, 1, 9, 30, 0, 0, ny))
+			So(s.Close, ShouldEqual, time.Date(2023, 3, 1, 16, 0, 0, 0, ny))
+			So(s.EarlyClose, ShouldBeFalse)
+			So(s.Duration(), ShouldEqual, 390*time.Minute)
+		})
+
+		Convey("TestCalendar_Session should follow daylight saving time", func() {
+			winter, _ := cal.Session(time.Date(2023, 3, 10, 0, 0, 0, 0, ny))
+			summer, _ := cal.Session(time.Date(2023, 3, 13, 0, 0, 0, 0, ny))
+			So(winter.Open.UTC(), ShouldEqual, time.Date(2023, 3, 10, 14, 30, 0, 0, time.UTC))
+			So(summer.Open.UTC(), ShouldEqual, time.Date(2023, 3, 13, 13, 30, 0, 0, time.UTC))
+		})
+
+		Convey("TestCalendar_Session should use the exchange's day for times in other zones", func() {
+			s, ok := cal.Session(time.Date(2023, 3, 2, 2, 0, 0, 0, time.UTC))
+			So(ok, ShouldBeTrue)
+			So(s.Date, ShouldEqual, time.Date(2023, 3, 1, 0, 0, 0, 0, ny))
+		})
+
+		Convey("TestCalendar_Session should close early on half days", func() {
+			s, ok := cal.Session(time.Date(2024, 12, 24, 0, 0, 0, 0, ny))
+			So(ok, ShouldBeTrue)
+			So(s.EarlyClose, ShouldBeTrue)
+			So(s.Close, ShouldEqual, time.Date(2024, 12, 24, 13, 0, 0, 0, ny))
+			So(s.Contains(time.Date(2024, 12, 24, 12, 59, 0, 0, ny)), ShouldBeTrue)
+			So(s.Contains(time.Date(2024, 12, 24, 13, 0, 0, 0, ny)), ShouldBeFalse)
+		})
+
+		Convey("TestCalendar_Session should have no session on weekends and holidays", func() {
+			for _, d := range []time.Time{
+				time.Date(2023, 3, 4, 0, 0, 0, 0, ny),
+				time.Date(2023, 3, 5, 0, 0, 0, 0, ny),
+				time.Date(2022, 6, 20, 0, 0, 0, 0, ny),
+				time.Date(2023, 4, 7, 0, 0, 0, 0, ny),
+				time.Date(2025, 1, 9, 0, 0, 0, 0, ny),
+				time.Date(2026, 7, 3, 0, 0, 0, 0, ny),
+			} {
+				So(cal.IsTradingDay(d), ShouldBeFalse)
+			}
+
+			name, ok := cal.HolidayName(time.Date(2024, 11, 28, 0, 0, 0, 0, ny))
+			So(ok, ShouldBeTrue)
+			So(name, ShouldEqual, "Thanksgiving Day")
+		})
+	})
+}
+
+func TestCalendar_Sessions(t *testing.T) {
+	Convey("TestCalendar_Sessions should include every trading day in the range", t, func() {
+		cal := NewNyseCalendar()
+		ny := cal.Location()
+
+		sessions := cal.Sessions(time.Date(2023, 12, 22, 15, 0, 0, 0, ny), time.Date(2024, 1, 2, 9, 0, 0, 0, ny))
+		var dates []string
+		for _, s := range sessions {
+			dates = append(dates, s.Date.Format(dateLayout))
+		}
+
+		So(dates, ShouldResemble, []string{"2023-12-22", "2023-12-26", "2023-12-27", "2023-12-28", "2023-12-29", "2024-01-02"})
+		So(cal.Sessions(time.Date(2023, 12, 25, 0, 0, 0, 0, ny), time.Date(2023, 12, 25, 23, 0, 0, 0, ny)), ShouldBeEmpty)
+	})
+}
+
+func TestLoadCalendar(t *testing.T) {
+	Convey("TestLoadCalendar", t, func() {
+		dir := t.TempDir()
+
+		Convey("TestLoadCalendar should load a calendar file", func() {
+			path := filepath.Join(dir, "calendar.json")
+			data := `{"timeZone": "America/Chicago", "open": "08:30", "close": "15:00", "earlyClose": "12:00",
+				"holidays": [{"date": "2030-01-01", "name": "New Year's Day"}],
+				"earlyCloses": [{"date": "2030-12-24", "name": "Christmas Eve"}]}`
+			So(os.WriteFile(path, []byte(data), 0644), ShouldBeNil)
+
+			cal, err := LoadCalendar(path)
+			So(err, ShouldBeNil)
+			So(cal.IsTradingDay(time.Date(2030, 1, 1, 0, 0, 0, 0, cal.Location())), ShouldBeFalse)
+
+			s, ok := cal.Session(time.Date(2030, 12, 24, 0, 0, 0, 0, cal.Location()))
+			So(ok, ShouldBeTrue)
+			So(s.Open, ShouldEqual, time.Date(2030, 12, 24, 8, 30, 0, 0, cal.Location()))
+			So(s.Close, ShouldEqual, time.Date(2030, 12, 24, 12, 0, 0, 0, cal.Location()))
+		})
+
+		Convey("TestLoadCalendar should fail when the file is missing", func() {
+			_, err := LoadCalendar(filepath.Join(dir, "missing.json"))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("TestLoadCalendar should fail for an invalid date", func() {
+			_, err := NewCalendar(CalendarData{TimeZone: "America/New_York", Open: "09:30", Close: "16:00", EarlyClose: "13:00", Holidays: []CalendarDay{{Date: "2030-13-01"}}})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("TestLoadCalendar should fail for an unknown time zone", func() {
+			_, err := NewCalendar(CalendarData{TimeZone: "Nowhere/Special", Open: "09:30", Close: "16:00", EarlyClose: "13:00"})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("TestLoadCalendar should use the built in calendar without a path", func() {
+			cal, err := CalendarFromFile("")
+			So(err, ShouldBeNil)
+			So(cal, ShouldEqual, NewNyseCalendar())
+		})
+	})
+}
@@ -9,12 +9,16 @@ func (t TopicName) String() string {
 const (
 	TopicNameFetch      TopicName = "chachingFetchWorkerMain"
 	TopicNameFetchRetry TopicName = "chachingFetchWorkerRetry"
+	TopicNameBars       TopicName = "chachingStreamBars"
+	TopicNameTrades     TopicName = "chachingStreamTrades"
 )
 
 func AllTopics() []TopicName {
 	return []TopicName{
 		TopicNameFetch,
 		TopicNameFetchRetry,
+		TopicNameBars,
+		TopicNameTrades,
 	}
 }
 
@@ -2,8 +2,8 @@ package controller
 
 import (
 	"context"
+	"github.com/greenac/chaching/internal/calendar"
 	"github.com/greenac/chaching/internal/database/service"
-	error2 "github.com/greenac/chaching/internal/error"
 	"github.com/greenac/chaching/internal/service/analysis"
 	"github.com/greenac/chaching/internal/service/logger"
 	"time"
@@ -17,10 +17,11 @@ type SaleAmount struct {
 	SellPoint float64
 }
 
-func NewAnalysisController(l logger.ILogger, as analysis.IAnalysisService, dbs service.IDatabaseService) *AnalysisController {
+func NewAnalysisController(l logger.ILogger, as analysis.IAnalysisService, dbs service.IDatabaseService, cal calendar.ICalendar) *AnalysisController {
 	return &AnalysisController{
 		analysisService: as,
 		databaseService: dbs,
+		calendar:        cal,
 		logger:          l,
 	}
 }
@@ -28,6 +29,7 @@ func NewAnalysisController(l logger.ILogger, as analysis.IAnalysisService, dbs s
 type AnalysisController struct {
 	analysisService analysis.IAnalysisService
 	databaseService service.IDatabaseService
+	calendar        calendar.ICalendar
 	logger          logger.ILogger
 }
 
@@ -73,44 +75,38 @@ func (ctr *AnalysisController) BuySellInflectionPoint(company string, startDate
 	return maxSellPoint, nil
 }
 
+// InflectionPointsInRange finds the best sell point for each trading session between startDate and endDate
 func (ctr *AnalysisController) InflectionPointsInRange(company string, startDate time.Time, endDate time.Time) ([]float64, error) {
-	var genErr error2.IGenError
 	var inflections []float64
-	date := startDate
-	for endDate.After(date) {
-		saveSlope := true
-		nextDay := date.AddDate(0, 0, 1)
-		ip, err := ctr.BuySellInflectionPoint(company, date, nextDay)
+	for _, s := range ctr.calendar.Sessions(startDate, endDate) {
+		ip, err := ctr.BuySellInflectionPoint(company, s.Open, s.Close)
 		if err != nil {
 			if err.Error() != "no slope changes" {
 				ctr.logger.Error("AnalysisController->InflectionPointsInRange:failed to get inflection point with error: " + err.Error())
-				_ = genErr.AddMsg(err.Error())
 			}
-			saveSlope = false
+			continue
 		}
 
-		if saveSlope {
-			inflections = append(inflections, ip)
-		}
-
-		date = nextDay
+		inflections = append(inflections, ip)
 	}
 
 	return inflections, nil
 }
 
+// AmountsByDay calculates the amount made in each trading session between startDate and endDate, keyed by the session's date
 func (ctr *AnalysisController) AmountsByDay(company string, numOfStocks int, sellPoint float64, startDate time.Time, endDate time.Time) (map[time.Time]float64, error) {
 	amounts := map[time.Time]float64{}
-	date := startDate
-	for endDate.After(date) {
-		ed := time.Date(date.Year(), date.Month(), date.Day(), endDate.Hour(), endDate.Minute(), 0, 0, date.Location())
-		dps, err := ctr.databaseService.GetDataPointsInTimeRange(context.Background(), company, date, ed)
+	for _, s := range ctr.calendar.Sessions(startDate, endDate) {
+		dps, err := ctr.databaseService.GetDataPointsInTimeRange(context.Background(), company, s.Open, s.Close)
 		if err != nil {
 			ctr.logger.Error("AnalysisController->AmountsByDay:failed to retrieve data with error: " + err.Error())
 			return amounts, err
 		}
 
 		slopeChanges := ctr.analysisService.FindSlopeChanges(dps)
+		if len(slopeChanges) == 0 {
+			continue
+		}
 
 		normFactor := ctr.analysisService.CalcSlopeNormalizationFactor(slopeChanges)
 
@@ -120,12 +116,12 @@ func (ctr *AnalysisController) AmountsByDay(company string, numOfStocks int, sel
 		}
 
 		sales, gErr := ctr.analysisService.CalcSales(company, numOfStocks, slopeChanges, sellPoint)
-		if err != nil {
+		if gErr != nil {
 			ctr.logger.Error("AnalysisController->AmountsByDay:failed to calculate sales with error: " + gErr.Error())
 			return map[time.Time]float64{}, gErr
 		}
 
-		amounts[date] = ctr.analysisService.CalcAmount(slopeChanges[0].OpenPrice, sales)
+		amounts[s.Date] = ctr.analysisService.CalcAmount(slopeChanges[0].OpenPrice, sales)
 	}
 
 	return amounts, nil
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/fetch"
+	"github.com/greenac/chaching/internal/service/logger"
+	"strings"
+)
+
+const tickerLimit = 1000
+
+type CompanyQuery struct {
+	Market   string
+	Exchange string
+	Type     string
+	Inactive bool // list delisted tickers instead of active ones
+	Details  bool // fetch each ticker's details for its listing date, one extra request per ticker
+}
+
+// CompaniesController ingests ticker reference data from polygon. Its FetchService must point at the `/v3/reference` base url
+type CompaniesController struct {
+	FetchService fetch.IFetchService
+	Companies    service.ICompanyService
+	Logger       logger.ILogger
+	Unmarshaler  func(data []byte, v any) error
+}
+
+// SyncCompanies fetches the tickers matching the query and saves them as companies, returning the number saved.
+// Tickers whose details fail to load are saved without a listing date
+func (cc *CompaniesController) SyncCompanies(ctx context.Context, q CompanyQuery) (int, []genErr.IGenError) {
+	companies, ge := cc.FetchCompanies(ctx, q)
+	if ge != nil {
+		return 0, []genErr.IGenError{ge}
+	}
+
+	var errs []genErr.IGenError
+	if q.Details {
+		for i, c := range companies {
+			if ctx.Err() != nil {
+				errs = append(errs, &genErr.GenError{Messages: []string{"CompaniesController:SyncCompanies:details cancelled with: " + ctx.Err().Error()}})
+				break
+			}
+
+			detailed, ge := cc.FetchCompany(ctx, c.CompanyName)
+			if ge != nil {
+				errs = append(errs, ge.AddMsg("CompaniesController:SyncCompanies:saving without details"))
+				continue
+			}
+
+			companies[i] = detailed
+		}
+	}
+
+	if ge = cc.Companies.SaveCompanies(ctx, companies); ge != nil {
+		return 0, append(errs, ge.AddMsg("CompaniesController:SyncCompanies:failed to save companies"))
+	}
+
+	cc.Logger.Info(fmt.Sprintf("CompaniesController:SyncCompanies:saved %d companies", len(companies)))
+
+	return len(companies), errs
+}
+
+// FetchCompanies pages through `/v3/reference/tickers`
+func (cc *CompaniesController) FetchCompanies(ctx context.Context, q CompanyQuery) ([]models.Company, genErr.IGenError) {
+	market := q.Market
+	if market == "" {
+		market = "stocks"
+	}
+
+	var companies []models.Company
+	rps := model.PolygonTickersRequestParams{Market: market, Exchange: q.Exchange, Type: q.Type, Active: !q.Inactive, Limit: tickerLimit}
+	ge := fetchPages(ctx, cc.FetchService, rps, func(body []byte) (string, genErr.IGenError) {
+		pr := model.PolygonTickersResponse{}
+		if err := cc.Unmarshaler(body, &pr); err != nil {
+			return "", &genErr.GenError{Messages: []string{"CompaniesController:FetchCompanies:failed to unmarshal tickers with error: " + err.Error()}}
+		}
+
+		if strings.ToLower(pr.Status) != "ok" {
+			return "", &genErr.GenError{Messages: []string{"CompaniesController:FetchCompanies:tickers failed with status: " + pr.Status}}
+		}
+
+		for _, t := range pr.Results {
+			companies = append(companies, companyFromTicker(t))
+		}
+
+		return pr.NextUrl, nil
+	})
+	if ge != nil {
+		return []models.Company{}, ge.AddMsg("CompaniesController:FetchCompanies:failed to fetch tickers")
+	}
+
+	return companies, nil
+}
+
+// FetchCompany fetches a single ticker's details, which include its listing date
+func (cc *CompaniesController) FetchCompany(ctx context.Context, companyName string) (models.Company, genErr.IGenError) {
+	body, ge := cc.FetchService.FetchWithFetchData(ctx, model.PolygonTickerDetailsRequestParams{Ticker: companyName})
+	if ge != nil {
+		return models.Company{}, ge.AddMsg("CompaniesController:FetchCompany:failed to fetch details for: " + companyName)
+	}
+
+	pr := model.PolygonTickerDetailsResponse{}
+	if err := cc.Unmarshaler(body, &pr); err != nil {
+		return models.Company{}, &genErr.GenError{Messages: []string{"CompaniesController:FetchCompany:failed to unmarshal details for: " + companyName + " with error: " + err.Error()}}
+	}
+
+	if strings.ToLower(pr.Status) != "ok" {
+		return models.Company{}, &genErr.GenError{Messages: []string{"CompaniesController:FetchCompany:details for: " + companyName + " failed with status: " + pr.Status}}
+	}
+
+	return companyFromTicker(pr.Results), nil
+}
+
+func companyFromTicker(t model.PolygonTicker) models.Company {
+	return models.Company{
+		CompanyName:     t.Ticker,
+		Name:            t.Name,
+		Market:          t.Market,
+		Locale:          t.Locale,
+		PrimaryExchange: t.PrimaryExchange,
+		Type:            t.Type,
+		Currency:        strings.ToUpper(t.CurrencyName),
+		Active:          t.Active,
+		ListDate:        t.ListDate,
+		DelistedAt:      t.DelistedUtc,
+		Cik:             t.Cik,
+		CompositeFigi:   t.CompositeFigi,
+	}
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/rest/mocks"
+	restModels "github.com/greenac/chaching/internal/rest/models"
+	"github.com/greenac/chaching/internal/service/fetch"
+	logMocks "github.com/greenac/chaching/internal/service/logger/mocks"
+	"github.com/greenac/chaching/internal/utils"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type companyServiceMock struct {
+	saved []models.Company
+}
+
+func (cm *companyServiceMock) SaveCompanies(ctx context.Context, companies []models.Company) genErr.IGenError {
+	cm.saved = append(cm.saved, companies...)
+	return nil
+}
+
+func (cm *companyServiceMock) GetCompany(ctx context.Context, companyName string) (models.Company, bool, genErr.IGenError) {
+	return models.Company{}, false, nil
+}
+
+func (cm *companyServiceMock) ListCompanies(ctx context.Context, filter service.CompanyFilter) ([]models.Company, genErr.IGenError) {
+	return cm.saved, nil
+}
+
+func (cm *companyServiceMock) ListTickers(ctx context.Context, filter service.CompanyFilter) ([]string, genErr.IGenError) {
+	return []string{}, nil
+}
+
+func TestCompaniesController_SyncCompanies(t *testing.T) {
+	Convey("TestCompaniesController_SyncCompanies", t, func() {
+		cm := &companyServiceMock{}
+
+		Convey("TestCompaniesController_SyncCompanies should follow next_url and save every ticker", func() {
+			c := mocks.ClientMock{GetResponses: []restModels.Response{fixtureResponse("tickers_xnas_page1.json"), fixtureResponse("tickers_xnas_page2.json")}}
+			cc := CompaniesController{FetchService: fetch.NewFetchService("https://api.polygon.io/v3/reference", &c, utils.JoinUrl), Companies: cm, Logger: &logMocks.LoggerMock{}, Unmarshaler: json.Unmarshal}
+
+			saved, errs := cc.SyncCompanies(context.Background(), CompanyQuery{Exchange: "XNAS", Type: "CS"})
+			So(errs, ShouldBeEmpty)
+			So(saved, ShouldEqual, 2)
+			So(c.GetUrls, ShouldResemble, []string{
+				"https://api.polygon.io/v3/reference/tickers?active=true&exchange=XNAS&limit=1000&market=stocks&order=asc&sort=ticker&type=CS",
+				"https://api.polygon.io/v3/reference/tickers?cursor=YWN0aXZlPXRydWUmZGF0ZT0yMDIzLTAzLTAx",
+			})
+			So(cm.saved[1], ShouldResemble, models.Company{
+				CompanyName:     "MSFT",
+				Name:            "Microsoft Corp",
+				Market:          "stocks",
+				Locale:          "us",
+				PrimaryExchange: "XNAS",
+				Type:            "CS",
+				Currency:        "USD",
+				Active:          true,
+				Cik:             "0000789019",
+				CompositeFigi:   "BBG000BPH459",
+			})
+		})
+
+		Convey("TestCompaniesController_SyncCompanies should fill in listing dates from the details", func() {
+			c := mocks.ClientMock{GetResponses: []restModels.Response{fixtureResponse("tickers_xnas_page2.json"), fixtureResponse("ticker_details_aapl.json")}}
+			c.GetResponses[0].Body = []byte(`{"status": "OK", "results": [{"ticker": "AAPL", "primary_exchange": "XNAS", "active": true}]}`)
+			cc := CompaniesController{FetchService: fetch.NewFetchService("https://api.polygon.io/v3/reference", &c, utils.JoinUrl), Companies: cm, Logger: &logMocks.LoggerMock{}, Unmarshaler: json.Unmarshal}
+
+			saved, errs := cc.SyncCompanies(context.Background(), CompanyQuery{Details: true})
+			So(errs, ShouldBeEmpty)
+			So(saved, ShouldEqual, 1)
+			So(c.GetUrls[1], ShouldEqual, "https://api.polygon.io/v3/reference/tickers/AAPL")
+			So(cm.saved[0].ListDate, ShouldEqual, "1980-12-12")
+			So(cm.saved[0].Name, ShouldEqual, "Apple Inc.")
+		})
+
+		Convey("TestCompaniesController_SyncCompanies should save a ticker without details when they fail", func() {
+			c := mocks.ClientMock{GetResponses: []restModels.Response{
+				fixtureResponse("tickers_xnas_page2.json"),
+				{StatusCode: http.StatusNotFound, Status: "404 Not Found"},
+			}}
+			cc := CompaniesController{FetchService: fetch.NewFetchService("https://api.polygon.io/v3/reference", &c, utils.JoinUrl), Companies: cm, Logger: &logMocks.LoggerMock{}, Unmarshaler: json.Unmarshal}
+
+			saved, errs := cc.SyncCompanies(context.Background(), CompanyQuery{Details: true})
+			So(saved, ShouldEqual, 1)
+			So(len(errs), ShouldEqual, 1)
+			So(cm.saved[0].CompanyName, ShouldEqual, "MSFT")
+			So(cm.saved[0].ListDate, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/fetch"
+	"github.com/greenac/chaching/internal/service/logger"
+	"strings"
+)
+
+const corporateActionLimit = 1000
+
+// CorporateActionsController ingests splits and dividends from polygon's reference endpoints.
+// Its FetchService must point at the `/v3/reference` base url
+type CorporateActionsController struct {
+	FetchService     fetch.IFetchService
+	CorporateActions service.ICorporateActionService
+	Logger           logger.ILogger
+	Unmarshaler      func(data []byte, v any) error
+}
+
+// SyncCorporateActions fetches and saves the corporate actions of each target, returning the number saved
+func (cc *CorporateActionsController) SyncCorporateActions(ctx context.Context, targets []string) (int, []genErr.IGenError) {
+	var errs []genErr.IGenError
+	saved := 0
+	for _, name := range targets {
+		actions, ge := cc.FetchCorporateActions(ctx, name)
+		if ge != nil {
+			errs = append(errs, ge)
+			continue
+		}
+
+		if ge = cc.CorporateActions.SaveCorporateActions(ctx, actions); ge != nil {
+			errs = append(errs, ge.AddMsg("CorporateActionsController:SyncCorporateActions:failed to save for: "+name))
+			continue
+		}
+
+		cc.Logger.Info(fmt.Sprintf("CorporateActionsController:SyncCorporateActions:saved %d corporate actions for: %s", len(actions), name))
+		saved += len(actions)
+	}
+
+	return saved, errs
+}
+
+func (cc *CorporateActionsController) FetchCorporateActions(ctx context.Context, companyName string) ([]models.CorporateAction, genErr.IGenError) {
+	var actions []models.CorporateAction
+
+	ge := fetchPages(ctx, cc.FetchService, model.PolygonReferenceRequestParams{Type: model.PolygonReferenceTypeSplits, Ticker: companyName, Limit: corporateActionLimit}, func(body []byte) (string, genErr.IGenError) {
+		pr := model.PolygonSplitsResponse{}
+		if err := cc.Unmarshaler(body, &pr); err != nil {
+			return "", &genErr.GenError{Messages: []string{"CorporateActionsController:FetchCorporateActions:failed to unmarshal splits with error: " + err.Error()}}
+		}
+
+		if strings.ToLower(pr.Status) != "ok" {
+			return "", &genErr.GenError{Messages: []string{"CorporateActionsController:FetchCorporateActions:splits failed with status: " + pr.Status}}
+		}
+
+		for _, s := range pr.Results {
+			actions = append(actions, models.CorporateAction{Id: s.Id, CompanyName: companyName, Type: models.CorporateActionTypeSplit, ExDate: s.ExecutionDate, SplitFrom: s.SplitFrom, SplitTo: s.SplitTo})
+		}
+
+		return pr.NextUrl, nil
+	})
+	if ge != nil {
+		return []models.CorporateAction{}, ge.AddMsg("CorporateActionsController:FetchCorporateActions:failed to fetch splits for: " + companyName)
+	}
+
+	ge = fetchPages(ctx, cc.FetchService, model.PolygonReferenceRequestParams{Type: model.PolygonReferenceTypeDividends, Ticker: companyName, Limit: corporateActionLimit}, func(body []byte) (string, genErr.IGenError) {
+		pr := model.PolygonDividendsResponse{}
+		if err := cc.Unmarshaler(body, &pr); err != nil {
+			return "", &genErr.GenError{Messages: []string{"CorporateActionsController:FetchCorporateActions:failed to unmarshal dividends with error: " + err.Error()}}
+		}
+
+		if strings.ToLower(pr.Status) != "ok" {
+			return "", &genErr.GenError{Messages: []string{"CorporateActionsController:FetchCorporateActions:dividends failed with status: " + pr.Status}}
+		}
+
+		for _, d := range pr.Results {
+			actions = append(actions, models.CorporateAction{Id: d.Id, CompanyName: companyName, Type: models.CorporateActionTypeDividend, ExDate: d.ExDividendDate, CashAmount: d.CashAmount, Currency: d.Currency, DividendType: d.DividendType})
+		}
+
+		return pr.NextUrl, nil
+	})
+	if ge != nil {
+		return []models.CorporateAction{}, ge.AddMsg("CorporateActionsController:FetchCorporateActions:failed to fetch dividends for: " + companyName)
+	}
+
+	return actions, nil
+}
+
+// fetchPages fetches the first page and follows the `next_url` handle returns until it is empty
+func fetchPages(ctx context.Context, fs fetch.IFetchService, first fetch.IFetchData, handle func(body []byte) (string, genErr.IGenError)) genErr.IGenError {
+	body, ge := fs.FetchWithFetchData(ctx, first)
+	for {
+		if ge != nil {
+			return ge
+		}
+
+		var nextUrl string
+		nextUrl, ge = handle(body)
+		if ge != nil {
+			return ge
+		}
+
+		if nextUrl == "" {
+			return nil
+		}
+
+		body, ge = fs.FetchUrl(ctx, nextUrl)
+	}
+}
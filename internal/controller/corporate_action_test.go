@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/rest/mocks"
+	restModels "github.com/greenac/chaching/internal/rest/models"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/fetch"
+	logMocks "github.com/greenac/chaching/internal/service/logger/mocks"
+	"github.com/greenac/chaching/internal/utils"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type corporateActionServiceMock struct {
+	saved []models.CorporateAction
+}
+
+func (cm *corporateActionServiceMock) SaveCorporateActions(ctx context.Context, actions []models.CorporateAction) genErr.IGenError {
+	cm.saved = append(cm.saved, actions...)
+	return nil
+}
+
+func (cm *corporateActionServiceMock) GetCorporateActions(ctx context.Context, companyName string) ([]models.CorporateAction, genErr.IGenError) {
+	return cm.saved, nil
+}
+
+func jsonResponse(v any) restModels.Response {
+	body, _ := json.Marshal(v)
+
+	return restModels.Response{StatusCode: http.StatusOK, Body: body}
+}
+
+func TestCorporateActionsController_SyncCorporateActions(t *testing.T) {
+	Convey("TestCorporateActionsController_SyncCorporateActions", t, func() {
+		c := mocks.ClientMock{GetResponses: []restModels.Response{
+			jsonResponse(model.PolygonSplitsResponse{Status: "OK", NextUrl: "https://api.polygon.io/v3/reference/splits?cursor=1", Results: []model.PolygonSplit{
+				{Id: "s1", Ticker: "AAPL", ExecutionDate: "2020-08-31", SplitFrom: 1, SplitTo: 4},
+			}}),
+			jsonResponse(model.PolygonSplitsResponse{Status: "OK", Results: []model.PolygonSplit{
+				{Id: "s2", Ticker: "AAPL", ExecutionDate: "2014-06-09", SplitFrom: 1, SplitTo: 7},
+			}}),
+			jsonResponse(model.PolygonDividendsResponse{Status: "OK", Results: []model.PolygonDividend{
+				{Id: "d1", Ticker: "AAPL", ExDividendDate: "2023-02-10", CashAmount: 0.23, Currency: "USD", DividendType: "CD"},
+			}}),
+		}}
+		cm := &corporateActionServiceMock{}
+		cc := CorporateActionsController{
+			FetchService:     fetch.NewFetchService("https://api.polygon.io/v3/reference", &c, utils.JoinUrl),
+			CorporateActions: cm,
+			Logger:           &logMocks.LoggerMock{},
+			Unmarshaler:      json.Unmarshal,
+		}
+
+		Convey("TestCorporateActionsController_SyncCorporateActions should follow next_url and save splits and dividends", func() {
+			saved, errs := cc.SyncCorporateActions(context.Background(), []string{"AAPL"})
+			So(errs, ShouldBeEmpty)
+			So(saved, ShouldEqual, 3)
+			So(c.GetUrls, ShouldResemble, []string{
+				"https://api.polygon.io/v3/reference/splits?ticker=AAPL&limit=1000",
+				"https://api.polygon.io/v3/reference/splits?cursor=1",
+				"https://api.polygon.io/v3/reference/dividends?ticker=AAPL&limit=1000",
+			})
+			So(cm.saved[1], ShouldResemble, models.CorporateAction{Id: "s2", CompanyName: "AAPL", Type: models.CorporateActionTypeSplit, ExDate: "2014-06-09", SplitFrom: 1, SplitTo: 7})
+			So(cm.saved[2], ShouldResemble, models.CorporateAction{Id: "d1", CompanyName: "AAPL", Type: models.CorporateActionTypeDividend, ExDate: "2023-02-10", CashAmount: 0.23, Currency: "USD", DividendType: "CD"})
+		})
+
+		Convey("TestCorporateActionsController_SyncCorporateActions should report a failed status", func() {
+			c.GetResponses = []restModels.Response{jsonResponse(model.PolygonSplitsResponse{Status: "ERROR"})}
+			saved, errs := cc.SyncCorporateActions(context.Background(), []string{"AAPL"})
+			So(saved, ShouldEqual, 0)
+			So(len(errs), ShouldEqual, 1)
+			So(errs[0].Error(), ShouldEqual, "CorporateActionsController:FetchCorporateActions:splits failed with status: ERROR->CorporateActionsController:FetchCorporateActions:failed to fetch splits for: AAPL")
+			So(cm.saved, ShouldBeEmpty)
+		})
+	})
+}
@@ -3,14 +3,19 @@ package controller
 import (
 	"context"
 	"fmt"
+	"github.com/greenac/chaching/internal/calendar"
 	"github.com/greenac/chaching/internal/database/models"
 	"github.com/greenac/chaching/internal/database/service"
 	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/rest/limiter"
 	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/breaker"
 	"github.com/greenac/chaching/internal/service/fetch"
 	"github.com/greenac/chaching/internal/service/logger"
+	"github.com/greenac/chaching/internal/service/provider"
+	"github.com/greenac/chaching/internal/service/quality"
 	"github.com/greenac/chaching/internal/worker"
-	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,6 +27,7 @@ type FetchParams struct {
 	TimespanMultiplier int
 	Limit              int
 	Timespan           model.PolygonAggregateTimespan
+	Adjusted           bool
 }
 
 type FetchTargetParams struct {
@@ -35,149 +41,411 @@ type FetchTarget struct {
 	Name string
 }
 
+type FetchWindow struct {
+	From time.Time
+	To   time.Time
+	Bars int // most bars the window can return
+}
+
 type FetchTargetsRetVal struct {
-	DataPoints []models.DataPoint
-	Error      genErr.IGenError
+	CompanyName string
+	DataPoints  []models.DataPoint
+	Pages       int
+	Error       genErr.IGenError
 }
 
 type FetchController struct {
 	Targets         []string
-	StartOfDay      time.Time
-	EndOfDay        time.Time
+	Calendar        calendar.ICalendar
 	StartDate       time.Time
 	EndDate         time.Time
-	PartitionValue  time.Duration
-	FetchService    fetch.FetchService
+	PartitionValue  time.Duration // caps how much time each window covers, 0 sizes windows by the limit alone
+	Concurrency     int
+	Provider        provider.IProvider // where bars come from, polygon through FetchService when nil
+	FetchService    fetch.IFetchService
 	DatabaseService service.IDatabaseService
+	Watermarks      service.IWatermarkService // when set, each ticker resumes after its watermark and the watermark advances as windows are saved
+	Validator       quality.IValidator        // when set, bars are validated before they are saved
+	Quarantine      service.IQuarantineService
 	Logger          logger.ILogger
 	Unmarshaler     func(data []byte, v any) error
+	stopped         int32
+	stopReason      string
 }
 
 type FetchTaskResult struct {
 	DataPoints []models.DataPoint
 	Errors     *[]genErr.IGenError
+	Skipped    bool
+	Quality    quality.Report
 }
 
-func (fc *FetchController) RunFetch(fp FetchParams) []genErr.IGenError {
-	msgChan := make(chan worker.Message[FetchTaskResult])
+// FetchRunReport summarizes which fetch windows a run completed
+type FetchRunReport struct {
+	Windows    int
+	Completed  int
+	Failed     int
+	Skipped    int
+	DataPoints int
+	StopReason string
+	Quality    quality.Report
+}
+
+func (r FetchRunReport) String() string {
+	s := fmt.Sprintf("completed %d of %d windows with %d data points, %d failed, %d skipped", r.Completed, r.Windows, r.DataPoints, r.Failed, r.Skipped)
+	if r.StopReason != "" {
+		s += ", stopped because: " + r.StopReason
+	}
+
+	if r.Quality.Checked > 0 {
+		s += ", quality: " + r.Quality.String()
+	}
+
+	return s
+}
+
+func (fc *FetchController) RunFetch(ctx context.Context, fp FetchParams) (FetchRunReport, []genErr.IGenError) {
+	windows := fc.FetchPlan(fp).Windows
+
+	var tracker *watermarkTracker
+	if fc.Watermarks != nil {
+		marks, ge := fc.loadWatermarks(ctx, fp)
+		if ge != nil {
+			return FetchRunReport{}, []genErr.IGenError{ge}
+		}
+
+		windows = fc.planner(fp).Plan(resumeFrom(fc.StartDate, marks), fc.EndDate).Windows
+		tracker = newWatermarkTracker(fc.Watermarks, windows, marks, barLength(fp), time.Now)
+	}
+
+	fc.Logger.Info(fmt.Sprintf("FetchController:RunFetch:fetch # of windows: %d", len(windows)))
 
-	times := fc.partitionTimes()
-	fc.Logger.Info(fmt.Sprintf("FetchController:RunFetch:fetch # of times: %d", len(times)))
+	report := FetchRunReport{Windows: len(windows)}
 
-	wrkr := worker.NewWorker(10, msgChan)
+	msgChan := make(chan worker.Message[FetchTaskResult])
+	wrkr := worker.NewWorker(fc.concurrency(), fc.concurrency(), msgChan)
 	wrkr.Work()
 
 	go func() {
-		for i := 0; i < len(times)-1; i += 2 {
-			go func(from time.Time, to time.Time) {
-				task := func() FetchTaskResult {
-					var genErrs []genErr.IGenError
-					dps, errs := fc.FetchGroup(fp, from, to)
-					if errs != nil {
-						genErrs = append(genErrs, errs...)
+		defer wrkr.Close()
+		for i, w := range windows {
+			index := i
+			from := w.From
+			to := w.To
+			task := func() FetchTaskResult {
+				if fc.isStopped() || ctx.Err() != nil {
+					return FetchTaskResult{Skipped: true}
+				}
+
+				targets := fc.Targets
+				if tracker != nil {
+					targets = tracker.targets(index)
+				}
+
+				var genErrs []genErr.IGenError
+				var dps []models.DataPoint
+				var quarantined []models.QuarantinedDataPoint
+				var report quality.Report
+				results := fc.fetchGroup(ctx, fp, targets, from, to)
+				for _, r := range results {
+					if r.Error != nil {
+						genErrs = append(genErrs, r.Error)
 					}
 
-					gErrs := fc.DatabaseService.SaveDataPoints(context.Background(), dps)
-					if gErrs != nil {
-						genErrs = append(genErrs, *gErrs...)
+					bars := fc.dropOutsideSessions(r.DataPoints, fp, from, to)
+					if fc.Validator == nil {
+						dps = append(dps, bars...)
+						continue
 					}
 
-					return FetchTaskResult{DataPoints: dps, Errors: gErrs}
+					vr := fc.validate(bars, from, to)
+					dps = append(dps, vr.Accepted...)
+					quarantined = append(quarantined, vr.Quarantined...)
+					report.Merge(vr.Report)
+				}
+
+				if len(genErrs) > 0 {
+					fc.stopOnFatalError(genErrs)
+				}
+
+				// quarantined bars are part of the window too, so the watermark only advances once both are saved
+				qErr := fc.saveQuarantined(ctx, quarantined)
+				if qErr != nil {
+					genErrs = append(genErrs, qErr)
+				}
+
+				gErrs := fc.DatabaseService.SaveDataPoints(ctx, dps)
+				if gErrs != nil {
+					genErrs = append(genErrs, *gErrs...)
+				} else if tracker != nil && qErr == nil {
+					for _, r := range results {
+						if r.Error != nil {
+							continue
+						}
+
+						if ge := tracker.complete(ctx, r.CompanyName, index, lastBarTime(r.DataPoints)); ge != nil {
+							genErrs = append(genErrs, ge)
+						}
+					}
 				}
-				wrkr.AddTask(task)
-			}(times[i], times[i+1])
+
+				return FetchTaskResult{DataPoints: dps, Errors: &genErrs, Quality: report}
+			}
+
+			if err := wrkr.Submit(ctx, task); err != nil {
+				return
+			}
 		}
 	}()
 
 	var errors []genErr.IGenError
 	for msg := range msgChan {
-		if msg.Result.Errors != nil && len(*msg.Result.Errors) > 0 {
+		report.Quality.Merge(msg.Result.Quality)
+
+		switch {
+		case msg.Panic != nil:
+			report.Failed += 1
+			errors = append(errors, &genErr.GenError{Messages: []string{fmt.Sprintf("FetchController:RunFetch:fetch task panicked with: %+v", msg.Panic)}})
+		case msg.Result.Skipped:
+			// counted with the windows that were never submitted below
+		case msg.Result.Errors != nil && len(*msg.Result.Errors) > 0:
+			report.Failed += 1
 			errors = append(errors, *msg.Result.Errors...)
+		default:
+			report.Completed += 1
+			report.DataPoints += len(msg.Result.DataPoints)
+		}
+	}
+
+	// windows that were never submitted because the context finished are skipped as well
+	report.Skipped = report.Windows - report.Completed - report.Failed
+
+	if fc.isStopped() {
+		report.StopReason = fc.stopReason
+	} else if ctx.Err() != nil {
+		report.StopReason = "fetch was cancelled: " + ctx.Err().Error()
+	}
+
+	if report.Skipped > 0 {
+		fc.Logger.Warn(fmt.Sprintf("FetchController:RunFetch:skipped %d windows after stopping, %s", report.Skipped, report.StopReason))
+	}
+
+	for _, st := range wrkr.Stats() {
+		fc.Logger.Debug(fmt.Sprintf("FetchController:RunFetch:worker %d ran %d tasks, average: %s, max: %s, panics: %d", st.WorkerId, st.Tasks, st.AverageDuration(), st.MaxDuration, st.Panics))
+	}
+
+	return report, errors
+}
+
+// dropOutsideSessions removes the extended hours bars a window returns between the sessions it joins, so a day holds
+// the same bars however the fetch was planned. Bars outside the window are left to the validator
+func (fc *FetchController) dropOutsideSessions(dps []models.DataPoint, fp FetchParams, from time.Time, to time.Time) []models.DataPoint {
+	if !fc.planner(fp).intraday() {
+		return dps
+	}
+
+	length := barLength(fp)
+	kept := make([]models.DataPoint, 0, len(dps))
+	for _, dp := range dps {
+		start := time.UnixMilli(dp.StartTime)
+		if !start.Before(from) && !start.After(to) {
+			if _, outside := quality.OutsideSession(fc.calendar(), fp.Timespan, start, start.Add(length)); outside {
+				continue
+			}
+		}
+
+		kept = append(kept, dp)
+	}
+
+	if dropped := len(dps) - len(kept); dropped > 0 {
+		fc.Logger.Debug(fmt.Sprintf("FetchController:dropOutsideSessions:dropped %d bars outside the sessions from: %s to: %s", dropped, from.Format(time.RFC3339), to.Format(time.RFC3339)))
+	}
+
+	return kept
+}
+
+// validate runs the validator over one target's bars, logging every bar it flags
+func (fc *FetchController) validate(dps []models.DataPoint, from time.Time, to time.Time) quality.Result {
+	res := fc.Validator.Validate(dps, from, to)
+	for _, fb := range res.Flagged {
+		if fb.Action == quality.ActionWarn {
+			fc.Logger.Debug("FetchController:validate:" + fb.String())
+		} else {
+			fc.Logger.Warn("FetchController:validate:" + fb.String())
 		}
 	}
 
-	return errors
+	return res
 }
 
-func (fc *FetchController) FetchGroup(fp FetchParams, from time.Time, to time.Time) ([]models.DataPoint, []genErr.IGenError) {
+// saveQuarantined saves the bars that failed validation, they are dropped with a warning without a quarantine service
+func (fc *FetchController) saveQuarantined(ctx context.Context, qdps []models.QuarantinedDataPoint) genErr.IGenError {
+	if len(qdps) == 0 {
+		return nil
+	}
+
+	if fc.Quarantine == nil {
+		fc.Logger.Warn(fmt.Sprintf("FetchController:saveQuarantined:dropping %d quarantined bars without a quarantine service", len(qdps)))
+		return nil
+	}
+
+	if ge := fc.Quarantine.SaveQuarantined(ctx, qdps); ge != nil {
+		return ge.AddMsg("FetchController:saveQuarantined:failed")
+	}
+
+	return nil
+}
+
+func (fc *FetchController) concurrency() int {
+	if fc.Concurrency > 0 {
+		return fc.Concurrency
+	}
+
+	return ConcurrencyCount
+}
+
+func (fc *FetchController) isStopped() bool {
+	return atomic.LoadInt32(&fc.stopped) == 1
+}
+
+// stopOnFatalError stops scheduling fetches once the daily request budget is used up
+// or the circuit breaker around the fetch service has opened
+func (fc *FetchController) stopOnFatalError(errs []genErr.IGenError) {
+	for _, e := range errs {
+		var reason string
+		switch et := e.(type) {
+		case *limiter.BudgetExhaustedError:
+			reason = "request budget exhausted, resets at: " + et.ResetsAt.Format(time.RFC3339)
+		case *breaker.OpenError:
+			reason = "circuit breaker open: " + et.Reason
+		default:
+			continue
+		}
+
+		if atomic.CompareAndSwapInt32(&fc.stopped, 0, 1) {
+			fc.stopReason = reason
+			fc.Logger.Warn("FetchController:RunFetch:stopping fetch, " + reason)
+		}
+		return
+	}
+}
+
+func (fc *FetchController) FetchGroup(ctx context.Context, fp FetchParams, from time.Time, to time.Time) ([]models.DataPoint, []genErr.IGenError) {
 	var genErrors []genErr.IGenError
 	var dataPts []models.DataPoint
 
-	c := make(chan FetchTargetsRetVal, len(fc.Targets))
+	for _, r := range fc.fetchGroup(ctx, fp, fc.Targets, from, to) {
+		dataPts = append(dataPts, r.DataPoints...)
+		if r.Error != nil {
+			genErrors = append(genErrors, r.Error)
+		}
+	}
 
-	for _, name := range fc.Targets {
+	return dataPts, genErrors
+}
+
+// fetchGroup fetches the targets concurrently and returns one result per target
+func (fc *FetchController) fetchGroup(ctx context.Context, fp FetchParams, targets []string, from time.Time, to time.Time) []FetchTargetsRetVal {
+	c := make(chan FetchTargetsRetVal, len(targets))
+
+	for _, name := range targets {
 		go func(n string) {
 			defer func() {
 				if r := recover(); r != nil {
-					fc.Logger.Error(fmt.Sprintf("FetchController:FetchGroup:panic recovered for name: %s, fetch params: %+v, from: %s, to: %s, panic: %+v", n, fp, from.Format(time.RFC3339), to.Format(time.RFC3339), r))
+					msg := fmt.Sprintf("FetchController:FetchGroup:panic recovered for name: %s, fetch params: %+v, from: %s, to: %s, panic: %+v", n, fp, from.Format(time.RFC3339), to.Format(time.RFC3339), r)
+					fc.Logger.Error(msg)
+					c <- FetchTargetsRetVal{CompanyName: n, Error: &genErr.GenError{Messages: []string{msg}}}
 				}
 			}()
 
-			dps, gErr := fc.FetchTargets(FetchTargetParams{FetchParams: fp, CompanyName: n, From: from, To: to})
-			c <- FetchTargetsRetVal{DataPoints: dps, Error: gErr}
+			dps, pages, gErr := fc.FetchTargets(ctx, FetchTargetParams{FetchParams: fp, CompanyName: n, From: from, To: to})
+			c <- FetchTargetsRetVal{CompanyName: n, DataPoints: dps, Pages: pages, Error: gErr}
 		}(name)
 	}
 
-	for r := range c {
-		dataPts = append(dataPts, r.DataPoints...)
-		if r.Error != nil {
-			genErrors = append(genErrors, r.Error)
-		}
+	results := make([]FetchTargetsRetVal, len(targets))
+	for i := range targets {
+		results[i] = <-c
 	}
 
-	return dataPts, genErrors
+	return results
 }
 
-func (fc *FetchController) FetchTargets(fp FetchTargetParams) ([]models.DataPoint, genErr.IGenError) {
-	rps := model.PolygonAggregateRequestParams{
-		CompanyName:   fp.CompanyName,
-		Multiplier:    fp.TimespanMultiplier,
-		Timespan:      fp.Timespan,
-		From:          fp.From,
-		To:            fp.To,
-		SortDirection: SortDirection,
-		Limit:         fp.Limit,
-	}
-
-	body, ge := fc.FetchService.FetchWithFetchData(rps)
+// FetchTargets fetches the bars of a single company from the controller's provider. It returns the data points
+// along with the number of pages fetched
+func (fc *FetchController) FetchTargets(ctx context.Context, fp FetchTargetParams) ([]models.DataPoint, int, genErr.IGenError) {
+	dps, pages, ge := fc.provider().Bars(ctx, provider.BarRequest{
+		CompanyName: fp.CompanyName,
+		Timespan:    fp.Timespan,
+		Multiplier:  fp.TimespanMultiplier,
+		From:        fp.From,
+		To:          fp.To,
+		Limit:       fp.Limit,
+		Adjusted:    fp.Adjusted,
+	})
 	if ge != nil {
-		return []models.DataPoint{}, ge
+		return []models.DataPoint{}, pages, ge
 	}
 
-	pr := model.PolygonAggregateResponse{}
-	err := fc.Unmarshaler(body, &pr)
-	if err != nil {
-		return []models.DataPoint{}, genErr.GenError{Messages: []string{"FetchController:FetchTargets:failed to unmarshall with err: " + err.Error() + " for: " + fp.CompanyName}}
-	}
+	fc.Logger.Info(fmt.Sprintf("FetchController:FetchTargets:fetched %d pages with %d data points for: %s", pages, len(dps), fp.CompanyName))
 
-	if strings.ToLower(pr.Status) != "ok" {
-		return []models.DataPoint{}, &genErr.GenError{Messages: []string{"FetchController:FetchTargets:failed for: " + fp.CompanyName + " with status: " + pr.Status + " at time: " + fp.From.Format(time.RFC3339)}}
+	return dps, pages, nil
+}
+
+// provider defaults to polygon through the controller's FetchService
+func (fc *FetchController) provider() provider.IProvider {
+	if fc.Provider != nil {
+		return fc.Provider
 	}
 
-	dps := make([]models.DataPoint, len(pr.DataPoints))
-	for i, dp := range pr.DataPoints {
-		dps[i] = models.DataPoint{CompanyName: fp.CompanyName, PolygonDataPoint: dp}
+	return provider.NewPolygonProvider(fc.FetchService, fc.Unmarshaler)
+}
+
+// FetchPlan plans the windows between the controller's start and end, one request each
+func (fc *FetchController) FetchPlan(fp FetchParams) FetchPlan {
+	return fc.planner(fp).Plan(fc.StartDate, fc.EndDate)
+}
+
+func (fc *FetchController) planner(fp FetchParams) FetchPlanner {
+	return FetchPlanner{Calendar: fc.calendar(), Timespan: fp.Timespan, Multiplier: fp.TimespanMultiplier, Limit: fp.Limit, MaxWindow: fc.PartitionValue}
+}
+
+// loadWatermarks reads the watermark of every target, targets without one get an empty watermark
+func (fc *FetchController) loadWatermarks(ctx context.Context, fp FetchParams) (map[string]models.Watermark, genErr.IGenError) {
+	marks := map[string]models.Watermark{}
+	for _, name := range fc.Targets {
+		w, ok, ge := fc.Watermarks.GetWatermark(ctx, name, fp.Timespan, fp.TimespanMultiplier, fp.Adjusted)
+		if ge != nil {
+			return nil, ge.AddMsg("FetchController:loadWatermarks:failed to load watermarks")
+		}
+
+		if ok {
+			fc.Logger.Info(fmt.Sprintf("FetchController:loadWatermarks:%s has been saved through: %s", name, w.Through.Format(time.RFC3339)))
+		} else {
+			w = models.Watermark{CompanyName: name, Timespan: fp.Timespan, Multiplier: fp.TimespanMultiplier, Adjusted: fp.Adjusted}
+		}
+
+		marks[name] = w
 	}
 
-	return dps, nil
+	return marks, nil
 }
 
-func (fc *FetchController) partitionTimes() []time.Time {
-	var times []time.Time
-	t := fc.StartDate
-	startOfDay := fc.StartDate
-	endOfDay := fc.EndOfDay
-	for t.Before(fc.EndDate) || t.Equal(fc.EndDate) {
-		if t.After(endOfDay) || t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
-			startOfDay = startOfDay.Add(24 * time.Hour)
-			endOfDay = endOfDay.Add(24 * time.Hour)
-			t = startOfDay
-			continue
+func lastBarTime(dps []models.DataPoint) int64 {
+	var last int64
+	for _, dp := range dps {
+		if dp.StartTime > last {
+			last = dp.StartTime
 		}
+	}
+
+	return last
+}
 
-		times = append(times, t)
-		t = t.Add(fc.PartitionValue)
+func (fc *FetchController) calendar() calendar.ICalendar {
+	if fc.Calendar != nil {
+		return fc.Calendar
 	}
 
-	return times
+	return calendar.NewNyseCalendar()
 }
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/greenac/chaching/internal/calendar"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/service/chaching_kafka"
+	"github.com/greenac/chaching/internal/service/logger"
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+// FetchProducer publishes a FetchMessage for every window of every target, for the fetch consumers to work through.
+// Messages are keyed by ticker so each ticker's windows land on one partition in order. Windows are planned for
+// Params, which the consumers should fetch with
+type FetchProducer struct {
+	Producer       chaching_kafka.IProducer
+	Calendar       calendar.ICalendar
+	Params         FetchParams
+	PartitionValue time.Duration
+	Logger         logger.ILogger
+	Now            func() time.Time
+	NewNonce       func() uuid.UUID
+}
+
+// Publish writes the windows between start and end for each target, returning the number of messages written
+func (fp *FetchProducer) Publish(ctx context.Context, targets []string, start time.Time, end time.Time) (int, genErr.IGenError) {
+	fc := FetchController{Calendar: fp.Calendar, PartitionValue: fp.PartitionValue}
+	windows := fc.planner(fp.Params).Plan(start, end).Windows
+
+	published := 0
+	for _, target := range targets {
+		msgs := make([]kafka.Message, len(windows))
+		for i, w := range windows {
+			value, err := json.Marshal(chaching_kafka.Message[FetchMessage]{
+				KafkaMessage: chaching_kafka.KafkaMessage[FetchMessage]{
+					Payload: FetchMessage{Company: target, From: w.From, To: w.To},
+					Headers: chaching_kafka.KafkaHeaders{Nonce: fp.nonce()},
+				},
+				CreatedAt: fp.now(),
+			})
+			if err != nil {
+				return published, &genErr.GenError{Messages: []string{"FetchProducer:Publish:failed to marshal message for: " + target + " with error: " + err.Error()}}
+			}
+
+			msgs[i] = kafka.Message{Key: []byte(target), Value: value}
+		}
+
+		if len(msgs) == 0 {
+			continue
+		}
+
+		if err := fp.Producer.WriteMessages(ctx, msgs...); err != nil {
+			return published, &genErr.GenError{Messages: []string{"FetchProducer:Publish:failed to write messages for: " + target + " with error: " + err.Error()}}
+		}
+
+		published += len(msgs)
+		fp.Logger.Info(fmt.Sprintf("FetchProducer:Publish:published %d windows for: %s", len(msgs), target))
+	}
+
+	return published, nil
+}
+
+func (fp *FetchProducer) now() time.Time {
+	if fp.Now != nil {
+		return fp.Now()
+	}
+
+	return time.Now()
+}
+
+func (fp *FetchProducer) nonce() uuid.UUID {
+	if fp.NewNonce != nil {
+		return fp.NewNonce()
+	}
+
+	return uuid.New()
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/greenac/chaching/internal/calendar"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/chaching_kafka"
+	logMocks "github.com/greenac/chaching/internal/service/logger/mocks"
+	"github.com/segmentio/kafka-go"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type producerMock struct {
+	messages []kafka.Message
+	err      error
+}
+
+func (pm *producerMock) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if pm.err != nil {
+		return pm.err
+	}
+
+	pm.messages = append(pm.messages, msgs...)
+	return nil
+}
+
+func TestFetchProducer_Publish(t *testing.T) {
+	Convey("TestFetchProducer_Publish", t, func() {
+		cal := calendar.NewNyseCalendar()
+		now := time.Date(2023, 3, 2, 8, 0, 0, 0, time.UTC)
+		nonce := uuid.MustParse("7b0b3f4e-5c9e-4c43-9a39-1f3f1c1b2c6d")
+		start := time.Date(2023, 3, 1, 0, 0, 0, 0, cal.Location())
+		end := time.Date(2023, 3, 1, 23, 59, 0, 0, cal.Location())
+		pm := &producerMock{}
+		params := FetchParams{TimespanMultiplier: 1, Limit: 100, Timespan: model.PolygonAggregateTimespanMinute}
+		fp := FetchProducer{Producer: pm, Calendar: cal, Params: params, Logger: &logMocks.LoggerMock{}, Now: func() time.Time { return now }, NewNonce: func() uuid.UUID { return nonce }}
+		windows := (&FetchController{Calendar: cal}).planner(params).Plan(start, end).Windows
+
+		Convey("TestFetchProducer_Publish should write a message keyed by ticker for each window", func() {
+			n, ge := fp.Publish(context.Background(), []string{"AAPL", "AMZN"}, start, end)
+			So(ge, ShouldBeNil)
+			So(n, ShouldEqual, 2*len(windows))
+			So(len(pm.messages), ShouldEqual, n)
+			So(string(pm.messages[0].Key), ShouldEqual, "AAPL")
+			So(string(pm.messages[len(windows)].Key), ShouldEqual, "AMZN")
+
+			var m chaching_kafka.Message[FetchMessage]
+			So(json.Unmarshal(pm.messages[0].Value, &m), ShouldBeNil)
+			So(m.CreatedAt.Equal(now), ShouldBeTrue)
+			So(m.KafkaMessage.Headers.Nonce, ShouldEqual, nonce)
+			So(m.KafkaMessage.Payload.Company, ShouldEqual, "AAPL")
+			So(m.KafkaMessage.Payload.From.Equal(windows[0].From), ShouldBeTrue)
+			So(m.KafkaMessage.Payload.To.Equal(windows[0].To), ShouldBeTrue)
+		})
+
+		Convey("TestFetchProducer_Publish should stop at the first failed write", func() {
+			pm.err = errors.New("broker down")
+			n, ge := fp.Publish(context.Background(), []string{"AAPL", "AMZN"}, start, end)
+			So(n, ShouldEqual, 0)
+			So(ge, ShouldNotBeNil)
+			So(ge.Error(), ShouldEqual, "FetchProducer:Publish:failed to write messages for: AAPL with error: broker down")
+		})
+	})
+}
@@ -0,0 +1,447 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/greenac/chaching/internal/calendar"
+	dbMocks "github.com/greenac/chaching/internal/database/mocks"
+	"github.com/greenac/chaching/internal/database/models"
+	dbService "github.com/greenac/chaching/internal/database/service"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/rest/cassette"
+	rest "github.com/greenac/chaching/internal/rest/client"
+	"github.com/greenac/chaching/internal/rest/mocks"
+	restModels "github.com/greenac/chaching/internal/rest/models"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/database"
+	"github.com/greenac/chaching/internal/service/fetch"
+	logMocks "github.com/greenac/chaching/internal/service/logger/mocks"
+	"github.com/greenac/chaching/internal/service/provider"
+	"github.com/greenac/chaching/internal/service/quality"
+	"github.com/greenac/chaching/internal/utils"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func aggregateResponse(nextUrl string, startTimes ...int64) restModels.Response {
+	pr := model.PolygonAggregateResponse{Status: "OK", Ticker: "AAPL", NextUrl: nextUrl}
+	for _, st := range startTimes {
+		pr.DataPoints = append(pr.DataPoints, model.PolygonDataPoint{StartTime: st, OpenPrice: 1})
+	}
+
+	body, _ := json.Marshal(pr)
+
+	return restModels.Response{StatusCode: http.StatusOK, Body: body}
+}
+
+func TestFetchController_FetchTargets(t *testing.T) {
+	Convey("TestFetchController_FetchTargets", t, func() {
+		from := time.Date(2023, 3, 1, 9, 30, 0, 0, time.UTC)
+		fp := FetchTargetParams{
+			FetchParams: FetchParams{TimespanMultiplier: 1, Limit: 2, Timespan: model.PolygonAggregateTimespanMinute},
+			CompanyName: "AAPL",
+			From:        from,
+			To:          from.Add(time.Hour),
+		}
+
+		Convey("TestFetchController_FetchTargets should follow next_url until exhausted", func() {
+			c := mocks.ClientMock{GetResponses: []restModels.Response{
+				aggregateResponse("https://api.polygon.io/next?cursor=1", 1, 2),
+				aggregateResponse("https://api.polygon.io/next?cursor=2", 3, 4),
+				aggregateResponse("", 5),
+			}}
+			fc := FetchController{FetchService: fetch.NewFetchService("https://api.polygon.io", &c, utils.JoinUrl), Logger: &logMocks.LoggerMock{}, Unmarshaler: json.Unmarshal}
+
+			dps, pages, err := fc.FetchTargets(context.Background(), fp)
+			So(err, ShouldBeNil)
+			So(pages, ShouldEqual, 3)
+			So(len(dps), ShouldEqual, 5)
+			So(dps[4], ShouldResemble, models.DataPoint{CompanyName: "AAPL", Timespan: model.PolygonAggregateTimespanMinute, PolygonDataPoint: model.PolygonDataPoint{StartTime: 5, OpenPrice: 1}})
+			So(c.GetUrls[1:], ShouldResemble, []string{"https://api.polygon.io/next?cursor=1", "https://api.polygon.io/next?cursor=2"})
+		})
+
+		Convey("TestFetchController_FetchTargets should fail when a page fails", func() {
+			c := mocks.ClientMock{
+				GetResponses: []restModels.Response{aggregateResponse("https://api.polygon.io/next?cursor=1", 1, 2)},
+				GetError:     &genErr.GenError{Messages: []string{"bad bad thing"}},
+			}
+			fc := FetchController{FetchService: fetch.NewFetchService("https://api.polygon.io", &c, utils.JoinUrl), Logger: &logMocks.LoggerMock{}, Unmarshaler: json.Unmarshal}
+
+			dps, pages, err := fc.FetchTargets(context.Background(), fp)
+			So(dps, ShouldBeEmpty)
+			So(pages, ShouldEqual, 1)
+			So(err.Error(), ShouldEqual, "bad bad thing->FetchService:FetchUrl:failed to get: https://api.polygon.io/next?cursor=1->PolygonProvider:Bars:failed to fetch page: 2 for: AAPL")
+		})
+
+		Convey("TestFetchController_FetchTargets should read bars from its provider when set", func() {
+			files := fstest.MapFS{"AAPL.csv": {Data: []byte("t,o,h,l,c,v\n1677663000000,1,2,0.5,1.5,10\n1677666600001,1,1,1,1,1\n")}}
+			fc := FetchController{Provider: provider.NewCsvProvider(files, time.UTC), Logger: &logMocks.LoggerMock{}}
+
+			dps, pages, err := fc.FetchTargets(context.Background(), fp)
+			So(err, ShouldBeNil)
+			So(pages, ShouldEqual, 1)
+			So(dps, ShouldResemble, []models.DataPoint{{CompanyName: "AAPL", Timespan: model.PolygonAggregateTimespanMinute, PolygonDataPoint: model.PolygonDataPoint{StartTime: 1677663000000, OpenPrice: 1, HighestPrice: 2, LowestPrice: 0.5, ClosePrice: 1.5, Volume: 10}}})
+		})
+
+		Convey("TestFetchController_FetchTargets should page through a recorded polygon exchange", func() {
+			cs, ge := cassette.New(filepath.Join("testdata", "cassettes", "aggs_aapl_2023-03-01.json"), cassette.ModeFromEnv(), &http.Client{Timeout: 30 * time.Second})
+			So(ge, ShouldBeNil)
+
+			rc := &rest.Client{
+				BaseHeaders: &restModels.Headers{"Authorization": restModels.HeaderValue{"Bearer " + os.Getenv("POLYGON_API_KEY")}},
+				HttpClient:  cs,
+				BodyReader:  io.ReadAll,
+				GetRequest:  http.NewRequestWithContext,
+			}
+			fc := FetchController{FetchService: fetch.NewFetchService("https://api.polygon.io/v2/aggs/ticker", rc, utils.JoinUrl), Logger: &logMocks.LoggerMock{}, Unmarshaler: json.Unmarshal}
+			start := time.Date(2023, 3, 1, 9, 30, 0, 0, calendar.NewNyseCalendar().Location())
+			rfp := FetchTargetParams{FetchParams: FetchParams{TimespanMultiplier: 1, Limit: 3, Timespan: model.PolygonAggregateTimespanMinute}, CompanyName: "AAPL", From: start, To: start.Add(5 * time.Minute)}
+
+			dps, pages, err := fc.FetchTargets(context.Background(), rfp)
+			So(err, ShouldBeNil)
+			So(pages, ShouldEqual, 2)
+			So(len(dps), ShouldEqual, 6)
+			So(dps[0], ShouldResemble, models.DataPoint{CompanyName: "AAPL", Timespan: model.PolygonAggregateTimespanMinute, PolygonDataPoint: model.PolygonDataPoint{StartTime: 1677681000000, OpenPrice: 146.83, HighestPrice: 147.16, LowestPrice: 146.71, ClosePrice: 147.0401, Volume: 1245337, VolumeWeightedPrice: 146.9176, NumOfTxs: 12377}})
+			So(dps[5].StartTime, ShouldEqual, int64(1677681300000))
+		})
+	})
+}
+
+type databaseServiceMock struct {
+	mu    sync.Mutex
+	saved []models.DataPoint
+}
+
+func (dm *databaseServiceMock) SaveDataPoints(ctx context.Context, dps []models.DataPoint) *[]genErr.IGenError {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.saved = append(dm.saved, dps...)
+	return nil
+}
+
+func (dm *databaseServiceMock) GetDataPointsInTimeRange(ctx context.Context, companyName string, startDate time.Time, endDate time.Time) ([]models.DataPoint, genErr.IGenError) {
+	return []models.DataPoint{}, nil
+}
+
+func TestFetchController_RunFetch(t *testing.T) {
+	Convey("TestFetchController_RunFetch", t, func() {
+		cal := calendar.NewNyseCalendar()
+		start := time.Date(2023, 3, 1, 9, 30, 0, 0, cal.Location())
+		dbs := &databaseServiceMock{}
+		c := mocks.ClientMock{GetResponse: aggregateResponse("", 1)}
+		fc := FetchController{
+			Targets:         []string{"AAPL"},
+			StartDate:       start,
+			EndDate:         start.Add(3 * time.Minute),
+			Calendar:        cal,
+			FetchService:    fetch.NewFetchService("https://api.polygon.io", &c, utils.JoinUrl),
+			DatabaseService: dbs,
+			Logger:          &logMocks.LoggerMock{},
+			Unmarshaler:     json.Unmarshal,
+		}
+		fp := FetchParams{TimespanMultiplier: 1, Limit: 100, Timespan: model.PolygonAggregateTimespanMinute}
+
+		Convey("TestFetchController_RunFetch should report completed windows", func() {
+			report, errs := fc.RunFetch(context.Background(), fp)
+			So(errs, ShouldBeEmpty)
+			So(report, ShouldResemble, FetchRunReport{Windows: 1, Completed: 1, DataPoints: 1})
+			So(len(dbs.saved), ShouldEqual, 1)
+		})
+
+		Convey("TestFetchController_RunFetch should finish when there are more windows than workers", func() {
+			fc.EndDate = start.Add(time.Hour)
+			fc.Concurrency = 2
+			fp.Limit = 2
+			report, errs := fc.RunFetch(context.Background(), fp)
+			So(errs, ShouldBeEmpty)
+			So(report, ShouldResemble, FetchRunReport{Windows: 31, Completed: 31, DataPoints: 31})
+		})
+
+		Convey("TestFetchController_RunFetch should skip windows once cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			report, errs := fc.RunFetch(ctx, fp)
+			So(errs, ShouldBeEmpty)
+			So(report, ShouldResemble, FetchRunReport{Windows: 1, Skipped: 1, StopReason: "fetch was cancelled: context canceled"})
+			So(dbs.saved, ShouldBeEmpty)
+		})
+
+		Convey("TestFetchController_RunFetch should save the same bars for a day whether the fetch is one day or three", func() {
+			at := func(d, h int) int64 {
+				return time.Date(2023, 3, d, h, 0, 0, 0, cal.Location()).UnixMilli()
+			}
+			fp.Limit = 50000
+
+			// the joined window returns the extended hours bars between the sessions, which are dropped
+			fc.StartDate, fc.EndDate = start, start.AddDate(0, 0, 2).Add(7*time.Hour)
+			c.GetResponse = aggregateResponse("", at(1, 10), at(1, 18), at(2, 5), at(2, 10), at(2, 19), at(3, 10))
+			report, errs := fc.RunFetch(context.Background(), fp)
+			So(errs, ShouldBeEmpty)
+			So(report.Windows, ShouldEqual, 1)
+			threeDays := dbs.saved
+
+			dbs.saved = nil
+			fc.StartDate, fc.EndDate = start.AddDate(0, 0, 1), start.AddDate(0, 0, 1).Add(7*time.Hour)
+			c.GetResponse = aggregateResponse("", at(2, 10))
+			_, errs = fc.RunFetch(context.Background(), fp)
+			So(errs, ShouldBeEmpty)
+
+			var shared []models.DataPoint
+			for _, dp := range threeDays {
+				if dp.StartTime >= at(2, 0) && dp.StartTime < at(3, 0) {
+					shared = append(shared, dp)
+				}
+			}
+			So(len(threeDays), ShouldEqual, 3)
+			So(shared, ShouldResemble, dbs.saved)
+		})
+
+		Convey("TestFetchController_RunFetch should save only valid bars and quarantine the rest", func() {
+			clean := model.PolygonDataPoint{StartTime: start.Add(time.Minute).UnixMilli(), OpenPrice: 1, HighestPrice: 2, LowestPrice: 1, ClosePrice: 2, Volume: 10}
+			broken := model.PolygonDataPoint{StartTime: start.Add(2 * time.Minute).UnixMilli(), OpenPrice: 5, HighestPrice: 2, LowestPrice: 1, ClosePrice: 2, Volume: 10}
+			body, _ := json.Marshal(model.PolygonAggregateResponse{Status: "OK", Ticker: "AAPL", DataPoints: []model.PolygonDataPoint{clean, broken}})
+			c.GetResponse = restModels.Response{StatusCode: http.StatusOK, Body: body}
+			qm := &quarantineServiceMock{}
+			fc.Validator = quality.NewValidator(quality.DefaultConfig(), cal)
+			fc.Quarantine = qm
+
+			report, errs := fc.RunFetch(context.Background(), fp)
+			So(errs, ShouldBeEmpty)
+			So(report.DataPoints, ShouldEqual, 1)
+			So(report.Quality, ShouldResemble, quality.Report{Checked: 2, Accepted: 1, Quarantined: 1, Violations: map[quality.Rule]int{quality.RulePriceRange: 1}})
+			So(report.String(), ShouldEqual, "completed 1 of 1 windows with 1 data points, 0 failed, 0 skipped, quality: checked 2 bars, accepted 1 with 0 warned, quarantined 1, rejected 0, violations: priceRange: 1")
+			So(dbs.saved, ShouldResemble, []models.DataPoint{{CompanyName: "AAPL", Timespan: model.PolygonAggregateTimespanMinute, PolygonDataPoint: clean}})
+			So(len(qm.saved), ShouldEqual, 1)
+			So(qm.saved[0].PolygonDataPoint, ShouldResemble, broken)
+			So(qm.saved[0].Reasons, ShouldResemble, []string{"priceRange: open 5 is outside low 1 and high 2"})
+		})
+	})
+}
+
+type quarantineServiceMock struct {
+	mu    sync.Mutex
+	saved []models.QuarantinedDataPoint
+	err   genErr.IGenError
+}
+
+func (qm *quarantineServiceMock) SaveQuarantined(ctx context.Context, qdps []models.QuarantinedDataPoint) genErr.IGenError {
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+	if qm.err != nil {
+		return qm.err
+	}
+	qm.saved = append(qm.saved, qdps...)
+	return nil
+}
+
+func (qm *quarantineServiceMock) GetQuarantined(ctx context.Context, companyName string, timespan model.PolygonAggregateTimespan, multiplier int, adjusted bool) ([]models.QuarantinedDataPoint, genErr.IGenError) {
+	return qm.saved, nil
+}
+
+type watermarkServiceMock struct {
+	mu    sync.Mutex
+	marks map[string]models.Watermark
+	saves []models.Watermark
+}
+
+func (wm *watermarkServiceMock) GetWatermark(ctx context.Context, companyName string, timespan model.PolygonAggregateTimespan, multiplier int, adjusted bool) (models.Watermark, bool, genErr.IGenError) {
+	w, ok := wm.marks[companyName]
+	return w, ok, nil
+}
+
+func (wm *watermarkServiceMock) SaveWatermark(ctx context.Context, w models.Watermark) genErr.IGenError {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	wm.marks[w.CompanyName] = w
+	wm.saves = append(wm.saves, w)
+	return nil
+}
+
+func TestFetchController_RunFetchWithWatermarks(t *testing.T) {
+	Convey("TestFetchController_RunFetchWithWatermarks should resume each ticker after its watermark", t, func() {
+		cal := calendar.NewNyseCalendar()
+		start := time.Date(2023, 3, 1, 9, 30, 0, 0, cal.Location())
+		wm := &watermarkServiceMock{marks: map[string]models.Watermark{
+			"AAPL": {CompanyName: "AAPL", Timespan: model.PolygonAggregateTimespanMinute, Multiplier: 1, Through: start.Add(3 * time.Minute), LastBarTime: 7},
+		}}
+		// each window returns the bar of its last minute, AAPL from the window its watermark falls inside
+		bar := func(m int) restModels.Response {
+			return aggregateResponse("", start.Add(time.Duration(m)*time.Minute).UnixMilli())
+		}
+		last := start.Add(7 * time.Minute)
+		c := mocks.ClientMock{GetResponses: []restModels.Response{bar(1), bar(3), bar(3), bar(5), bar(5), bar(7), bar(7)}}
+		dbs := &databaseServiceMock{}
+		fc := FetchController{
+			Targets:         []string{"AAPL", "AMZN"},
+			Calendar:        cal,
+			StartDate:       start,
+			EndDate:         start.Add(7 * time.Minute),
+			Concurrency:     1,
+			FetchService:    fetch.NewFetchService("https://api.polygon.io", &c, utils.JoinUrl),
+			DatabaseService: dbs,
+			Watermarks:      wm,
+			Logger:          &logMocks.LoggerMock{},
+			Unmarshaler:     json.Unmarshal,
+		}
+
+		report, errs := fc.RunFetch(context.Background(), FetchParams{TimespanMultiplier: 1, Limit: 2, Timespan: model.PolygonAggregateTimespanMinute})
+		So(errs, ShouldBeEmpty)
+		So(report, ShouldResemble, FetchRunReport{Windows: 4, Completed: 4, DataPoints: 7})
+		So(len(c.GetUrls), ShouldEqual, 7)
+		So(wm.marks["AAPL"].Through.Equal(last), ShouldBeTrue)
+		So(wm.marks["AAPL"].LastBarTime, ShouldEqual, last.UnixMilli())
+		So(wm.marks["AMZN"], ShouldResemble, models.Watermark{CompanyName: "AMZN", Timespan: model.PolygonAggregateTimespanMinute, Multiplier: 1, Through: last.UTC(), LastBarTime: last.UnixMilli()})
+	})
+
+	Convey("TestFetchController_RunFetchWithWatermarks should hold the watermark when the quarantined bars fail to save", t, func() {
+		cal := calendar.NewNyseCalendar()
+		start := time.Date(2023, 3, 1, 9, 30, 0, 0, cal.Location())
+		wm := &watermarkServiceMock{marks: map[string]models.Watermark{}}
+		clean := model.PolygonDataPoint{StartTime: start.UnixMilli(), OpenPrice: 1, HighestPrice: 2, LowestPrice: 1, ClosePrice: 2, Volume: 10}
+		broken := model.PolygonDataPoint{StartTime: start.Add(time.Minute).UnixMilli(), OpenPrice: 5, HighestPrice: 2, LowestPrice: 1, ClosePrice: 2, Volume: 10}
+		body, _ := json.Marshal(model.PolygonAggregateResponse{Status: "OK", Ticker: "AAPL", DataPoints: []model.PolygonDataPoint{clean, broken}})
+		c := mocks.ClientMock{GetResponse: restModels.Response{StatusCode: http.StatusOK, Body: body}}
+		fc := FetchController{
+			Targets:         []string{"AAPL"},
+			Calendar:        cal,
+			StartDate:       start,
+			EndDate:         start.Add(2 * time.Minute),
+			FetchService:    fetch.NewFetchService("https://api.polygon.io", &c, utils.JoinUrl),
+			DatabaseService: &databaseServiceMock{},
+			Watermarks:      wm,
+			Validator:       quality.NewValidator(quality.DefaultConfig(), cal),
+			Quarantine:      &quarantineServiceMock{err: &genErr.GenError{Messages: []string{"quarantine is down"}}},
+			Logger:          &logMocks.LoggerMock{},
+			Unmarshaler:     json.Unmarshal,
+		}
+
+		report, errs := fc.RunFetch(context.Background(), FetchParams{TimespanMultiplier: 1, Limit: 100, Timespan: model.PolygonAggregateTimespanMinute})
+		So(len(errs), ShouldEqual, 1)
+		So(report.Failed, ShouldEqual, 1)
+		So(wm.saves, ShouldBeEmpty)
+	})
+}
+
+func TestFetchController_RunFetchEndToEnd(t *testing.T) {
+	Convey("TestFetchController_RunFetchEndToEnd should save bars and watermarks that read back from the table", t, func() {
+		cal := calendar.NewNyseCalendar()
+		start := time.Date(2023, 3, 1, 9, 30, 0, 0, cal.Location())
+		fake := dbMocks.NewDynamoFake(dbMocks.ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+		dbs := dbService.NewDatabaseService(database.NewDatabase[models.DbDataPoint](fake, 25, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap))
+		ws := dbService.NewWatermarkService(database.NewDatabase[models.DbWatermark](fake, 25, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap), time.Now)
+		c := mocks.ClientMock{GetResponse: aggregateResponse("", start.UnixMilli(), start.Add(time.Minute).UnixMilli(), start.Add(2*time.Minute).UnixMilli())}
+		fc := FetchController{
+			Targets:         []string{"AAPL"},
+			Calendar:        cal,
+			StartDate:       start,
+			EndDate:         start.Add(3 * time.Minute),
+			FetchService:    fetch.NewFetchService("https://api.polygon.io", &c, utils.JoinUrl),
+			DatabaseService: dbs,
+			Watermarks:      ws,
+			Logger:          &logMocks.LoggerMock{},
+			Unmarshaler:     json.Unmarshal,
+		}
+
+		report, errs := fc.RunFetch(context.Background(), FetchParams{TimespanMultiplier: 1, Limit: 100, Timespan: model.PolygonAggregateTimespanMinute})
+		So(errs, ShouldBeEmpty)
+		So(report, ShouldResemble, FetchRunReport{Windows: 1, Completed: 1, DataPoints: 3})
+
+		dps, ge := dbs.GetDataPointsInTimeRange(context.Background(), "AAPL", start, start.Add(time.Hour))
+		So(ge, ShouldBeNil)
+		So(len(dps), ShouldEqual, 3)
+		So(dps[2].StartTime, ShouldEqual, start.Add(2*time.Minute).UnixMilli())
+
+		w, ok, ge := ws.GetWatermark(context.Background(), "AAPL", model.PolygonAggregateTimespanMinute, 1, false)
+		So(ge, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+		So(w.Through.Equal(start.Add(2*time.Minute)), ShouldBeTrue)
+		So(w.LastBarTime, ShouldEqual, start.Add(2*time.Minute).UnixMilli())
+	})
+}
+
+func TestWatermarkTracker(t *testing.T) {
+	Convey("TestWatermarkTracker", t, func() {
+		start := time.Date(2023, 3, 1, 14, 30, 0, 0, time.UTC)
+		var windows []FetchWindow
+		for i := 0; i < 4; i += 1 {
+			from := start.Add(time.Duration(2*i) * time.Minute)
+			windows = append(windows, FetchWindow{From: from, To: from.Add(2*time.Minute - time.Millisecond)})
+		}
+		bar := func(i int) int64 {
+			return start.Add(time.Duration(i) * time.Minute).UnixMilli()
+		}
+		now := func() time.Time { return start.Add(time.Hour) }
+		wm := &watermarkServiceMock{marks: map[string]models.Watermark{}}
+		wt := newWatermarkTracker(wm, windows, map[string]models.Watermark{"AAPL": {CompanyName: "AAPL"}}, time.Minute, now)
+		ctx := context.Background()
+
+		Convey("TestWatermarkTracker should only advance over windows saved in order", func() {
+			So(wt.complete(ctx, "AAPL", 1, bar(3)), ShouldBeNil)
+			So(wm.saves, ShouldBeEmpty)
+
+			So(wt.complete(ctx, "AAPL", 0, bar(1)), ShouldBeNil)
+			So(len(wm.saves), ShouldEqual, 1)
+			So(wm.marks["AAPL"].Through, ShouldEqual, start.Add(3*time.Minute))
+			So(wm.marks["AAPL"].LastBarTime, ShouldEqual, bar(3))
+
+			So(wt.complete(ctx, "AAPL", 3, bar(7)), ShouldBeNil)
+			So(len(wm.saves), ShouldEqual, 1)
+			So(wt.targets(2), ShouldResemble, []string{"AAPL"})
+		})
+
+		Convey("TestWatermarkTracker should advance to the last bar saved when the final window returns fewer bars than it covers", func() {
+			So(wt.complete(ctx, "AAPL", 0, bar(1)), ShouldBeNil)
+			So(wt.complete(ctx, "AAPL", 1, bar(2)), ShouldBeNil)
+			So(wm.marks["AAPL"].Through, ShouldEqual, start.Add(2*time.Minute))
+			So(resumeFrom(start, wm.marks), ShouldEqual, start.Add(2*time.Minute+time.Millisecond))
+
+			So(wt.complete(ctx, "AAPL", 2, 0), ShouldBeNil)
+			So(len(wm.saves), ShouldEqual, 2)
+		})
+
+		Convey("TestWatermarkTracker should leave a bar that may still be open for the next run", func() {
+			wt = newWatermarkTracker(wm, windows, map[string]models.Watermark{"AAPL": {CompanyName: "AAPL"}}, time.Minute, func() time.Time {
+				return start.Add(90 * time.Second)
+			})
+
+			So(wt.complete(ctx, "AAPL", 0, bar(1)), ShouldBeNil)
+			So(wm.marks["AAPL"].Through, ShouldEqual, start.Add(time.Minute-time.Millisecond))
+			So(wm.marks["AAPL"].LastBarTime, ShouldEqual, bar(1))
+			So(resumeFrom(start, wm.marks), ShouldEqual, start.Add(time.Minute))
+		})
+
+		Convey("TestWatermarkTracker should skip windows a ticker has already saved", func() {
+			wt = newWatermarkTracker(wm, windows, map[string]models.Watermark{
+				"AAPL": {CompanyName: "AAPL", Through: windows[1].To},
+				"AMZN": {CompanyName: "AMZN"},
+			}, time.Minute, now)
+			So(wt.targets(1), ShouldResemble, []string{"AMZN"})
+			So(wt.targets(2), ShouldResemble, []string{"AAPL", "AMZN"})
+
+			So(wt.complete(ctx, "AAPL", 2, bar(5)), ShouldBeNil)
+			So(wm.marks["AAPL"].Through, ShouldEqual, start.Add(5*time.Minute))
+		})
+
+		Convey("TestWatermarkTracker should fetch again a window its watermark falls inside", func() {
+			wt = newWatermarkTracker(wm, windows, map[string]models.Watermark{"AAPL": {CompanyName: "AAPL", Through: start.Add(2 * time.Minute)}}, time.Minute, now)
+			So(wt.targets(0), ShouldBeEmpty)
+			So(wt.targets(1), ShouldResemble, []string{"AAPL"})
+		})
+
+		Convey("TestWatermarkTracker should resume after the earliest watermark", func() {
+			marks := map[string]models.Watermark{"AAPL": {Through: windows[2].To}, "AMZN": {Through: windows[1].To}}
+			So(resumeFrom(start, marks), ShouldEqual, windows[1].To.Add(time.Millisecond))
+			marks["MSFT"] = models.Watermark{}
+			So(resumeFrom(start, marks), ShouldEqual, start)
+		})
+	})
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/greenac/chaching/internal/calendar"
+	"github.com/greenac/chaching/internal/database/service"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/service/gaps"
+	"github.com/greenac/chaching/internal/service/logger"
+	"github.com/greenac/chaching/internal/worker"
+	"time"
+)
+
+type GapsController struct {
+	Calendar        calendar.ICalendar
+	DatabaseService service.IDatabaseService
+	FetchController *FetchController
+	Watermarks      service.IWatermarkService // when set, bars a ticker's watermark has passed are counted as empty, not missing
+	Logger          logger.ILogger
+}
+
+// BackfillReport summarizes a backfill of the gaps found by FindGaps
+type BackfillReport struct {
+	Gaps       int
+	Filled     int
+	Failed     int
+	DataPoints int
+}
+
+func (r BackfillReport) String() string {
+	return fmt.Sprintf("refetched %d of %d gaps with %d data points, %d failed", r.Filled, r.Gaps, r.DataPoints, r.Failed)
+}
+
+// FindGaps lists the missing bars of each target for every trading session between start and end, bars step apart
+// in the series of fp. Only days with missing bars are returned
+func (gc *GapsController) FindGaps(ctx context.Context, fp FetchParams, targets []string, start time.Time, end time.Time, step time.Duration) ([]gaps.DayGaps, []genErr.IGenError) {
+	var days []gaps.DayGaps
+	var errs []genErr.IGenError
+	for _, name := range targets {
+		var fetchedThrough time.Time
+		if gc.Watermarks != nil {
+			w, ok, ge := gc.Watermarks.GetWatermark(ctx, name, fp.Timespan, fp.TimespanMultiplier, fp.Adjusted)
+			if ge != nil {
+				errs = append(errs, ge.AddMsg("GapsController:FindGaps:failed to get watermark for: "+name))
+				continue
+			}
+
+			if ok {
+				fetchedThrough = w.Through
+			}
+		}
+
+		for _, s := range gc.Calendar.Sessions(start, end) {
+			if ctx.Err() != nil {
+				return days, append(errs, &genErr.GenError{Messages: []string{"GapsController:FindGaps:cancelled with: " + ctx.Err().Error()}})
+			}
+
+			dps, ge := gc.DatabaseService.GetDataPointsInTimeRange(ctx, name, gaps.FirstBar(s, step), s.Close)
+			if ge != nil {
+				errs = append(errs, ge.AddMsg("GapsController:FindGaps:failed to get data points for: "+name+" on: "+s.Date.Format("2006-01-02")))
+				continue
+			}
+
+			dg := gaps.FindGaps(name, s, step, dps, fetchedThrough)
+			if len(dg.Gaps) > 0 {
+				days = append(days, dg)
+			}
+		}
+	}
+
+	return days, errs
+}
+
+// Backfill refetches only the missing windows through FetchController.FetchTargets and saves what it finds
+func (gc *GapsController) Backfill(ctx context.Context, fp FetchParams, days []gaps.DayGaps) (BackfillReport, []genErr.IGenError) {
+	report := BackfillReport{}
+
+	msgChan := make(chan worker.Message[FetchTaskResult])
+	wrkr := worker.NewWorker(gc.FetchController.concurrency(), gc.FetchController.concurrency(), msgChan)
+	wrkr.Work()
+
+	go func() {
+		defer wrkr.Close()
+		for _, dg := range days {
+			for _, g := range dg.Gaps {
+				tp := FetchTargetParams{FetchParams: fp, CompanyName: dg.CompanyName, From: g.From, To: g.To}
+				task := func() FetchTaskResult {
+					if ctx.Err() != nil {
+						return FetchTaskResult{Skipped: true}
+					}
+
+					dps, _, ge := gc.FetchController.FetchTargets(ctx, tp)
+					if ge != nil {
+						return FetchTaskResult{Errors: &[]genErr.IGenError{ge}}
+					}
+
+					return FetchTaskResult{DataPoints: dps, Errors: gc.DatabaseService.SaveDataPoints(ctx, dps)}
+				}
+
+				if err := wrkr.Submit(ctx, task); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for _, dg := range days {
+		report.Gaps += len(dg.Gaps)
+	}
+
+	var errors []genErr.IGenError
+	for msg := range msgChan {
+		switch {
+		case msg.Panic != nil:
+			report.Failed += 1
+			errors = append(errors, &genErr.GenError{Messages: []string{fmt.Sprintf("GapsController:Backfill:backfill task panicked with: %+v", msg.Panic)}})
+		case msg.Result.Skipped:
+		case msg.Result.Errors != nil && len(*msg.Result.Errors) > 0:
+			report.Failed += 1
+			errors = append(errors, *msg.Result.Errors...)
+		default:
+			report.Filled += 1
+			report.DataPoints += len(msg.Result.DataPoints)
+		}
+	}
+
+	if skipped := report.Gaps - report.Filled - report.Failed; skipped > 0 {
+		gc.Logger.Warn(fmt.Sprintf("GapsController:Backfill:skipped %d gaps after the backfill was cancelled", skipped))
+	}
+
+	return report, errors
+}
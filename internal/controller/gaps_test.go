@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/greenac/chaching/internal/calendar"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/rest/mocks"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/fetch"
+	"github.com/greenac/chaching/internal/service/gaps"
+	logMocks "github.com/greenac/chaching/internal/service/logger/mocks"
+	"github.com/greenac/chaching/internal/utils"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type storedDataPointsMock struct {
+	mu    sync.Mutex
+	dps   map[string][]models.DataPoint
+	saved []models.DataPoint
+}
+
+func (dm *storedDataPointsMock) SaveDataPoints(ctx context.Context, dps []models.DataPoint) *[]genErr.IGenError {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.saved = append(dm.saved, dps...)
+	return nil
+}
+
+func (dm *storedDataPointsMock) GetDataPointsInTimeRange(ctx context.Context, companyName string, startDate time.Time, endDate time.Time) ([]models.DataPoint, genErr.IGenError) {
+	var dps []models.DataPoint
+	for _, dp := range dm.dps[companyName] {
+		if dp.StartTime >= startDate.UnixMilli() && dp.StartTime <= endDate.UnixMilli() {
+			dps = append(dps, dp)
+		}
+	}
+	return dps, nil
+}
+
+func TestGapsController(t *testing.T) {
+	Convey("TestGapsController", t, func() {
+		cal := calendar.NewNyseCalendar()
+		day := time.Date(2023, 3, 1, 0, 0, 0, 0, cal.Location())
+		session, _ := cal.Session(day)
+
+		first := session.Open.Truncate(time.Hour)
+		missing := first.Add(2 * time.Hour)
+
+		var stored []models.DataPoint
+		for t := first; t.Before(session.Close); t = t.Add(time.Hour) {
+			if !t.Equal(missing) {
+				stored = append(stored, models.DataPoint{CompanyName: "AAPL", PolygonDataPoint: model.PolygonDataPoint{StartTime: t.UnixMilli()}})
+			}
+		}
+
+		dbs := &storedDataPointsMock{dps: map[string][]models.DataPoint{"AAPL": stored}}
+		c := mocks.ClientMock{GetResponse: aggregateResponse("", missing.UnixMilli())}
+		gc := GapsController{
+			Calendar:        cal,
+			DatabaseService: dbs,
+			Logger:          &logMocks.LoggerMock{},
+			FetchController: &FetchController{
+				FetchService: fetch.NewFetchService("https://api.polygon.io", &c, utils.JoinUrl),
+				Logger:       &logMocks.LoggerMock{},
+				Unmarshaler:  json.Unmarshal,
+			},
+		}
+
+		fp := FetchParams{TimespanMultiplier: 1, Limit: 100, Timespan: model.PolygonAggregateTimespanHour}
+
+		Convey("TestGapsController should find the missing bars of each trading day", func() {
+			days, errs := gc.FindGaps(context.Background(), fp, []string{"AAPL"}, day, day.AddDate(0, 0, 4), time.Hour)
+			So(errs, ShouldBeEmpty)
+			So(len(days), ShouldEqual, 3)
+			So(days[0], ShouldResemble, gaps.DayGaps{
+				CompanyName: "AAPL",
+				Date:        "2023-03-01",
+				Expected:    7,
+				Found:       6,
+				Gaps:        []gaps.Gap{{From: missing, To: missing, Bars: 1}},
+			})
+			So(days[1].Date, ShouldEqual, "2023-03-02")
+			So(days[2].Date, ShouldEqual, "2023-03-03")
+		})
+
+		Convey("TestGapsController should not report bars the watermark has passed", func() {
+			gc.Watermarks = &watermarkServiceMock{marks: map[string]models.Watermark{
+				"AAPL": {CompanyName: "AAPL", Timespan: model.PolygonAggregateTimespanHour, Multiplier: 1, Through: session.Close},
+			}}
+
+			days, errs := gc.FindGaps(context.Background(), fp, []string{"AAPL"}, day, day.AddDate(0, 0, 4), time.Hour)
+			So(errs, ShouldBeEmpty)
+			So(len(days), ShouldEqual, 2)
+			So(days[0].Date, ShouldEqual, "2023-03-02")
+		})
+
+		Convey("TestGapsController should refetch only the missing windows", func() {
+			days, _ := gc.FindGaps(context.Background(), fp, []string{"AAPL"}, day, day, time.Hour)
+
+			report, errs := gc.Backfill(context.Background(), fp, days)
+			So(errs, ShouldBeEmpty)
+			So(report, ShouldResemble, BackfillReport{Gaps: 1, Filled: 1, DataPoints: 1})
+			So(len(c.GetUrls), ShouldEqual, 1)
+			So(c.GetUrls[0], ShouldContainSubstring, model.PolygonAggregateRequestParams{
+				CompanyName:   "AAPL",
+				Multiplier:    1,
+				Timespan:      model.PolygonAggregateTimespanHour,
+				From:          missing,
+				To:            missing,
+				SortDirection: SortDirection,
+				Limit:         100,
+			}.Url())
+			So(dbs.saved[0].StartTime, ShouldEqual, missing.UnixMilli())
+		})
+	})
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/greenac/chaching/internal/calendar"
+	"github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/fetch"
+	"github.com/greenac/chaching/internal/service/logger"
+	"github.com/greenac/chaching/internal/worker"
+	"strings"
+	"time"
+)
+
+type GroupedDailyParams struct {
+	Adjusted   bool
+	IncludeOtc bool
+}
+
+// GroupedDailyReport summarizes the days fetched by SyncGroupedDaily
+type GroupedDailyReport struct {
+	Days       int
+	Completed  int
+	Failed     int
+	DataPoints int
+}
+
+func (r GroupedDailyReport) String() string {
+	return fmt.Sprintf("completed %d of %d days with %d data points, %d failed", r.Completed, r.Days, r.DataPoints, r.Failed)
+}
+
+// GroupedDailyController keeps a whole market daily history with one request per trading day, using polygon's
+// grouped daily aggregates. Its FetchService must point at the `/v2/aggs` base url
+type GroupedDailyController struct {
+	Calendar        calendar.ICalendar
+	Concurrency     int
+	FetchService    fetch.IFetchService
+	DatabaseService service.IDatabaseService
+	Logger          logger.ILogger
+	Unmarshaler     func(data []byte, v any) error
+}
+
+// SyncGroupedDaily fetches and saves the day bar of every ticker for each trading day between start and end
+func (gc *GroupedDailyController) SyncGroupedDaily(ctx context.Context, start time.Time, end time.Time, gp GroupedDailyParams) (GroupedDailyReport, []genErr.IGenError) {
+	sessions := gc.calendar().Sessions(start, end)
+	report := GroupedDailyReport{Days: len(sessions)}
+
+	concurrency := gc.Concurrency
+	if concurrency < 1 {
+		concurrency = ConcurrencyCount
+	}
+
+	msgChan := make(chan worker.Message[FetchTaskResult])
+	wrkr := worker.NewWorker(concurrency, concurrency, msgChan)
+	wrkr.Work()
+
+	go func() {
+		defer wrkr.Close()
+		for _, s := range sessions {
+			day := s.Date
+			task := func() FetchTaskResult {
+				if ctx.Err() != nil {
+					return FetchTaskResult{Skipped: true}
+				}
+
+				dps, ge := gc.FetchGroupedDaily(ctx, day, gp)
+				if ge != nil {
+					return FetchTaskResult{Errors: &[]genErr.IGenError{ge}}
+				}
+
+				return FetchTaskResult{DataPoints: dps, Errors: gc.DatabaseService.SaveDataPoints(ctx, dps)}
+			}
+
+			if err := wrkr.Submit(ctx, task); err != nil {
+				return
+			}
+		}
+	}()
+
+	var errors []genErr.IGenError
+	for msg := range msgChan {
+		switch {
+		case msg.Panic != nil:
+			report.Failed += 1
+			errors = append(errors, &genErr.GenError{Messages: []string{fmt.Sprintf("GroupedDailyController:SyncGroupedDaily:task panicked with: %+v", msg.Panic)}})
+		case msg.Result.Skipped:
+		case msg.Result.Errors != nil && len(*msg.Result.Errors) > 0:
+			report.Failed += 1
+			errors = append(errors, *msg.Result.Errors...)
+		default:
+			report.Completed += 1
+			report.DataPoints += len(msg.Result.DataPoints)
+		}
+	}
+
+	if skipped := report.Days - report.Completed - report.Failed; skipped > 0 {
+		gc.Logger.Warn(fmt.Sprintf("GroupedDailyController:SyncGroupedDaily:skipped %d days after the sync was cancelled", skipped))
+	}
+
+	return report, errors
+}
+
+// FetchGroupedDaily fetches the day bar of every ticker for day, fanned out into one data point per ticker
+func (gc *GroupedDailyController) FetchGroupedDaily(ctx context.Context, day time.Time, gp GroupedDailyParams) ([]models.DataPoint, genErr.IGenError) {
+	rps := model.PolygonGroupedDailyRequestParams{Date: day, Adjusted: gp.Adjusted, IncludeOtc: gp.IncludeOtc}
+
+	body, ge := gc.FetchService.FetchWithFetchData(ctx, rps)
+	if ge != nil {
+		return []models.DataPoint{}, ge.AddMsg("GroupedDailyController:FetchGroupedDaily:failed to fetch: " + day.Format(dayLayout))
+	}
+
+	pr := model.PolygonGroupedDailyResponse{}
+	if err := gc.Unmarshaler(body, &pr); err != nil {
+		return []models.DataPoint{}, &genErr.GenError{Messages: []string{"GroupedDailyController:FetchGroupedDaily:failed to unmarshal with error: " + err.Error() + " for: " + day.Format(dayLayout)}}
+	}
+
+	if strings.ToLower(pr.Status) != "ok" {
+		return []models.DataPoint{}, &genErr.GenError{Messages: []string{"GroupedDailyController:FetchGroupedDaily:failed for: " + day.Format(dayLayout) + " with status: " + pr.Status}}
+	}
+
+	// grouped bars are stamped at the close, range day bars at midnight in new york. Both are stored at midnight
+	// so a day fetched both ways is one bar
+	local := day.In(gc.calendar().Location())
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	if s, ok := gc.calendar().Session(day); ok {
+		start = s.Date
+	}
+
+	dps := make([]models.DataPoint, 0, len(pr.DataPoints))
+	for _, gdp := range pr.DataPoints {
+		if gdp.Ticker == "" {
+			continue
+		}
+
+		dp := models.DataPoint{CompanyName: gdp.Ticker, Timespan: model.PolygonAggregateTimespanDay, Adjusted: gp.Adjusted, PolygonDataPoint: gdp.PolygonDataPoint}
+		dp.StartTime = start.UnixMilli()
+		dps = append(dps, dp)
+	}
+
+	gc.Logger.Info(fmt.Sprintf("GroupedDailyController:FetchGroupedDaily:fetched %d day bars for: %s", len(dps), day.Format(dayLayout)))
+
+	return dps, nil
+}
+
+func (gc *GroupedDailyController) calendar() calendar.ICalendar {
+	if gc.Calendar != nil {
+		return gc.Calendar
+	}
+
+	return calendar.NewNyseCalendar()
+}
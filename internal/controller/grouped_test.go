@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/greenac/chaching/internal/calendar"
+	dbMocks "github.com/greenac/chaching/internal/database/mocks"
+	"github.com/greenac/chaching/internal/database/models"
+	dbService "github.com/greenac/chaching/internal/database/service"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/rest/mocks"
+	restModels "github.com/greenac/chaching/internal/rest/models"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/database"
+	"github.com/greenac/chaching/internal/service/fetch"
+	logMocks "github.com/greenac/chaching/internal/service/logger/mocks"
+	"github.com/greenac/chaching/internal/utils"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGroupedDailyController_FetchGroupedDaily(t *testing.T) {
+	Convey("TestGroupedDailyController_FetchGroupedDaily", t, func() {
+		cal := calendar.NewNyseCalendar()
+		day := time.Date(2023, 3, 1, 0, 0, 0, 0, cal.Location())
+		c := mocks.ClientMock{GetResponses: []restModels.Response{fixtureResponse("grouped_daily_2023-03-01.json")}}
+		gc := GroupedDailyController{Calendar: cal, FetchService: fetch.NewFetchService("https://api.polygon.io/v2/aggs", &c, utils.JoinUrl), Logger: &logMocks.LoggerMock{}, Unmarshaler: json.Unmarshal}
+
+		dps, ge := gc.FetchGroupedDaily(context.Background(), day, GroupedDailyParams{Adjusted: true})
+		So(ge, ShouldBeNil)
+		So(c.GetUrls, ShouldResemble, []string{"https://api.polygon.io/v2/aggs/grouped/locale/us/market/stocks/2023-03-01?adjusted=true&include_otc=false"})
+		So(len(dps), ShouldEqual, 3)
+		So(dps[1], ShouldResemble, models.DataPoint{
+			CompanyName: "MSFT",
+			Timespan:    model.PolygonAggregateTimespanDay,
+			Adjusted:    true,
+			PolygonDataPoint: model.PolygonDataPoint{
+				ClosePrice:          246.27,
+				HighestPrice:        250.93,
+				LowestPrice:         245.79,
+				NumOfTxs:            379442,
+				OpenPrice:           250.76,
+				StartTime:           day.UnixMilli(),
+				Volume:              28021463,
+				VolumeWeightedPrice: 247.0842,
+			},
+		})
+		So(dps[1].DatabaseModel().Pk, ShouldEqual, "type#dataPoint#adjusted#name#MSFT#timespan#day")
+
+		Convey("TestGroupedDailyController_FetchGroupedDaily should store the same item as a range day bar", func() {
+			fake := dbMocks.NewDynamoFake(dbMocks.ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+			dbs := dbService.NewDatabaseService(database.NewDatabase[models.DbDataPoint](fake, 25, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap))
+
+			ranged := models.DataPoint{CompanyName: "MSFT", Timespan: model.PolygonAggregateTimespanDay, Adjusted: true, PolygonDataPoint: model.PolygonDataPoint{StartTime: day.UnixMilli(), ClosePrice: 246.27}}
+			So(dbs.SaveDataPoints(context.Background(), []models.DataPoint{ranged}), ShouldBeNil)
+			So(dbs.SaveDataPoints(context.Background(), dps[1:2]), ShouldBeNil)
+			So(len(fake.Items("Chaching")), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestGroupedDailyController_SyncGroupedDaily(t *testing.T) {
+	Convey("TestGroupedDailyController_SyncGroupedDaily", t, func() {
+		cal := calendar.NewNyseCalendar()
+		dbs := &databaseServiceMock{}
+
+		Convey("TestGroupedDailyController_SyncGroupedDaily should make one request per trading day", func() {
+			c := mocks.ClientMock{GetResponse: fixtureResponse("grouped_daily_2023-03-01.json")}
+			gc := GroupedDailyController{Calendar: cal, FetchService: fetch.NewFetchService("https://api.polygon.io/v2/aggs", &c, utils.JoinUrl), DatabaseService: dbs, Logger: &logMocks.LoggerMock{}, Unmarshaler: json.Unmarshal}
+
+			// Mar 3 2023 is a friday, so the weekend is skipped
+			start := time.Date(2023, 3, 2, 0, 0, 0, 0, cal.Location())
+			report, errs := gc.SyncGroupedDaily(context.Background(), start, start.AddDate(0, 0, 4), GroupedDailyParams{})
+			So(errs, ShouldBeEmpty)
+			So(report, ShouldResemble, GroupedDailyReport{Days: 3, Completed: 3, DataPoints: 9})
+			So(len(c.GetUrls), ShouldEqual, 3)
+			So(len(dbs.saved), ShouldEqual, 9)
+		})
+
+		Convey("TestGroupedDailyController_SyncGroupedDaily should count failed days", func() {
+			c := mocks.ClientMock{GetError: &genErr.GenError{Messages: []string{"bad bad thing"}}}
+			gc := GroupedDailyController{Calendar: cal, FetchService: fetch.NewFetchService("https://api.polygon.io/v2/aggs", &c, utils.JoinUrl), DatabaseService: dbs, Logger: &logMocks.LoggerMock{}, Unmarshaler: json.Unmarshal}
+
+			day := time.Date(2023, 3, 1, 0, 0, 0, 0, cal.Location())
+			report, errs := gc.SyncGroupedDaily(context.Background(), day, day.Add(time.Hour), GroupedDailyParams{})
+			So(report, ShouldResemble, GroupedDailyReport{Days: 1, Failed: 1})
+			So(errs[0].Error(), ShouldEqual, "bad bad thing->FetchService:Fetch:failed to get->GroupedDailyController:FetchGroupedDaily:failed to fetch: 2023-03-01")
+		})
+	})
+}
@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/greenac/chaching/internal/calendar"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"strings"
+	"time"
+)
+
+// FetchPlanner turns a date range into the windows a fetch requests, each sized to come back in a single response.
+// Windows start at a session's open and end at a later session's close, joining sessions while their bars fit. An
+// intraday window that spans sessions also returns the extended hours bars between them, which count against the
+// limit like any other bar and are dropped before saving. Day and longer windows run from midnight of their first
+// trading day. Polygon's limit counts the base aggregates a response is built from, so a window holds
+// limit/multiplier bars
+type FetchPlanner struct {
+	Calendar   calendar.ICalendar
+	Timespan   model.PolygonAggregateTimespan
+	Multiplier int
+	Limit      int           // most base aggregates a single response is built from
+	MaxWindow  time.Duration // caps how much time a window covers, 0 leaves windows as long as the limit allows
+}
+
+const (
+	preMarketOpen = 4 * time.Hour // polygon's intraday bars start at 04:00 on a trading day
+	afterHours    = 4 * time.Hour // and run until four hours after the close
+)
+
+// FetchPlan is the windows a fetch requests, in time order
+type FetchPlan struct {
+	Timespan   model.PolygonAggregateTimespan
+	Multiplier int
+	Limit      int
+	Windows    []FetchWindow
+}
+
+// Bars returns the most bars the plan can fetch
+func (p FetchPlan) Bars() int {
+	bars := 0
+	for _, w := range p.Windows {
+		bars += w.Bars
+	}
+
+	return bars
+}
+
+func (p FetchPlan) String() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%d windows of %d %s bars with up to %d bars each, at most %d bars", len(p.Windows), p.Multiplier, p.Timespan, p.maxBars(), p.Bars()))
+	for i, w := range p.Windows {
+		b.WriteString(fmt.Sprintf("\n%5d  %s  %s  %6d bars", i+1, w.From.Format(time.RFC3339), w.To.Format(time.RFC3339), w.Bars))
+	}
+
+	return b.String()
+}
+
+// maxBars returns the most bars a window of the plan holds
+func (p FetchPlan) maxBars() int {
+	return FetchPlanner{Multiplier: p.Multiplier, Limit: p.Limit}.maxBars()
+}
+
+// Plan returns the windows covering the trading sessions between start and end. Window ends are inclusive and one
+// millisecond before the next window's start, matching polygon's inclusive `to`
+func (fp FetchPlanner) Plan(start time.Time, end time.Time) FetchPlan {
+	plan := FetchPlan{Timespan: fp.Timespan, Multiplier: fp.multiplier(), Limit: fp.Limit}
+
+	var current *FetchWindow
+	for _, seg := range fp.segments(start, end) {
+		if current != nil {
+			joined := FetchWindow{From: current.From, To: seg.To}
+			if joined.Bars = fp.bars(joined.From, joined.To); fp.fits(joined) {
+				*current = joined
+				continue
+			}
+
+			plan.Windows = append(plan.Windows, *current)
+			current = nil
+		}
+
+		chunks := fp.split(seg)
+		plan.Windows = append(plan.Windows, chunks[:len(chunks)-1]...)
+		last := chunks[len(chunks)-1]
+		current = &last
+	}
+
+	if current != nil {
+		plan.Windows = append(plan.Windows, *current)
+	}
+
+	return plan
+}
+
+// segments returns the part of each session between start and end that bars start in
+func (fp FetchPlanner) segments(start time.Time, end time.Time) []FetchWindow {
+	var segs []FetchWindow
+	for _, s := range fp.Calendar.Sessions(start, end) {
+		seg := FetchWindow{From: s.Open, To: s.Close.Add(-time.Millisecond)}
+		if fp.intraday() {
+			if start.After(seg.From) {
+				seg.From = start
+			}
+		} else {
+			seg = FetchWindow{From: s.Date, To: s.Date.AddDate(0, 0, 1).Add(-time.Millisecond)}
+		}
+
+		if end.Before(seg.To) {
+			seg.To = end
+		}
+
+		if seg.To.Before(seg.From) {
+			continue
+		}
+
+		seg.Bars = fp.bars(seg.From, seg.To)
+		segs = append(segs, seg)
+	}
+
+	return segs
+}
+
+// split breaks an intraday segment too big for one window into back to back windows
+func (fp FetchPlanner) split(seg FetchWindow) []FetchWindow {
+	if fp.fits(seg) || !fp.intraday() {
+		return []FetchWindow{seg}
+	}
+
+	step := time.Duration(fp.maxBars()) * fp.barDuration()
+	if fp.MaxWindow > 0 && fp.MaxWindow < step {
+		step = fp.MaxWindow
+	}
+
+	var chunks []FetchWindow
+	for from := seg.From; !from.After(seg.To); from = from.Add(step) {
+		c := FetchWindow{From: from, To: from.Add(step - time.Millisecond)}
+		if c.To.After(seg.To) {
+			c.To = seg.To
+		}
+
+		c.Bars = fp.bars(c.From, c.To)
+		chunks = append(chunks, c)
+	}
+
+	return chunks
+}
+
+func (fp FetchPlanner) fits(w FetchWindow) bool {
+	return w.Bars <= fp.maxBars() && (fp.MaxWindow <= 0 || w.To.Sub(w.From) < fp.MaxWindow)
+}
+
+// bars returns the most bars polygon can return from `from` through `to`. Intraday bars are counted over the
+// extended hours of the trading days in the span, longer bars by the periods the trading days fall in
+func (fp FetchPlanner) bars(from time.Time, to time.Time) int {
+	if fp.intraday() {
+		bars := 0
+		for _, s := range fp.Calendar.Sessions(from, to) {
+			start, end := s.Date.Add(preMarketOpen), s.Close.Add(afterHours-time.Millisecond)
+			if from.After(start) {
+				start = from
+			}
+
+			if to.Before(end) {
+				end = to
+			}
+
+			if !end.Before(start) {
+				bars += int(end.Sub(start)/fp.barDuration()) + 1
+			}
+		}
+
+		return bars
+	}
+
+	periods := map[string]bool{}
+	for _, s := range fp.Calendar.Sessions(from, to) {
+		periods[fp.period(s.Date)] = true
+	}
+
+	bars := (len(periods) + fp.multiplier() - 1) / fp.multiplier()
+	if fp.multiplier() > 1 {
+		// multi period bars are not aligned to the window, so it can straddle one more
+		bars += 1
+	}
+
+	return bars
+}
+
+func (fp FetchPlanner) period(day time.Time) string {
+	switch fp.Timespan {
+	case model.PolygonAggregateTimespanWeek:
+		y, w := day.ISOWeek()
+		return fmt.Sprintf("%d-w%d", y, w)
+	case model.PolygonAggregateTimespanMonth:
+		return day.Format("2006-01")
+	case model.PolygonAggregateTimespanQuarter:
+		return fmt.Sprintf("%d-q%d", day.Year(), (int(day.Month())-1)/3)
+	case model.PolygonAggregateTimespanYear:
+		return day.Format("2006")
+	}
+
+	return day.Format("2006-01-02")
+}
+
+func (fp FetchPlanner) intraday() bool {
+	switch fp.Timespan {
+	case model.PolygonAggregateTimespanSecond, model.PolygonAggregateTimespanHour, model.PolygonAggregateTimespanMinute, "":
+		return true
+	}
+
+	return false
+}
+
+func (fp FetchPlanner) barDuration() time.Duration {
+	unit := time.Minute
+	switch fp.Timespan {
+	case model.PolygonAggregateTimespanSecond:
+		unit = time.Second
+	case model.PolygonAggregateTimespanHour:
+		unit = time.Hour
+	}
+
+	return time.Duration(fp.multiplier()) * unit
+}
+
+func (fp FetchPlanner) multiplier() int {
+	if fp.Multiplier > 0 {
+		return fp.Multiplier
+	}
+
+	return 1
+}
+
+// maxBars returns the most bars of the planner's size that fit in one response
+func (fp FetchPlanner) maxBars() int {
+	if bars := fp.Limit / fp.multiplier(); bars > 0 {
+		return bars
+	}
+
+	return 1
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"github.com/greenac/chaching/internal/calendar"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFetchPlanner_Plan(t *testing.T) {
+	Convey("TestFetchPlanner_Plan", t, func() {
+		cal := calendar.NewNyseCalendar()
+		at := func(y int, m time.Month, d, h, min int) time.Time {
+			return time.Date(y, m, d, h, min, 0, 0, cal.Location())
+		}
+		fp := FetchPlanner{Calendar: cal, Timespan: model.PolygonAggregateTimespanMinute, Multiplier: 1, Limit: 50000}
+
+		Convey("TestFetchPlanner_Plan should join sessions into one window while the bars fit the limit", func() {
+			// the extended hours after the open on the 6th and before the close on the 10th, good friday is a holiday
+			plan := fp.Plan(at(2023, 4, 6, 0, 0), at(2023, 4, 10, 23, 59))
+			So(plan.Windows, ShouldResemble, []FetchWindow{{From: at(2023, 4, 6, 9, 30), To: at(2023, 4, 10, 16, 0).Add(-time.Millisecond), Bars: 630 + 720}})
+		})
+
+		Convey("TestFetchPlanner_Plan should cover a year of minute bars in a handful of windows", func() {
+			plan := fp.Plan(at(2023, 1, 1, 0, 0), at(2023, 12, 31, 23, 59))
+			So(len(plan.Windows), ShouldBeLessThan, 10)
+			So(plan.Windows[0].From, ShouldEqual, at(2023, 1, 3, 9, 30))
+			for _, w := range plan.Windows {
+				So(w.Bars, ShouldBeLessThanOrEqualTo, fp.Limit)
+			}
+		})
+
+		Convey("TestFetchPlanner_Plan should count the extended hours between the sessions it joins", func() {
+			fp.Limit = 2000
+			plan := fp.Plan(at(2023, 3, 1, 0, 0), at(2023, 3, 3, 23, 59))
+			So(plan.Windows, ShouldResemble, []FetchWindow{
+				{From: at(2023, 3, 1, 9, 30), To: at(2023, 3, 2, 16, 0).Add(-time.Millisecond), Bars: 630 + 720},
+				{From: at(2023, 3, 3, 9, 30), To: at(2023, 3, 3, 16, 0).Add(-time.Millisecond), Bars: 390},
+			})
+		})
+
+		Convey("TestFetchPlanner_Plan should divide the limit by the multiplier", func() {
+			fp.Multiplier = 5
+			fp.Limit = 50
+			plan := fp.Plan(at(2023, 3, 1, 0, 0), at(2023, 3, 1, 23, 59))
+			So(len(plan.Windows), ShouldEqual, 8)
+			So(plan.Windows[0], ShouldResemble, FetchWindow{From: at(2023, 3, 1, 9, 30), To: at(2023, 3, 1, 10, 20).Add(-time.Millisecond), Bars: 10})
+			So(plan.Bars(), ShouldEqual, 78)
+		})
+
+		Convey("TestFetchPlanner_Plan should split a session holding more bars than the limit", func() {
+			fp.Limit = 100
+			plan := fp.Plan(at(2023, 3, 1, 0, 0), at(2023, 3, 1, 23, 59))
+			So(len(plan.Windows), ShouldEqual, 4)
+			So(plan.Windows[1], ShouldResemble, FetchWindow{From: at(2023, 3, 1, 11, 10), To: at(2023, 3, 1, 12, 50).Add(-time.Millisecond), Bars: 100})
+			So(plan.Windows[3], ShouldResemble, FetchWindow{From: at(2023, 3, 1, 14, 30), To: at(2023, 3, 1, 16, 0).Add(-time.Millisecond), Bars: 90})
+			So(plan.Bars(), ShouldEqual, 390)
+		})
+
+		Convey("TestFetchPlanner_Plan should give each session of second bars its own window", func() {
+			fp.Timespan = model.PolygonAggregateTimespanSecond
+			plan := fp.Plan(at(2023, 3, 1, 0, 0), at(2023, 3, 2, 23, 59))
+			So(len(plan.Windows), ShouldEqual, 2)
+			So(plan.Windows[0].Bars, ShouldEqual, 23400)
+			So(plan.Windows[1].From, ShouldEqual, at(2023, 3, 2, 9, 30))
+		})
+
+		Convey("TestFetchPlanner_Plan should cover a year of day bars in one window", func() {
+			fp.Timespan = model.PolygonAggregateTimespanDay
+			plan := fp.Plan(at(2023, 1, 1, 0, 0), at(2023, 12, 31, 23, 59))
+			So(plan.Windows, ShouldResemble, []FetchWindow{{From: at(2023, 1, 3, 0, 0), To: at(2023, 12, 30, 0, 0).Add(-time.Millisecond), Bars: 250}})
+		})
+
+		Convey("TestFetchPlanner_Plan should keep windows within the max window", func() {
+			fp.MaxWindow = 24 * time.Hour
+			plan := fp.Plan(at(2023, 3, 1, 0, 0), at(2023, 3, 3, 23, 59))
+			So(len(plan.Windows), ShouldEqual, 3)
+			for _, w := range plan.Windows {
+				So(w.To.Sub(w.From), ShouldBeLessThan, fp.MaxWindow)
+			}
+		})
+
+		Convey("TestFetchPlanner_Plan should stop at the early close and start within the requested range", func() {
+			plan := fp.Plan(at(2023, 11, 24, 10, 0), at(2023, 11, 24, 23, 59))
+			So(plan.Windows, ShouldResemble, []FetchWindow{{From: at(2023, 11, 24, 10, 0), To: at(2023, 11, 24, 13, 0).Add(-time.Millisecond), Bars: 180}})
+		})
+
+		Convey("TestFetchPlanner_Plan should return windows in order that neither overlap nor exceed the limit", func() {
+			fp.Limit = 100
+			plan := fp.Plan(at(2023, 3, 1, 0, 0), at(2023, 3, 10, 23, 59))
+			So(plan.Bars(), ShouldEqual, 8*390)
+			for i, w := range plan.Windows {
+				So(w.Bars, ShouldBeLessThanOrEqualTo, fp.Limit)
+				if i > 0 {
+					So(w.From.After(plan.Windows[i-1].To), ShouldBeTrue)
+				}
+			}
+		})
+
+		Convey("TestFetchPlanner_Plan should print a row for each window", func() {
+			fp.Limit = 200
+			plan := fp.Plan(at(2023, 3, 1, 9, 30), at(2023, 3, 1, 13, 0))
+			So(plan.String(), ShouldEqual, "2 windows of 1 minute bars with up to 200 bars each, at most 211 bars\n"+
+				"    1  2023-03-01T09:30:00-05:00  2023-03-01T12:49:59-05:00     200 bars\n"+
+				"    2  2023-03-01T12:50:00-05:00  2023-03-01T13:00:00-05:00      11 bars")
+		})
+	})
+}
@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/greenac/chaching/internal/calendar"
+	"github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/fetch"
+	"github.com/greenac/chaching/internal/service/logger"
+	"strings"
+	"time"
+)
+
+const dayLayout = "2006-01-02"
+
+// TickLimit is the largest page polygon returns for trades and quotes
+const TickLimit = 50000
+
+type TickParams struct {
+	CompanyName string
+	From        time.Time
+	To          time.Time
+	Limit       int
+}
+
+type TickSyncParams struct {
+	Trades bool
+	Quotes bool
+	Limit  int
+}
+
+// TickSyncReport summarizes the ticks saved by SyncTicks
+type TickSyncReport struct {
+	Days   int
+	Trades int
+	Quotes int
+	Failed int
+}
+
+func (r TickSyncReport) String() string {
+	return fmt.Sprintf("saved %d trades and %d quotes over %d ticker days, %d failed", r.Trades, r.Quotes, r.Days, r.Failed)
+}
+
+// TicksController ingests tick level trades and NBBO quotes. Its FetchService must point at the `/v3` base url
+type TicksController struct {
+	Calendar     calendar.ICalendar
+	FetchService fetch.IFetchService
+	TickService  service.ITickService
+	Logger       logger.ILogger
+	Unmarshaler  func(data []byte, v any) error
+}
+
+// SyncTicks fetches and saves the ticks of each target for every trading day between start and end. Each day
+// covers the extended hours as well, from midnight to midnight in the exchange's time zone
+func (tc *TicksController) SyncTicks(ctx context.Context, targets []string, start time.Time, end time.Time, sp TickSyncParams) (TickSyncReport, []genErr.IGenError) {
+	report := TickSyncReport{}
+	var errs []genErr.IGenError
+	for _, s := range tc.calendar().Sessions(start, end) {
+		from := s.Date
+		if start.After(from) {
+			from = start
+		}
+
+		to := s.Date.AddDate(0, 0, 1)
+		if end.Before(to) {
+			to = end
+		}
+
+		if !to.After(from) {
+			continue
+		}
+
+		for _, name := range targets {
+			if ctx.Err() != nil {
+				return report, append(errs, &genErr.GenError{Messages: []string{"TicksController:SyncTicks:cancelled with: " + ctx.Err().Error()}})
+			}
+
+			report.Days += 1
+			tp := TickParams{CompanyName: name, From: from, To: to, Limit: sp.Limit}
+
+			if sp.Trades {
+				_, ge := tc.EachTradePage(ctx, tp, func(trades []models.Trade) genErr.IGenError {
+					report.Trades += len(trades)
+					return tc.TickService.SaveTrades(ctx, trades)
+				})
+				if ge != nil {
+					report.Failed += 1
+					errs = append(errs, ge.AddMsg("TicksController:SyncTicks:failed to sync trades for: "+name+" on: "+s.Date.Format(dayLayout)))
+					continue
+				}
+			}
+
+			if sp.Quotes {
+				_, ge := tc.EachQuotePage(ctx, tp, func(quotes []models.Quote) genErr.IGenError {
+					report.Quotes += len(quotes)
+					return tc.TickService.SaveQuotes(ctx, quotes)
+				})
+				if ge != nil {
+					report.Failed += 1
+					errs = append(errs, ge.AddMsg("TicksController:SyncTicks:failed to sync quotes for: "+name+" on: "+s.Date.Format(dayLayout)))
+				}
+			}
+		}
+	}
+
+	return report, errs
+}
+
+// FetchTrades fetches every trade of a company in [From, To) along with the number of pages fetched
+func (tc *TicksController) FetchTrades(ctx context.Context, tp TickParams) ([]models.Trade, int, genErr.IGenError) {
+	var trades []models.Trade
+	pages, ge := tc.EachTradePage(ctx, tp, func(page []models.Trade) genErr.IGenError {
+		trades = append(trades, page...)
+		return nil
+	})
+	if ge != nil {
+		return []models.Trade{}, pages, ge
+	}
+
+	return trades, pages, nil
+}
+
+// FetchQuotes fetches every quote of a company in [From, To) along with the number of pages fetched
+func (tc *TicksController) FetchQuotes(ctx context.Context, tp TickParams) ([]models.Quote, int, genErr.IGenError) {
+	var quotes []models.Quote
+	pages, ge := tc.EachQuotePage(ctx, tp, func(page []models.Quote) genErr.IGenError {
+		quotes = append(quotes, page...)
+		return nil
+	})
+	if ge != nil {
+		return []models.Quote{}, pages, ge
+	}
+
+	return quotes, pages, nil
+}
+
+// EachTradePage follows polygon's `next_url` cursor through a company's trades, passing each page to handle
+// so a busy day never has to be held in memory at once
+func (tc *TicksController) EachTradePage(ctx context.Context, tp TickParams, handle func(trades []models.Trade) genErr.IGenError) (int, genErr.IGenError) {
+	pages := 0
+	ge := fetchPages(ctx, tc.FetchService, tc.requestParams(model.PolygonTickTypeTrades, tp), func(body []byte) (string, genErr.IGenError) {
+		pr := model.PolygonTradesResponse{}
+		if err := tc.Unmarshaler(body, &pr); err != nil {
+			return "", &genErr.GenError{Messages: []string{"TicksController:EachTradePage:failed to unmarshal trades with error: " + err.Error()}}
+		}
+
+		if strings.ToLower(pr.Status) != "ok" {
+			return "", &genErr.GenError{Messages: []string{"TicksController:EachTradePage:trades failed with status: " + pr.Status}}
+		}
+
+		pages += 1
+		trades := make([]models.Trade, len(pr.Results))
+		for i, t := range pr.Results {
+			trades[i] = models.Trade{PolygonTrade: t, CompanyName: tp.CompanyName, Day: tc.day(t.SipTimestamp)}
+		}
+
+		if ge := handle(trades); ge != nil {
+			return "", ge.AddMsg(fmt.Sprintf("TicksController:EachTradePage:failed to handle page: %d", pages))
+		}
+
+		return pr.NextUrl, nil
+	})
+	if ge != nil {
+		return pages, ge.AddMsg("TicksController:EachTradePage:failed to fetch trades for: " + tp.CompanyName)
+	}
+
+	tc.Logger.Debug(fmt.Sprintf("TicksController:EachTradePage:fetched %d pages of trades for: %s", pages, tp.CompanyName))
+
+	return pages, nil
+}
+
+// EachQuotePage follows polygon's `next_url` cursor through a company's NBBO quotes, passing each page to handle
+func (tc *TicksController) EachQuotePage(ctx context.Context, tp TickParams, handle func(quotes []models.Quote) genErr.IGenError) (int, genErr.IGenError) {
+	pages := 0
+	ge := fetchPages(ctx, tc.FetchService, tc.requestParams(model.PolygonTickTypeQuotes, tp), func(body []byte) (string, genErr.IGenError) {
+		pr := model.PolygonQuotesResponse{}
+		if err := tc.Unmarshaler(body, &pr); err != nil {
+			return "", &genErr.GenError{Messages: []string{"TicksController:EachQuotePage:failed to unmarshal quotes with error: " + err.Error()}}
+		}
+
+		if strings.ToLower(pr.Status) != "ok" {
+			return "", &genErr.GenError{Messages: []string{"TicksController:EachQuotePage:quotes failed with status: " + pr.Status}}
+		}
+
+		pages += 1
+		quotes := make([]models.Quote, len(pr.Results))
+		for i, q := range pr.Results {
+			quotes[i] = models.Quote{PolygonQuote: q, CompanyName: tp.CompanyName, Day: tc.day(q.SipTimestamp)}
+		}
+
+		if ge := handle(quotes); ge != nil {
+			return "", ge.AddMsg(fmt.Sprintf("TicksController:EachQuotePage:failed to handle page: %d", pages))
+		}
+
+		return pr.NextUrl, nil
+	})
+	if ge != nil {
+		return pages, ge.AddMsg("TicksController:EachQuotePage:failed to fetch quotes for: " + tp.CompanyName)
+	}
+
+	tc.Logger.Debug(fmt.Sprintf("TicksController:EachQuotePage:fetched %d pages of quotes for: %s", pages, tp.CompanyName))
+
+	return pages, nil
+}
+
+func (tc *TicksController) requestParams(tt model.PolygonTickType, tp TickParams) model.PolygonTickRequestParams {
+	limit := tp.Limit
+	if limit <= 0 || limit > TickLimit {
+		limit = TickLimit
+	}
+
+	return model.PolygonTickRequestParams{Type: tt, Ticker: tp.CompanyName, From: tp.From, To: tp.To, Limit: limit}
+}
+
+// day returns the exchange day a tick's SIP timestamp falls on, which is the partition it is stored in
+func (tc *TicksController) day(sipTimestamp int64) string {
+	return time.Unix(0, sipTimestamp).In(tc.calendar().Location()).Format(dayLayout)
+}
+
+func (tc *TicksController) calendar() calendar.ICalendar {
+	if tc.Calendar != nil {
+		return tc.Calendar
+	}
+
+	return calendar.NewNyseCalendar()
+}
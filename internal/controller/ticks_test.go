@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/greenac/chaching/internal/calendar"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/rest/mocks"
+	restModels "github.com/greenac/chaching/internal/rest/models"
+	"github.com/greenac/chaching/internal/service/fetch"
+	logMocks "github.com/greenac/chaching/internal/service/logger/mocks"
+	"github.com/greenac/chaching/internal/utils"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func fixtureResponse(name string) restModels.Response {
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		panic(err)
+	}
+
+	return restModels.Response{StatusCode: http.StatusOK, Body: body}
+}
+
+type tickServiceMock struct {
+	trades     []models.Trade
+	quotes     []models.Quote
+	tradeSaves int
+	saveError  genErr.IGenError
+}
+
+func (tm *tickServiceMock) SaveTrades(ctx context.Context, trades []models.Trade) genErr.IGenError {
+	tm.tradeSaves += 1
+	if tm.saveError != nil {
+		return tm.saveError
+	}
+
+	tm.trades = append(tm.trades, trades...)
+	return nil
+}
+
+func (tm *tickServiceMock) GetTrades(ctx context.Context, companyName string, day string, from time.Time, to time.Time) ([]models.Trade, genErr.IGenError) {
+	return tm.trades, nil
+}
+
+func (tm *tickServiceMock) SaveQuotes(ctx context.Context, quotes []models.Quote) genErr.IGenError {
+	tm.quotes = append(tm.quotes, quotes...)
+	return nil
+}
+
+func (tm *tickServiceMock) GetQuotes(ctx context.Context, companyName string, day string, from time.Time, to time.Time) ([]models.Quote, genErr.IGenError) {
+	return tm.quotes, nil
+}
+
+func TestTicksController_FetchTrades(t *testing.T) {
+	Convey("TestTicksController_FetchTrades", t, func() {
+		cal := calendar.NewNyseCalendar()
+		from := time.Date(2023, 3, 1, 0, 0, 0, 0, cal.Location())
+		tp := TickParams{CompanyName: "AAPL", From: from, To: from.AddDate(0, 0, 1), Limit: 2}
+
+		Convey("TestTicksController_FetchTrades should follow next_url through the recorded pages", func() {
+			c := mocks.ClientMock{GetResponses: []restModels.Response{fixtureResponse("trades_aapl_page1.json"), fixtureResponse("trades_aapl_page2.json")}}
+			tc := TicksController{Calendar: cal, FetchService: fetch.NewFetchService("https://api.polygon.io/v3", &c, utils.JoinUrl), Logger: &logMocks.LoggerMock{}, Unmarshaler: json.Unmarshal}
+
+			trades, pages, ge := tc.FetchTrades(context.Background(), tp)
+			So(ge, ShouldBeNil)
+			So(pages, ShouldEqual, 2)
+			So(len(trades), ShouldEqual, 3)
+			So(c.GetUrls, ShouldResemble, []string{
+				"https://api.polygon.io/v3/trades/AAPL?timestamp.gte=1677646800000000000&timestamp.lt=1677733200000000000&order=asc&sort=timestamp&limit=2",
+				"https://api.polygon.io/v3/trades/AAPL?cursor=YXA9NDE3OCZhcz0mbGltaXQ9MiZvcmRlcj1hc2Mmc29ydD10aW1lc3RhbXA",
+			})
+
+			So(trades[1].CompanyName, ShouldEqual, "AAPL")
+			So(trades[1].Price, ShouldEqual, 146.84)
+			So(trades[1].TrfId, ShouldEqual, 201)
+			So(trades[1].Conditions, ShouldResemble, []int{37})
+
+			// an after hours trade is past midnight in UTC but still on the exchange's day
+			So(trades[2].Day, ShouldEqual, "2023-03-01")
+			So(trades[2].DatabaseModel().Pk, ShouldEqual, "type#trade#name#AAPL#day#2023-03-01")
+			So(trades[2].DatabaseModel().Sk, ShouldEqual, "sipTimestamp#1677717000112457984#391204")
+		})
+
+		Convey("TestTicksController_FetchTrades should fail when a page fails", func() {
+			c := mocks.ClientMock{
+				GetResponses: []restModels.Response{fixtureResponse("trades_aapl_page1.json")},
+				GetError:     &genErr.GenError{Messages: []string{"bad bad thing"}},
+			}
+			tc := TicksController{Calendar: cal, FetchService: fetch.NewFetchService("https://api.polygon.io/v3", &c, utils.JoinUrl), Logger: &logMocks.LoggerMock{}, Unmarshaler: json.Unmarshal}
+
+			trades, pages, ge := tc.FetchTrades(context.Background(), tp)
+			So(trades, ShouldBeEmpty)
+			So(pages, ShouldEqual, 1)
+			So(ge.Error(), ShouldStartWith, "bad bad thing->FetchService:FetchUrl:failed to get: https://api.polygon.io/v3/trades/AAPL?cursor=")
+			So(ge.Error(), ShouldEndWith, "->TicksController:EachTradePage:failed to fetch trades for: AAPL")
+		})
+	})
+}
+
+func TestTicksController_FetchQuotes(t *testing.T) {
+	Convey("TestTicksController_FetchQuotes", t, func() {
+		cal := calendar.NewNyseCalendar()
+		from := time.Date(2023, 3, 1, 9, 30, 0, 0, cal.Location())
+		c := mocks.ClientMock{GetResponses: []restModels.Response{fixtureResponse("quotes_aapl.json")}}
+		tc := TicksController{Calendar: cal, FetchService: fetch.NewFetchService("https://api.polygon.io/v3", &c, utils.JoinUrl), Logger: &logMocks.LoggerMock{}, Unmarshaler: json.Unmarshal}
+
+		quotes, pages, ge := tc.FetchQuotes(context.Background(), TickParams{CompanyName: "AAPL", From: from, To: from.Add(time.Minute)})
+		So(ge, ShouldBeNil)
+		So(pages, ShouldEqual, 1)
+		So(len(quotes), ShouldEqual, 2)
+		So(c.GetUrls[0], ShouldEqual, "https://api.polygon.io/v3/quotes/AAPL?timestamp.gte=1677681000000000000&timestamp.lt=1677681060000000000&order=asc&sort=timestamp&limit=50000")
+		So(quotes[0].BidPrice, ShouldEqual, 146.82)
+		So(quotes[0].AskPrice, ShouldEqual, 146.86)
+		So(quotes[0].Indicators, ShouldResemble, []int{604})
+		So(quotes[1].Day, ShouldEqual, "2023-03-01")
+		So(quotes[1].DatabaseModel().Pk, ShouldEqual, "type#quote#name#AAPL#day#2023-03-01")
+	})
+}
+
+func TestTicksController_SyncTicks(t *testing.T) {
+	Convey("TestTicksController_SyncTicks", t, func() {
+		cal := calendar.NewNyseCalendar()
+		day := time.Date(2023, 3, 1, 0, 0, 0, 0, cal.Location())
+		c := mocks.ClientMock{GetResponses: []restModels.Response{
+			fixtureResponse("trades_aapl_page1.json"),
+			fixtureResponse("trades_aapl_page2.json"),
+			fixtureResponse("quotes_aapl.json"),
+		}}
+		tm := &tickServiceMock{}
+		tc := TicksController{Calendar: cal, FetchService: fetch.NewFetchService("https://api.polygon.io/v3", &c, utils.JoinUrl), TickService: tm, Logger: &logMocks.LoggerMock{}, Unmarshaler: json.Unmarshal}
+
+		Convey("TestTicksController_SyncTicks should save each page as it is fetched", func() {
+			report, errs := tc.SyncTicks(context.Background(), []string{"AAPL"}, day, day.AddDate(0, 0, 1), TickSyncParams{Trades: true, Quotes: true})
+			So(errs, ShouldBeEmpty)
+			So(report, ShouldResemble, TickSyncReport{Days: 1, Trades: 3, Quotes: 2})
+			So(tm.tradeSaves, ShouldEqual, 2)
+			So(len(tm.trades), ShouldEqual, 3)
+			So(len(tm.quotes), ShouldEqual, 2)
+		})
+
+		Convey("TestTicksController_SyncTicks should stop paging a day when saving fails", func() {
+			tm.saveError = &genErr.GenError{Messages: []string{"throttled"}}
+			report, errs := tc.SyncTicks(context.Background(), []string{"AAPL"}, day, day.AddDate(0, 0, 1), TickSyncParams{Trades: true})
+			So(report.Failed, ShouldEqual, 1)
+			So(tm.tradeSaves, ShouldEqual, 1)
+			So(len(c.GetUrls), ShouldEqual, 1)
+			So(errs[0].Error(), ShouldEqual, "throttled->TicksController:EachTradePage:failed to handle page: 1->TicksController:EachTradePage:failed to fetch trades for: AAPL->TicksController:SyncTicks:failed to sync trades for: AAPL on: 2023-03-01")
+		})
+	})
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"context"
+	"github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+type tickerWatermark struct {
+	watermark models.Watermark
+	stored    bool
+	next      int           // index of the first window that has not been saved
+	done      map[int]int64 // windows saved out of order, with the start time of their newest bar
+}
+
+// watermarkTracker advances each ticker's watermark over the contiguous run of windows that have been saved,
+// so a window that fails holds the watermark back until a later run fetches it again. The watermark moves to the
+// newest bar saved rather than the end of the window, so bars polygon publishes late are fetched by the next run
+type watermarkTracker struct {
+	mu        sync.Mutex
+	service   service.IWatermarkService
+	windows   []FetchWindow
+	marks     map[string]*tickerWatermark
+	barLength time.Duration
+	now       func() time.Time
+}
+
+func newWatermarkTracker(service service.IWatermarkService, windows []FetchWindow, marks map[string]models.Watermark, barLength time.Duration, now func() time.Time) *watermarkTracker {
+	wt := watermarkTracker{service: service, windows: windows, marks: map[string]*tickerWatermark{}, barLength: barLength, now: now}
+	for name, w := range marks {
+		tw := &tickerWatermark{watermark: w, stored: !w.Through.IsZero(), done: map[int]int64{}}
+		for tw.next < len(windows) && !wt.needs(tw, tw.next) {
+			tw.next += 1
+		}
+		wt.marks[name] = tw
+	}
+
+	return &wt
+}
+
+// needs reports whether window i ends after the ticker's watermark. A window the watermark falls inside is fetched
+// again for the bars after it
+func (wt *watermarkTracker) needs(tw *tickerWatermark, i int) bool {
+	return !tw.stored || wt.windows[i].To.After(tw.watermark.Through)
+}
+
+// targets returns the tickers that have not been saved through window i
+func (wt *watermarkTracker) targets(i int) []string {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+
+	var names []string
+	for name, tw := range wt.marks {
+		if wt.needs(tw, i) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// complete records that the ticker's bars for window i are saved and stores the watermark if it moved
+func (wt *watermarkTracker) complete(ctx context.Context, name string, i int, lastBarTime int64) genErr.IGenError {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+
+	tw, ok := wt.marks[name]
+	if !ok {
+		return nil
+	}
+
+	tw.done[i] = lastBarTime
+	advanced := false
+	for {
+		lbt, ok := tw.done[tw.next]
+		if !ok {
+			break
+		}
+
+		delete(tw.done, tw.next)
+		if through, ok := wt.through(lbt); ok && (!tw.stored || through.After(tw.watermark.Through)) {
+			tw.watermark.Through = through
+			tw.stored = true
+			advanced = true
+		}
+
+		if lbt > tw.watermark.LastBarTime {
+			tw.watermark.LastBarTime = lbt
+		}
+
+		tw.next += 1
+	}
+
+	if !advanced {
+		return nil
+	}
+
+	return wt.service.SaveWatermark(ctx, tw.watermark)
+}
+
+// through is how far a window whose newest bar starts at lastBarTime has been saved. A window without bars doesn't
+// move the watermark, and a bar that may still be open is left for the next run to fetch again
+func (wt *watermarkTracker) through(lastBarTime int64) (time.Time, bool) {
+	if lastBarTime <= 0 {
+		return time.Time{}, false
+	}
+
+	start := time.UnixMilli(lastBarTime).UTC()
+	if start.Add(wt.barLength).After(wt.now()) {
+		return start.Add(-time.Millisecond), true
+	}
+
+	return start, true
+}
+
+// barLength is how long a bar of the params lasts. Calendar timespans use their longest length, so a bar is only
+// taken to be closed once it certainly is
+func barLength(fp FetchParams) time.Duration {
+	unit := time.Minute
+	switch fp.Timespan {
+	case model.PolygonAggregateTimespanSecond:
+		unit = time.Second
+	case model.PolygonAggregateTimespanHour:
+		unit = time.Hour
+	case model.PolygonAggregateTimespanDay:
+		unit = 24 * time.Hour
+	case model.PolygonAggregateTimespanWeek:
+		unit = 7 * 24 * time.Hour
+	case model.PolygonAggregateTimespanMonth:
+		unit = 31 * 24 * time.Hour
+	case model.PolygonAggregateTimespanQuarter:
+		unit = 92 * 24 * time.Hour
+	case model.PolygonAggregateTimespanYear:
+		unit = 366 * 24 * time.Hour
+	}
+
+	if fp.TimespanMultiplier > 1 {
+		return time.Duration(fp.TimespanMultiplier) * unit
+	}
+
+	return unit
+}
+
+// resumeFrom returns the earliest time any ticker still needs, which is never before start. A ticker resumes a
+// millisecond after its watermark, the start of the newest bar it saved
+func resumeFrom(start time.Time, marks map[string]models.Watermark) time.Time {
+	var resume time.Time
+	for _, w := range marks {
+		t := start
+		if next := w.Through.Add(time.Millisecond); !w.Through.IsZero() && next.After(start) {
+			t = next
+		}
+
+		if resume.IsZero() || t.Before(resume) {
+			resume = t
+		}
+	}
+
+	if resume.IsZero() {
+		return start
+	}
+
+	return resume
+}
@@ -45,7 +45,8 @@ func (pm *DataPointPersistenceManager) SaveNewDataPoints(ctx context.Context, dp
 
 		points := dps[i:ri]
 		wrs := make([]types.WriteRequest, len(points))
-		for i, m := range points {
+		for i, p := range points {
+			m := p.DatabaseModel()
 			m.Pk = helpers.CreateCompositeKey(keys.Pk, m.CompanyName)
 			m.Sk = helpers.CreateCompositeKey(keys.Sk, strconv.FormatInt(m.StartTime, 10))
 			m.CreatedAt = time.Now()
@@ -7,22 +7,26 @@ import (
 )
 
 type ClientMock struct {
-	PutItemOutput        dynamodb.PutItemOutput
-	PutItemError         error
-	QueryOutput          dynamodb.QueryOutput
-	QueryError           error
-	GetItemOutput        dynamodb.GetItemOutput
-	GetItemError         error
-	DeleteItemOutput     dynamodb.DeleteItemOutput
-	DeleteItemError      error
-	UpdateItemOutput     dynamodb.UpdateItemOutput
-	UpdateItemError      error
-	CreateTableOutput    dynamodb.CreateTableOutput
-	CreateTableError     error
-	DeleteTableOutput    dynamodb.DeleteTableOutput
-	DeleteTableError     error
-	BatchWriteItemOutput dynamodb.BatchWriteItemOutput
-	BatchWriteItemError  error
+	PutItemOutput            dynamodb.PutItemOutput
+	PutItemError             error
+	QueryOutput              dynamodb.QueryOutput
+	QueryError               error
+	GetItemOutput            dynamodb.GetItemOutput
+	GetItemError             error
+	DeleteItemOutput         dynamodb.DeleteItemOutput
+	DeleteItemError          error
+	UpdateItemOutput         dynamodb.UpdateItemOutput
+	UpdateItemError          error
+	CreateTableOutput        dynamodb.CreateTableOutput
+	CreateTableError         error
+	DeleteTableOutput        dynamodb.DeleteTableOutput
+	DeleteTableError         error
+	BatchWriteItemOutput     dynamodb.BatchWriteItemOutput
+	BatchWriteItemError      error
+	BatchGetItemOutput       dynamodb.BatchGetItemOutput
+	BatchGetItemError        error
+	TransactWriteItemsOutput dynamodb.TransactWriteItemsOutput
+	TransactWriteItemsError  error
 }
 
 var _ models.IDatabaseClient = (*ClientMock)(nil)
@@ -58,3 +62,11 @@ func (c ClientMock) DeleteTable(ctx context.Context, params *dynamodb.DeleteTabl
 func (c ClientMock) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
 	return &c.BatchWriteItemOutput, c.BatchWriteItemError
 }
+
+func (c ClientMock) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	return &c.BatchGetItemOutput, c.BatchGetItemError
+}
+
+func (c ClientMock) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	return &c.TransactWriteItemsOutput, c.TransactWriteItemsError
+}
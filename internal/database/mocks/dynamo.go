@@ -0,0 +1,1241 @@
+package mocks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/greenac/chaching/internal/database/models"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxBatchWriteRequests is the most requests dynamo takes in one BatchWriteItem call
+const maxBatchWriteRequests = 25
+
+// maxBatchGetKeys is the most keys dynamo takes in one BatchGetItem call
+const maxBatchGetKeys = 100
+
+// maxTransactItems is the most operations dynamo takes in one TransactWriteItems call
+const maxTransactItems = 100
+
+type Operation string
+
+const (
+	OperationPutItem            Operation = "PutItem"
+	OperationQuery              Operation = "Query"
+	OperationGetItem            Operation = "GetItem"
+	OperationDeleteItem         Operation = "DeleteItem"
+	OperationUpdateItem         Operation = "UpdateItem"
+	OperationCreateTable        Operation = "CreateTable"
+	OperationDeleteTable        Operation = "DeleteTable"
+	OperationBatchWriteItem     Operation = "BatchWriteItem"
+	OperationBatchGetItem       Operation = "BatchGetItem"
+	OperationTransactWriteItems Operation = "TransactWriteItems"
+)
+
+// DynamoFake is an in memory IDatabaseClient that stores items and evaluates key, condition and update expressions
+// the way dynamo does, so code using the client can be tested without dynamo local. Set Errors and Unprocessed before use to inject failures
+type DynamoFake struct {
+	// Errors are returned by the next calls of an operation, one per call, before the operation runs normally again
+	Errors map[Operation][]error
+	// Unprocessed picks the requests of a BatchWriteItem call to hand back unprocessed. call counts from 1
+	Unprocessed func(call int, table string, requests []types.WriteRequest) []types.WriteRequest
+	// UnprocessedKeys picks the keys of a BatchGetItem call to hand back unprocessed. call counts from 1
+	UnprocessedKeys func(call int, table string, keys []map[string]types.AttributeValue) []map[string]types.AttributeValue
+	// PageSize stands in for dynamo's 1MB page limit, capping the items a query without a Limit returns. 0 doesn't cap
+	PageSize int
+	// Latency is how long each call takes before it runs, standing in for the round trip to dynamo
+	Latency time.Duration
+
+	mu     sync.Mutex
+	tables map[string]*fakeTable
+	calls  map[Operation]int
+}
+
+// the client interfaces of database/models and service/database are the same, so the fake stands in for both
+var _ models.IDatabaseClient = (*DynamoFake)(nil)
+
+// NewDynamoFake returns a fake holding the given tables
+func NewDynamoFake(tables ...dynamodb.CreateTableInput) *DynamoFake {
+	f := &DynamoFake{tables: map[string]*fakeTable{}, calls: map[Operation]int{}}
+	for i := range tables {
+		if _, err := f.CreateTable(context.Background(), &tables[i]); err != nil {
+			panic(err)
+		}
+	}
+
+	return f
+}
+
+// ChachingTable is the schema of files/dynamo-table.json, named by the config's table and indexes
+func ChachingTable(config models.DynamoConfig) dynamodb.CreateTableInput {
+	return dynamodb.CreateTableInput{
+		TableName: aws.String(config.MainTable),
+		KeySchema: keySchemaElements(models.DbPartitionKey, models.DbSearchKey),
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{IndexName: aws.String(config.Index1), KeySchema: keySchemaElements(models.DbGlobalPartitionKey1, models.DbGlobalSearchKey1)},
+			{IndexName: aws.String(config.Index2), KeySchema: keySchemaElements(models.DbGlobalPartitionKey2, models.DbGlobalSearchKey2)},
+		},
+	}
+}
+
+// ThrottlingError is the error dynamo returns when a table's throughput is exceeded
+func ThrottlingError() error {
+	return &types.ProvisionedThroughputExceededException{Message: aws.String("The level of configured provisioned throughput for the table was exceeded")}
+}
+
+// LeaveUnprocessed hands back the last n requests of each of the first `calls` BatchWriteItem calls
+func LeaveUnprocessed(n int, calls int) func(int, string, []types.WriteRequest) []types.WriteRequest {
+	return func(call int, table string, requests []types.WriteRequest) []types.WriteRequest {
+		if call > calls || n <= 0 {
+			return nil
+		}
+
+		if n > len(requests) {
+			return requests
+		}
+
+		return requests[len(requests)-n:]
+	}
+}
+
+// Calls returns how many times an operation was called, including calls that failed
+func (f *DynamoFake) Calls(op Operation) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[op]
+}
+
+// Items returns the items of a table ordered by key
+func (f *DynamoFake) Items(table string) []map[string]types.AttributeValue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.tables[table]
+	if !ok {
+		return nil
+	}
+
+	items := make([]map[string]types.AttributeValue, 0, len(t.items))
+	for _, item := range t.items {
+		items = append(items, copyItem(item))
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return compareKeys(t.key.order(items[i], t.key), t.key.order(items[j], t.key)) < 0
+	})
+
+	return items
+}
+
+func (f *DynamoFake) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(OperationPutItem); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := t.keyOf(params.Item, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCondition(params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, t.items[k])
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := t.put(params.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.PutItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		out.Attributes = old
+	}
+
+	return out, nil
+}
+
+func (f *DynamoFake) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(OperationGetItem); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := t.keyOf(params.Key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.GetItemOutput{Item: copyItem(t.items[k])}, nil
+}
+
+func (f *DynamoFake) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(OperationDeleteItem); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := t.keyOf(params.Key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCondition(params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, t.items[k])
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := t.delete(params.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.DeleteItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		out.Attributes = old
+	}
+
+	return out, nil
+}
+
+// UpdateItem applies SET, REMOVE and ADD actions to the item of the key, creating it when there is none
+func (f *DynamoFake) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(OperationUpdateItem); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := t.keyOf(params.Key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[k]
+	err = checkCondition(params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, old)
+	if err != nil {
+		return nil, err
+	}
+
+	item := old
+	if item == nil {
+		item = params.Key
+	}
+
+	updated, err := applyUpdate(aws.ToString(params.UpdateExpression), params.ExpressionAttributeNames, params.ExpressionAttributeValues, item, t.key)
+	if err != nil {
+		return nil, err
+	}
+
+	t.items[k] = updated
+
+	out := &dynamodb.UpdateItemOutput{}
+	switch params.ReturnValues {
+	case types.ReturnValueAllNew:
+		out.Attributes = copyItem(updated)
+	case types.ReturnValueAllOld:
+		out.Attributes = copyItem(old)
+	}
+
+	return out, nil
+}
+
+func (f *DynamoFake) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(OperationCreateTable); err != nil {
+		return nil, err
+	}
+
+	name := aws.ToString(params.TableName)
+	if _, ok := f.tables[name]; ok {
+		return nil, &types.ResourceInUseException{Message: aws.String("Table already exists: " + name)}
+	}
+
+	key, err := newFakeKey(params.KeySchema)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &fakeTable{key: key, indexes: map[string]fakeKey{}, items: map[string]map[string]types.AttributeValue{}}
+	for _, gsi := range params.GlobalSecondaryIndexes {
+		if t.indexes[aws.ToString(gsi.IndexName)], err = newFakeKey(gsi.KeySchema); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, lsi := range params.LocalSecondaryIndexes {
+		if t.indexes[aws.ToString(lsi.IndexName)], err = newFakeKey(lsi.KeySchema); err != nil {
+			return nil, err
+		}
+	}
+
+	f.tables[name] = t
+
+	return &dynamodb.CreateTableOutput{TableDescription: &types.TableDescription{TableName: aws.String(name), TableStatus: types.TableStatusActive}}, nil
+}
+
+func (f *DynamoFake) DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(OperationDeleteTable); err != nil {
+		return nil, err
+	}
+
+	if _, err := f.table(params.TableName); err != nil {
+		return nil, err
+	}
+
+	delete(f.tables, aws.ToString(params.TableName))
+
+	return &dynamodb.DeleteTableOutput{TableDescription: &types.TableDescription{TableName: params.TableName, TableStatus: types.TableStatusDeleting}}, nil
+}
+
+// BatchWriteItem applies the requests that Unprocessed doesn't pick. Like dynamo it rejects the whole call when it
+// holds more than 25 requests or touches the same key twice
+func (f *DynamoFake) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(OperationBatchWriteItem); err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for name, requests := range params.RequestItems {
+		t, err := f.table(aws.String(name))
+		if err != nil {
+			return nil, err
+		}
+
+		seen := map[string]bool{}
+		for _, r := range requests {
+			k, err := t.keyOf(writeRequestKey(r), false)
+			if err != nil {
+				return nil, err
+			}
+
+			if seen[k] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+
+			seen[k] = true
+		}
+
+		total += len(requests)
+	}
+
+	if total == 0 || total > maxBatchWriteRequests {
+		return nil, validationError(fmt.Sprintf("BatchWriteItem takes between 1 and %d requests, got: %d", maxBatchWriteRequests, total))
+	}
+
+	call := f.calls[OperationBatchWriteItem]
+	out := &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]types.WriteRequest{}}
+	for name, requests := range params.RequestItems {
+		t := f.tables[name]
+
+		skip := map[string]bool{}
+		if f.Unprocessed != nil {
+			for _, r := range f.Unprocessed(call, name, requests) {
+				k, _ := t.keyOf(writeRequestKey(r), false)
+				skip[k] = true
+				out.UnprocessedItems[name] = append(out.UnprocessedItems[name], r)
+			}
+		}
+
+		for _, r := range requests {
+			if k, _ := t.keyOf(writeRequestKey(r), false); skip[k] {
+				continue
+			}
+
+			if r.PutRequest != nil {
+				_, _ = t.put(r.PutRequest.Item)
+			} else {
+				_, _ = t.delete(r.DeleteRequest.Key)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// BatchGetItem gets the items of the keys UnprocessedKeys doesn't pick. Like dynamo it rejects the whole call when it
+// holds more than 100 keys or the same key twice
+func (f *DynamoFake) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(OperationBatchGetItem); err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for name, ka := range params.RequestItems {
+		t, err := f.table(aws.String(name))
+		if err != nil {
+			return nil, err
+		}
+
+		seen := map[string]bool{}
+		for _, key := range ka.Keys {
+			k, err := t.keyOf(key, true)
+			if err != nil {
+				return nil, err
+			}
+
+			if seen[k] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+
+			seen[k] = true
+		}
+
+		total += len(ka.Keys)
+	}
+
+	if total == 0 || total > maxBatchGetKeys {
+		return nil, validationError(fmt.Sprintf("BatchGetItem takes between 1 and %d keys, got: %d", maxBatchGetKeys, total))
+	}
+
+	call := f.calls[OperationBatchGetItem]
+	out := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]types.AttributeValue{}, UnprocessedKeys: map[string]types.KeysAndAttributes{}}
+	for name, ka := range params.RequestItems {
+		t := f.tables[name]
+
+		skip := map[string]bool{}
+		if f.UnprocessedKeys != nil {
+			unprocessed := f.UnprocessedKeys(call, name, ka.Keys)
+			for _, key := range unprocessed {
+				k, _ := t.keyOf(key, true)
+				skip[k] = true
+			}
+
+			if len(unprocessed) > 0 {
+				out.UnprocessedKeys[name] = types.KeysAndAttributes{Keys: unprocessed}
+			}
+		}
+
+		for _, key := range ka.Keys {
+			k, _ := t.keyOf(key, true)
+			if item, ok := t.items[k]; ok && !skip[k] {
+				out.Responses[name] = append(out.Responses[name], copyItem(item))
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// TransactWriteItems checks the condition of every operation before making any write. When one doesn't hold it
+// cancels the whole call with a reason per operation, and like dynamo it rejects more than 100 operations or two
+// operations on one item
+func (f *DynamoFake) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(OperationTransactWriteItems); err != nil {
+		return nil, err
+	}
+
+	total := len(params.TransactItems)
+	if total == 0 || total > maxTransactItems {
+		return nil, validationError(fmt.Sprintf("TransactWriteItems takes between 1 and %d operations, got: %d", maxTransactItems, total))
+	}
+
+	type write struct {
+		table *fakeTable
+		key   string
+		item  map[string]types.AttributeValue // nil deletes the item
+	}
+
+	var writes []write
+	seen := map[string]bool{}
+	reasons := make([]types.CancellationReason, total)
+	canceled := false
+	for i, ti := range params.TransactItems {
+		op, err := newTransactOperation(ti)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := f.table(op.table)
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := t.keyOf(op.key, ti.Put == nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[aws.ToString(op.table)+"\x00"+k] {
+			return nil, validationError("Transaction request cannot include multiple operations on one item")
+		}
+
+		seen[aws.ToString(op.table)+"\x00"+k] = true
+
+		old := t.items[k]
+		reasons[i] = types.CancellationReason{Code: aws.String("None")}
+		err = checkCondition(op.condition, op.names, op.values, old)
+		if err != nil {
+			var ccf *types.ConditionalCheckFailedException
+			if !errors.As(err, &ccf) {
+				return nil, err
+			}
+
+			canceled = true
+			reasons[i] = types.CancellationReason{Code: aws.String("ConditionalCheckFailed"), Message: aws.String("The conditional request failed")}
+			if op.returnValues == types.ReturnValuesOnConditionCheckFailureAllOld {
+				reasons[i].Item = copyItem(old)
+			}
+
+			continue
+		}
+
+		switch {
+		case ti.Put != nil:
+			writes = append(writes, write{table: t, key: k, item: copyItem(ti.Put.Item)})
+		case ti.Update != nil:
+			item := old
+			if item == nil {
+				item = ti.Update.Key
+			}
+
+			updated, err := applyUpdate(aws.ToString(ti.Update.UpdateExpression), op.names, op.values, item, t.key)
+			if err != nil {
+				return nil, err
+			}
+
+			writes = append(writes, write{table: t, key: k, item: updated})
+		case ti.Delete != nil:
+			writes = append(writes, write{table: t, key: k})
+		}
+	}
+
+	if canceled {
+		codes := make([]string, total)
+		for i, r := range reasons {
+			codes[i] = aws.ToString(r.Code)
+		}
+
+		return nil, &types.TransactionCanceledException{
+			Message:             aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons [" + strings.Join(codes, ", ") + "]"),
+			CancellationReasons: reasons,
+		}
+	}
+
+	for _, w := range writes {
+		if w.item == nil {
+			delete(w.table.items, w.key)
+		} else {
+			w.table.items[w.key] = w.item
+		}
+	}
+
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// transactOperation is what the operations of a transaction have in common
+type transactOperation struct {
+	table        *string
+	key          map[string]types.AttributeValue
+	condition    *string
+	names        map[string]string
+	values       map[string]types.AttributeValue
+	returnValues types.ReturnValuesOnConditionCheckFailure
+}
+
+func newTransactOperation(ti types.TransactWriteItem) (transactOperation, error) {
+	ops := 0
+	var op transactOperation
+	if p := ti.Put; p != nil {
+		ops += 1
+		op = transactOperation{p.TableName, p.Item, p.ConditionExpression, p.ExpressionAttributeNames, p.ExpressionAttributeValues, p.ReturnValuesOnConditionCheckFailure}
+	}
+
+	if u := ti.Update; u != nil {
+		ops += 1
+		op = transactOperation{u.TableName, u.Key, u.ConditionExpression, u.ExpressionAttributeNames, u.ExpressionAttributeValues, u.ReturnValuesOnConditionCheckFailure}
+	}
+
+	if d := ti.Delete; d != nil {
+		ops += 1
+		op = transactOperation{d.TableName, d.Key, d.ConditionExpression, d.ExpressionAttributeNames, d.ExpressionAttributeValues, d.ReturnValuesOnConditionCheckFailure}
+	}
+
+	if c := ti.ConditionCheck; c != nil {
+		ops += 1
+		op = transactOperation{c.TableName, c.Key, c.ConditionExpression, c.ExpressionAttributeNames, c.ExpressionAttributeValues, c.ReturnValuesOnConditionCheckFailure}
+	}
+
+	if ops != 1 {
+		return op, validationError("TransactItems can only contain one of Check, Put, Update or Delete")
+	}
+
+	return op, nil
+}
+
+// Query evaluates KeyConditions or a KeyConditionExpression against a table or one of its indexes. Items come back
+// in sort key order. Like dynamo, a page that stops at Limit carries a LastEvaluatedKey even when nothing follows
+func (f *DynamoFake) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin(OperationQuery); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	key := t.key
+	if params.IndexName != nil {
+		var ok bool
+		if key, ok = t.indexes[*params.IndexName]; !ok {
+			return nil, validationError("The table does not have the specified index: " + *params.IndexName)
+		}
+	}
+
+	conditions, err := queryConditions(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := key.validateConditions(conditions); err != nil {
+		return nil, err
+	}
+
+	var matched []map[string]types.AttributeValue
+	for _, item := range t.items {
+		ok, err := matches(item, conditions)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			matched = append(matched, item)
+		}
+	}
+
+	forward := params.ScanIndexForward == nil || *params.ScanIndexForward
+	sort.Slice(matched, func(i, j int) bool {
+		c := compareKeys(key.order(matched[i], t.key), key.order(matched[j], t.key))
+		if forward {
+			return c < 0
+		}
+
+		return c > 0
+	})
+
+	if params.ExclusiveStartKey != nil {
+		start := key.order(params.ExclusiveStartKey, t.key)
+		i := 0
+		for ; i < len(matched); i += 1 {
+			c := compareKeys(key.order(matched[i], t.key), start)
+			if (forward && c > 0) || (!forward && c < 0) {
+				break
+			}
+		}
+
+		matched = matched[i:]
+	}
+
+	limit := f.PageSize
+	if params.Limit != nil {
+		limit = int(*params.Limit)
+	}
+
+	out := &dynamodb.QueryOutput{}
+	if limit > 0 && (len(matched) > limit || (params.Limit != nil && len(matched) == limit)) {
+		matched = matched[:limit]
+		out.LastEvaluatedKey = key.lastEvaluatedKey(matched[limit-1], t.key)
+	}
+
+	for _, item := range matched {
+		out.Items = append(out.Items, copyItem(item))
+	}
+
+	out.Count = int32(len(out.Items))
+	out.ScannedCount = out.Count
+
+	return out, nil
+}
+
+// wait sleeps for the fake's latency, outside the lock so concurrent calls overlap like they would against dynamo
+func (f *DynamoFake) wait(ctx context.Context) error {
+	if f.Latency <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(f.Latency)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// begin counts the call and returns the next error injected for the operation
+func (f *DynamoFake) begin(op Operation) error {
+	f.calls[op] += 1
+
+	errs := f.Errors[op]
+	if len(errs) == 0 {
+		return nil
+	}
+
+	f.Errors[op] = errs[1:]
+
+	return errs[0]
+}
+
+func (f *DynamoFake) table(name *string) (*fakeTable, error) {
+	t, ok := f.tables[aws.ToString(name)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found: Table: " + aws.ToString(name) + " not found")}
+	}
+
+	return t, nil
+}
+
+type fakeTable struct {
+	key     fakeKey
+	indexes map[string]fakeKey
+	items   map[string]map[string]types.AttributeValue
+}
+
+func (t *fakeTable) put(item map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	k, err := t.keyOf(item, false)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[k]
+	t.items[k] = copyItem(item)
+
+	return old, nil
+}
+
+func (t *fakeTable) delete(key map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	k, err := t.keyOf(key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[k]
+	delete(t.items, k)
+
+	return old, nil
+}
+
+// keyOf returns the string an item is stored under. exact requires the attributes to be the key and nothing else
+func (t *fakeTable) keyOf(item map[string]types.AttributeValue, exact bool) (string, error) {
+	if exact && len(item) != len(t.key.attributes()) {
+		return "", validationError("The provided key element does not match the schema")
+	}
+
+	var parts []string
+	for _, name := range t.key.attributes() {
+		s, ok := scalar(item[name])
+		if !ok {
+			return "", validationError("One or more parameter values were invalid: Missing the key " + name + " in the item")
+		}
+
+		parts = append(parts, s)
+	}
+
+	return strings.Join(parts, "\x00"), nil
+}
+
+type fakeKey struct {
+	hash    string
+	sortKey string
+}
+
+func newFakeKey(schema []types.KeySchemaElement) (fakeKey, error) {
+	var k fakeKey
+	for _, e := range schema {
+		switch e.KeyType {
+		case types.KeyTypeHash:
+			k.hash = aws.ToString(e.AttributeName)
+		case types.KeyTypeRange:
+			k.sortKey = aws.ToString(e.AttributeName)
+		}
+	}
+
+	if k.hash == "" {
+		return k, validationError("key schema is missing a hash key")
+	}
+
+	return k, nil
+}
+
+func (k fakeKey) attributes() []string {
+	if k.sortKey == "" {
+		return []string{k.hash}
+	}
+
+	return []string{k.hash, k.sortKey}
+}
+
+// order is what items are sorted by within a query, the sort key and then the table key to break ties on an index
+func (k fakeKey) order(item map[string]types.AttributeValue, table fakeKey) []types.AttributeValue {
+	order := []types.AttributeValue{item[k.sortKey]}
+	for _, name := range table.attributes() {
+		order = append(order, item[name])
+	}
+
+	return order
+}
+
+func (k fakeKey) lastEvaluatedKey(item map[string]types.AttributeValue, table fakeKey) map[string]types.AttributeValue {
+	lek := map[string]types.AttributeValue{}
+	for _, name := range append(k.attributes(), table.attributes()...) {
+		lek[name] = item[name]
+	}
+
+	return lek
+}
+
+func (k fakeKey) validateConditions(conditions map[string]types.Condition) error {
+	hash, ok := conditions[k.hash]
+	if !ok || hash.ComparisonOperator != types.ComparisonOperatorEq {
+		return validationError("Query condition missed key schema element: " + k.hash)
+	}
+
+	for name, c := range conditions {
+		switch name {
+		case k.hash:
+		case k.sortKey:
+			switch c.ComparisonOperator {
+			case types.ComparisonOperatorEq, types.ComparisonOperatorLt, types.ComparisonOperatorLe, types.ComparisonOperatorGt,
+				types.ComparisonOperatorGe, types.ComparisonOperatorBetween, types.ComparisonOperatorBeginsWith:
+			default:
+				return validationError("Unsupported operator on KeyCondition: " + string(c.ComparisonOperator))
+			}
+		default:
+			return validationError("Query condition missed key schema element, got: " + name)
+		}
+	}
+
+	return nil
+}
+
+func matches(item map[string]types.AttributeValue, conditions map[string]types.Condition) (bool, error) {
+	for name, c := range conditions {
+		av, ok := item[name]
+		if !ok {
+			return false, nil
+		}
+
+		args := len(c.AttributeValueList)
+		want := 1
+		if c.ComparisonOperator == types.ComparisonOperatorBetween {
+			want = 2
+		}
+
+		if args != want {
+			return false, validationError(fmt.Sprintf("%s takes %d values, got: %d", c.ComparisonOperator, want, args))
+		}
+
+		cmp := compare(av, c.AttributeValueList[0])
+		var match bool
+		switch c.ComparisonOperator {
+		case types.ComparisonOperatorEq:
+			match = cmp == 0
+		case types.ComparisonOperatorLt:
+			match = cmp < 0
+		case types.ComparisonOperatorLe:
+			match = cmp <= 0
+		case types.ComparisonOperatorGt:
+			match = cmp > 0
+		case types.ComparisonOperatorGe:
+			match = cmp >= 0
+		case types.ComparisonOperatorBetween:
+			match = cmp >= 0 && compare(av, c.AttributeValueList[1]) <= 0
+		case types.ComparisonOperatorBeginsWith:
+			match = beginsWith(av, c.AttributeValueList[0])
+		}
+
+		if !match {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func queryConditions(params *dynamodb.QueryInput) (map[string]types.Condition, error) {
+	switch {
+	case params.KeyConditions != nil && params.KeyConditionExpression != nil:
+		return nil, validationError("Can not use both expression and non-expression parameters in the same request")
+	case params.KeyConditions != nil:
+		return params.KeyConditions, nil
+	case params.KeyConditionExpression != nil:
+		return parseKeyConditionExpression(*params.KeyConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	}
+
+	return nil, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified")
+}
+
+// parseKeyConditionExpression turns an expression such as `pk = :pk and begins_with(sk, :prefix)` into the
+// conditions it stands for
+func parseKeyConditionExpression(expr string, names map[string]string, values map[string]types.AttributeValue) (map[string]types.Condition, error) {
+	tokens := tokenize(expr)
+	pos := 0
+	next := func() string {
+		if pos >= len(tokens) {
+			return ""
+		}
+
+		pos += 1
+		return tokens[pos-1]
+	}
+
+	expect := func(want string) error {
+		if got := next(); !strings.EqualFold(got, want) {
+			return validationError(fmt.Sprintf("Invalid KeyConditionExpression: expected: %q, got: %q in: %s", want, got, expr))
+		}
+
+		return nil
+	}
+
+	name := func() (string, error) {
+		tok := next()
+		if strings.HasPrefix(tok, "#") {
+			n, ok := names[tok]
+			if !ok {
+				return "", validationError("An expression attribute name used in the document path is not defined: " + tok)
+			}
+
+			return n, nil
+		}
+
+		if tok == "" || strings.HasPrefix(tok, ":") {
+			return "", validationError("Invalid KeyConditionExpression: expected an attribute name in: " + expr)
+		}
+
+		return tok, nil
+	}
+
+	value := func() (types.AttributeValue, error) {
+		tok := next()
+		v, ok := values[tok]
+		if !ok {
+			return nil, validationError("An expression attribute value used in expression is not defined: " + tok)
+		}
+
+		return v, nil
+	}
+
+	conditions := map[string]types.Condition{}
+	for {
+		var attr string
+		var c types.Condition
+		var err error
+
+		if strings.EqualFold(peek(tokens, pos), "begins_with") {
+			pos += 1
+			if err = expect("("); err != nil {
+				return nil, err
+			}
+
+			if attr, err = name(); err != nil {
+				return nil, err
+			}
+
+			if err = expect(","); err != nil {
+				return nil, err
+			}
+
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+
+			if err = expect(")"); err != nil {
+				return nil, err
+			}
+
+			c = types.Condition{ComparisonOperator: types.ComparisonOperatorBeginsWith, AttributeValueList: []types.AttributeValue{v}}
+		} else {
+			if attr, err = name(); err != nil {
+				return nil, err
+			}
+
+			op := next()
+			operators := map[string]types.ComparisonOperator{"=": types.ComparisonOperatorEq, "<": types.ComparisonOperatorLt, "<=": types.ComparisonOperatorLe, ">": types.ComparisonOperatorGt, ">=": types.ComparisonOperatorGe}
+			if strings.EqualFold(op, "between") {
+				low, err := value()
+				if err != nil {
+					return nil, err
+				}
+
+				if err = expect("and"); err != nil {
+					return nil, err
+				}
+
+				high, err := value()
+				if err != nil {
+					return nil, err
+				}
+
+				c = types.Condition{ComparisonOperator: types.ComparisonOperatorBetween, AttributeValueList: []types.AttributeValue{low, high}}
+			} else if co, ok := operators[op]; ok {
+				v, err := value()
+				if err != nil {
+					return nil, err
+				}
+
+				c = types.Condition{ComparisonOperator: co, AttributeValueList: []types.AttributeValue{v}}
+			} else {
+				return nil, validationError(fmt.Sprintf("Invalid KeyConditionExpression: unsupported operator: %q in: %s", op, expr))
+			}
+		}
+
+		if _, ok := conditions[attr]; ok {
+			return nil, validationError("KeyConditionExpressions must only contain one condition per key: " + attr)
+		}
+
+		conditions[attr] = c
+
+		if pos >= len(tokens) {
+			return conditions, nil
+		}
+
+		if err := expect("and"); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func tokenize(expr string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for i := 0; i < len(expr); i += 1 {
+		ch := expr[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			flush()
+		case ch == '(' || ch == ')' || ch == ',' || ch == '=' || ch == '+' || ch == '-':
+			flush()
+			tokens = append(tokens, string(ch))
+		case ch == '<' || ch == '>':
+			flush()
+			if i+1 < len(expr) && (expr[i+1] == '=' || ch == '<' && expr[i+1] == '>') {
+				tokens = append(tokens, expr[i:i+2])
+				i += 1
+			} else {
+				tokens = append(tokens, string(ch))
+			}
+		default:
+			current.WriteByte(ch)
+		}
+	}
+
+	flush()
+
+	return tokens
+}
+
+func peek(tokens []string, pos int) string {
+	if pos >= len(tokens) {
+		return ""
+	}
+
+	return tokens[pos]
+}
+
+// scalar renders a key attribute as a string, reporting false for missing and non key types
+func scalar(av types.AttributeValue) (string, bool) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return "S" + v.Value, true
+	case *types.AttributeValueMemberN:
+		return "N" + v.Value, true
+	case *types.AttributeValueMemberB:
+		return "B" + string(v.Value), true
+	}
+
+	return "", false
+}
+
+// compare orders values of the same key type, strings and binary by bytes and numbers by value. Missing values sort first
+func compare(a types.AttributeValue, b types.AttributeValue) int {
+	switch av := a.(type) {
+	case *types.AttributeValueMemberS:
+		if bv, ok := b.(*types.AttributeValueMemberS); ok {
+			return strings.Compare(av.Value, bv.Value)
+		}
+	case *types.AttributeValueMemberN:
+		if bv, ok := b.(*types.AttributeValueMemberN); ok {
+			x, _, errX := big.ParseFloat(av.Value, 10, 128, big.ToNearestEven)
+			y, _, errY := big.ParseFloat(bv.Value, 10, 128, big.ToNearestEven)
+			if errX == nil && errY == nil {
+				return x.Cmp(y)
+			}
+
+			return strings.Compare(av.Value, bv.Value)
+		}
+	case *types.AttributeValueMemberB:
+		if bv, ok := b.(*types.AttributeValueMemberB); ok {
+			return bytes.Compare(av.Value, bv.Value)
+		}
+	}
+
+	as, _ := scalar(a)
+	bs, _ := scalar(b)
+
+	return strings.Compare(as, bs)
+}
+
+func compareKeys(a []types.AttributeValue, b []types.AttributeValue) int {
+	for i := range a {
+		if c := compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+func beginsWith(a types.AttributeValue, prefix types.AttributeValue) bool {
+	switch av := a.(type) {
+	case *types.AttributeValueMemberS:
+		p, ok := prefix.(*types.AttributeValueMemberS)
+		return ok && strings.HasPrefix(av.Value, p.Value)
+	case *types.AttributeValueMemberB:
+		p, ok := prefix.(*types.AttributeValueMemberB)
+		return ok && bytes.HasPrefix(av.Value, p.Value)
+	}
+
+	return false
+}
+
+func writeRequestKey(r types.WriteRequest) map[string]types.AttributeValue {
+	if r.PutRequest != nil {
+		return r.PutRequest.Item
+	}
+
+	if r.DeleteRequest != nil {
+		return r.DeleteRequest.Key
+	}
+
+	return nil
+}
+
+func copyItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+
+	c := make(map[string]types.AttributeValue, len(item))
+	for k, v := range item {
+		c[k] = v
+	}
+
+	return c
+}
+
+func keySchemaElements(hash string, sortKey string) []types.KeySchemaElement {
+	return []types.KeySchemaElement{
+		{AttributeName: aws.String(hash), KeyType: types.KeyTypeHash},
+		{AttributeName: aws.String(sortKey), KeyType: types.KeyTypeRange},
+	}
+}
+
+func validationError(msg string) error {
+	return &smithy.GenericAPIError{Code: "ValidationException", Message: msg}
+}
@@ -0,0 +1,456 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/greenac/chaching/internal/database/models"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func fakeItem(pk string, sk string, gpk1 string) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{
+		models.DbPartitionKey: &types.AttributeValueMemberS{Value: pk},
+		models.DbSearchKey:    &types.AttributeValueMemberS{Value: sk},
+	}
+
+	if gpk1 != "" {
+		item[models.DbGlobalPartitionKey1] = &types.AttributeValueMemberS{Value: gpk1}
+		item[models.DbGlobalSearchKey1] = &types.AttributeValueMemberS{Value: pk}
+	}
+
+	return item
+}
+
+func sks(items []map[string]types.AttributeValue) []string {
+	var keys []string
+	for _, item := range items {
+		keys = append(keys, item[models.DbSearchKey].(*types.AttributeValueMemberS).Value)
+	}
+
+	return keys
+}
+
+func s(v string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: v}
+}
+
+func TestDynamoFake_Query(t *testing.T) {
+	Convey("TestDynamoFake_Query", t, func() {
+		ctx := context.Background()
+		config := models.DynamoConfig{MainTable: "Chaching", Index1: "ChachingIndex1", Index2: "ChachingIndex2"}
+		f := NewDynamoFake(ChachingTable(config))
+		for _, sk := range []string{"timeStamp#3", "timeStamp#1", "timeStamp#2", "other#1"} {
+			_, err := f.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("Chaching"), Item: fakeItem("AAPL", sk, "")})
+			So(err, ShouldBeNil)
+		}
+		_, _ = f.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("Chaching"), Item: fakeItem("AMZN", "timeStamp#1", "")})
+
+		Convey("TestDynamoFake_Query should evaluate key conditions in sort key order", func() {
+			out, err := f.Query(ctx, &dynamodb.QueryInput{TableName: aws.String("Chaching"), KeyConditions: map[string]types.Condition{
+				models.DbPartitionKey: {ComparisonOperator: types.ComparisonOperatorEq, AttributeValueList: []types.AttributeValue{s("AAPL")}},
+				models.DbSearchKey:    {ComparisonOperator: types.ComparisonOperatorBetween, AttributeValueList: []types.AttributeValue{s("timeStamp#2"), s("timeStamp#3")}},
+			}})
+			So(err, ShouldBeNil)
+			So(sks(out.Items), ShouldResemble, []string{"timeStamp#2", "timeStamp#3"})
+			So(out.Count, ShouldEqual, 2)
+		})
+
+		Convey("TestDynamoFake_Query should evaluate key condition expressions", func() {
+			out, err := f.Query(ctx, &dynamodb.QueryInput{
+				TableName:                 aws.String("Chaching"),
+				KeyConditionExpression:    aws.String("#pk = :pk AND begins_with(sk, :prefix)"),
+				ExpressionAttributeNames:  map[string]string{"#pk": "pk"},
+				ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("AAPL"), ":prefix": s("timeStamp#")},
+				ScanIndexForward:          aws.Bool(false),
+			})
+			So(err, ShouldBeNil)
+			So(sks(out.Items), ShouldResemble, []string{"timeStamp#3", "timeStamp#2", "timeStamp#1"})
+
+			out, err = f.Query(ctx, &dynamodb.QueryInput{
+				TableName:                 aws.String("Chaching"),
+				KeyConditionExpression:    aws.String("pk = :pk and sk Between :start and :end"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("AAPL"), ":start": s("timeStamp#1"), ":end": s("timeStamp#2")},
+			})
+			So(err, ShouldBeNil)
+			So(sks(out.Items), ShouldResemble, []string{"timeStamp#1", "timeStamp#2"})
+		})
+
+		Convey("TestDynamoFake_Query should reject conditions dynamo would reject", func() {
+			_, err := f.Query(ctx, &dynamodb.QueryInput{TableName: aws.String("Chaching"), KeyConditions: map[string]types.Condition{
+				models.DbSearchKey: {ComparisonOperator: types.ComparisonOperatorEq, AttributeValueList: []types.AttributeValue{s("timeStamp#1")}},
+			}})
+			So(err.Error(), ShouldEqual, "api error ValidationException: Query condition missed key schema element: pk")
+
+			_, err = f.Query(ctx, &dynamodb.QueryInput{TableName: aws.String("Chaching"), KeyConditionExpression: aws.String("pk = :missing")})
+			So(err.Error(), ShouldEqual, "api error ValidationException: An expression attribute value used in expression is not defined: :missing")
+
+			_, err = f.Query(ctx, &dynamodb.QueryInput{TableName: aws.String("Nope"), KeyConditionExpression: aws.String("pk = :pk")})
+			var rnf *types.ResourceNotFoundException
+			So(errors.As(err, &rnf), ShouldBeTrue)
+		})
+
+		Convey("TestDynamoFake_Query should paginate with Limit and LastEvaluatedKey", func() {
+			input := dynamodb.QueryInput{
+				TableName:                 aws.String("Chaching"),
+				KeyConditionExpression:    aws.String("pk = :pk and begins_with(sk, :prefix)"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("AAPL"), ":prefix": s("timeStamp#")},
+				Limit:                     aws.Int32(2),
+			}
+
+			out, err := f.Query(ctx, &input)
+			So(err, ShouldBeNil)
+			So(sks(out.Items), ShouldResemble, []string{"timeStamp#1", "timeStamp#2"})
+			So(out.LastEvaluatedKey, ShouldResemble, map[string]types.AttributeValue{models.DbPartitionKey: s("AAPL"), models.DbSearchKey: s("timeStamp#2")})
+
+			input.ExclusiveStartKey = out.LastEvaluatedKey
+			out, err = f.Query(ctx, &input)
+			So(err, ShouldBeNil)
+			So(sks(out.Items), ShouldResemble, []string{"timeStamp#3"})
+			So(out.LastEvaluatedKey, ShouldBeNil)
+		})
+
+		Convey("TestDynamoFake_Query should cap pages without a limit at the page size", func() {
+			f.PageSize = 1
+			out, err := f.Query(ctx, &dynamodb.QueryInput{TableName: aws.String("Chaching"), KeyConditions: map[string]types.Condition{
+				models.DbPartitionKey: {ComparisonOperator: types.ComparisonOperatorEq, AttributeValueList: []types.AttributeValue{s("AMZN")}},
+			}})
+			So(err, ShouldBeNil)
+			So(len(out.Items), ShouldEqual, 1)
+			So(out.LastEvaluatedKey, ShouldBeNil)
+		})
+
+		Convey("TestDynamoFake_Query should query the items of an index", func() {
+			_, _ = f.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("Chaching"), Item: fakeItem("MSFT", "company", "type#company")})
+			_, _ = f.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("Chaching"), Item: fakeItem("GOOG", "company", "type#company")})
+
+			out, err := f.Query(ctx, &dynamodb.QueryInput{TableName: aws.String("Chaching"), IndexName: aws.String("ChachingIndex1"), Limit: aws.Int32(1), KeyConditions: map[string]types.Condition{
+				models.DbGlobalPartitionKey1: {ComparisonOperator: types.ComparisonOperatorEq, AttributeValueList: []types.AttributeValue{s("type#company")}},
+			}})
+			So(err, ShouldBeNil)
+			So(out.Items[0][models.DbPartitionKey], ShouldResemble, s("GOOG"))
+			So(out.LastEvaluatedKey, ShouldResemble, map[string]types.AttributeValue{
+				models.DbPartitionKey: s("GOOG"), models.DbSearchKey: s("company"), models.DbGlobalPartitionKey1: s("type#company"), models.DbGlobalSearchKey1: s("GOOG"),
+			})
+
+			_, err = f.Query(ctx, &dynamodb.QueryInput{TableName: aws.String("Chaching"), IndexName: aws.String("ChachingIndex3"), KeyConditionExpression: aws.String("gpk1 = :v")})
+			So(err.Error(), ShouldEqual, "api error ValidationException: The table does not have the specified index: ChachingIndex3")
+		})
+	})
+}
+
+func TestDynamoFake_BatchWriteItem(t *testing.T) {
+	Convey("TestDynamoFake_BatchWriteItem", t, func() {
+		ctx := context.Background()
+		f := NewDynamoFake(ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+		requests := func(n int) []types.WriteRequest {
+			wrs := make([]types.WriteRequest, n)
+			for i := range wrs {
+				wrs[i] = types.WriteRequest{PutRequest: &types.PutRequest{Item: fakeItem("AAPL", fmt.Sprintf("timeStamp#%02d", i), "")}}
+			}
+
+			return wrs
+		}
+
+		Convey("TestDynamoFake_BatchWriteItem should write puts and deletes", func() {
+			out, err := f.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{"Chaching": requests(3)}})
+			So(err, ShouldBeNil)
+			So(out.UnprocessedItems, ShouldBeEmpty)
+
+			_, err = f.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{"Chaching": {
+				{DeleteRequest: &types.DeleteRequest{Key: fakeItem("AAPL", "timeStamp#01", "")}},
+			}}})
+			So(err, ShouldBeNil)
+			So(sks(f.Items("Chaching")), ShouldResemble, []string{"timeStamp#00", "timeStamp#02"})
+		})
+
+		Convey("TestDynamoFake_BatchWriteItem should reject more than 25 requests and duplicate keys", func() {
+			_, err := f.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{"Chaching": requests(26)}})
+			var ae smithy.APIError
+			So(errors.As(err, &ae), ShouldBeTrue)
+			So(ae.ErrorCode(), ShouldEqual, "ValidationException")
+			So(ae.ErrorMessage(), ShouldEqual, "BatchWriteItem takes between 1 and 25 requests, got: 26")
+
+			wrs := requests(2)
+			_, err = f.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{"Chaching": append(wrs, wrs[0])}})
+			So(err.Error(), ShouldEqual, "api error ValidationException: Provided list of item keys contains duplicates")
+			So(f.Items("Chaching"), ShouldBeEmpty)
+		})
+
+		Convey("TestDynamoFake_BatchWriteItem should hand back the requests picked as unprocessed", func() {
+			f.Unprocessed = LeaveUnprocessed(2, 1)
+			wrs := requests(5)
+
+			out, err := f.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{"Chaching": wrs}})
+			So(err, ShouldBeNil)
+			So(out.UnprocessedItems["Chaching"], ShouldResemble, wrs[3:])
+			So(len(f.Items("Chaching")), ShouldEqual, 3)
+
+			out, err = f.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: out.UnprocessedItems})
+			So(err, ShouldBeNil)
+			So(out.UnprocessedItems, ShouldBeEmpty)
+			So(len(f.Items("Chaching")), ShouldEqual, 5)
+		})
+
+		Convey("TestDynamoFake_BatchWriteItem should return injected throttling errors before writing", func() {
+			f.Errors = map[Operation][]error{OperationBatchWriteItem: {ThrottlingError()}}
+
+			_, err := f.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{"Chaching": requests(1)}})
+			var pte *types.ProvisionedThroughputExceededException
+			So(errors.As(err, &pte), ShouldBeTrue)
+			So(f.Items("Chaching"), ShouldBeEmpty)
+
+			_, err = f.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{"Chaching": requests(1)}})
+			So(err, ShouldBeNil)
+			So(f.Calls(OperationBatchWriteItem), ShouldEqual, 2)
+		})
+	})
+}
+
+func TestDynamoFake_Items(t *testing.T) {
+	Convey("TestDynamoFake_Items", t, func() {
+		ctx := context.Background()
+		f := NewDynamoFake(ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+
+		Convey("TestDynamoFake_Items should get, replace and delete single items", func() {
+			_, err := f.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("Chaching"), Item: fakeItem("AAPL", "company", "")})
+			So(err, ShouldBeNil)
+
+			replaced := fakeItem("AAPL", "company", "type#company")
+			out, err := f.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("Chaching"), Item: replaced, ReturnValues: types.ReturnValueAllOld})
+			So(err, ShouldBeNil)
+			So(out.Attributes, ShouldResemble, fakeItem("AAPL", "company", ""))
+
+			got, err := f.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("Chaching"), Key: fakeItem("AAPL", "company", "")})
+			So(err, ShouldBeNil)
+			So(got.Item, ShouldResemble, replaced)
+
+			_, err = f.DeleteItem(ctx, &dynamodb.DeleteItemInput{TableName: aws.String("Chaching"), Key: fakeItem("AAPL", "company", "")})
+			So(err, ShouldBeNil)
+			got, err = f.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("Chaching"), Key: fakeItem("AAPL", "company", "")})
+			So(err, ShouldBeNil)
+			So(got.Item, ShouldBeNil)
+		})
+
+		Convey("TestDynamoFake_Items should reject items missing a key", func() {
+			_, err := f.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("Chaching"), Item: map[string]types.AttributeValue{models.DbPartitionKey: s("AAPL")}})
+			So(err.Error(), ShouldEqual, "api error ValidationException: One or more parameter values were invalid: Missing the key sk in the item")
+		})
+	})
+}
+
+func n(v string) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: v}
+}
+
+func TestDynamoFake_ConditionExpression(t *testing.T) {
+	Convey("TestDynamoFake_ConditionExpression", t, func() {
+		ctx := context.Background()
+		f := NewDynamoFake(ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+		item := fakeItem("AAPL", "watermark#minute", "")
+		item["version"] = n("2")
+		_, err := f.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("Chaching"), Item: item})
+		So(err, ShouldBeNil)
+
+		put := func(condition string, values map[string]types.AttributeValue) error {
+			_, err := f.PutItem(ctx, &dynamodb.PutItemInput{
+				TableName:                 aws.String("Chaching"),
+				Item:                      fakeItem("AAPL", "watermark#minute", ""),
+				ConditionExpression:       aws.String(condition),
+				ExpressionAttributeNames:  map[string]string{"#v": "version", "#sk": "sk"},
+				ExpressionAttributeValues: values,
+			})
+			return err
+		}
+
+		Convey("TestDynamoFake_ConditionExpression should write when the condition holds for the item it replaces", func() {
+			So(put("#v = :v", map[string]types.AttributeValue{":v": n("2.0")}), ShouldBeNil)
+			So(f.Items("Chaching")[0]["version"], ShouldBeNil)
+		})
+
+		Convey("TestDynamoFake_ConditionExpression should fail a write whose condition doesn't hold and leave the item", func() {
+			err := put("attribute_not_exists(#v) OR #v <> :v", map[string]types.AttributeValue{":v": n("2")})
+			var ccf *types.ConditionalCheckFailedException
+			So(errors.As(err, &ccf), ShouldBeTrue)
+			So(f.Items("Chaching")[0]["version"], ShouldResemble, n("2"))
+		})
+
+		Convey("TestDynamoFake_ConditionExpression should evaluate functions, NOT and parentheses", func() {
+			values := map[string]types.AttributeValue{":p": s("water"), ":lo": n("1"), ":hi": n("3")}
+			So(put("begins_with(#sk, :p) AND NOT (#v BETWEEN :hi AND :hi)", values), ShouldBeNil)
+			So(put("(attribute_exists(#v) OR #sk < :p) AND #v >= :lo", values), ShouldNotBeNil)
+		})
+
+		Convey("TestDynamoFake_ConditionExpression should evaluate against no item for a new key", func() {
+			_, err := f.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+				TableName:                aws.String("Chaching"),
+				Key:                      fakeItem("AMZN", "watermark#minute", ""),
+				ConditionExpression:      aws.String("attribute_exists(#sk)"),
+				ExpressionAttributeNames: map[string]string{"#sk": "sk"},
+			})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("TestDynamoFake_ConditionExpression should reject undefined placeholders", func() {
+			err := put("#v = :missing", nil)
+			So(err.Error(), ShouldContainSubstring, "An expression attribute value used in expression is not defined: :missing")
+		})
+	})
+}
+
+func TestDynamoFake_UpdateItem(t *testing.T) {
+	Convey("TestDynamoFake_UpdateItem", t, func() {
+		ctx := context.Background()
+		f := NewDynamoFake(ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+		update := func(expr string, values map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+			out, err := f.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName:                 aws.String("Chaching"),
+				Key:                       fakeItem("AAPL", "watermark#minute", ""),
+				UpdateExpression:          aws.String(expr),
+				ExpressionAttributeNames:  map[string]string{"#c": "count", "#m": "meta", "#s": "source", "#t": "tags", "#a": "at"},
+				ExpressionAttributeValues: values,
+				ReturnValues:              types.ReturnValueAllNew,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return out.Attributes, nil
+		}
+
+		Convey("TestDynamoFake_UpdateItem should create the item when there is none", func() {
+			item, err := update("SET #a = if_not_exists(#a, :a), #m = :m ADD #c :one", map[string]types.AttributeValue{
+				":a": s("2023-04-06"), ":one": n("1"), ":m": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}},
+			})
+			So(err, ShouldBeNil)
+			So(item["at"], ShouldResemble, s("2023-04-06"))
+			So(item["count"], ShouldResemble, n("1"))
+			So(len(f.Items("Chaching")), ShouldEqual, 1)
+		})
+
+		Convey("TestDynamoFake_UpdateItem should read every operand from the item before the update", func() {
+			_, err := update("SET #a = :a, #c = :c, #m = :m, #t = :t", map[string]types.AttributeValue{
+				":a": s("2023-04-06"), ":c": n("1.5"), ":t": &types.AttributeValueMemberSS{Value: []string{"a"}},
+				":m": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"source": s("polygon")}},
+			})
+			So(err, ShouldBeNil)
+
+			item, err := update("SET #a = if_not_exists(#a, :a), #c = #c + :c, #m.#s = :s REMOVE #a ADD #t :t", map[string]types.AttributeValue{
+				":a": s("2023-04-07"), ":c": n("2.25"), ":s": s("iex"), ":t": &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+			})
+			So(err, ShouldBeNil)
+			So(item["at"], ShouldBeNil)
+			So(item["count"], ShouldResemble, n("3.75"))
+			So(item["meta"], ShouldResemble, &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"source": s("iex")}})
+			So(item["tags"], ShouldResemble, &types.AttributeValueMemberSS{Value: []string{"a", "b"}})
+
+			item, err = update("SET #c = #c - :c", map[string]types.AttributeValue{":c": n("0.75")})
+			So(err, ShouldBeNil)
+			So(item["count"], ShouldResemble, n("3"))
+		})
+
+		Convey("TestDynamoFake_UpdateItem should not update the key", func() {
+			_, err := f.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName:                 aws.String("Chaching"),
+				Key:                       fakeItem("AAPL", "watermark#minute", ""),
+				UpdateExpression:          aws.String("SET sk = :sk"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":sk": s("other")},
+			})
+			So(err.Error(), ShouldContainSubstring, "Cannot update attribute sk. This attribute is part of the key")
+		})
+
+		Convey("TestDynamoFake_UpdateItem should fail arithmetic on a missing attribute", func() {
+			_, err := update("SET #c = #c + :c", map[string]types.AttributeValue{":c": n("1")})
+			So(err, ShouldNotBeNil)
+			So(len(f.Items("Chaching")), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestDynamoFake_BatchGetItem(t *testing.T) {
+	Convey("TestDynamoFake_BatchGetItem", t, func() {
+		ctx := context.Background()
+		f := NewDynamoFake(ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+		for _, sk := range []string{"timeStamp#1", "timeStamp#2", "timeStamp#3"} {
+			_, _ = f.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("Chaching"), Item: fakeItem("AAPL", sk, "")})
+		}
+
+		get := func(sks ...string) (*dynamodb.BatchGetItemOutput, error) {
+			var keys []map[string]types.AttributeValue
+			for _, sk := range sks {
+				keys = append(keys, fakeItem("AAPL", sk, ""))
+			}
+
+			return f.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: map[string]types.KeysAndAttributes{"Chaching": {Keys: keys}}})
+		}
+
+		Convey("TestDynamoFake_BatchGetItem should return the items of the keys it finds", func() {
+			out, err := get("timeStamp#3", "timeStamp#9", "timeStamp#1")
+			So(err, ShouldBeNil)
+			So(sks(out.Responses["Chaching"]), ShouldResemble, []string{"timeStamp#3", "timeStamp#1"})
+		})
+
+		Convey("TestDynamoFake_BatchGetItem should hand back the keys UnprocessedKeys picks", func() {
+			f.UnprocessedKeys = func(call int, table string, keys []map[string]types.AttributeValue) []map[string]types.AttributeValue {
+				return keys[1:]
+			}
+
+			out, err := get("timeStamp#1", "timeStamp#2", "timeStamp#3")
+			So(err, ShouldBeNil)
+			So(sks(out.Responses["Chaching"]), ShouldResemble, []string{"timeStamp#1"})
+			So(sks(out.UnprocessedKeys["Chaching"].Keys), ShouldResemble, []string{"timeStamp#2", "timeStamp#3"})
+		})
+
+		Convey("TestDynamoFake_BatchGetItem should reject duplicate keys and more than 100 keys", func() {
+			_, err := get("timeStamp#1", "timeStamp#1")
+			So(err.Error(), ShouldContainSubstring, "Provided list of item keys contains duplicates")
+
+			var many []string
+			for i := 0; i < 101; i += 1 {
+				many = append(many, fmt.Sprintf("timeStamp#%d", i))
+			}
+			_, err = get(many...)
+			So(err.Error(), ShouldContainSubstring, "BatchGetItem takes between 1 and 100 keys, got: 101")
+		})
+	})
+}
+
+func TestDynamoFake_TransactWriteItems(t *testing.T) {
+	Convey("TestDynamoFake_TransactWriteItems", t, func() {
+		ctx := context.Background()
+		f := NewDynamoFake(ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+		_, _ = f.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("Chaching"), Item: fakeItem("AAPL", "timeStamp#1", "")})
+
+		Convey("TestDynamoFake_TransactWriteItems should cancel every write when a condition fails", func() {
+			_, err := f.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+				{Put: &types.Put{TableName: aws.String("Chaching"), Item: fakeItem("AAPL", "timeStamp#2", "")}},
+				{Delete: &types.Delete{TableName: aws.String("Chaching"), Key: fakeItem("AAPL", "timeStamp#1", ""), ConditionExpression: aws.String("attribute_not_exists(pk)"),
+					ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld}},
+			}})
+
+			var tce *types.TransactionCanceledException
+			So(errors.As(err, &tce), ShouldBeTrue)
+			So(aws.ToString(tce.Message), ShouldEqual, "Transaction cancelled, please refer cancellation reasons for specific reasons [None, ConditionalCheckFailed]")
+			So(aws.ToString(tce.CancellationReasons[1].Code), ShouldEqual, "ConditionalCheckFailed")
+			So(tce.CancellationReasons[1].Item, ShouldResemble, fakeItem("AAPL", "timeStamp#1", ""))
+			So(sks(f.Items("Chaching")), ShouldResemble, []string{"timeStamp#1"})
+		})
+
+		Convey("TestDynamoFake_TransactWriteItems should reject two operations on one item", func() {
+			_, err := f.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+				{Put: &types.Put{TableName: aws.String("Chaching"), Item: fakeItem("AAPL", "timeStamp#1", "gpk")}},
+				{ConditionCheck: &types.ConditionCheck{TableName: aws.String("Chaching"), Key: fakeItem("AAPL", "timeStamp#1", ""), ConditionExpression: aws.String("attribute_exists(pk)")}},
+			}})
+
+			var apiErr smithy.APIError
+			So(errors.As(err, &apiErr), ShouldBeTrue)
+			So(apiErr.ErrorMessage(), ShouldEqual, "Transaction request cannot include multiple operations on one item")
+		})
+	})
+}
@@ -0,0 +1,545 @@
+package mocks
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// conditionFailedError is the error dynamo returns when a write's ConditionExpression doesn't hold
+func conditionFailedError() error {
+	return &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+}
+
+// checkCondition evaluates a write's ConditionExpression against the item it replaces, nil when there is none
+func checkCondition(expr *string, names map[string]string, values map[string]types.AttributeValue, item map[string]types.AttributeValue) error {
+	if expr == nil {
+		return nil
+	}
+
+	p := newExpressionParser(*expr, names, values)
+	holds, err := p.or(item)
+	if err != nil {
+		return err
+	}
+
+	if p.pos < len(p.tokens) {
+		return p.invalid("unexpected: " + p.peek())
+	}
+
+	if !holds {
+		return conditionFailedError()
+	}
+
+	return nil
+}
+
+// applyUpdate returns the item an UpdateExpression makes of old. Like dynamo, every operand reads the item as it was
+// before the update
+func applyUpdate(expr string, names map[string]string, values map[string]types.AttributeValue, old map[string]types.AttributeValue, key fakeKey) (map[string]types.AttributeValue, error) {
+	p := newExpressionParser(expr, names, values)
+	updated := copyItem(old)
+	for p.pos < len(p.tokens) {
+		clause := strings.ToUpper(p.next())
+		for {
+			path, err := p.path()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, k := range key.attributes() {
+				if path[0] == k && len(path) == 1 {
+					return nil, validationError("Cannot update attribute " + k + ". This attribute is part of the key")
+				}
+			}
+
+			switch clause {
+			case "SET":
+				if err := p.expect("="); err != nil {
+					return nil, err
+				}
+
+				v, err := p.setValue(old)
+				if err != nil {
+					return nil, err
+				}
+
+				err = setPath(updated, path, v)
+				if err != nil {
+					return nil, err
+				}
+			case "REMOVE":
+				removePath(updated, path)
+			case "ADD":
+				v, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+
+				sum, err := add(lookup(updated, path), v)
+				if err != nil {
+					return nil, err
+				}
+
+				err = setPath(updated, path, sum)
+				if err != nil {
+					return nil, err
+				}
+			default:
+				return nil, p.invalid("unsupported clause: " + clause)
+			}
+
+			if p.peek() != "," {
+				break
+			}
+
+			p.pos += 1
+		}
+	}
+
+	return updated, nil
+}
+
+type expressionParser struct {
+	expr   string
+	tokens []string
+	pos    int
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func newExpressionParser(expr string, names map[string]string, values map[string]types.AttributeValue) *expressionParser {
+	return &expressionParser{expr: expr, tokens: tokenize(expr), names: names, values: values}
+}
+
+func (p *expressionParser) next() string {
+	tok := p.peek()
+	if tok != "" {
+		p.pos += 1
+	}
+
+	return tok
+}
+
+func (p *expressionParser) peek() string {
+	return peek(p.tokens, p.pos)
+}
+
+func (p *expressionParser) expect(want string) error {
+	if got := p.next(); !strings.EqualFold(got, want) {
+		return p.invalid(fmt.Sprintf("expected: %q, got: %q", want, got))
+	}
+
+	return nil
+}
+
+func (p *expressionParser) invalid(msg string) error {
+	return validationError("Invalid expression: " + msg + " in: " + p.expr)
+}
+
+// path resolves a document path such as `#n0.#n1` into its attribute names
+func (p *expressionParser) path() ([]string, error) {
+	tok := p.next()
+	if tok == "" || strings.HasPrefix(tok, ":") {
+		return nil, p.invalid("expected an attribute name")
+	}
+
+	parts := strings.Split(tok, ".")
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "#") {
+			continue
+		}
+
+		n, ok := p.names[part]
+		if !ok {
+			return nil, validationError("An expression attribute name used in the document path is not defined: " + part)
+		}
+
+		parts[i] = n
+	}
+
+	return parts, nil
+}
+
+func (p *expressionParser) value() (types.AttributeValue, error) {
+	tok := p.next()
+	v, ok := p.values[tok]
+	if !ok {
+		return nil, validationError("An expression attribute value used in expression is not defined: " + tok)
+	}
+
+	return v, nil
+}
+
+// operand is a value or the attribute at a path of the item, nil when the item doesn't have it
+func (p *expressionParser) operand(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	if strings.HasPrefix(p.peek(), ":") {
+		return p.value()
+	}
+
+	path, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+
+	return lookup(item, path), nil
+}
+
+func (p *expressionParser) or(item map[string]types.AttributeValue) (bool, error) {
+	holds, err := p.and(item)
+	if err != nil {
+		return false, err
+	}
+
+	for strings.EqualFold(p.peek(), "or") {
+		p.pos += 1
+		h, err := p.and(item)
+		if err != nil {
+			return false, err
+		}
+
+		holds = holds || h
+	}
+
+	return holds, nil
+}
+
+func (p *expressionParser) and(item map[string]types.AttributeValue) (bool, error) {
+	holds, err := p.not(item)
+	if err != nil {
+		return false, err
+	}
+
+	for strings.EqualFold(p.peek(), "and") {
+		p.pos += 1
+		h, err := p.not(item)
+		if err != nil {
+			return false, err
+		}
+
+		holds = holds && h
+	}
+
+	return holds, nil
+}
+
+func (p *expressionParser) not(item map[string]types.AttributeValue) (bool, error) {
+	if strings.EqualFold(p.peek(), "not") {
+		p.pos += 1
+		holds, err := p.not(item)
+		return !holds, err
+	}
+
+	return p.predicate(item)
+}
+
+func (p *expressionParser) predicate(item map[string]types.AttributeValue) (bool, error) {
+	switch strings.ToLower(p.peek()) {
+	case "(":
+		p.pos += 1
+		holds, err := p.or(item)
+		if err != nil {
+			return false, err
+		}
+
+		return holds, p.expect(")")
+	case "attribute_exists", "attribute_not_exists":
+		fn := strings.ToLower(p.next())
+		if err := p.expect("("); err != nil {
+			return false, err
+		}
+
+		path, err := p.path()
+		if err != nil {
+			return false, err
+		}
+
+		exists := lookup(item, path) != nil
+
+		return exists == (fn == "attribute_exists"), p.expect(")")
+	case "begins_with":
+		p.pos += 1
+		if err := p.expect("("); err != nil {
+			return false, err
+		}
+
+		a, err := p.operand(item)
+		if err != nil {
+			return false, err
+		}
+
+		if err = p.expect(","); err != nil {
+			return false, err
+		}
+
+		prefix, err := p.operand(item)
+		if err != nil {
+			return false, err
+		}
+
+		return a != nil && prefix != nil && beginsWith(a, prefix), p.expect(")")
+	}
+
+	a, err := p.operand(item)
+	if err != nil {
+		return false, err
+	}
+
+	op := strings.ToLower(p.next())
+	if op == "between" {
+		low, err := p.operand(item)
+		if err != nil {
+			return false, err
+		}
+
+		if err = p.expect("and"); err != nil {
+			return false, err
+		}
+
+		high, err := p.operand(item)
+		if err != nil {
+			return false, err
+		}
+
+		return ordered(a, low) && ordered(a, high) && compare(a, low) >= 0 && compare(a, high) <= 0, nil
+	}
+
+	b, err := p.operand(item)
+	if err != nil {
+		return false, err
+	}
+
+	switch op {
+	case "=":
+		return equal(a, b), nil
+	case "<>":
+		return !equal(a, b), nil
+	case "<":
+		return ordered(a, b) && compare(a, b) < 0, nil
+	case "<=":
+		return ordered(a, b) && compare(a, b) <= 0, nil
+	case ">":
+		return ordered(a, b) && compare(a, b) > 0, nil
+	case ">=":
+		return ordered(a, b) && compare(a, b) >= 0, nil
+	}
+
+	return false, p.invalid("unsupported operator: " + op)
+}
+
+// setValue is the right hand side of a SET action: an operand, if_not_exists(path, operand) or two of them added
+// or subtracted
+func (p *expressionParser) setValue(old map[string]types.AttributeValue) (types.AttributeValue, error) {
+	a, err := p.setOperand(old)
+	if err != nil {
+		return nil, err
+	}
+
+	op := p.peek()
+	if op != "+" && op != "-" {
+		if a == nil {
+			return nil, validationError("The provided expression refers to an attribute that does not exist in the item")
+		}
+
+		return a, nil
+	}
+
+	p.pos += 1
+	b, err := p.setOperand(old)
+	if err != nil {
+		return nil, err
+	}
+
+	x, okX := a.(*types.AttributeValueMemberN)
+	y, okY := b.(*types.AttributeValueMemberN)
+	if !okX || !okY {
+		return nil, validationError("An operand in the update expression has an incorrect data type")
+	}
+
+	if op == "-" {
+		return addNumbers(x, y, -1)
+	}
+
+	return addNumbers(x, y, 1)
+}
+
+func (p *expressionParser) setOperand(old map[string]types.AttributeValue) (types.AttributeValue, error) {
+	if !strings.EqualFold(p.peek(), "if_not_exists") {
+		return p.operand(old)
+	}
+
+	p.pos += 1
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	path, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.expect(","); err != nil {
+		return nil, err
+	}
+
+	fallback, err := p.operand(old)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if v := lookup(old, path); v != nil {
+		return v, nil
+	}
+
+	return fallback, nil
+}
+
+// add is what ADD makes of an attribute: a number summed with the value or a set joined with it
+func add(existing types.AttributeValue, v types.AttributeValue) (types.AttributeValue, error) {
+	if existing == nil {
+		return v, nil
+	}
+
+	switch e := existing.(type) {
+	case *types.AttributeValueMemberN:
+		if n, ok := v.(*types.AttributeValueMemberN); ok {
+			return addNumbers(e, n, 1)
+		}
+	case *types.AttributeValueMemberSS:
+		if s, ok := v.(*types.AttributeValueMemberSS); ok {
+			return &types.AttributeValueMemberSS{Value: union(e.Value, s.Value)}, nil
+		}
+	case *types.AttributeValueMemberNS:
+		if s, ok := v.(*types.AttributeValueMemberNS); ok {
+			return &types.AttributeValueMemberNS{Value: union(e.Value, s.Value)}, nil
+		}
+	}
+
+	return nil, validationError("Incorrect operand type for operator or function; operator: ADD")
+}
+
+// addNumbers adds sign times b to a exactly, keeping as many decimal places as the more precise number has
+func addNumbers(a *types.AttributeValueMemberN, b *types.AttributeValueMemberN, sign int64) (types.AttributeValue, error) {
+	x, okX := new(big.Rat).SetString(a.Value)
+	y, okY := new(big.Rat).SetString(b.Value)
+	if !okX || !okY {
+		return nil, validationError("An operand in the update expression has an incorrect data type")
+	}
+
+	sum := new(big.Rat).Add(x, y.Mul(y, big.NewRat(sign, 1)))
+	if sum.IsInt() {
+		return &types.AttributeValueMemberN{Value: sum.Num().String()}, nil
+	}
+
+	places := 0
+	for _, n := range []string{a.Value, b.Value} {
+		if i := strings.Index(n, "."); i >= 0 && len(n)-i-1 > places {
+			places = len(n) - i - 1
+		}
+	}
+
+	return &types.AttributeValueMemberN{Value: strings.TrimRight(sum.FloatString(places), "0")}, nil
+}
+
+func union(a []string, b []string) []string {
+	u := append([]string{}, a...)
+	for _, s := range b {
+		found := false
+		for _, e := range u {
+			if e == s {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			u = append(u, s)
+		}
+	}
+
+	return u
+}
+
+// equal compares key types by value and everything else by its attribute value. A missing attribute equals nothing
+func equal(a types.AttributeValue, b types.AttributeValue) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	if ordered(a, b) {
+		return compare(a, b) == 0
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// ordered reports whether both values are present and of the same key type, which dynamo can order
+func ordered(a types.AttributeValue, b types.AttributeValue) bool {
+	as, okA := scalar(a)
+	bs, okB := scalar(b)
+
+	return okA && okB && as[0] == bs[0]
+}
+
+func lookup(item map[string]types.AttributeValue, path []string) types.AttributeValue {
+	v, ok := item[path[0]]
+	if !ok {
+		return nil
+	}
+
+	if len(path) == 1 {
+		return v
+	}
+
+	m, ok := v.(*types.AttributeValueMemberM)
+	if !ok {
+		return nil
+	}
+
+	return lookup(m.Value, path[1:])
+}
+
+// setPath sets the attribute at a path, copying the maps along it so items already handed out aren't changed
+func setPath(item map[string]types.AttributeValue, path []string, v types.AttributeValue) error {
+	if len(path) == 1 {
+		item[path[0]] = v
+		return nil
+	}
+
+	m, ok := item[path[0]].(*types.AttributeValueMemberM)
+	if !ok {
+		return validationError("The document path provided in the update expression is invalid for update")
+	}
+
+	c := copyItem(m.Value)
+	if err := setPath(c, path[1:], v); err != nil {
+		return err
+	}
+
+	item[path[0]] = &types.AttributeValueMemberM{Value: c}
+
+	return nil
+}
+
+func removePath(item map[string]types.AttributeValue, path []string) {
+	if len(path) == 1 {
+		delete(item, path[0])
+		return
+	}
+
+	m, ok := item[path[0]].(*types.AttributeValueMemberM)
+	if !ok {
+		return
+	}
+
+	c := copyItem(m.Value)
+	removePath(c, path[1:])
+	item[path[0]] = &types.AttributeValueMemberM{Value: c}
+}
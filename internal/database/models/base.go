@@ -25,14 +25,22 @@ type IDatabaseClient interface {
 	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
 	DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error)
 	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
 }
 
 type ModelType string
 
 const (
-	ModelTypeCompany     ModelType = "company"
-	ModelTypeDataPoint   ModelType = "dataPoint"
-	ModelTypeTransaction ModelType = "transaction"
+	ModelTypeCompany         ModelType = "company"
+	ModelTypeDataPoint       ModelType = "dataPoint"
+	ModelTypeTransaction     ModelType = "transaction"
+	ModelTypeWatermark       ModelType = "watermark"
+	ModelTypeCorporateAction ModelType = "corporateAction"
+	ModelTypeTrade           ModelType = "trade"
+	ModelTypeQuote           ModelType = "quote"
+	ModelTypeWatchlist       ModelType = "watchlist"
+	ModelTypeQuarantine      ModelType = "quarantine"
 )
 
 const (
@@ -40,6 +48,11 @@ const (
 	DbSearchKey    = "sk"
 	DbGsi1Key      = "gsi1"
 	DbGsi2Key      = "gsi2"
+
+	DbGlobalPartitionKey1 = "gpk1"
+	DbGlobalSearchKey1    = "gsk1"
+	DbGlobalPartitionKey2 = "gpk2"
+	DbGlobalSearchKey2    = "gsk2"
 )
 
 type IDbModel interface {
@@ -82,14 +95,46 @@ func GetModelKeys(mt ModelType) ModelKeys {
 	switch mt {
 	case ModelTypeCompany:
 		mk = ModelKeys{
-			Pk: "type#company#",
-			Sk: "companyName#",
+			Pk:   CompanyModelKeyPk,
+			Sk:   CompanyModelKeySk,
+			Gpk1: CompanyModelKeyGpk1,
+			Gsk1: CompanyModelKeyGsk1,
 		}
 	case ModelTypeDataPoint:
 		mk = ModelKeys{
 			Pk: "type#dataPoint#compay#",
 			Sk: "timeStamp#",
 		}
+	case ModelTypeWatermark:
+		mk = ModelKeys{
+			Pk: WatermarkModelKeyPk,
+			Sk: WatermarkModelKeySk,
+		}
+	case ModelTypeCorporateAction:
+		mk = ModelKeys{
+			Pk: CorporateActionModelKeyPk,
+			Sk: CorporateActionModelKeySk,
+		}
+	case ModelTypeTrade:
+		mk = ModelKeys{
+			Pk: TradeModelKeyPk,
+			Sk: TickModelKeySk,
+		}
+	case ModelTypeQuote:
+		mk = ModelKeys{
+			Pk: QuoteModelKeyPk,
+			Sk: TickModelKeySk,
+		}
+	case ModelTypeWatchlist:
+		mk = ModelKeys{
+			Pk: WatchlistModelKeyPk,
+			Sk: WatchlistModelKeySk,
+		}
+	case ModelTypeQuarantine:
+		mk = ModelKeys{
+			Pk: QuarantineModelKeyPk,
+			Sk: DataPointModelKeySk,
+		}
 	case ModelTypeTransaction:
 		mk = ModelKeys{
 			Pk:   "transaction#",
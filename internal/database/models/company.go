@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	CompanyModelKeyPk   string = "type#company#"
+	CompanyModelKeySk   string = "companyName#"
+	CompanyModelKeyGpk1 string = "type#company#exchange#"
+	CompanyModelKeyGsk1 string = "companyName#"
+)
+
+type DbCompany struct {
+	Company
+	DataBaseModel
+}
+
+// Company is the reference data of a ticker. Every company shares one partition, sorted by ticker,
+// and index 1 partitions them by primary exchange
+type Company struct {
+	CompanyName     string    `json:"companyName" dynamodbav:"companyName"` // the ticker, named like every other model's ticker field
+	Name            string    `json:"name" dynamodbav:"name"`
+	Market          string    `json:"market" dynamodbav:"market"`
+	Locale          string    `json:"locale" dynamodbav:"locale"`
+	PrimaryExchange string    `json:"primaryExchange" dynamodbav:"primaryExchange"` // MIC code, such as XNAS
+	Type            string    `json:"type" dynamodbav:"type"`                       // polygon's ticker type, such as CS or ETF
+	Currency        string    `json:"currency" dynamodbav:"currency"`
+	Active          bool      `json:"active" dynamodbav:"active"`
+	ListDate        string    `json:"listDate,omitempty" dynamodbav:"listDate,omitempty"` // YYYY-MM-DD
+	DelistedAt      string    `json:"delistedAt,omitempty" dynamodbav:"delistedAt,omitempty"`
+	Cik             string    `json:"cik,omitempty" dynamodbav:"cik,omitempty"`
+	CompositeFigi   string    `json:"compositeFigi,omitempty" dynamodbav:"compositeFigi,omitempty"`
+	UpdatedAt       time.Time `json:"updatedAt,omitempty" dynamodbav:"updatedAt,omitempty"`
+}
+
+func CompanyKeys(companyName string) (string, string) {
+	return CompanyModelKeyPk, CompanyModelKeySk + companyName
+}
+
+func (c *Company) DatabaseModel() DbCompany {
+	pk, sk := CompanyKeys(c.CompanyName)
+
+	var gpk1, gsk1 string
+	if c.PrimaryExchange != "" {
+		gpk1 = CompanyModelKeyGpk1 + c.PrimaryExchange
+		gsk1 = CompanyModelKeyGsk1 + c.CompanyName
+	}
+
+	return DbCompany{
+		Company: *c,
+		DataBaseModel: DataBaseModel{
+			BaseDbModelWith2GlobalKeys{
+				BaseDbModelWith1GlobalKeys: BaseDbModelWith1GlobalKeys{
+					BaseDbModel: BaseDbModel{
+						Pk: pk,
+						Sk: sk,
+					},
+					GPK1: gpk1,
+					GSK1: gsk1,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	CorporateActionModelKeyPk string = "type#corporateAction#companyName#"
+	CorporateActionModelKeySk string = "exDate#"
+)
+
+type CorporateActionType string
+
+const (
+	CorporateActionTypeSplit    CorporateActionType = "split"
+	CorporateActionTypeDividend CorporateActionType = "dividend"
+)
+
+type DbCorporateAction struct {
+	CorporateAction
+	DataBaseModel
+}
+
+// CorporateAction is a split or cash dividend taking effect at the open on ExDate, a `YYYY-MM-DD` exchange day
+type CorporateAction struct {
+	Id           string              `json:"id" dynamodbav:"id"`
+	CompanyName  string              `json:"companyName" dynamodbav:"companyName"`
+	Type         CorporateActionType `json:"type" dynamodbav:"type"`
+	ExDate       string              `json:"exDate" dynamodbav:"exDate"`
+	SplitFrom    float64             `json:"splitFrom,omitempty" dynamodbav:"splitFrom,omitempty"`
+	SplitTo      float64             `json:"splitTo,omitempty" dynamodbav:"splitTo,omitempty"`
+	CashAmount   float64             `json:"cashAmount,omitempty" dynamodbav:"cashAmount,omitempty"`
+	Currency     string              `json:"currency,omitempty" dynamodbav:"currency,omitempty"`
+	DividendType string              `json:"dividendType,omitempty" dynamodbav:"dividendType,omitempty"`
+	CreatedAt    time.Time           `json:"createdAt,omitempty" dynamodbav:"createdAt,omitempty"`
+}
+
+// SplitRatio is the number of shares after the split for each share before it
+func (ca *CorporateAction) SplitRatio() float64 {
+	if ca.SplitFrom == 0 {
+		return 1
+	}
+
+	return ca.SplitTo / ca.SplitFrom
+}
+
+func (ca *CorporateAction) DatabaseModel() DbCorporateAction {
+	return DbCorporateAction{
+		CorporateAction: *ca,
+		DataBaseModel: DataBaseModel{
+			BaseDbModelWith2GlobalKeys{
+				BaseDbModelWith1GlobalKeys: BaseDbModelWith1GlobalKeys{
+					BaseDbModel: BaseDbModel{
+						Pk: CorporateActionModelKeyPk + ca.CompanyName,
+						Sk: CorporateActionModelKeySk + ca.ExDate + "#" + string(ca.Type) + "#" + ca.Id,
+					},
+				},
+			},
+		},
+	}
+}
@@ -7,10 +7,32 @@ import (
 )
 
 const (
-	DataPointModelKeyPk string = "type#dataPoint#name#"
-	DataPointModelKeySk string = "timeStamp#"
+	DataPointModelKeyPk         string = "type#dataPoint#name#"
+	DataPointAdjustedModelKeyPk string = "type#dataPoint#adjusted#name#"
+	DataPointModelKeySk         string = "timeStamp#"
 )
 
+// DataPointPk returns the partition key of a company's series, adjusted bars are stored beside the unadjusted ones.
+// Minute bars keep the original keys, bars of any other size get a partition of their own, such as `#timespan#5minute`
+func DataPointPk(companyName string, timespan model.PolygonAggregateTimespan, multiplier int, adjusted bool) string {
+	pk := DataPointModelKeyPk + companyName
+	if adjusted {
+		pk = DataPointAdjustedModelKeyPk + companyName
+	}
+
+	if timespan == "" {
+		timespan = model.PolygonAggregateTimespanMinute
+	}
+
+	if multiplier > 1 {
+		pk += "#timespan#" + strconv.Itoa(multiplier) + string(timespan)
+	} else if timespan != model.PolygonAggregateTimespanMinute {
+		pk += "#timespan#" + string(timespan)
+	}
+
+	return pk
+}
+
 type DbDataPoint struct {
 	DataPoint
 	DataBaseModel
@@ -18,9 +40,12 @@ type DbDataPoint struct {
 
 type DataPoint struct {
 	model.PolygonDataPoint
-	CompanyName string    `json:"companyName" dynamodbav:"companyName"`
-	CreatedAt   time.Time `json:"createdAt,omitempty" dynamodbav:"omitempty,createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt,omitempty" dynamodbav:"omitempty,updatedAt"`
+	CompanyName string                         `json:"companyName" dynamodbav:"companyName"`
+	Timespan    model.PolygonAggregateTimespan `json:"timespan,omitempty" dynamodbav:"timespan,omitempty"`     // empty for minute bars saved before timespans were recorded
+	Multiplier  int                            `json:"multiplier,omitempty" dynamodbav:"multiplier,omitempty"` // number of timespans in the bar, empty for 1
+	Adjusted    bool                           `json:"adjusted" dynamodbav:"adjusted"`
+	CreatedAt   time.Time                      `json:"createdAt,omitempty" dynamodbav:"omitempty,createdAt"`
+	UpdatedAt   time.Time                      `json:"updatedAt,omitempty" dynamodbav:"omitempty,updatedAt"`
 }
 
 func (dp *DataPoint) XVal() float64 {
@@ -42,7 +67,7 @@ func (dp *DataPoint) DatabaseModel() DbDataPoint {
 			BaseDbModelWith2GlobalKeys{
 				BaseDbModelWith1GlobalKeys: BaseDbModelWith1GlobalKeys{
 					BaseDbModel: BaseDbModel{
-						Pk: DataPointModelKeyPk + dp.CompanyName,
+						Pk: DataPointPk(dp.CompanyName, dp.Timespan, dp.Multiplier, dp.Adjusted),
 						Sk: DataPointModelKeySk + strconv.FormatInt(dp.StartTime, 10),
 					},
 				},
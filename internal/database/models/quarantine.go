@@ -0,0 +1,43 @@
+package models
+
+import (
+	"github.com/greenac/chaching/internal/rest/polygon/models"
+	"strconv"
+	"time"
+)
+
+const QuarantineModelKeyPk string = "type#quarantine#"
+
+// QuarantinePk returns the partition holding the bars of a series that failed validation, beside but apart from
+// the series itself so queries of the series never return them
+func QuarantinePk(companyName string, timespan model.PolygonAggregateTimespan, multiplier int, adjusted bool) string {
+	return QuarantineModelKeyPk + DataPointPk(companyName, timespan, multiplier, adjusted)
+}
+
+type DbQuarantinedDataPoint struct {
+	QuarantinedDataPoint
+	DataBaseModel
+}
+
+// QuarantinedDataPoint is a bar held back from its series, with the reasons it failed validation
+type QuarantinedDataPoint struct {
+	DataPoint
+	Reasons       []string  `json:"reasons" dynamodbav:"reasons"`
+	QuarantinedAt time.Time `json:"quarantinedAt" dynamodbav:"quarantinedAt"`
+}
+
+func (q *QuarantinedDataPoint) DatabaseModel() DbQuarantinedDataPoint {
+	return DbQuarantinedDataPoint{
+		QuarantinedDataPoint: *q,
+		DataBaseModel: DataBaseModel{
+			BaseDbModelWith2GlobalKeys{
+				BaseDbModelWith1GlobalKeys: BaseDbModelWith1GlobalKeys{
+					BaseDbModel: BaseDbModel{
+						Pk: QuarantinePk(q.CompanyName, q.Timespan, q.Multiplier, q.Adjusted),
+						Sk: DataPointModelKeySk + strconv.FormatInt(q.StartTime, 10),
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"github.com/greenac/chaching/internal/rest/polygon/models"
+	"time"
+)
+
+const (
+	TradeModelKeyPk string = "type#trade#name#"
+	QuoteModelKeyPk string = "type#quote#name#"
+	TickModelKeySk  string = "sipTimestamp#"
+)
+
+// TradePk returns the partition of one company's trades on one exchange day, formatted `YYYY-MM-DD`,
+// so a busy ticker's ticks are spread across a partition per day
+func TradePk(companyName string, day string) string {
+	return TradeModelKeyPk + companyName + "#day#" + day
+}
+
+func QuotePk(companyName string, day string) string {
+	return QuoteModelKeyPk + companyName + "#day#" + day
+}
+
+// TickSk orders ticks by their SIP timestamp. The nanos are zero padded so the keys sort in time order, and
+// the sequence number keeps ticks that share a timestamp apart
+func TickSk(sipTimestamp int64, sequenceNumber int64) string {
+	return fmt.Sprintf("%s%019d#%d", TickModelKeySk, sipTimestamp, sequenceNumber)
+}
+
+// TickSkBound is the sort key to compare against when querying ticks from or until a time, it sorts before
+// every tick at that time
+func TickSkBound(t time.Time) string {
+	return fmt.Sprintf("%s%019d", TickModelKeySk, t.UnixNano())
+}
+
+type DbTrade struct {
+	Trade
+	DataBaseModel
+}
+
+type Trade struct {
+	model.PolygonTrade
+	CompanyName string    `json:"companyName" dynamodbav:"companyName"`
+	Day         string    `json:"day" dynamodbav:"day"` // exchange day of the trade, `YYYY-MM-DD`
+	CreatedAt   time.Time `json:"createdAt,omitempty" dynamodbav:"createdAt,omitempty"`
+}
+
+func (t *Trade) Time() time.Time {
+	return time.Unix(0, t.SipTimestamp)
+}
+
+func (t *Trade) DatabaseModel() DbTrade {
+	return DbTrade{
+		Trade: *t,
+		DataBaseModel: DataBaseModel{
+			BaseDbModelWith2GlobalKeys{
+				BaseDbModelWith1GlobalKeys: BaseDbModelWith1GlobalKeys{
+					BaseDbModel: BaseDbModel{
+						Pk: TradePk(t.CompanyName, t.Day),
+						Sk: TickSk(t.SipTimestamp, t.SequenceNumber),
+					},
+				},
+			},
+		},
+	}
+}
+
+type DbQuote struct {
+	Quote
+	DataBaseModel
+}
+
+type Quote struct {
+	model.PolygonQuote
+	CompanyName string    `json:"companyName" dynamodbav:"companyName"`
+	Day         string    `json:"day" dynamodbav:"day"` // exchange day of the quote, `YYYY-MM-DD`
+	CreatedAt   time.Time `json:"createdAt,omitempty" dynamodbav:"createdAt,omitempty"`
+}
+
+func (q *Quote) Time() time.Time {
+	return time.Unix(0, q.SipTimestamp)
+}
+
+func (q *Quote) DatabaseModel() DbQuote {
+	return DbQuote{
+		Quote: *q,
+		DataBaseModel: DataBaseModel{
+			BaseDbModelWith2GlobalKeys{
+				BaseDbModelWith1GlobalKeys: BaseDbModelWith1GlobalKeys{
+					BaseDbModel: BaseDbModel{
+						Pk: QuotePk(q.CompanyName, q.Day),
+						Sk: TickSk(q.SipTimestamp, q.SequenceNumber),
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	WatchlistModelKeyPk string = "type#watchlist#"
+	WatchlistModelKeySk string = "name#"
+)
+
+type DbWatchlist struct {
+	Watchlist
+	DataBaseModel
+}
+
+// Watchlist is a named set of tickers that jobs can target instead of listing tickers themselves
+type Watchlist struct {
+	Name      string    `json:"name" dynamodbav:"name"`
+	Tickers   []string  `json:"tickers" dynamodbav:"tickers"`
+	CreatedAt time.Time `json:"createdAt,omitempty" dynamodbav:"createdAt,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty" dynamodbav:"updatedAt,omitempty"`
+}
+
+func WatchlistKeys(name string) (string, string) {
+	return WatchlistModelKeyPk, WatchlistModelKeySk + name
+}
+
+func (w *Watchlist) DatabaseModel() DbWatchlist {
+	pk, sk := WatchlistKeys(w.Name)
+
+	return DbWatchlist{
+		Watchlist: *w,
+		DataBaseModel: DataBaseModel{
+			BaseDbModelWith2GlobalKeys{
+				BaseDbModelWith1GlobalKeys: BaseDbModelWith1GlobalKeys{
+					BaseDbModel: BaseDbModel{
+						Pk: pk,
+						Sk: sk,
+					},
+				},
+			},
+		},
+	}
+}
+
+// NormalizeTickers upper cases and trims tickers, dropping blanks and duplicates while keeping their order
+func NormalizeTickers(tickers []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, t := range tickers {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		if t != "" && !seen[t] {
+			seen[t] = true
+			normalized = append(normalized, t)
+		}
+	}
+
+	return normalized
+}
+
+// Add returns the watchlist's tickers with tickers added, sorted
+func (w *Watchlist) Add(tickers []string) []string {
+	merged := NormalizeTickers(append(append([]string{}, w.Tickers...), tickers...))
+	sort.Strings(merged)
+
+	return merged
+}
+
+// Remove returns the watchlist's tickers without tickers
+func (w *Watchlist) Remove(tickers []string) []string {
+	drop := map[string]bool{}
+	for _, t := range NormalizeTickers(tickers) {
+		drop[t] = true
+	}
+
+	kept := []string{}
+	for _, t := range w.Tickers {
+		if !drop[t] {
+			kept = append(kept, t)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"fmt"
+	"github.com/greenac/chaching/internal/rest/polygon/models"
+	"time"
+)
+
+const (
+	WatermarkModelKeyPk string = "type#watermark#companyName#"
+	WatermarkModelKeySk string = "timespan#"
+)
+
+type DbWatermark struct {
+	Watermark
+	DataBaseModel
+}
+
+// Watermark records how far the bars of one company and aggregate size have been fetched and saved
+type Watermark struct {
+	CompanyName string                         `json:"companyName" dynamodbav:"companyName"`
+	Timespan    model.PolygonAggregateTimespan `json:"timespan" dynamodbav:"timespan"`
+	Multiplier  int                            `json:"multiplier" dynamodbav:"multiplier"`
+	Adjusted    bool                           `json:"adjusted" dynamodbav:"adjusted"`
+	Through     time.Time                      `json:"through" dynamodbav:"through"`         // every bar starting at or before this time has been saved
+	LastBarTime int64                          `json:"lastBarTime" dynamodbav:"lastBarTime"` // start time of the newest saved bar (millis)
+	UpdatedAt   time.Time                      `json:"updatedAt,omitempty" dynamodbav:"updatedAt,omitempty"`
+}
+
+func WatermarkKeys(companyName string, timespan model.PolygonAggregateTimespan, multiplier int, adjusted bool) (string, string) {
+	sk := fmt.Sprintf("%s%d#%s", WatermarkModelKeySk, multiplier, timespan)
+	if adjusted {
+		sk += "#adjusted"
+	}
+
+	return WatermarkModelKeyPk + companyName, sk
+}
+
+func (w *Watermark) DatabaseModel() DbWatermark {
+	pk, sk := WatermarkKeys(w.CompanyName, w.Timespan, w.Multiplier, w.Adjusted)
+
+	return DbWatermark{
+		Watermark: *w,
+		DataBaseModel: DataBaseModel{
+			BaseDbModelWith2GlobalKeys{
+				BaseDbModelWith1GlobalKeys: BaseDbModelWith1GlobalKeys{
+					BaseDbModel: BaseDbModel{Pk: pk, Sk: sk},
+				},
+			},
+		},
+	}
+}
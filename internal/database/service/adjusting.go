@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/service/adjustment"
+	"time"
+)
+
+// how far before a dividend's ex date to look for the previous close
+const prevCloseLookback = 10 * 24 * time.Hour
+
+var _ IDatabaseService = (*AdjustingDatabaseService)(nil)
+
+// AdjustingDatabaseService reads unadjusted bars and adjusts them for the company's stored corporate actions
+type AdjustingDatabaseService struct {
+	DatabaseService   IDatabaseService
+	CorporateActions  ICorporateActionService
+	AdjustmentService adjustment.IAdjustmentService
+	Options           adjustment.AdjustOptions
+}
+
+func (ads *AdjustingDatabaseService) SaveDataPoints(ctx context.Context, dps []models.DataPoint) *[]genErr.IGenError {
+	return ads.DatabaseService.SaveDataPoints(ctx, dps)
+}
+
+func (ads *AdjustingDatabaseService) GetDataPointsInTimeRange(ctx context.Context, companyName string, startDate time.Time, endDate time.Time) ([]models.DataPoint, genErr.IGenError) {
+	dps, ge := ads.DatabaseService.GetDataPointsInTimeRange(ctx, companyName, startDate, endDate)
+	if ge != nil {
+		return dps, ge
+	}
+
+	actions, ge := ads.CorporateActions.GetCorporateActions(ctx, companyName)
+	if ge != nil {
+		return []models.DataPoint{}, ge.AddMsg("AdjustingDatabaseService:GetDataPointsInTimeRange:failed to adjust data points for: " + companyName)
+	}
+
+	var lookupErr genErr.IGenError
+	factors := ads.AdjustmentService.Factors(actions, ads.Options, func(exDate time.Time) (float64, bool) {
+		if !exDate.After(startDate) || lookupErr != nil {
+			// the action does not touch this range, or a lookup already failed
+			return 0, false
+		}
+
+		prev, ge := ads.DatabaseService.GetDataPointsInTimeRange(ctx, companyName, exDate.Add(-prevCloseLookback), exDate.Add(-time.Millisecond))
+		if ge != nil {
+			lookupErr = ge
+			return 0, false
+		}
+
+		return adjustment.LastCloseBefore(prev, exDate)
+	})
+
+	if lookupErr != nil {
+		return []models.DataPoint{}, lookupErr.AddMsg("AdjustingDatabaseService:GetDataPointsInTimeRange:failed to find the close before a dividend for: " + companyName)
+	}
+
+	return ads.AdjustmentService.Apply(dps, factors), nil
+}
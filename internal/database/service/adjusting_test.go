@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/adjustment"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type rangeDatabaseServiceMock struct {
+	dps    []models.DataPoint
+	ranges [][2]time.Time
+}
+
+func (dm *rangeDatabaseServiceMock) SaveDataPoints(ctx context.Context, dps []models.DataPoint) *[]genErr.IGenError {
+	return nil
+}
+
+func (dm *rangeDatabaseServiceMock) GetDataPointsInTimeRange(ctx context.Context, companyName string, startDate time.Time, endDate time.Time) ([]models.DataPoint, genErr.IGenError) {
+	dm.ranges = append(dm.ranges, [2]time.Time{startDate, endDate})
+	var dps []models.DataPoint
+	for _, dp := range dm.dps {
+		if dp.StartTime >= startDate.UnixMilli() && dp.StartTime <= endDate.UnixMilli() {
+			dps = append(dps, dp)
+		}
+	}
+
+	return dps, nil
+}
+
+type corporateActionServiceMock struct {
+	actions []models.CorporateAction
+}
+
+func (cm *corporateActionServiceMock) SaveCorporateActions(ctx context.Context, actions []models.CorporateAction) genErr.IGenError {
+	cm.actions = append(cm.actions, actions...)
+	return nil
+}
+
+func (cm *corporateActionServiceMock) GetCorporateActions(ctx context.Context, companyName string) ([]models.CorporateAction, genErr.IGenError) {
+	return cm.actions, nil
+}
+
+func TestAdjustingDatabaseService_GetDataPointsInTimeRange(t *testing.T) {
+	Convey("TestAdjustingDatabaseService_GetDataPointsInTimeRange", t, func() {
+		loc, _ := time.LoadLocation("America/New_York")
+		friday := time.Date(2023, 3, 3, 15, 59, 0, 0, loc)
+		monday := time.Date(2023, 3, 6, 9, 30, 0, 0, loc)
+		dm := &rangeDatabaseServiceMock{dps: []models.DataPoint{
+			{CompanyName: "AAPL", PolygonDataPoint: model.PolygonDataPoint{StartTime: friday.UnixMilli(), ClosePrice: 50}},
+			{CompanyName: "AAPL", PolygonDataPoint: model.PolygonDataPoint{StartTime: monday.UnixMilli(), ClosePrice: 49}},
+		}}
+		cm := &corporateActionServiceMock{actions: []models.CorporateAction{
+			{Id: "d", CompanyName: "AAPL", Type: models.CorporateActionTypeDividend, ExDate: "2023-03-06", CashAmount: 1},
+		}}
+		ads := AdjustingDatabaseService{DatabaseService: dm, CorporateActions: cm, AdjustmentService: adjustment.NewAdjustmentService(loc), Options: adjustment.AdjustOptions{Dividends: true}}
+
+		Convey("TestAdjustingDatabaseService_GetDataPointsInTimeRange should look up the close before a dividend outside the range", func() {
+			dps, ge := ads.GetDataPointsInTimeRange(context.Background(), "AAPL", friday.Add(-time.Hour), friday.Add(time.Minute))
+			So(ge, ShouldBeNil)
+			So(len(dps), ShouldEqual, 1)
+			So(dps[0].ClosePrice, ShouldAlmostEqual, 49)
+			So(dps[0].Adjusted, ShouldBeTrue)
+			So(len(dm.ranges), ShouldEqual, 2)
+		})
+
+		Convey("TestAdjustingDatabaseService_GetDataPointsInTimeRange should not look up actions before the range", func() {
+			dps, ge := ads.GetDataPointsInTimeRange(context.Background(), "AAPL", monday, monday.Add(time.Hour))
+			So(ge, ShouldBeNil)
+			So(len(dps), ShouldEqual, 1)
+			So(dps[0].ClosePrice, ShouldEqual, 49)
+			So(len(dm.ranges), ShouldEqual, 1)
+		})
+	})
+}
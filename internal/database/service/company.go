@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/service/database"
+	"strings"
+	"time"
+)
+
+// CompanyFilter narrows the companies listed, empty fields match every company
+type CompanyFilter struct {
+	Exchange   string
+	Type       string
+	ActiveOnly bool
+}
+
+func (f CompanyFilter) matches(c models.Company) bool {
+	if f.Exchange != "" && !strings.EqualFold(c.PrimaryExchange, f.Exchange) {
+		return false
+	}
+
+	if f.Type != "" && !strings.EqualFold(c.Type, f.Type) {
+		return false
+	}
+
+	return !f.ActiveOnly || c.Active
+}
+
+type ICompanyService interface {
+	SaveCompanies(ctx context.Context, companies []models.Company) genErr.IGenError
+	GetCompany(ctx context.Context, companyName string) (models.Company, bool, genErr.IGenError)
+	ListCompanies(ctx context.Context, filter CompanyFilter) ([]models.Company, genErr.IGenError)
+	ListTickers(ctx context.Context, filter CompanyFilter) ([]string, genErr.IGenError)
+}
+
+var _ ICompanyService = (*CompanyService)(nil)
+
+// NewCompanyService creates a service that lists companies by exchange through exchangeIndex, the table's first global index
+func NewCompanyService(database database.IDatabase[models.DbCompany], exchangeIndex string, now func() time.Time) ICompanyService {
+	return &CompanyService{database: database, exchangeIndex: exchangeIndex, now: now}
+}
+
+type CompanyService struct {
+	database      database.IDatabase[models.DbCompany]
+	exchangeIndex string
+	now           func() time.Time
+}
+
+func (cs *CompanyService) SaveCompanies(ctx context.Context, companies []models.Company) genErr.IGenError {
+	dbModels := make([]models.DbCompany, len(companies))
+	for i, c := range companies {
+		c.UpdatedAt = cs.now()
+		dbModels[i] = c.DatabaseModel()
+	}
+
+	if err := cs.database.BatchWrite(ctx, dbModels); err != nil {
+		return &genErr.GenError{Messages: []string{"CompanyService:SaveCompanies:failed to save companies with error: " + err.Error()}}
+	}
+
+	return nil
+}
+
+// GetCompany returns the stored company, reporting false when the ticker has not been ingested
+func (cs *CompanyService) GetCompany(ctx context.Context, companyName string) (models.Company, bool, genErr.IGenError) {
+	pk, sk := models.CompanyKeys(companyName)
+	m, err := cs.database.GetItem(ctx, map[string]types.AttributeValue{
+		models.DbPartitionKey: &types.AttributeValueMemberS{Value: pk},
+		models.DbSearchKey:    &types.AttributeValueMemberS{Value: sk},
+	})
+	if err != nil {
+		return models.Company{}, false, &genErr.GenError{Messages: []string{"CompanyService:GetCompany:failed to get company: " + companyName + " with error: " + err.Error()}}
+	}
+
+	if m.Pk == "" {
+		return models.Company{}, false, nil
+	}
+
+	return m.Company, true, nil
+}
+
+// ListCompanies returns the stored companies ordered by ticker, reading only the exchange's partition
+// of the exchange index when the filter has an exchange
+func (cs *CompanyService) ListCompanies(ctx context.Context, filter CompanyFilter) ([]models.Company, genErr.IGenError) {
+	qry := map[string]types.Condition{
+		models.DbPartitionKey: {
+			ComparisonOperator: types.ComparisonOperatorEq,
+			AttributeValueList: []types.AttributeValue{&types.AttributeValueMemberS{Value: models.CompanyModelKeyPk}},
+		},
+	}
+	index := ""
+
+	if filter.Exchange != "" {
+		qry = map[string]types.Condition{
+			models.DbGlobalPartitionKey1: {
+				ComparisonOperator: types.ComparisonOperatorEq,
+				AttributeValueList: []types.AttributeValue{&types.AttributeValueMemberS{Value: models.CompanyModelKeyGpk1 + strings.ToUpper(filter.Exchange)}},
+			},
+		}
+		index = cs.exchangeIndex
+	}
+
+	dbCompanies, err := cs.database.Query(ctx, qry, index)
+	if err != nil {
+		return []models.Company{}, &genErr.GenError{Messages: []string{"CompanyService:ListCompanies:failed to list companies with error: " + err.Error()}}
+	}
+
+	companies := []models.Company{}
+	for _, c := range dbCompanies {
+		if filter.matches(c.Company) {
+			companies = append(companies, c.Company)
+		}
+	}
+
+	return companies, nil
+}
+
+func (cs *CompanyService) ListTickers(ctx context.Context, filter CompanyFilter) ([]string, genErr.IGenError) {
+	companies, ge := cs.ListCompanies(ctx, filter)
+	if ge != nil {
+		return []string{}, ge.AddMsg("CompanyService:ListTickers:failed to list tickers")
+	}
+
+	tickers := make([]string, len(companies))
+	for i, c := range companies {
+		tickers[i] = c.CompanyName
+	}
+
+	return tickers, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/greenac/chaching/internal/database/models"
+	dbMocks "github.com/greenac/chaching/internal/service/database/mocks"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompanyService(t *testing.T) {
+	Convey("TestCompanyService", t, func() {
+		now := time.Date(2023, 3, 2, 8, 0, 0, 0, time.UTC)
+		dm := &dbMocks.DatabaseMock[models.DbCompany]{}
+		cs := NewCompanyService(dm, "ChachingIndex1", func() time.Time { return now })
+		So(cs.SaveCompanies(context.Background(), []models.Company{
+			{CompanyName: "AAPL", PrimaryExchange: "XNAS", Type: "CS", Active: true},
+			{CompanyName: "QQQ", PrimaryExchange: "XNAS", Type: "ETF", Active: true},
+			{CompanyName: "TWTR", PrimaryExchange: "XNYS", Type: "CS", Active: false},
+		}), ShouldBeNil)
+
+		Convey("TestCompanyService should key companies by ticker and index them by exchange", func() {
+			So(dm.Items[0].Pk, ShouldEqual, "type#company#")
+			So(dm.Items[0].Sk, ShouldEqual, "companyName#AAPL")
+			So(dm.Items[0].GPK1, ShouldEqual, "type#company#exchange#XNAS")
+			So(dm.Items[0].GSK1, ShouldEqual, "companyName#AAPL")
+			So(dm.Items[0].UpdatedAt, ShouldEqual, now)
+		})
+
+		Convey("TestCompanyService should look up a company by ticker", func() {
+			c, ok, ge := cs.GetCompany(context.Background(), "QQQ")
+			So(ge, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(c.Type, ShouldEqual, "ETF")
+
+			_, ok, ge = cs.GetCompany(context.Background(), "NOPE")
+			So(ge, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("TestCompanyService should list an exchange through the exchange index", func() {
+			tickers, ge := cs.ListTickers(context.Background(), CompanyFilter{Exchange: "xnas", Type: "cs", ActiveOnly: true})
+			So(ge, ShouldBeNil)
+			So(tickers, ShouldResemble, []string{"AAPL"})
+			So(dm.QueryIndex, ShouldEqual, "ChachingIndex1")
+			So(dm.QueryKey[models.DbGlobalPartitionKey1].AttributeValueList[0].(*types.AttributeValueMemberS).Value, ShouldEqual, "type#company#exchange#XNAS")
+		})
+
+		Convey("TestCompanyService should list every company from the company partition", func() {
+			tickers, ge := cs.ListTickers(context.Background(), CompanyFilter{})
+			So(ge, ShouldBeNil)
+			So(tickers, ShouldResemble, []string{"AAPL", "QQQ", "TWTR"})
+			So(dm.QueryIndex, ShouldEqual, "")
+			So(dm.QueryKey[models.DbPartitionKey].AttributeValueList[0].(*types.AttributeValueMemberS).Value, ShouldEqual, "type#company#")
+		})
+	})
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/service/database"
+)
+
+type ICorporateActionService interface {
+	SaveCorporateActions(ctx context.Context, actions []models.CorporateAction) genErr.IGenError
+	GetCorporateActions(ctx context.Context, companyName string) ([]models.CorporateAction, genErr.IGenError)
+}
+
+var _ ICorporateActionService = (*CorporateActionService)(nil)
+
+func NewCorporateActionService(database database.IDatabase[models.DbCorporateAction]) ICorporateActionService {
+	return &CorporateActionService{database: database}
+}
+
+type CorporateActionService struct {
+	database database.IDatabase[models.DbCorporateAction]
+}
+
+func (cs *CorporateActionService) SaveCorporateActions(ctx context.Context, actions []models.CorporateAction) genErr.IGenError {
+	dbModels := make([]models.DbCorporateAction, len(actions))
+	for i, a := range actions {
+		dbModels[i] = a.DatabaseModel()
+	}
+
+	err := cs.database.BatchWrite(ctx, dbModels)
+	if err != nil {
+		return &genErr.GenError{Messages: []string{"CorporateActionService:SaveCorporateActions:failed to save corporate actions with error: " + err.Error()}}
+	}
+
+	return nil
+}
+
+// GetCorporateActions returns every split and dividend stored for the company, ordered by ex date
+func (cs *CorporateActionService) GetCorporateActions(ctx context.Context, companyName string) ([]models.CorporateAction, genErr.IGenError) {
+	qry := map[string]types.Condition{
+		models.DbPartitionKey: {
+			ComparisonOperator: types.ComparisonOperatorEq,
+			AttributeValueList: []types.AttributeValue{
+				&types.AttributeValueMemberS{Value: models.CorporateActionModelKeyPk + companyName},
+			},
+		},
+	}
+
+	dbActions, err := cs.database.Query(ctx, qry, "")
+	if err != nil {
+		return []models.CorporateAction{}, &genErr.GenError{Messages: []string{"CorporateActionService:GetCorporateActions:failed to get corporate actions for: " + companyName + " with error: " + err.Error()}}
+	}
+
+	actions := make([]models.CorporateAction, len(dbActions))
+	for i, a := range dbActions {
+		actions[i] = a.CorporateAction
+	}
+
+	return actions, nil
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/greenac/chaching/internal/database/models"
 	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
 	"github.com/greenac/chaching/internal/service/database"
 	"strconv"
 	"time"
@@ -21,8 +22,21 @@ func NewDatabaseService(database database.IDatabase[models.DbDataPoint]) IDataba
 	return &DatabaseService{database: database}
 }
 
+// NewAdjustedDatabaseService reads the adjusted bars fetched from polygon rather than the unadjusted ones
+func NewAdjustedDatabaseService(database database.IDatabase[models.DbDataPoint]) IDatabaseService {
+	return &DatabaseService{database: database, adjusted: true}
+}
+
+// NewTimespanDatabaseService reads the bars of one size, such as the day bars from grouped daily fetches
+func NewTimespanDatabaseService(database database.IDatabase[models.DbDataPoint], timespan model.PolygonAggregateTimespan, multiplier int, adjusted bool) IDatabaseService {
+	return &DatabaseService{database: database, timespan: timespan, multiplier: multiplier, adjusted: adjusted}
+}
+
 type DatabaseService struct {
-	database database.IDatabase[models.DbDataPoint]
+	database   database.IDatabase[models.DbDataPoint]
+	timespan   model.PolygonAggregateTimespan
+	multiplier int
+	adjusted   bool
 }
 
 func (dbs *DatabaseService) SaveDataPoints(ctx context.Context, dps []models.DataPoint) *[]genErr.IGenError {
@@ -35,11 +49,10 @@ func (dbs *DatabaseService) SaveDataPoints(ctx context.Context, dps []models.Dat
 	err := dbs.database.BatchWrite(ctx, dbModels)
 	if err != nil {
 		var genErrs []genErr.IGenError
-		switch err.(type) {
-		case database.BatchWriteError[models.DataPoint]:
-			bwe := err.(database.BatchWriteError[models.DataPoint])
+		switch bwe := err.(type) {
+		case database.BatchWriteError[models.DbDataPoint]:
 			for _, e := range bwe.FailedWrites {
-				genErrs = append(genErrs, &genErr.GenError{Messages: []string{e.Error.Error()}})
+				genErrs = append(genErrs, &genErr.GenError{Messages: []string{"DatabaseService:SaveDataPoints:failed to save " + e.Input.CompanyName + " bar at: " + strconv.FormatInt(e.Input.StartTime, 10) + " with error: " + e.Error.Error()}})
 			}
 		default:
 			genErrs = []genErr.IGenError{&genErr.GenError{Messages: []string{err.Error()}}}
@@ -55,14 +68,14 @@ func (dbs *DatabaseService) GetDataPointsInTimeRange(ctx context.Context, compan
 		models.DbPartitionKey: {
 			ComparisonOperator: types.ComparisonOperatorEq,
 			AttributeValueList: []types.AttributeValue{
-				&types.AttributeValueMemberS{Value: models.DataPointModelKeyPk + companyName},
+				&types.AttributeValueMemberS{Value: models.DataPointPk(companyName, dbs.timespan, dbs.multiplier, dbs.adjusted)},
 			},
 		},
 		models.DbSearchKey: {
 			ComparisonOperator: types.ComparisonOperatorBetween,
 			AttributeValueList: []types.AttributeValue{
-				&types.AttributeValueMemberS{Value: models.DataPointModelKeySk + strconv.FormatInt(startDate.Unix(), 10)},
-				&types.AttributeValueMemberS{Value: models.DataPointModelKeySk + strconv.FormatInt(endDate.Unix(), 10)},
+				&types.AttributeValueMemberS{Value: models.DataPointModelKeySk + strconv.FormatInt(startDate.UnixMilli(), 10)},
+				&types.AttributeValueMemberS{Value: models.DataPointModelKeySk + strconv.FormatInt(endDate.UnixMilli(), 10)},
 			},
 		},
 	}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/greenac/chaching/internal/database/mocks"
+	"github.com/greenac/chaching/internal/database/models"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/database"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDatabaseService(t *testing.T) {
+	Convey("TestDatabaseService", t, func() {
+		ctx := context.Background()
+		fake := mocks.NewDynamoFake(mocks.ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+		dbs := NewDatabaseService(database.NewDatabase[models.DbDataPoint](fake, 25, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap))
+		start := time.Date(2023, 3, 1, 14, 30, 0, 0, time.UTC)
+
+		var dps []models.DataPoint
+		for i := 0; i < 60; i += 1 {
+			dps = append(dps, models.DataPoint{CompanyName: "AAPL", PolygonDataPoint: model.PolygonDataPoint{StartTime: start.Add(time.Duration(i) * time.Minute).UnixMilli(), ClosePrice: float64(i)}})
+		}
+
+		Convey("TestDatabaseService should save bars in batches and read back a range across pages", func() {
+			So(dbs.SaveDataPoints(ctx, dps), ShouldBeNil)
+			So(fake.Calls(mocks.OperationBatchWriteItem), ShouldEqual, 3)
+
+			fake.PageSize = 7
+			got, ge := dbs.GetDataPointsInTimeRange(ctx, "AAPL", start.Add(10*time.Minute), start.Add(39*time.Minute))
+			So(ge, ShouldBeNil)
+			So(len(got), ShouldEqual, 30)
+			So(got[0], ShouldResemble, dps[10])
+			So(got[29], ShouldResemble, dps[39])
+			So(fake.Calls(mocks.OperationQuery), ShouldEqual, 5)
+		})
+
+		Convey("TestDatabaseService should not read bars of other series", func() {
+			other := models.DataPoint{CompanyName: "AAPL", Adjusted: true, PolygonDataPoint: model.PolygonDataPoint{StartTime: start.UnixMilli()}}
+			So(dbs.SaveDataPoints(ctx, []models.DataPoint{dps[0], other}), ShouldBeNil)
+
+			got, ge := dbs.GetDataPointsInTimeRange(ctx, "AAPL", start, start.Add(time.Hour))
+			So(ge, ShouldBeNil)
+			So(got, ShouldResemble, []models.DataPoint{dps[0]})
+		})
+
+		Convey("TestDatabaseService should keep bars of each multiplier in their own series", func() {
+			fiveMinute := models.DataPoint{CompanyName: "AAPL", Timespan: model.PolygonAggregateTimespanMinute, Multiplier: 5, PolygonDataPoint: model.PolygonDataPoint{StartTime: start.UnixMilli(), ClosePrice: 100}}
+			So(fiveMinute.DatabaseModel().Pk, ShouldEqual, "type#dataPoint#name#AAPL#timespan#5minute")
+			So(dbs.SaveDataPoints(ctx, []models.DataPoint{dps[0], fiveMinute}), ShouldBeNil)
+
+			got, ge := dbs.GetDataPointsInTimeRange(ctx, "AAPL", start, start.Add(time.Hour))
+			So(ge, ShouldBeNil)
+			So(got, ShouldResemble, []models.DataPoint{dps[0]})
+
+			got, ge = NewTimespanDatabaseService(database.NewDatabase[models.DbDataPoint](fake, 25, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap), model.PolygonAggregateTimespanMinute, 5, false).GetDataPointsInTimeRange(ctx, "AAPL", start, start.Add(time.Hour))
+			So(ge, ShouldBeNil)
+			So(got, ShouldResemble, []models.DataPoint{fiveMinute})
+		})
+
+		Convey("TestDatabaseService should retry a throttled batch", func() {
+			fake.Errors = map[mocks.Operation][]error{mocks.OperationBatchWriteItem: {mocks.ThrottlingError()}}
+
+			So(dbs.SaveDataPoints(ctx, dps[:2]), ShouldBeNil)
+			So(fake.Calls(mocks.OperationBatchWriteItem), ShouldEqual, 2)
+		})
+
+		Convey("TestDatabaseService should report the bars that finally failed to save", func() {
+			fake.Unprocessed = mocks.LeaveUnprocessed(1, 10)
+			dbs = NewDatabaseService(database.NewDatabaseWithBatchRetry[models.DbDataPoint](fake, 25, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap, database.BatchRetry{MaxAttempts: 2}))
+
+			errs := dbs.SaveDataPoints(ctx, dps[:2])
+			So(errs, ShouldNotBeNil)
+			So(len(*errs), ShouldEqual, 1)
+			So((*errs)[0].Error(), ShouldEqual, "DatabaseService:SaveDataPoints:failed to save AAPL bar at: 1677681060000 with error: batch item failed to write: still unprocessed after 2 attempts")
+		})
+	})
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/database"
+	"time"
+)
+
+type IQuarantineService interface {
+	SaveQuarantined(ctx context.Context, qdps []models.QuarantinedDataPoint) genErr.IGenError
+	GetQuarantined(ctx context.Context, companyName string, timespan model.PolygonAggregateTimespan, multiplier int, adjusted bool) ([]models.QuarantinedDataPoint, genErr.IGenError)
+}
+
+var _ IQuarantineService = (*QuarantineService)(nil)
+
+func NewQuarantineService(database database.IDatabase[models.DbQuarantinedDataPoint], now func() time.Time) IQuarantineService {
+	return &QuarantineService{database: database, now: now}
+}
+
+type QuarantineService struct {
+	database database.IDatabase[models.DbQuarantinedDataPoint]
+	now      func() time.Time
+}
+
+func (qs *QuarantineService) SaveQuarantined(ctx context.Context, qdps []models.QuarantinedDataPoint) genErr.IGenError {
+	if len(qdps) == 0 {
+		return nil
+	}
+
+	now := qs.now()
+	dbModels := make([]models.DbQuarantinedDataPoint, len(qdps))
+	for i, q := range qdps {
+		q.QuarantinedAt = now
+		dbModels[i] = q.DatabaseModel()
+	}
+
+	if err := qs.database.BatchWrite(ctx, dbModels); err != nil {
+		return &genErr.GenError{Messages: []string{"QuarantineService:SaveQuarantined:failed to save quarantined bars with error: " + err.Error()}}
+	}
+
+	return nil
+}
+
+// GetQuarantined returns every quarantined bar of a series, in the order of their sort keys
+func (qs *QuarantineService) GetQuarantined(ctx context.Context, companyName string, timespan model.PolygonAggregateTimespan, multiplier int, adjusted bool) ([]models.QuarantinedDataPoint, genErr.IGenError) {
+	qry := map[string]types.Condition{
+		models.DbPartitionKey: {
+			ComparisonOperator: types.ComparisonOperatorEq,
+			AttributeValueList: []types.AttributeValue{
+				&types.AttributeValueMemberS{Value: models.QuarantinePk(companyName, timespan, multiplier, adjusted)},
+			},
+		},
+	}
+
+	dbModels, err := qs.database.Query(ctx, qry, "")
+	if err != nil {
+		return []models.QuarantinedDataPoint{}, &genErr.GenError{Messages: []string{"QuarantineService:GetQuarantined:failed to get quarantined bars for: " + companyName + " with error: " + err.Error()}}
+	}
+
+	qdps := make([]models.QuarantinedDataPoint, len(dbModels))
+	for i, m := range dbModels {
+		qdps[i] = m.QuarantinedDataPoint
+	}
+
+	return qdps, nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/greenac/chaching/internal/database/models"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	dbMocks "github.com/greenac/chaching/internal/service/database/mocks"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQuarantineService(t *testing.T) {
+	Convey("TestQuarantineService", t, func() {
+		now := time.Date(2023, 3, 2, 8, 0, 0, 0, time.UTC)
+		dm := &dbMocks.DatabaseMock[models.DbQuarantinedDataPoint]{}
+		qs := NewQuarantineService(dm, func() time.Time { return now })
+		q := models.QuarantinedDataPoint{
+			DataPoint: models.DataPoint{CompanyName: "AAPL", Timespan: model.PolygonAggregateTimespanHour, PolygonDataPoint: model.PolygonDataPoint{StartTime: 1677681000000, LowestPrice: 2, OpenPrice: 1}},
+			Reasons:   []string{"priceRange: open 1 is outside low 2 and high 0"},
+		}
+
+		Convey("TestQuarantineService should save bars apart from their series with the time they were quarantined", func() {
+			So(qs.SaveQuarantined(context.Background(), []models.QuarantinedDataPoint{q}), ShouldBeNil)
+			So(dm.Written[0].Pk, ShouldEqual, "type#quarantine#type#dataPoint#name#AAPL#timespan#hour")
+			So(dm.Written[0].Sk, ShouldEqual, "timeStamp#1677681000000")
+			So(dm.Written[0].QuarantinedAt, ShouldEqual, now)
+			So(dm.Written[0].Reasons, ShouldResemble, q.Reasons)
+		})
+
+		Convey("TestQuarantineService should read back the quarantine partition of a series", func() {
+			So(qs.SaveQuarantined(context.Background(), []models.QuarantinedDataPoint{q}), ShouldBeNil)
+
+			qdps, ge := qs.GetQuarantined(context.Background(), "AAPL", model.PolygonAggregateTimespanHour, 1, false)
+			So(ge, ShouldBeNil)
+			So(len(qdps), ShouldEqual, 1)
+			So(qdps[0].CompanyName, ShouldEqual, "AAPL")
+			So(dm.QueryKey[models.DbPartitionKey].AttributeValueList[0].(*types.AttributeValueMemberS).Value, ShouldEqual, "type#quarantine#type#dataPoint#name#AAPL#timespan#hour")
+		})
+
+		Convey("TestQuarantineService should skip empty saves", func() {
+			So(qs.SaveQuarantined(context.Background(), nil), ShouldBeNil)
+			So(dm.Written, ShouldBeEmpty)
+		})
+	})
+}
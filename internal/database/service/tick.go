@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/service/database"
+	"time"
+)
+
+type ITickService interface {
+	SaveTrades(ctx context.Context, trades []models.Trade) genErr.IGenError
+	GetTrades(ctx context.Context, companyName string, day string, from time.Time, to time.Time) ([]models.Trade, genErr.IGenError)
+	SaveQuotes(ctx context.Context, quotes []models.Quote) genErr.IGenError
+	GetQuotes(ctx context.Context, companyName string, day string, from time.Time, to time.Time) ([]models.Quote, genErr.IGenError)
+}
+
+var _ ITickService = (*TickService)(nil)
+
+func NewTickService(trades database.IDatabase[models.DbTrade], quotes database.IDatabase[models.DbQuote]) ITickService {
+	return &TickService{trades: trades, quotes: quotes}
+}
+
+type TickService struct {
+	trades database.IDatabase[models.DbTrade]
+	quotes database.IDatabase[models.DbQuote]
+}
+
+func (ts *TickService) SaveTrades(ctx context.Context, trades []models.Trade) genErr.IGenError {
+	dbModels := make([]models.DbTrade, len(trades))
+	for i, t := range trades {
+		dbModels[i] = t.DatabaseModel()
+	}
+
+	if err := ts.trades.BatchWrite(ctx, dbModels); err != nil {
+		return &genErr.GenError{Messages: []string{"TickService:SaveTrades:failed to save trades with error: " + err.Error()}}
+	}
+
+	return nil
+}
+
+// GetTrades returns the company's trades on day from `from` up to but not including `to`
+func (ts *TickService) GetTrades(ctx context.Context, companyName string, day string, from time.Time, to time.Time) ([]models.Trade, genErr.IGenError) {
+	dbTrades, err := ts.trades.Query(ctx, tickQuery(models.TradePk(companyName, day), from, to), "")
+	if err != nil {
+		return []models.Trade{}, &genErr.GenError{Messages: []string{"TickService:GetTrades:failed to get trades for: " + companyName + " on: " + day + " with error: " + err.Error()}}
+	}
+
+	trades := make([]models.Trade, len(dbTrades))
+	for i, t := range dbTrades {
+		trades[i] = t.Trade
+	}
+
+	return trades, nil
+}
+
+func (ts *TickService) SaveQuotes(ctx context.Context, quotes []models.Quote) genErr.IGenError {
+	dbModels := make([]models.DbQuote, len(quotes))
+	for i, q := range quotes {
+		dbModels[i] = q.DatabaseModel()
+	}
+
+	if err := ts.quotes.BatchWrite(ctx, dbModels); err != nil {
+		return &genErr.GenError{Messages: []string{"TickService:SaveQuotes:failed to save quotes with error: " + err.Error()}}
+	}
+
+	return nil
+}
+
+// GetQuotes returns the company's quotes on day from `from` up to but not including `to`
+func (ts *TickService) GetQuotes(ctx context.Context, companyName string, day string, from time.Time, to time.Time) ([]models.Quote, genErr.IGenError) {
+	dbQuotes, err := ts.quotes.Query(ctx, tickQuery(models.QuotePk(companyName, day), from, to), "")
+	if err != nil {
+		return []models.Quote{}, &genErr.GenError{Messages: []string{"TickService:GetQuotes:failed to get quotes for: " + companyName + " on: " + day + " with error: " + err.Error()}}
+	}
+
+	quotes := make([]models.Quote, len(dbQuotes))
+	for i, q := range dbQuotes {
+		quotes[i] = q.Quote
+	}
+
+	return quotes, nil
+}
+
+// tickQuery selects a day partition's ticks in [from, to), since a tick's sort key sorts after the bound of its own time
+func tickQuery(pk string, from time.Time, to time.Time) map[string]types.Condition {
+	return map[string]types.Condition{
+		models.DbPartitionKey: {
+			ComparisonOperator: types.ComparisonOperatorEq,
+			AttributeValueList: []types.AttributeValue{
+				&types.AttributeValueMemberS{Value: pk},
+			},
+		},
+		models.DbSearchKey: {
+			ComparisonOperator: types.ComparisonOperatorBetween,
+			AttributeValueList: []types.AttributeValue{
+				&types.AttributeValueMemberS{Value: models.TickSkBound(from)},
+				&types.AttributeValueMemberS{Value: models.TickSkBound(to)},
+			},
+		},
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/greenac/chaching/internal/database/models"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	dbMocks "github.com/greenac/chaching/internal/service/database/mocks"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTickService_Trades(t *testing.T) {
+	Convey("TestTickService_Trades", t, func() {
+		dm := &dbMocks.DatabaseMock[models.DbTrade]{}
+		ts := NewTickService(dm, nil)
+		trade := models.Trade{CompanyName: "AAPL", Day: "2023-03-01", PolygonTrade: model.PolygonTrade{Id: "1", SipTimestamp: 1677681000003826432, SequenceNumber: 4177, Price: 146.83}}
+
+		Convey("TestTickService_Trades should key trades by ticker day and nanosecond timestamp", func() {
+			So(ts.SaveTrades(context.Background(), []models.Trade{trade}), ShouldBeNil)
+			So(dm.Written[0].Pk, ShouldEqual, "type#trade#name#AAPL#day#2023-03-01")
+			So(dm.Written[0].Sk, ShouldEqual, "sipTimestamp#1677681000003826432#4177")
+		})
+
+		Convey("TestTickService_Trades should query the day partition between the zero padded bounds", func() {
+			from := time.Unix(0, 1677681000000000000)
+			trades, ge := ts.GetTrades(context.Background(), "AAPL", "2023-03-01", from, from.Add(time.Second))
+			So(ge, ShouldBeNil)
+			So(trades, ShouldBeEmpty)
+			So(dm.QueryKey[models.DbPartitionKey].AttributeValueList[0].(*types.AttributeValueMemberS).Value, ShouldEqual, "type#trade#name#AAPL#day#2023-03-01")
+			bounds := dm.QueryKey[models.DbSearchKey].AttributeValueList
+			So(bounds[0].(*types.AttributeValueMemberS).Value, ShouldEqual, "sipTimestamp#1677681000000000000")
+			So(bounds[1].(*types.AttributeValueMemberS).Value, ShouldEqual, "sipTimestamp#1677681001000000000")
+			So(models.TickSk(trade.SipTimestamp, trade.SequenceNumber) > bounds[0].(*types.AttributeValueMemberS).Value, ShouldBeTrue)
+			So(models.TickSk(from.Add(time.Second).UnixNano(), 1) > bounds[1].(*types.AttributeValueMemberS).Value, ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/service/database"
+	"sort"
+	"strings"
+	"time"
+)
+
+type IWatchlistService interface {
+	CreateWatchlist(ctx context.Context, name string, tickers []string) (models.Watchlist, genErr.IGenError)
+	GetWatchlist(ctx context.Context, name string) (models.Watchlist, bool, genErr.IGenError)
+	AddTickers(ctx context.Context, name string, tickers []string) (models.Watchlist, genErr.IGenError)
+	RemoveTickers(ctx context.Context, name string, tickers []string) (models.Watchlist, genErr.IGenError)
+	ListWatchlists(ctx context.Context) ([]models.Watchlist, genErr.IGenError)
+	Tickers(ctx context.Context, name string) ([]string, genErr.IGenError)
+}
+
+var _ IWatchlistService = (*WatchlistService)(nil)
+
+// NewWatchlistService creates a watchlist service that rejects tickers missing from companies' reference data.
+// A nil companies skips validation, for tables where reference data has not been ingested
+func NewWatchlistService(database database.IDatabase[models.DbWatchlist], companies ICompanyService, now func() time.Time) IWatchlistService {
+	return &WatchlistService{database: database, companies: companies, now: now}
+}
+
+type WatchlistService struct {
+	database  database.IDatabase[models.DbWatchlist]
+	companies ICompanyService
+	now       func() time.Time
+}
+
+func (ws *WatchlistService) CreateWatchlist(ctx context.Context, name string, tickers []string) (models.Watchlist, genErr.IGenError) {
+	if name == "" {
+		return models.Watchlist{}, &genErr.GenError{Messages: []string{"WatchlistService:CreateWatchlist:watchlist name is required"}}
+	}
+
+	_, exists, ge := ws.GetWatchlist(ctx, name)
+	if ge != nil {
+		return models.Watchlist{}, ge.AddMsg("WatchlistService:CreateWatchlist:failed to check watchlist: " + name)
+	}
+
+	if exists {
+		return models.Watchlist{}, &genErr.GenError{Messages: []string{"WatchlistService:CreateWatchlist:watchlist: " + name + " already exists"}}
+	}
+
+	wl := models.Watchlist{Name: name, CreatedAt: ws.now()}
+	wl.Tickers = wl.Add(tickers)
+	if ge = ws.validate(ctx, wl.Tickers); ge != nil {
+		return models.Watchlist{}, ge.AddMsg("WatchlistService:CreateWatchlist:invalid tickers for: " + name)
+	}
+
+	return ws.save(ctx, wl)
+}
+
+// GetWatchlist returns the stored watchlist, reporting false when there is no watchlist with name
+func (ws *WatchlistService) GetWatchlist(ctx context.Context, name string) (models.Watchlist, bool, genErr.IGenError) {
+	pk, sk := models.WatchlistKeys(name)
+	m, err := ws.database.GetItem(ctx, map[string]types.AttributeValue{
+		models.DbPartitionKey: &types.AttributeValueMemberS{Value: pk},
+		models.DbSearchKey:    &types.AttributeValueMemberS{Value: sk},
+	})
+	if err != nil {
+		return models.Watchlist{}, false, &genErr.GenError{Messages: []string{"WatchlistService:GetWatchlist:failed to get watchlist: " + name + " with error: " + err.Error()}}
+	}
+
+	if m.Pk == "" {
+		return models.Watchlist{}, false, nil
+	}
+
+	return m.Watchlist, true, nil
+}
+
+func (ws *WatchlistService) AddTickers(ctx context.Context, name string, tickers []string) (models.Watchlist, genErr.IGenError) {
+	wl, ge := ws.mustGet(ctx, name)
+	if ge != nil {
+		return models.Watchlist{}, ge.AddMsg("WatchlistService:AddTickers:failed to add tickers")
+	}
+
+	if ge = ws.validate(ctx, models.NormalizeTickers(tickers)); ge != nil {
+		return models.Watchlist{}, ge.AddMsg("WatchlistService:AddTickers:invalid tickers for: " + name)
+	}
+
+	wl.Tickers = wl.Add(tickers)
+
+	return ws.save(ctx, wl)
+}
+
+func (ws *WatchlistService) RemoveTickers(ctx context.Context, name string, tickers []string) (models.Watchlist, genErr.IGenError) {
+	wl, ge := ws.mustGet(ctx, name)
+	if ge != nil {
+		return models.Watchlist{}, ge.AddMsg("WatchlistService:RemoveTickers:failed to remove tickers")
+	}
+
+	wl.Tickers = wl.Remove(tickers)
+
+	return ws.save(ctx, wl)
+}
+
+// ListWatchlists returns every watchlist ordered by name
+func (ws *WatchlistService) ListWatchlists(ctx context.Context) ([]models.Watchlist, genErr.IGenError) {
+	qry := map[string]types.Condition{
+		models.DbPartitionKey: {
+			ComparisonOperator: types.ComparisonOperatorEq,
+			AttributeValueList: []types.AttributeValue{&types.AttributeValueMemberS{Value: models.WatchlistModelKeyPk}},
+		},
+	}
+
+	dbWatchlists, err := ws.database.Query(ctx, qry, "")
+	if err != nil {
+		return []models.Watchlist{}, &genErr.GenError{Messages: []string{"WatchlistService:ListWatchlists:failed to list watchlists with error: " + err.Error()}}
+	}
+
+	watchlists := make([]models.Watchlist, len(dbWatchlists))
+	for i, w := range dbWatchlists {
+		watchlists[i] = w.Watchlist
+	}
+
+	sort.Slice(watchlists, func(i, j int) bool { return watchlists[i].Name < watchlists[j].Name })
+
+	return watchlists, nil
+}
+
+// Tickers returns the tickers of watchlist name, failing when it does not exist or is empty
+func (ws *WatchlistService) Tickers(ctx context.Context, name string) ([]string, genErr.IGenError) {
+	wl, ge := ws.mustGet(ctx, name)
+	if ge != nil {
+		return []string{}, ge.AddMsg("WatchlistService:Tickers:failed to get tickers")
+	}
+
+	if len(wl.Tickers) == 0 {
+		return []string{}, &genErr.GenError{Messages: []string{"WatchlistService:Tickers:watchlist: " + name + " has no tickers"}}
+	}
+
+	return wl.Tickers, nil
+}
+
+func (ws *WatchlistService) mustGet(ctx context.Context, name string) (models.Watchlist, genErr.IGenError) {
+	wl, ok, ge := ws.GetWatchlist(ctx, name)
+	if ge != nil {
+		return models.Watchlist{}, ge
+	}
+
+	if !ok {
+		return models.Watchlist{}, &genErr.GenError{Messages: []string{"WatchlistService:watchlist: " + name + " does not exist"}}
+	}
+
+	return wl, nil
+}
+
+func (ws *WatchlistService) save(ctx context.Context, wl models.Watchlist) (models.Watchlist, genErr.IGenError) {
+	wl.UpdatedAt = ws.now()
+	if err := ws.database.UpsertOne(ctx, wl.DatabaseModel()); err != nil {
+		return models.Watchlist{}, &genErr.GenError{Messages: []string{"WatchlistService:save:failed to save watchlist: " + wl.Name + " with error: " + err.Error()}}
+	}
+
+	return wl, nil
+}
+
+func (ws *WatchlistService) validate(ctx context.Context, tickers []string) genErr.IGenError {
+	if ws.companies == nil {
+		return nil
+	}
+
+	var unknown []string
+	for _, t := range tickers {
+		_, ok, ge := ws.companies.GetCompany(ctx, t)
+		if ge != nil {
+			return ge.AddMsg("WatchlistService:validate:failed to look up: " + t)
+		}
+
+		if !ok {
+			unknown = append(unknown, t)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return &genErr.GenError{Messages: []string{"WatchlistService:validate:unknown tickers: " + strings.Join(unknown, ",")}}
+	}
+
+	return nil
+}
+
+// ResolveTargets returns tickers when given, otherwise the tickers of watchlist, failing when neither is set
+func ResolveTargets(ctx context.Context, ws IWatchlistService, tickers []string, watchlist string) ([]string, genErr.IGenError) {
+	if len(tickers) > 0 {
+		return models.NormalizeTickers(tickers), nil
+	}
+
+	if watchlist == "" {
+		return []string{}, &genErr.GenError{Messages: []string{"ResolveTargets:either tickers or a watchlist is required"}}
+	}
+
+	wt, ge := ws.Tickers(ctx, watchlist)
+	if ge != nil {
+		return []string{}, ge.AddMsg("ResolveTargets:failed to resolve watchlist: " + watchlist)
+	}
+
+	return wt, nil
+}
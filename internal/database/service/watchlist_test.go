@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"github.com/greenac/chaching/internal/database/models"
+	dbMocks "github.com/greenac/chaching/internal/service/database/mocks"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWatchlistService(t *testing.T) {
+	Convey("TestWatchlistService", t, func() {
+		now := time.Date(2023, 3, 2, 8, 0, 0, 0, time.UTC)
+		dm := &dbMocks.DatabaseMock[models.DbWatchlist]{}
+		cdm := &dbMocks.DatabaseMock[models.DbCompany]{}
+		cs := NewCompanyService(cdm, "ChachingIndex1", func() time.Time { return now })
+		So(cs.SaveCompanies(context.Background(), []models.Company{{CompanyName: "AAPL"}, {CompanyName: "AMZN"}, {CompanyName: "MSFT"}}), ShouldBeNil)
+		ws := NewWatchlistService(dm, cs, func() time.Time { return now })
+
+		wl, ge := ws.CreateWatchlist(context.Background(), "tech", []string{" amzn", "AAPL", "aapl"})
+		So(ge, ShouldBeNil)
+
+		Convey("TestWatchlistService should store a watchlist as one item with normalized tickers", func() {
+			So(wl.Tickers, ShouldResemble, []string{"AAPL", "AMZN"})
+			item, ok := dm.Item("type#watchlist#", "name#tech")
+			So(ok, ShouldBeTrue)
+			So(item.CreatedAt, ShouldEqual, now)
+		})
+
+		Convey("TestWatchlistService should not create a watchlist twice", func() {
+			_, ge := ws.CreateWatchlist(context.Background(), "tech", []string{"MSFT"})
+			So(ge, ShouldNotBeNil)
+			So(ge.Error(), ShouldContainSubstring, "already exists")
+		})
+
+		Convey("TestWatchlistService should add and remove tickers", func() {
+			wl, ge := ws.AddTickers(context.Background(), "tech", []string{"msft"})
+			So(ge, ShouldBeNil)
+			So(wl.Tickers, ShouldResemble, []string{"AAPL", "AMZN", "MSFT"})
+
+			wl, ge = ws.RemoveTickers(context.Background(), "tech", []string{"amzn"})
+			So(ge, ShouldBeNil)
+			So(wl.Tickers, ShouldResemble, []string{"AAPL", "MSFT"})
+
+			tickers, ge := ws.Tickers(context.Background(), "tech")
+			So(ge, ShouldBeNil)
+			So(tickers, ShouldResemble, []string{"AAPL", "MSFT"})
+		})
+
+		Convey("TestWatchlistService should reject tickers missing from reference data", func() {
+			_, ge := ws.AddTickers(context.Background(), "tech", []string{"NOPE", "MSFT"})
+			So(ge, ShouldNotBeNil)
+			So(ge.Error(), ShouldContainSubstring, "unknown tickers: NOPE")
+			item, _ := dm.Item("type#watchlist#", "name#tech")
+			So(item.Tickers, ShouldResemble, []string{"AAPL", "AMZN"})
+		})
+
+		Convey("TestWatchlistService should skip validation without a company service", func() {
+			ws := NewWatchlistService(dm, nil, func() time.Time { return now })
+			wl, ge := ws.CreateWatchlist(context.Background(), "misc", []string{"NOPE"})
+			So(ge, ShouldBeNil)
+			So(wl.Tickers, ShouldResemble, []string{"NOPE"})
+
+			wls, ge := ws.ListWatchlists(context.Background())
+			So(ge, ShouldBeNil)
+			So(len(wls), ShouldEqual, 2)
+			So(wls[0].Name, ShouldEqual, "misc")
+		})
+
+		Convey("TestWatchlistService should fail on a missing watchlist", func() {
+			_, ge := ws.Tickers(context.Background(), "nope")
+			So(ge, ShouldNotBeNil)
+			So(ge.Error(), ShouldContainSubstring, "watchlist: nope does not exist")
+		})
+
+		Convey("TestWatchlistService should prefer tickers over the watchlist when resolving targets", func() {
+			tickers, ge := ResolveTargets(context.Background(), ws, []string{"msft"}, "tech")
+			So(ge, ShouldBeNil)
+			So(tickers, ShouldResemble, []string{"MSFT"})
+
+			tickers, ge = ResolveTargets(context.Background(), ws, nil, "tech")
+			So(ge, ShouldBeNil)
+			So(tickers, ShouldResemble, []string{"AAPL", "AMZN"})
+
+			_, ge = ResolveTargets(context.Background(), ws, nil, "")
+			So(ge, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/database"
+	"time"
+)
+
+type IWatermarkService interface {
+	GetWatermark(ctx context.Context, companyName string, timespan model.PolygonAggregateTimespan, multiplier int, adjusted bool) (models.Watermark, bool, genErr.IGenError)
+	SaveWatermark(ctx context.Context, w models.Watermark) genErr.IGenError
+}
+
+var _ IWatermarkService = (*WatermarkService)(nil)
+
+func NewWatermarkService(database database.IDatabase[models.DbWatermark], now func() time.Time) IWatermarkService {
+	return &WatermarkService{database: database, now: now}
+}
+
+type WatermarkService struct {
+	database database.IDatabase[models.DbWatermark]
+	now      func() time.Time
+}
+
+// GetWatermark returns the stored watermark, reporting false when the company has not been fetched at this aggregate size
+func (ws *WatermarkService) GetWatermark(ctx context.Context, companyName string, timespan model.PolygonAggregateTimespan, multiplier int, adjusted bool) (models.Watermark, bool, genErr.IGenError) {
+	pk, sk := models.WatermarkKeys(companyName, timespan, multiplier, adjusted)
+	m, err := ws.database.GetItem(ctx, map[string]types.AttributeValue{
+		models.DbPartitionKey: &types.AttributeValueMemberS{Value: pk},
+		models.DbSearchKey:    &types.AttributeValueMemberS{Value: sk},
+	})
+	if err != nil {
+		return models.Watermark{}, false, &genErr.GenError{Messages: []string{"WatermarkService:GetWatermark:failed to get watermark for: " + companyName + " with error: " + err.Error()}}
+	}
+
+	if m.Pk == "" {
+		return models.Watermark{}, false, nil
+	}
+
+	return m.Watermark, true, nil
+}
+
+func (ws *WatermarkService) SaveWatermark(ctx context.Context, w models.Watermark) genErr.IGenError {
+	w.UpdatedAt = ws.now()
+	err := ws.database.UpsertOne(ctx, w.DatabaseModel())
+	if err != nil {
+		return &genErr.GenError{Messages: []string{"WatermarkService:SaveWatermark:failed to save watermark for: " + w.CompanyName + " with error: " + err.Error()}}
+	}
+
+	return nil
+}
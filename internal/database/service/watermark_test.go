@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/greenac/chaching/internal/database/models"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	dbMocks "github.com/greenac/chaching/internal/service/database/mocks"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWatermarkService(t *testing.T) {
+	Convey("TestWatermarkService", t, func() {
+		now := time.Date(2023, 3, 2, 8, 0, 0, 0, time.UTC)
+		dm := &dbMocks.DatabaseMock[models.DbWatermark]{}
+		ws := NewWatermarkService(dm, func() time.Time { return now })
+		w := models.Watermark{CompanyName: "AAPL", Timespan: model.PolygonAggregateTimespanMinute, Multiplier: 1, Through: time.Date(2023, 3, 1, 21, 0, 0, 0, time.UTC), LastBarTime: 1677704340000}
+
+		Convey("TestWatermarkService should report a missing watermark", func() {
+			_, ok, ge := ws.GetWatermark(context.Background(), "AAPL", model.PolygonAggregateTimespanMinute, 1, false)
+			So(ge, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+			So(dm.GetKey[models.DbPartitionKey], ShouldResemble, &types.AttributeValueMemberS{Value: "type#watermark#companyName#AAPL"})
+			So(dm.GetKey[models.DbSearchKey], ShouldResemble, &types.AttributeValueMemberS{Value: "timespan#1#minute"})
+		})
+
+		Convey("TestWatermarkService should save and read back a watermark per timespan", func() {
+			So(ws.SaveWatermark(context.Background(), w), ShouldBeNil)
+
+			got, ok, ge := ws.GetWatermark(context.Background(), "AAPL", model.PolygonAggregateTimespanMinute, 1, false)
+			So(ge, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			w.UpdatedAt = now
+			So(got, ShouldResemble, w)
+
+			_, ok, _ = ws.GetWatermark(context.Background(), "AAPL", model.PolygonAggregateTimespanMinute, 5, false)
+			So(ok, ShouldBeFalse)
+
+			_, ok, _ = ws.GetWatermark(context.Background(), "AAPL", model.PolygonAggregateTimespanMinute, 1, true)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("TestWatermarkService should return database errors", func() {
+			dm.GetError = errors.New("boom")
+			dm.PutError = errors.New("bang")
+
+			_, _, ge := ws.GetWatermark(context.Background(), "AAPL", model.PolygonAggregateTimespanMinute, 1, false)
+			So(ge.Error(), ShouldEqual, "WatermarkService:GetWatermark:failed to get watermark for: AAPL with error: boom")
+			So(ws.SaveWatermark(context.Background(), w).Error(), ShouldEqual, "WatermarkService:SaveWatermark:failed to save watermark for: AAPL with error: bang")
+		})
+	})
+}
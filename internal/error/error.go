@@ -28,5 +28,5 @@ func (e GenError) Error() string {
 
 func (e GenError) AddMsg(msg string) IGenError {
 	e.Messages = append(e.Messages, msg)
-	return e
+	return &e
 }
@@ -0,0 +1,336 @@
+package job
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/provider"
+	"github.com/greenac/chaching/internal/service/quality"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// MaxLimit is the largest number of aggregates polygon returns in a single response
+const MaxLimit = 50000
+
+// FetchJobSpec describes a fetch run as it is written in a job spec file or passed on the command line.
+// Dates are either RFC3339 times or `YYYY-MM-DD` days in the market calendar's time zone. Incremental jobs
+// resume each ticker after its watermark, using start only for tickers that have none, and end now by default.
+// A spec names either its tickers or a stored watchlist to take them from
+type FetchJobSpec struct {
+	Tickers     []string          `json:"tickers"`
+	Watchlist   string            `json:"watchlist"`
+	Start       string            `json:"start"`
+	End         string            `json:"end"`
+	Timespan    string            `json:"timespan"`
+	Multiplier  int               `json:"multiplier"`
+	Limit       int               `json:"limit"`
+	Partition   string            `json:"partition"` // longest time a request window may cover, empty sizes windows by the limit alone
+	Concurrency int               `json:"concurrency"`
+	DryRun      bool              `json:"dryRun"`
+	Incremental bool              `json:"incremental"`
+	Adjusted    bool              `json:"adjusted"`
+	Provider    string            `json:"provider"`
+	CsvDir      string            `json:"csvDir"` // directory of `<TICKER>.csv` files read by the csv provider
+	Validate    bool              `json:"validate"`
+	Quality     map[string]string `json:"quality"` // action of each quality rule, for example: {"jump": "quarantine"}, on top of the defaults
+	MaxJump     float64           `json:"maxJump"`
+}
+
+// FetchJob is a validated FetchJobSpec
+type FetchJob struct {
+	Tickers     []string // empty when the job targets a watchlist, which is read when the job runs
+	Watchlist   string
+	StartDate   time.Time
+	EndDate     time.Time
+	Timespan    model.PolygonAggregateTimespan
+	Multiplier  int
+	Limit       int
+	Partition   time.Duration
+	Concurrency int
+	DryRun      bool
+	Incremental bool
+	Adjusted    bool
+	Provider    provider.ProviderName
+	CsvDir      string
+	Validate    bool
+	Quality     quality.Config
+}
+
+func (j FetchJob) String() string {
+	targets := "tickers: " + strings.Join(j.Tickers, ",")
+	if j.Watchlist != "" {
+		targets = "watchlist: " + j.Watchlist
+	}
+
+	return fmt.Sprintf(
+		"%s, from: %s, to: %s, %d %s bars, limit: %d, partition: %s, concurrency: %d, dry run: %t, incremental: %t, adjusted: %t, provider: %s, validate: %t",
+		targets, j.StartDate.Format(time.RFC3339), j.EndDate.Format(time.RFC3339), j.Multiplier, j.Timespan, j.Limit, j.Partition, j.Concurrency, j.DryRun, j.Incremental, j.Adjusted, j.Provider, j.Validate,
+	)
+}
+
+func DefaultFetchJobSpec() FetchJobSpec {
+	return FetchJobSpec{
+		Timespan:    string(model.PolygonAggregateTimespanMinute),
+		Multiplier:  1,
+		Limit:       MaxLimit,
+		Concurrency: 10,
+		Provider:    string(provider.ProviderNamePolygon),
+		Validate:    true,
+		MaxJump:     quality.DefaultConfig().MaxJump,
+	}
+}
+
+// LoadFetchJobSpec reads a job spec file on top of spec, so fields missing from the file keep their value in spec
+func LoadFetchJobSpec(path string, spec FetchJobSpec) (FetchJobSpec, genErr.IGenError) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return spec, &genErr.GenError{Messages: []string{"LoadFetchJobSpec:failed to read job spec: " + path + " with error: " + err.Error()}}
+	}
+
+	err = json.Unmarshal(b, &spec)
+	if err != nil {
+		return spec, &genErr.GenError{Messages: []string{"LoadFetchJobSpec:failed to unmarshal job spec: " + path + " with error: " + err.Error()}}
+	}
+
+	return spec, nil
+}
+
+// ParseFetchFlags builds a spec from the defaults, then the job spec file given by `-job`, then any flags set on the command line
+func ParseFetchFlags(args []string, output io.Writer) (FetchJobSpec, error) {
+	spec := DefaultFetchJobSpec()
+
+	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	jobPath := fs.String("job", "", "path to a json job spec, flags given alongside it override the file")
+	tickers := fs.String("tickers", "", "comma separated tickers to fetch, for example: AAPL,AMZN")
+	watchlist := fs.String("watchlist", "", "name of a stored watchlist to fetch instead of tickers")
+	start := fs.String("start", "", "first day or time to fetch, YYYY-MM-DD or RFC3339")
+	end := fs.String("end", "", "last day or time to fetch, YYYY-MM-DD or RFC3339")
+	timespan := fs.String("timespan", spec.Timespan, fmt.Sprintf("aggregate timespan, one of: %v", model.AllPolygonAggregateTimespans()))
+	multiplier := fs.Int("multiplier", spec.Multiplier, "number of timespans in each aggregate")
+	limit := fs.Int("limit", spec.Limit, "max number of aggregates per request")
+	partition := fs.String("partition", spec.Partition, "longest time a request window may cover, for example 24h, empty sizes windows by the limit alone")
+	concurrency := fs.Int("concurrency", spec.Concurrency, "number of windows fetched at once")
+	dryRun := fs.Bool("dry-run", false, "print the planned windows without fetching or saving")
+	incremental := fs.Bool("incremental", false, "resume each ticker after its stored watermark, end defaults to now")
+	adjusted := fs.Bool("adjusted", false, "fetch split adjusted bars, which are stored beside the unadjusted series")
+	providerName := fs.String("provider", spec.Provider, fmt.Sprintf("where bars come from, one of: %v", provider.AllProviderNames()))
+	csvDir := fs.String("csv-dir", "", "directory of <TICKER>.csv files read by the csv provider")
+	validate := fs.Bool("validate", spec.Validate, "validate bars before saving them")
+	qualityRules := fs.String("quality", "", fmt.Sprintf("comma separated rule=action pairs, rules: %v, actions: %v", quality.AllRules(), quality.AllActions()))
+	maxJump := fs.Float64("max-jump", spec.MaxJump, "largest fraction a close may move from the previous close before the jump rule fires")
+
+	if err := fs.Parse(args); err != nil {
+		return spec, err
+	}
+
+	if fs.NArg() > 0 {
+		return spec, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	if *jobPath != "" {
+		var ge genErr.IGenError
+		spec, ge = LoadFetchJobSpec(*jobPath, spec)
+		if ge != nil {
+			return spec, ge
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "tickers":
+			spec.Tickers = strings.Split(*tickers, ",")
+			spec.Watchlist = ""
+		case "watchlist":
+			spec.Watchlist = *watchlist
+		case "start":
+			spec.Start = *start
+		case "end":
+			spec.End = *end
+		case "timespan":
+			spec.Timespan = *timespan
+		case "multiplier":
+			spec.Multiplier = *multiplier
+		case "limit":
+			spec.Limit = *limit
+		case "partition":
+			spec.Partition = *partition
+		case "concurrency":
+			spec.Concurrency = *concurrency
+		case "dry-run":
+			spec.DryRun = *dryRun
+		case "incremental":
+			spec.Incremental = *incremental
+		case "adjusted":
+			spec.Adjusted = *adjusted
+		case "provider":
+			spec.Provider = *providerName
+		case "csv-dir":
+			spec.CsvDir = *csvDir
+		case "validate":
+			spec.Validate = *validate
+		case "max-jump":
+			spec.MaxJump = *maxJump
+		}
+	})
+
+	if *qualityRules != "" {
+		if spec.Quality == nil {
+			spec.Quality = map[string]string{}
+		}
+
+		for _, pair := range strings.Split(*qualityRules, ",") {
+			rule, action, ok := strings.Cut(pair, "=")
+			if !ok {
+				return spec, fmt.Errorf("invalid quality rule: %q, expected rule=action", pair)
+			}
+
+			spec.Quality[strings.TrimSpace(rule)] = strings.TrimSpace(action)
+		}
+	}
+
+	if isFlagSet(fs, "watchlist") && !isFlagSet(fs, "tickers") {
+		spec.Tickers = nil
+	}
+
+	return spec, nil
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+
+	return set
+}
+
+// Resolve validates the spec and parses its dates in loc. An end given as a day includes the whole day
+func (s FetchJobSpec) Resolve(loc *time.Location, now time.Time) (FetchJob, genErr.IGenError) {
+	tickers := models.NormalizeTickers(s.Tickers)
+	watchlist := strings.TrimSpace(s.Watchlist)
+
+	if len(tickers) > 0 && watchlist != "" {
+		return FetchJob{}, &genErr.GenError{Messages: []string{"FetchJobSpec:Resolve:tickers and a watchlist cannot both be set"}}
+	}
+
+	if len(tickers) == 0 && watchlist == "" {
+		return FetchJob{}, &genErr.GenError{Messages: []string{"FetchJobSpec:Resolve:at least one ticker or a watchlist is required"}}
+	}
+
+	startDate, _, ge := parseDate(s.Start, loc)
+	if ge != nil {
+		return FetchJob{}, ge.AddMsg("FetchJobSpec:Resolve:invalid start")
+	}
+
+	endDate := now
+	if s.End != "" || !s.Incremental {
+		var isDay bool
+		endDate, isDay, ge = parseDate(s.End, loc)
+		if ge != nil {
+			return FetchJob{}, ge.AddMsg("FetchJobSpec:Resolve:invalid end")
+		}
+
+		if isDay {
+			endDate = endDate.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+
+	if !startDate.Before(endDate) {
+		return FetchJob{}, &genErr.GenError{Messages: []string{"FetchJobSpec:Resolve:start: " + s.Start + " must be before end: " + s.End}}
+	}
+
+	timespan, err := model.ParsePolygonAggregateTimespan(s.Timespan)
+	if err != nil {
+		return FetchJob{}, &genErr.GenError{Messages: []string{"FetchJobSpec:Resolve:" + err.Error()}}
+	}
+
+	if s.Multiplier < 1 {
+		return FetchJob{}, &genErr.GenError{Messages: []string{fmt.Sprintf("FetchJobSpec:Resolve:multiplier must be at least 1, got: %d", s.Multiplier)}}
+	}
+
+	if s.Limit < 1 || s.Limit > MaxLimit {
+		return FetchJob{}, &genErr.GenError{Messages: []string{fmt.Sprintf("FetchJobSpec:Resolve:limit must be between 1 and %d, got: %d", MaxLimit, s.Limit)}}
+	}
+
+	var partition time.Duration
+	if s.Partition != "" {
+		partition, err = time.ParseDuration(s.Partition)
+		if err != nil || partition <= 0 {
+			return FetchJob{}, &genErr.GenError{Messages: []string{"FetchJobSpec:Resolve:partition must be a positive duration, got: " + s.Partition}}
+		}
+	}
+
+	if s.Concurrency < 1 {
+		return FetchJob{}, &genErr.GenError{Messages: []string{fmt.Sprintf("FetchJobSpec:Resolve:concurrency must be at least 1, got: %d", s.Concurrency)}}
+	}
+
+	providerName := provider.ProviderNamePolygon
+	if s.Provider != "" {
+		providerName, err = provider.ParseProviderName(s.Provider)
+		if err != nil {
+			return FetchJob{}, &genErr.GenError{Messages: []string{"FetchJobSpec:Resolve:" + err.Error()}}
+		}
+	}
+
+	if providerName == provider.ProviderNameCsv && s.CsvDir == "" {
+		return FetchJob{}, &genErr.GenError{Messages: []string{"FetchJobSpec:Resolve:the csv provider requires a csv dir"}}
+	}
+
+	qualityConfig, err := quality.DefaultConfig().WithActions(s.Quality)
+	if err != nil {
+		return FetchJob{}, &genErr.GenError{Messages: []string{"FetchJobSpec:Resolve:" + err.Error()}}
+	}
+
+	if s.MaxJump < 0 {
+		return FetchJob{}, &genErr.GenError{Messages: []string{fmt.Sprintf("FetchJobSpec:Resolve:max jump can not be negative, got: %g", s.MaxJump)}}
+	}
+
+	qualityConfig.MaxJump = s.MaxJump
+
+	return FetchJob{
+		Tickers:     tickers,
+		Watchlist:   watchlist,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Timespan:    timespan,
+		Multiplier:  s.Multiplier,
+		Limit:       s.Limit,
+		Partition:   partition,
+		Concurrency: s.Concurrency,
+		DryRun:      s.DryRun,
+		Incremental: s.Incremental,
+		Adjusted:    s.Adjusted,
+		Provider:    providerName,
+		CsvDir:      s.CsvDir,
+		Validate:    s.Validate,
+		Quality:     qualityConfig,
+	}, nil
+}
+
+// parseDate parses an RFC3339 time or a `YYYY-MM-DD` day in loc, reporting whether it was a day
+func parseDate(value string, loc *time.Location) (time.Time, bool, genErr.IGenError) {
+	if value == "" {
+		return time.Time{}, false, &genErr.GenError{Messages: []string{"parseDate:date is required"}}
+	}
+
+	if t, err := time.ParseInLocation(dateLayout, value, loc); err == nil {
+		return t, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, &genErr.GenError{Messages: []string{"parseDate:expected YYYY-MM-DD or RFC3339, got: " + value}}
+	}
+
+	return t, false, nil
+}
@@ -0,0 +1,188 @@
+package job
+
+import (
+	"bytes"
+	"flag"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/provider"
+	"github.com/greenac/chaching/internal/service/quality"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseFetchFlags(t *testing.T) {
+	Convey("TestParseFetchFlags", t, func() {
+		out := &bytes.Buffer{}
+
+		Convey("TestParseFetchFlags should use the defaults for flags that are not set", func() {
+			spec, err := ParseFetchFlags([]string{"-tickers", "AAPL,AMZN", "-start", "2023-03-01", "-end", "2023-03-31"}, out)
+			So(err, ShouldBeNil)
+
+			exp := DefaultFetchJobSpec()
+			exp.Tickers = []string{"AAPL", "AMZN"}
+			exp.Start = "2023-03-01"
+			exp.End = "2023-03-31"
+			So(spec, ShouldResemble, exp)
+		})
+
+		Convey("TestParseFetchFlags should let flags override the job spec file", func() {
+			path := filepath.Join(t.TempDir(), "job.json")
+			So(os.WriteFile(path, []byte(`{"tickers": ["MSFT"], "start": "2023-01-03", "end": "2023-01-31", "timespan": "hour", "concurrency": 3}`), 0644), ShouldBeNil)
+
+			spec, err := ParseFetchFlags([]string{"-job", path, "-concurrency", "7", "-dry-run", "-adjusted", "-provider", "csv", "-csv-dir", "archive"}, out)
+			So(err, ShouldBeNil)
+			So(spec.Tickers, ShouldResemble, []string{"MSFT"})
+			So(spec.Timespan, ShouldEqual, "hour")
+			So(spec.Limit, ShouldEqual, MaxLimit)
+			So(spec.Concurrency, ShouldEqual, 7)
+			So(spec.DryRun, ShouldBeTrue)
+			So(spec.Adjusted, ShouldBeTrue)
+			So(spec.Provider, ShouldEqual, "csv")
+			So(spec.CsvDir, ShouldEqual, "archive")
+		})
+
+		Convey("TestParseFetchFlags should merge quality flags into the job spec file's rules", func() {
+			path := filepath.Join(t.TempDir(), "job.json")
+			So(os.WriteFile(path, []byte(`{"tickers": ["MSFT"], "quality": {"jump": "reject"}}`), 0644), ShouldBeNil)
+
+			spec, err := ParseFetchFlags([]string{"-job", path, "-quality", "session=off, volume=quarantine", "-max-jump", "0.2", "-validate=false"}, out)
+			So(err, ShouldBeNil)
+			So(spec.Quality, ShouldResemble, map[string]string{"jump": "reject", "session": "off", "volume": "quarantine"})
+			So(spec.MaxJump, ShouldEqual, 0.2)
+			So(spec.Validate, ShouldBeFalse)
+		})
+
+		Convey("TestParseFetchFlags should fail for a quality rule without an action", func() {
+			_, err := ParseFetchFlags([]string{"-quality", "session"}, out)
+			So(err.Error(), ShouldEqual, `invalid quality rule: "session", expected rule=action`)
+		})
+
+		Convey("TestParseFetchFlags should let a watchlist flag replace the job spec file's tickers", func() {
+			path := filepath.Join(t.TempDir(), "job.json")
+			So(os.WriteFile(path, []byte(`{"tickers": ["MSFT"], "start": "2023-01-03", "end": "2023-01-31"}`), 0644), ShouldBeNil)
+
+			spec, err := ParseFetchFlags([]string{"-job", path, "-watchlist", "tech"}, out)
+			So(err, ShouldBeNil)
+			So(spec.Tickers, ShouldBeNil)
+			So(spec.Watchlist, ShouldEqual, "tech")
+		})
+
+		Convey("TestParseFetchFlags should fail for a missing job spec file", func() {
+			_, err := ParseFetchFlags([]string{"-job", filepath.Join(t.TempDir(), "missing.json")}, out)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("TestParseFetchFlags should return flag.ErrHelp for -h", func() {
+			_, err := ParseFetchFlags([]string{"-h"}, out)
+			So(err, ShouldEqual, flag.ErrHelp)
+			So(out.String(), ShouldContainSubstring, "-timespan")
+		})
+	})
+}
+
+func TestFetchJobSpec_Resolve(t *testing.T) {
+	Convey("TestFetchJobSpec_Resolve", t, func() {
+		ny, err := time.LoadLocation("America/New_York")
+		So(err, ShouldBeNil)
+
+		now := time.Date(2023, 4, 3, 12, 0, 0, 0, ny)
+		spec := DefaultFetchJobSpec()
+		spec.Tickers = []string{" aapl", "AAPL", "amzn", ""}
+		spec.Start = "2023-03-01"
+		spec.End = "2023-03-31"
+
+		Convey("TestFetchJobSpec_Resolve should resolve a valid spec", func() {
+			j, ge := spec.Resolve(ny, now)
+			So(ge, ShouldBeNil)
+			So(j, ShouldResemble, FetchJob{
+				Tickers:     []string{"AAPL", "AMZN"},
+				StartDate:   time.Date(2023, 3, 1, 0, 0, 0, 0, ny),
+				EndDate:     time.Date(2023, 3, 31, 23, 59, 59, 999999999, ny),
+				Timespan:    model.PolygonAggregateTimespanMinute,
+				Multiplier:  1,
+				Limit:       MaxLimit,
+				Concurrency: 10,
+				Provider:    provider.ProviderNamePolygon,
+				Validate:    true,
+				Quality:     quality.DefaultConfig(),
+			})
+		})
+
+		Convey("TestFetchJobSpec_Resolve should apply quality actions on top of the defaults", func() {
+			spec.Quality = map[string]string{"jump": "quarantine", "session": "off"}
+			spec.MaxJump = 0.25
+			j, ge := spec.Resolve(ny, now)
+			So(ge, ShouldBeNil)
+			So(j.Quality.Actions[quality.RuleJump], ShouldEqual, quality.ActionQuarantine)
+			So(j.Quality.Actions[quality.RuleSession], ShouldEqual, quality.ActionOff)
+			So(j.Quality.Actions[quality.RuleVolume], ShouldEqual, quality.ActionReject)
+			So(j.Quality.MaxJump, ShouldEqual, 0.25)
+		})
+
+		Convey("TestFetchJobSpec_Resolve should reject unknown quality rules", func() {
+			spec.Quality = map[string]string{"spread": "warn"}
+			_, ge := spec.Resolve(ny, now)
+			So(ge.Error(), ShouldEqual, `FetchJobSpec:Resolve:invalid quality rule: "spread", expected one of: [priceRange volume window session duplicate jump]`)
+		})
+
+		Convey("TestFetchJobSpec_Resolve should resolve a watchlist spec without tickers", func() {
+			spec.Tickers = nil
+			spec.Watchlist = " tech "
+			j, ge := spec.Resolve(ny, now)
+			So(ge, ShouldBeNil)
+			So(j.Tickers, ShouldBeEmpty)
+			So(j.Watchlist, ShouldEqual, "tech")
+			So(j.String(), ShouldStartWith, "watchlist: tech, from:")
+		})
+
+		Convey("TestFetchJobSpec_Resolve should accept RFC3339 times", func() {
+			spec.Start = "2023-03-01T09:30:00-05:00"
+			spec.End = "2023-03-01T16:00:00-05:00"
+			j, ge := spec.Resolve(ny, now)
+			So(ge, ShouldBeNil)
+			So(j.StartDate.Equal(time.Date(2023, 3, 1, 9, 30, 0, 0, ny)), ShouldBeTrue)
+			So(j.EndDate.Equal(time.Date(2023, 3, 1, 16, 0, 0, 0, ny)), ShouldBeTrue)
+		})
+
+		Convey("TestFetchJobSpec_Resolve should end incremental jobs now when no end is given", func() {
+			spec.End = ""
+			spec.Incremental = true
+			j, ge := spec.Resolve(ny, now)
+			So(ge, ShouldBeNil)
+			So(j.EndDate, ShouldEqual, now)
+			So(j.Incremental, ShouldBeTrue)
+		})
+
+		Convey("TestFetchJobSpec_Resolve should reject invalid specs", func() {
+			cases := []struct {
+				update func(s *FetchJobSpec)
+				msg    string
+			}{
+				{func(s *FetchJobSpec) { s.Tickers = nil }, "FetchJobSpec:Resolve:at least one ticker or a watchlist is required"},
+				{func(s *FetchJobSpec) { s.Watchlist = "tech" }, "FetchJobSpec:Resolve:tickers and a watchlist cannot both be set"},
+				{func(s *FetchJobSpec) { s.Start = "" }, "parseDate:date is required->FetchJobSpec:Resolve:invalid start"},
+				{func(s *FetchJobSpec) { s.End = "03/31/2023" }, "parseDate:expected YYYY-MM-DD or RFC3339, got: 03/31/2023->FetchJobSpec:Resolve:invalid end"},
+				{func(s *FetchJobSpec) { s.End = "2023-02-01" }, "FetchJobSpec:Resolve:start: 2023-03-01 must be before end: 2023-02-01"},
+				{func(s *FetchJobSpec) { s.Timespan = "fortnight" }, `FetchJobSpec:Resolve:invalid timespan: "fortnight", expected one of: [second minute hour day week month quarter year]`},
+				{func(s *FetchJobSpec) { s.Multiplier = 0 }, "FetchJobSpec:Resolve:multiplier must be at least 1, got: 0"},
+				{func(s *FetchJobSpec) { s.Limit = 50001 }, "FetchJobSpec:Resolve:limit must be between 1 and 50000, got: 50001"},
+				{func(s *FetchJobSpec) { s.Partition = "-1m" }, "FetchJobSpec:Resolve:partition must be a positive duration, got: -1m"},
+				{func(s *FetchJobSpec) { s.Concurrency = 0 }, "FetchJobSpec:Resolve:concurrency must be at least 1, got: 0"},
+				{func(s *FetchJobSpec) { s.Provider = "yahoo" }, `FetchJobSpec:Resolve:invalid provider: "yahoo", expected one of: [polygon csv]`},
+				{func(s *FetchJobSpec) { s.Provider = "csv" }, "FetchJobSpec:Resolve:the csv provider requires a csv dir"},
+			}
+
+			for _, c := range cases {
+				s := spec
+				c.update(&s)
+				_, ge := s.Resolve(ny, now)
+				So(ge, ShouldNotBeNil)
+				So(ge.Error(), ShouldEqual, c.msg)
+			}
+		})
+	})
+}
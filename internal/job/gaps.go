@@ -0,0 +1,132 @@
+package job
+
+import (
+	"flag"
+	"fmt"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"io"
+	"strings"
+	"time"
+)
+
+type GapsOutputFormat string
+
+const (
+	GapsOutputFormatTable GapsOutputFormat = "table"
+	GapsOutputFormatJson  GapsOutputFormat = "json"
+)
+
+type GapsJobSpec struct {
+	Tickers     []string
+	Start       string
+	End         string
+	Timespan    string
+	Multiplier  int
+	Format      string
+	Backfill    bool
+	Limit       int
+	Concurrency int
+}
+
+type GapsJob struct {
+	Tickers     []string
+	StartDate   time.Time
+	EndDate     time.Time
+	Timespan    model.PolygonAggregateTimespan
+	Multiplier  int
+	Step        time.Duration
+	Format      GapsOutputFormat
+	Backfill    bool
+	Limit       int
+	Concurrency int
+}
+
+func ParseGapsFlags(args []string, output io.Writer) (GapsJobSpec, error) {
+	fs := flag.NewFlagSet("gaps", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of gaps:")
+		fmt.Fprintln(fs.Output(), "  Reports the bars missing from each trading session and optionally refetches them.")
+		fmt.Fprintln(fs.Output(), "  Polygon has no bar for a minute without trades. Bars up to a ticker's watermark were")
+		fmt.Fprintln(fs.Output(), "  fetched by an incremental fetch, so the ones missing there are reported as empty, not")
+		fmt.Fprintln(fs.Output(), "  missing, and are never backfilled. Bars past the watermark are reported as missing.")
+		fs.PrintDefaults()
+	}
+
+	tickers := fs.String("tickers", "", "comma separated tickers to check, for example: AAPL,AMZN")
+	start := fs.String("start", "", "first day or time to check, YYYY-MM-DD or RFC3339")
+	end := fs.String("end", "", "last day or time to check, YYYY-MM-DD or RFC3339, defaults to now")
+	timespan := fs.String("timespan", string(model.PolygonAggregateTimespanMinute), "bar timespan, minute or hour")
+	multiplier := fs.Int("multiplier", 1, "number of timespans in each bar")
+	format := fs.String("format", string(GapsOutputFormatTable), "output format, table or json")
+	backfill := fs.Bool("backfill", false, "refetch the missing windows after reporting them")
+	limit := fs.Int("limit", 100, "max number of aggregates per backfill request")
+	concurrency := fs.Int("concurrency", 5, "number of gaps backfilled at once")
+
+	if err := fs.Parse(args); err != nil {
+		return GapsJobSpec{}, err
+	}
+
+	if fs.NArg() > 0 {
+		return GapsJobSpec{}, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	return GapsJobSpec{
+		Tickers:     strings.Split(*tickers, ","),
+		Start:       *start,
+		End:         *end,
+		Timespan:    *timespan,
+		Multiplier:  *multiplier,
+		Format:      *format,
+		Backfill:    *backfill,
+		Limit:       *limit,
+		Concurrency: *concurrency,
+	}, nil
+}
+
+func (s GapsJobSpec) Resolve(loc *time.Location, now time.Time) (GapsJob, genErr.IGenError) {
+	fs := FetchJobSpec{
+		Tickers:     s.Tickers,
+		Start:       s.Start,
+		End:         s.End,
+		Timespan:    s.Timespan,
+		Multiplier:  s.Multiplier,
+		Limit:       s.Limit,
+		Concurrency: s.Concurrency,
+		Incremental: true,
+	}
+
+	fj, ge := fs.Resolve(loc, now)
+	if ge != nil {
+		return GapsJob{}, ge.AddMsg("GapsJobSpec:Resolve:invalid gaps job")
+	}
+
+	var step time.Duration
+	switch fj.Timespan {
+	case model.PolygonAggregateTimespanMinute:
+		step = time.Duration(fj.Multiplier) * time.Minute
+	case model.PolygonAggregateTimespanHour:
+		step = time.Duration(fj.Multiplier) * time.Hour
+	default:
+		return GapsJob{}, &genErr.GenError{Messages: []string{"GapsJobSpec:Resolve:gaps can only be found for minute or hour bars, got: " + string(fj.Timespan)}}
+	}
+
+	format := GapsOutputFormat(strings.ToLower(s.Format))
+	if format != GapsOutputFormatTable && format != GapsOutputFormatJson {
+		return GapsJob{}, &genErr.GenError{Messages: []string{"GapsJobSpec:Resolve:format must be table or json, got: " + s.Format}}
+	}
+
+	return GapsJob{
+		Tickers:     fj.Tickers,
+		StartDate:   fj.StartDate,
+		EndDate:     fj.EndDate,
+		Timespan:    fj.Timespan,
+		Multiplier:  fj.Multiplier,
+		Step:        step,
+		Format:      format,
+		Backfill:    s.Backfill,
+		Limit:       fj.Limit,
+		Concurrency: fj.Concurrency,
+	}, nil
+}
@@ -0,0 +1,50 @@
+package job
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGapsJobSpec_Resolve(t *testing.T) {
+	Convey("TestGapsJobSpec_Resolve", t, func() {
+		ny, err := time.LoadLocation("America/New_York")
+		So(err, ShouldBeNil)
+		now := time.Date(2023, 4, 3, 12, 0, 0, 0, ny)
+
+		spec, err := ParseGapsFlags([]string{"-tickers", "aapl", "-start", "2023-03-01", "-multiplier", "5", "-format", "JSON"}, &bytes.Buffer{})
+		So(err, ShouldBeNil)
+
+		Convey("TestGapsJobSpec_Resolve should default the end to now", func() {
+			j, ge := spec.Resolve(ny, now)
+			So(ge, ShouldBeNil)
+			So(j.Tickers, ShouldResemble, []string{"AAPL"})
+			So(j.EndDate, ShouldEqual, now)
+			So(j.Step, ShouldEqual, 5*time.Minute)
+			So(j.Format, ShouldEqual, GapsOutputFormatJson)
+		})
+
+		Convey("TestGapsJobSpec_Resolve should reject timespans longer than an hour", func() {
+			spec.Timespan = "day"
+			_, ge := spec.Resolve(ny, now)
+			So(ge.Error(), ShouldEqual, "GapsJobSpec:Resolve:gaps can only be found for minute or hour bars, got: day")
+		})
+
+		Convey("TestGapsJobSpec_Resolve should explain empty minutes in the help", func() {
+			b := &bytes.Buffer{}
+			_, err := ParseGapsFlags([]string{"-h"}, b)
+			So(err, ShouldEqual, flag.ErrHelp)
+			So(b.String(), ShouldContainSubstring, "Polygon has no bar for a minute without trades")
+			So(b.String(), ShouldContainSubstring, "-backfill")
+		})
+
+		Convey("TestGapsJobSpec_Resolve should reject unknown formats", func() {
+			spec.Format = "csv"
+			_, ge := spec.Resolve(ny, now)
+			So(ge.Error(), ShouldEqual, "GapsJobSpec:Resolve:format must be table or json, got: csv")
+		})
+	})
+}
@@ -0,0 +1,76 @@
+package job
+
+import (
+	"flag"
+	"fmt"
+	genErr "github.com/greenac/chaching/internal/error"
+	"io"
+	"time"
+)
+
+type GroupedJobSpec struct {
+	Start       string
+	End         string
+	Adjusted    bool
+	IncludeOtc  bool
+	Concurrency int
+}
+
+type GroupedJob struct {
+	StartDate   time.Time
+	EndDate     time.Time
+	Adjusted    bool
+	IncludeOtc  bool
+	Concurrency int
+}
+
+func ParseGroupedFlags(args []string, output io.Writer) (GroupedJobSpec, error) {
+	fs := flag.NewFlagSet("grouped", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	start := fs.String("start", "", "first day to fetch, YYYY-MM-DD")
+	end := fs.String("end", "", "last day to fetch, YYYY-MM-DD, defaults to today")
+	adjusted := fs.Bool("adjusted", false, "fetch split adjusted bars, which are stored beside the unadjusted series")
+	includeOtc := fs.Bool("include-otc", false, "include otc securities")
+	concurrency := fs.Int("concurrency", 3, "number of days fetched at once")
+
+	if err := fs.Parse(args); err != nil {
+		return GroupedJobSpec{}, err
+	}
+
+	if fs.NArg() > 0 {
+		return GroupedJobSpec{}, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	return GroupedJobSpec{Start: *start, End: *end, Adjusted: *adjusted, IncludeOtc: *includeOtc, Concurrency: *concurrency}, nil
+}
+
+func (s GroupedJobSpec) Resolve(loc *time.Location, now time.Time) (GroupedJob, genErr.IGenError) {
+	startDate, _, ge := parseDate(s.Start, loc)
+	if ge != nil {
+		return GroupedJob{}, ge.AddMsg("GroupedJobSpec:Resolve:invalid start")
+	}
+
+	endDate := now
+	if s.End != "" {
+		var isDay bool
+		endDate, isDay, ge = parseDate(s.End, loc)
+		if ge != nil {
+			return GroupedJob{}, ge.AddMsg("GroupedJobSpec:Resolve:invalid end")
+		}
+
+		if isDay {
+			endDate = endDate.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+
+	if endDate.Before(startDate) {
+		return GroupedJob{}, &genErr.GenError{Messages: []string{"GroupedJobSpec:Resolve:start: " + s.Start + " must not be after end: " + s.End}}
+	}
+
+	if s.Concurrency < 1 {
+		return GroupedJob{}, &genErr.GenError{Messages: []string{fmt.Sprintf("GroupedJobSpec:Resolve:concurrency must be at least 1, got: %d", s.Concurrency)}}
+	}
+
+	return GroupedJob{StartDate: startDate, EndDate: endDate, Adjusted: s.Adjusted, IncludeOtc: s.IncludeOtc, Concurrency: s.Concurrency}, nil
+}
@@ -0,0 +1,40 @@
+package job
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGroupedJobSpec_Resolve(t *testing.T) {
+	Convey("TestGroupedJobSpec_Resolve", t, func() {
+		ny, err := time.LoadLocation("America/New_York")
+		So(err, ShouldBeNil)
+		now := time.Date(2023, 4, 3, 12, 0, 0, 0, ny)
+
+		Convey("TestGroupedJobSpec_Resolve should allow a single day", func() {
+			spec, err := ParseGroupedFlags([]string{"-start", "2023-03-01", "-end", "2023-03-01", "-adjusted"}, &bytes.Buffer{})
+			So(err, ShouldBeNil)
+
+			j, ge := spec.Resolve(ny, now)
+			So(ge, ShouldBeNil)
+			So(j.StartDate, ShouldEqual, time.Date(2023, 3, 1, 0, 0, 0, 0, ny))
+			So(j.EndDate, ShouldEqual, time.Date(2023, 3, 2, 0, 0, 0, 0, ny).Add(-time.Nanosecond))
+			So(j.Adjusted, ShouldBeTrue)
+			So(j.Concurrency, ShouldEqual, 3)
+		})
+
+		Convey("TestGroupedJobSpec_Resolve should default the end to now", func() {
+			j, ge := GroupedJobSpec{Start: "2023-03-01", Concurrency: 1}.Resolve(ny, now)
+			So(ge, ShouldBeNil)
+			So(j.EndDate, ShouldEqual, now)
+		})
+
+		Convey("TestGroupedJobSpec_Resolve should reject an end before the start", func() {
+			_, ge := GroupedJobSpec{Start: "2023-03-02", End: "2023-03-01", Concurrency: 1}.Resolve(ny, now)
+			So(ge.Error(), ShouldEqual, "GroupedJobSpec:Resolve:start: 2023-03-02 must not be after end: 2023-03-01")
+		})
+	})
+}
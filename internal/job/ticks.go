@@ -0,0 +1,84 @@
+package job
+
+import (
+	"flag"
+	"fmt"
+	genErr "github.com/greenac/chaching/internal/error"
+	"io"
+	"strings"
+	"time"
+)
+
+type TicksJobSpec struct {
+	Tickers []string
+	Start   string
+	End     string
+	Trades  bool
+	Quotes  bool
+	Limit   int
+}
+
+type TicksJob struct {
+	Tickers   []string
+	StartDate time.Time
+	EndDate   time.Time
+	Trades    bool
+	Quotes    bool
+	Limit     int
+}
+
+func ParseTicksFlags(args []string, output io.Writer) (TicksJobSpec, error) {
+	fs := flag.NewFlagSet("ticks", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	tickers := fs.String("tickers", "", "comma separated tickers to fetch, for example: AAPL,AMZN")
+	start := fs.String("start", "", "first day or time to fetch, YYYY-MM-DD or RFC3339")
+	end := fs.String("end", "", "last day or time to fetch, YYYY-MM-DD or RFC3339, defaults to now")
+	trades := fs.Bool("trades", true, "fetch trades")
+	quotes := fs.Bool("quotes", false, "fetch NBBO quotes")
+	limit := fs.Int("limit", 50000, "max number of ticks per request")
+
+	if err := fs.Parse(args); err != nil {
+		return TicksJobSpec{}, err
+	}
+
+	if fs.NArg() > 0 {
+		return TicksJobSpec{}, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	return TicksJobSpec{
+		Tickers: strings.Split(*tickers, ","),
+		Start:   *start,
+		End:     *end,
+		Trades:  *trades,
+		Quotes:  *quotes,
+		Limit:   *limit,
+	}, nil
+}
+
+func (s TicksJobSpec) Resolve(loc *time.Location, now time.Time) (TicksJob, genErr.IGenError) {
+	if !s.Trades && !s.Quotes {
+		return TicksJob{}, &genErr.GenError{Messages: []string{"TicksJobSpec:Resolve:at least one of trades or quotes must be fetched"}}
+	}
+
+	fs := DefaultFetchJobSpec()
+	fs.Tickers = s.Tickers
+	fs.Start = s.Start
+	fs.End = s.End
+	fs.Limit = s.Limit
+	fs.Incremental = true
+
+	fj, ge := fs.Resolve(loc, now)
+	if ge != nil {
+		return TicksJob{}, ge.AddMsg("TicksJobSpec:Resolve:invalid ticks job")
+	}
+
+	return TicksJob{
+		Tickers:   fj.Tickers,
+		StartDate: fj.StartDate,
+		EndDate:   fj.EndDate,
+		Trades:    s.Trades,
+		Quotes:    s.Quotes,
+		Limit:     fj.Limit,
+	}, nil
+}
@@ -0,0 +1,38 @@
+package job
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTicksJobSpec_Resolve(t *testing.T) {
+	Convey("TestTicksJobSpec_Resolve", t, func() {
+		ny, err := time.LoadLocation("America/New_York")
+		So(err, ShouldBeNil)
+		now := time.Date(2023, 4, 3, 12, 0, 0, 0, ny)
+
+		spec, err := ParseTicksFlags([]string{"-tickers", "aapl,msft", "-start", "2023-03-01", "-end", "2023-03-01", "-quotes"}, &bytes.Buffer{})
+		So(err, ShouldBeNil)
+
+		Convey("TestTicksJobSpec_Resolve should fetch trades and quotes through the end of the day", func() {
+			j, ge := spec.Resolve(ny, now)
+			So(ge, ShouldBeNil)
+			So(j.Tickers, ShouldResemble, []string{"AAPL", "MSFT"})
+			So(j.StartDate, ShouldEqual, time.Date(2023, 3, 1, 0, 0, 0, 0, ny))
+			So(j.EndDate.After(time.Date(2023, 3, 1, 23, 59, 0, 0, ny)), ShouldBeTrue)
+			So(j.Trades, ShouldBeTrue)
+			So(j.Quotes, ShouldBeTrue)
+			So(j.Limit, ShouldEqual, 50000)
+		})
+
+		Convey("TestTicksJobSpec_Resolve should require trades or quotes", func() {
+			spec.Trades = false
+			spec.Quotes = false
+			_, ge := spec.Resolve(ny, now)
+			So(ge.Error(), ShouldEqual, "TicksJobSpec:Resolve:at least one of trades or quotes must be fetched")
+		})
+	})
+}
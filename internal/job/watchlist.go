@@ -0,0 +1,83 @@
+package job
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/greenac/chaching/internal/database/models"
+	"io"
+	"strings"
+)
+
+type WatchlistAction string
+
+const (
+	WatchlistActionCreate WatchlistAction = "create"
+	WatchlistActionAdd    WatchlistAction = "add"
+	WatchlistActionRemove WatchlistAction = "remove"
+	WatchlistActionList   WatchlistAction = "list"
+	WatchlistActionShow   WatchlistAction = "show"
+)
+
+func AllWatchlistActions() []WatchlistAction {
+	return []WatchlistAction{WatchlistActionCreate, WatchlistActionAdd, WatchlistActionRemove, WatchlistActionList, WatchlistActionShow}
+}
+
+// WatchlistCommand is one watchlist subcommand, such as `add tech AAPL,AMZN MSFT`
+type WatchlistCommand struct {
+	Action     WatchlistAction
+	Name       string
+	Tickers    []string
+	NoValidate bool
+}
+
+// ParseWatchlistCommand parses `<action> [flags] [name] [tickers...]`, where tickers are separated by commas or spaces
+func ParseWatchlistCommand(args []string, output io.Writer) (WatchlistCommand, error) {
+	if len(args) == 0 {
+		return WatchlistCommand{}, fmt.Errorf("an action is required, one of: %v", AllWatchlistActions())
+	}
+
+	cmd := WatchlistCommand{Action: WatchlistAction(args[0])}
+
+	fs := flag.NewFlagSet("watchlist "+args[0], flag.ContinueOnError)
+	fs.SetOutput(output)
+	noValidate := fs.Bool("no-validate", false, "skip checking tickers against the stored reference data")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return WatchlistCommand{}, err
+	}
+
+	cmd.NoValidate = *noValidate
+	rest := fs.Args()
+
+	switch cmd.Action {
+	case WatchlistActionList:
+		if len(rest) > 0 {
+			return WatchlistCommand{}, fmt.Errorf("unexpected arguments: %v", rest)
+		}
+
+		return cmd, nil
+	case WatchlistActionShow:
+		if len(rest) != 1 {
+			return WatchlistCommand{}, errors.New("show takes a watchlist name")
+		}
+	case WatchlistActionCreate, WatchlistActionAdd, WatchlistActionRemove:
+		if len(rest) == 0 {
+			return WatchlistCommand{}, fmt.Errorf("%s takes a watchlist name followed by tickers", cmd.Action)
+		}
+	default:
+		return WatchlistCommand{}, fmt.Errorf("unknown action: %q, expected one of: %v", cmd.Action, AllWatchlistActions())
+	}
+
+	cmd.Name = rest[0]
+	for _, arg := range rest[1:] {
+		cmd.Tickers = append(cmd.Tickers, strings.Split(arg, ",")...)
+	}
+	cmd.Tickers = models.NormalizeTickers(cmd.Tickers)
+
+	if (cmd.Action == WatchlistActionAdd || cmd.Action == WatchlistActionRemove) && len(cmd.Tickers) == 0 {
+		return WatchlistCommand{}, fmt.Errorf("%s takes at least one ticker", cmd.Action)
+	}
+
+	return cmd, nil
+}
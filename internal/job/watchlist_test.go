@@ -0,0 +1,53 @@
+package job
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseWatchlistCommand(t *testing.T) {
+	Convey("TestParseWatchlistCommand", t, func() {
+		out := &bytes.Buffer{}
+
+		Convey("TestParseWatchlistCommand should split tickers on commas and spaces", func() {
+			cmd, err := ParseWatchlistCommand([]string{"create", "-no-validate", "tech", "aapl,AMZN", "msft", "AAPL"}, out)
+			So(err, ShouldBeNil)
+			So(cmd, ShouldResemble, WatchlistCommand{Action: WatchlistActionCreate, Name: "tech", Tickers: []string{"AAPL", "AMZN", "MSFT"}, NoValidate: true})
+		})
+
+		Convey("TestParseWatchlistCommand should allow creating an empty watchlist", func() {
+			cmd, err := ParseWatchlistCommand([]string{"create", "tech"}, out)
+			So(err, ShouldBeNil)
+			So(cmd.Name, ShouldEqual, "tech")
+			So(cmd.Tickers, ShouldBeEmpty)
+		})
+
+		Convey("TestParseWatchlistCommand should parse list and show", func() {
+			cmd, err := ParseWatchlistCommand([]string{"list"}, out)
+			So(err, ShouldBeNil)
+			So(cmd.Action, ShouldEqual, WatchlistActionList)
+
+			cmd, err = ParseWatchlistCommand([]string{"show", "tech"}, out)
+			So(err, ShouldBeNil)
+			So(cmd.Name, ShouldEqual, "tech")
+		})
+
+		Convey("TestParseWatchlistCommand should reject invalid commands", func() {
+			cases := [][]string{
+				{},
+				{"rename", "tech"},
+				{"add", "tech"},
+				{"remove"},
+				{"show"},
+				{"list", "tech"},
+			}
+
+			for _, args := range cases {
+				_, err := ParseWatchlistCommand(args, out)
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
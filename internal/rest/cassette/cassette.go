@@ -0,0 +1,282 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/rest/models"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ModeEnvVar switches cassettes into record mode when set to `record`, so fixtures can be refreshed by
+// rerunning the tests against the real api
+const ModeEnvVar = "CASSETTE_MODE"
+
+const scrubbed = "[scrubbed]"
+
+type Mode string
+
+const (
+	ModeReplay Mode = "replay"
+	ModeRecord Mode = "record"
+)
+
+// ModeFromEnv reads the mode from ModeEnvVar, replaying unless it asks to record
+func ModeFromEnv() Mode {
+	if Mode(strings.ToLower(strings.TrimSpace(os.Getenv(ModeEnvVar)))) == ModeRecord {
+		return ModeRecord
+	}
+
+	return ModeReplay
+}
+
+// DefaultScrubHeaders and DefaultScrubParams hold the credentials polygon accepts, which are never written to disk
+var (
+	DefaultScrubHeaders = []string{"Authorization", "X-Api-Key", "Cookie", "Set-Cookie"}
+	DefaultScrubParams  = []string{"apiKey"}
+)
+
+type Request struct {
+	Method string      `json:"method"`
+	Url    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+}
+
+// Response keeps json bodies as json so recorded fixtures stay readable, other bodies are kept as text
+type Response struct {
+	StatusCode int             `json:"statusCode"`
+	Status     string          `json:"status"`
+	Header     http.Header     `json:"header,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	RawBody    string          `json:"rawBody,omitempty"`
+}
+
+func (r Response) body() []byte {
+	if len(r.Body) > 0 {
+		return r.Body
+	}
+
+	return []byte(r.RawBody)
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type file struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+var _ models.IHttpClient = (*Cassette)(nil)
+
+// New opens the cassette at path. Replaying loads its interactions, recording starts it over and sends every
+// request through transport, saving each exchange as it completes
+func New(path string, mode Mode, transport models.IHttpClient) (*Cassette, genErr.IGenError) {
+	c := &Cassette{
+		Path:         path,
+		Mode:         mode,
+		Transport:    transport,
+		ScrubHeaders: DefaultScrubHeaders,
+		ScrubParams:  DefaultScrubParams,
+		played:       map[int]bool{},
+	}
+
+	if mode == ModeRecord {
+		if transport == nil {
+			return nil, &genErr.GenError{Messages: []string{"Cassette:New:recording requires a transport"}}
+		}
+
+		return c, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &genErr.GenError{Messages: []string{"Cassette:New:failed to read cassette: " + path + " with error: " + err.Error()}}
+	}
+
+	var f file
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, &genErr.GenError{Messages: []string{"Cassette:New:failed to unmarshal cassette: " + path + " with error: " + err.Error()}}
+	}
+
+	c.interactions = f.Interactions
+
+	return c, nil
+}
+
+// Cassette is an http client that records exchanges to disk or replays them. Requests are matched by method,
+// path and query, ignoring the host and scrubbed params, and each recorded interaction is replayed once, in order
+type Cassette struct {
+	Path         string
+	Mode         Mode
+	Transport    models.IHttpClient
+	ScrubHeaders []string
+	ScrubParams  []string
+	mu           sync.Mutex
+	interactions []Interaction
+	played       map[int]bool
+}
+
+func (c *Cassette) Do(req *http.Request) (*http.Response, error) {
+	if c.Mode == ModeRecord {
+		return c.record(req)
+	}
+
+	return c.replay(req)
+}
+
+// Interactions returns the recorded or loaded interactions
+func (c *Cassette) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Interaction{}, c.interactions...)
+}
+
+func (c *Cassette) replay(req *http.Request) (*http.Response, error) {
+	key := c.matchKey(req.Method, req.URL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, in := range c.interactions {
+		if c.played[i] {
+			continue
+		}
+
+		u, err := url.Parse(in.Request.Url)
+		if err != nil || c.matchKey(in.Request.Method, u) != key {
+			continue
+		}
+
+		c.played[i] = true
+
+		return in.Response.httpResponse(req), nil
+	}
+
+	return nil, &genErr.GenError{Messages: []string{"Cassette:replay:no recorded interaction for: " + key + " in: " + c.Path}}
+}
+
+func (c *Cassette) record(req *http.Request) (*http.Response, error) {
+	res, err := c.Transport.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if res.Body != nil {
+		body, err = io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if err != nil {
+			return nil, &genErr.GenError{Messages: []string{"Cassette:record:failed to read response body with error: " + err.Error()}}
+		}
+	}
+
+	in := Interaction{
+		Request:  Request{Method: req.Method, Url: c.scrubUrl(req.URL), Header: c.scrubHeader(req.Header)},
+		Response: Response{StatusCode: res.StatusCode, Status: res.Status, Header: c.scrubHeader(res.Header)},
+	}
+
+	if json.Valid(body) {
+		in.Response.Body = body
+	} else {
+		in.Response.RawBody = string(body)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.interactions = append(c.interactions, in)
+	if ge := c.save(); ge != nil {
+		return nil, ge.AddMsg("Cassette:record:failed to save")
+	}
+
+	return in.Response.httpResponse(req), nil
+}
+
+// save writes the cassette, callers must hold mu
+func (c *Cassette) save() genErr.IGenError {
+	b, err := json.MarshalIndent(file{Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return &genErr.GenError{Messages: []string{"Cassette:save:failed to marshal with error: " + err.Error()}}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0o755); err != nil {
+		return &genErr.GenError{Messages: []string{"Cassette:save:failed to create dir for: " + c.Path + " with error: " + err.Error()}}
+	}
+
+	if err := os.WriteFile(c.Path, append(b, '\n'), 0o644); err != nil {
+		return &genErr.GenError{Messages: []string{"Cassette:save:failed to write: " + c.Path + " with error: " + err.Error()}}
+	}
+
+	return nil
+}
+
+func (c *Cassette) matchKey(method string, u *url.URL) string {
+	q := u.Query()
+	for _, p := range c.ScrubParams {
+		q.Del(p)
+	}
+
+	key := strings.ToUpper(method) + " " + u.Path
+	if len(q) > 0 {
+		key += "?" + q.Encode()
+	}
+
+	return key
+}
+
+func (c *Cassette) scrubUrl(u *url.URL) string {
+	scrubbedUrl := *u
+	q := u.Query()
+	for _, p := range c.ScrubParams {
+		if q.Has(p) {
+			q.Set(p, scrubbed)
+		}
+	}
+
+	scrubbedUrl.RawQuery = q.Encode()
+	scrubbedUrl.User = nil
+
+	return scrubbedUrl.String()
+}
+
+func (c *Cassette) scrubHeader(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+
+	sh := h.Clone()
+	for _, name := range c.ScrubHeaders {
+		if sh.Get(name) != "" {
+			sh.Set(name, scrubbed)
+		}
+	}
+
+	return sh
+}
+
+func (r Response) httpResponse(req *http.Request) *http.Response {
+	body := r.body()
+	status := r.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode))
+	}
+
+	return &http.Response{
+		StatusCode:    r.StatusCode,
+		Status:        status,
+		Header:        r.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,140 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func get(c *Cassette, url string, header http.Header) (*http.Response, string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		panic(err)
+	}
+
+	return res, string(body), nil
+}
+
+func TestModeFromEnv(t *testing.T) {
+	Convey("TestModeFromEnv", t, func() {
+		Convey("TestModeFromEnv should replay by default", func() {
+			t.Setenv(ModeEnvVar, "")
+			So(ModeFromEnv(), ShouldEqual, ModeReplay)
+		})
+
+		Convey("TestModeFromEnv should record when asked to", func() {
+			t.Setenv(ModeEnvVar, " Record ")
+			So(ModeFromEnv(), ShouldEqual, ModeRecord)
+		})
+	})
+}
+
+func TestCassette(t *testing.T) {
+	Convey("TestCassette", t, func() {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls += 1
+			w.Header().Set("Set-Cookie", "session=abc")
+			if r.URL.Path == "/text" {
+				_, _ = w.Write([]byte("plain text"))
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status":"OK","page":"` + r.URL.Query().Get("cursor") + `"}`))
+		}))
+		defer server.Close()
+
+		path := filepath.Join(t.TempDir(), "cassettes", "aggs.json")
+
+		Convey("TestCassette should record exchanges with credentials scrubbed and replay them without the server", func() {
+			rc, ge := New(path, ModeRecord, server.Client())
+			So(ge, ShouldBeNil)
+
+			_, body, err := get(rc, server.URL+"/v2/aggs?cursor=1&apiKey=secret", http.Header{"Authorization": {"Bearer secret"}})
+			So(err, ShouldBeNil)
+			So(body, ShouldEqual, `{"status":"OK","page":"1"}`)
+			_, _, err = get(rc, server.URL+"/v2/aggs?cursor=2", nil)
+			So(err, ShouldBeNil)
+			_, body, err = get(rc, server.URL+"/text", nil)
+			So(err, ShouldBeNil)
+			So(body, ShouldEqual, "plain text")
+
+			saved, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(string(saved), ShouldNotContainSubstring, "secret")
+			So(string(saved), ShouldNotContainSubstring, "session=abc")
+			So(rc.Interactions()[0].Request.Header.Get("Authorization"), ShouldEqual, scrubbed)
+			So(rc.Interactions()[2].Response.RawBody, ShouldEqual, "plain text")
+
+			server.Close()
+
+			pc, ge := New(path, ModeReplay, nil)
+			So(ge, ShouldBeNil)
+
+			Convey("TestCassette should match by method, path and query in any host and param order", func() {
+				res, body, err := get(pc, "https://api.polygon.io/v2/aggs?cursor=2", nil)
+				So(err, ShouldBeNil)
+				So(res.StatusCode, ShouldEqual, http.StatusOK)
+				So(res.Header.Get("Content-Type"), ShouldEqual, "application/json")
+				So(body, ShouldContainSubstring, `"page": "2"`)
+
+				_, body, err = get(pc, "https://api.polygon.io/v2/aggs?apiKey=other&cursor=1", nil)
+				So(err, ShouldBeNil)
+				So(body, ShouldContainSubstring, `"page": "1"`)
+
+				_, body, err = get(pc, "https://api.polygon.io/text", nil)
+				So(err, ShouldBeNil)
+				So(body, ShouldEqual, "plain text")
+			})
+
+			Convey("TestCassette should replay each interaction once", func() {
+				_, _, err := get(pc, "https://api.polygon.io/v2/aggs?cursor=1", nil)
+				So(err, ShouldBeNil)
+
+				_, _, err = get(pc, "https://api.polygon.io/v2/aggs?cursor=1", nil)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "Cassette:replay:no recorded interaction for: GET /v2/aggs?cursor=1 in: "+path)
+			})
+
+			Convey("TestCassette should fail for requests it never recorded", func() {
+				_, _, err := get(pc, "https://api.polygon.io/v2/aggs?cursor=3", nil)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "Cassette:replay:no recorded interaction for: GET /v2/aggs?cursor=3 in: "+path)
+			})
+
+			So(calls, ShouldEqual, 3)
+		})
+
+		Convey("TestCassette should fail to replay a missing cassette", func() {
+			_, ge := New(path, ModeReplay, nil)
+			So(ge, ShouldNotBeNil)
+			So(ge.Error(), ShouldStartWith, "Cassette:New:failed to read cassette: "+path)
+		})
+
+		Convey("TestCassette should require a transport to record", func() {
+			_, ge := New(path, ModeRecord, nil)
+			So(ge, ShouldNotBeNil)
+			So(ge.Error(), ShouldEqual, "Cassette:New:recording requires a transport")
+		})
+	})
+}
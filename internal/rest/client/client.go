@@ -2,10 +2,16 @@ package rest
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/rest/limiter"
 	"github.com/greenac/chaching/internal/rest/models"
+	"github.com/greenac/chaching/internal/service/logger"
 	"io"
+	"math/rand"
 	"net/http"
+	"time"
 )
 
 var _ models.IClient = (*Client)(nil)
@@ -14,11 +20,16 @@ type Client struct {
 	BaseHeaders *models.Headers
 	HttpClient  models.IHttpClient
 	BodyReader  func(r io.Reader) ([]byte, error)
-	GetRequest  func(method, url string, body io.Reader) (*http.Request, error)
+	GetRequest  func(ctx context.Context, method, url string, body io.Reader) (*http.Request, error)
+	Limiter     limiter.ILimiter
+	RetryPolicy *models.RetryPolicy
+	Logger      logger.ILogger
+	sleep       func(ctx context.Context, d time.Duration) error
+	jitter      func(d time.Duration) time.Duration
 }
 
-func (c *Client) Get(url string, headers *models.Headers, params models.UrlParams) (models.Response, genErr.IGenError) {
-	req, err := c.GetRequest("GET", url, nil)
+func (c *Client) Get(ctx context.Context, url string, headers *models.Headers, params models.UrlParams) (models.Response, genErr.IGenError) {
+	req, err := c.GetRequest(ctx, "GET", url, nil)
 	if err != nil {
 		ge := genErr.GenError{}
 		return models.Response{}, ge.AddMsg("ClientImpl:Get:failed to get new request with url: " + url + "with error: " + err.Error())
@@ -27,8 +38,8 @@ func (c *Client) Get(url string, headers *models.Headers, params models.UrlParam
 	return c.makeRequest(req, c.makeHeaders(headers))
 }
 
-func (c *Client) PostBody(url string, headers *models.Headers, body []byte) (models.Response, genErr.IGenError) {
-	req, err := c.GetRequest("POST", url, bytes.NewReader(body))
+func (c *Client) PostBody(ctx context.Context, url string, headers *models.Headers, body []byte) (models.Response, genErr.IGenError) {
+	req, err := c.GetRequest(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		ge := genErr.GenError{}
 		return models.Response{}, ge.AddMsg("ClientImpl:PostBody:failed to get new request with url: " + url + "with error: " + err.Error())
@@ -37,8 +48,8 @@ func (c *Client) PostBody(url string, headers *models.Headers, body []byte) (mod
 	return c.makeRequest(req, c.makeHeaders(headers))
 }
 
-func (c *Client) PostUrl(url string, headers *models.Headers, params models.UrlParams) (models.Response, genErr.IGenError) {
-	req, err := c.GetRequest("POST", url, nil)
+func (c *Client) PostUrl(ctx context.Context, url string, headers *models.Headers, params models.UrlParams) (models.Response, genErr.IGenError) {
+	req, err := c.GetRequest(ctx, "POST", url, nil)
 	if err != nil {
 		ge := genErr.GenError{}
 		return models.Response{}, ge.AddMsg("ClientImpl:PostUrl:failed to get new request with url: " + url + "with error: " + err.Error())
@@ -70,22 +81,114 @@ func (c *Client) makeRequest(req *http.Request, headers map[string]string) (mode
 		req.Header.Add(k, h)
 	}
 
+	maxAttempts := 1
+	if c.RetryPolicy != nil && c.RetryPolicy.MaxAttempts > 1 {
+		maxAttempts = c.RetryPolicy.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt += 1 {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				ge := genErr.GenError{}
+				return models.Response{}, ge.AddMsg("ClientImpl:makeRequest:failed to reset request body with error: " + err.Error())
+			}
+			req.Body = body
+		}
+
+		resp, ge, retryable := c.doRequest(req)
+		if attempt >= maxAttempts || !retryable {
+			return resp, ge
+		}
+
+		delay := c.retryDelay(attempt, resp)
+		if c.Logger != nil {
+			reason := resp.Status
+			if ge != nil {
+				reason = ge.Error()
+			}
+			c.Logger.Warn(fmt.Sprintf("ClientImpl:makeRequest:attempt %d of %d for url: %s failed with: %s, retrying in: %s", attempt, maxAttempts, req.URL.Redacted(), reason, delay))
+		}
+
+		err := c.sleeper()(req.Context(), delay)
+		if err != nil {
+			ge := genErr.GenError{}
+			return models.Response{}, ge.AddMsg("ClientImpl:makeRequest:stopped retrying with error: " + err.Error())
+		}
+	}
+}
+
+// doRequest makes a single attempt at the request. The returned bool reports whether the attempt can be retried
+func (c *Client) doRequest(req *http.Request) (models.Response, genErr.IGenError, bool) {
+	if c.Limiter != nil {
+		err := c.Limiter.Wait(req.Context())
+		if err != nil {
+			if ge, ok := err.(genErr.IGenError); ok {
+				return models.Response{}, ge.AddMsg("ClientImpl:makeRequest:rate limiter refused request"), false
+			}
+
+			ge := genErr.GenError{}
+			return models.Response{}, ge.AddMsg("ClientImpl:makeRequest:failed waiting on rate limiter with error: " + err.Error()), false
+		}
+	}
+
 	res, err := c.HttpClient.Do(req)
 	if err != nil {
 		ge := genErr.GenError{}
-		return models.Response{}, ge.AddMsg("ClientImpl:makeRequest:failed to make request with error: " + err.Error())
+		return models.Response{}, ge.AddMsg("ClientImpl:makeRequest:failed to make request with error: " + err.Error()), req.Context().Err() == nil
 	}
 
 	var body []byte
 	if res.Body != nil {
+		defer res.Body.Close()
 		b, err := c.BodyReader(res.Body)
 		if err != nil {
 			ge := genErr.GenError{}
-			return models.Response{}, ge.AddMsg("ClientImpl:makeRequest:failed to read response body with error: " + err.Error())
+			return models.Response{}, ge.AddMsg("ClientImpl:makeRequest:failed to read response body with error: " + err.Error()), true
 		}
 
 		body = b
 	}
 
-	return models.Response{StatusCode: res.StatusCode, Status: res.Status, Body: body}, nil
+	resp := models.Response{StatusCode: res.StatusCode, Status: res.Status, Body: body, Header: res.Header}
+
+	return resp, nil, c.RetryPolicy != nil && c.RetryPolicy.ShouldRetryStatus(res.StatusCode)
+}
+
+// retryDelay backs off exponentially with jitter, waiting at least as long as a `Retry-After` header asks. The
+// header is capped at the policy's MaxDelay, so a bad or hostile value can't stall a worker for hours
+func (c *Client) retryDelay(attempt int, resp models.Response) time.Duration {
+	delay := c.RetryPolicy.Backoff(attempt)
+	if c.jitter != nil {
+		delay = c.jitter(delay)
+	} else if delay > 0 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+
+	if ra, ok := resp.RetryAfter(time.Now()); ok && ra > delay {
+		delay = ra
+		if c.RetryPolicy.MaxDelay > 0 && delay > c.RetryPolicy.MaxDelay {
+			delay = c.RetryPolicy.MaxDelay
+		}
+	}
+
+	return delay
+}
+
+func (c *Client) sleeper() func(ctx context.Context, d time.Duration) error {
+	if c.sleep != nil {
+		return c.sleep
+	}
+
+	return func(ctx context.Context, d time.Duration) error {
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			return nil
+		}
+	}
 }
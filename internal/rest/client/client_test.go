@@ -2,15 +2,19 @@ package rest
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/rest/limiter"
 	"github.com/greenac/chaching/internal/rest/mocks"
 	"github.com/greenac/chaching/internal/rest/models"
+	logMocks "github.com/greenac/chaching/internal/service/logger/mocks"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -109,6 +113,19 @@ func TestClientImpl_makeRequest(t *testing.T) {
 			So(err, ShouldResemble, &genErr.GenError{Messages: []string{"ClientImpl:makeRequest:failed to make request with error: bad bad thing"}})
 		})
 
+		Convey("ClientImpl_makeRequest fails when the rate limiter refuses the request", func() {
+			c := Client{
+				HttpClient: &mocks.HttpClientMock{DoResponse: http.Response{StatusCode: http.StatusOK}},
+				Limiter:    limiter.NewLimiter(limiter.Config{DailyBudget: 1}),
+			}
+			req, _ := http.NewRequest("GET", "https://someurl.com", nil)
+			_, err := c.makeRequest(req, c.makeHeaders(nil))
+			So(err, ShouldBeNil)
+
+			_, err = c.makeRequest(req, c.makeHeaders(nil))
+			So(err, ShouldHaveSameTypeAs, &limiter.BudgetExhaustedError{})
+		})
+
 		Convey("ClientImpl_makeRequest fails with error reading body", func() {
 			e := errors.New("illiterate")
 			obj := mockRespObj{FirstName: "jimmy", LastName: "fallon", Age: "47"}
@@ -139,9 +156,9 @@ func TestClientImpl_Get(t *testing.T) {
 					DoResponse: http.Response{StatusCode: http.StatusOK, Status: "a-ok", Body: ioutil.NopCloser(bytes.NewReader(body))},
 				},
 				BodyReader: ioutil.ReadAll,
-				GetRequest: http.NewRequest,
+				GetRequest: http.NewRequestWithContext,
 			}
-			r, err := c.Get("https://yippie.com", nil, models.UrlParams{models.UrlParam{LVal: "beach", Compare: models.UrlParamTypeEqual, RVal: "ball"}})
+			r, err := c.Get(context.Background(), "https://yippie.com", nil, models.UrlParams{models.UrlParam{LVal: "beach", Compare: models.UrlParamTypeEqual, RVal: "ball"}})
 			So(err, ShouldBeNil)
 			So(r, ShouldResemble, resp)
 		})
@@ -156,11 +173,11 @@ func TestClientImpl_Get(t *testing.T) {
 					DoResponse: http.Response{StatusCode: http.StatusOK, Status: "a-ok", Body: ioutil.NopCloser(bytes.NewReader(body))},
 				},
 				BodyReader: ioutil.ReadAll,
-				GetRequest: func(method, url string, body io.Reader) (*http.Request, error) {
+				GetRequest: func(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
 					return nil, e
 				},
 			}
-			_, err := c.Get(url, nil, models.UrlParams{models.UrlParam{LVal: "beach", Compare: models.UrlParamTypeEqual, RVal: "ball"}})
+			_, err := c.Get(context.Background(), url, nil, models.UrlParams{models.UrlParam{LVal: "beach", Compare: models.UrlParamTypeEqual, RVal: "ball"}})
 			So(err, ShouldResemble, &genErr.GenError{Messages: []string{"ClientImpl:Get:failed to get new request with url: " + url + "with error: " + e.Error()}})
 		})
 	})
@@ -177,9 +194,9 @@ func TestClientImpl_PostBody(t *testing.T) {
 					DoResponse: http.Response{StatusCode: http.StatusOK, Status: "a-ok", Body: ioutil.NopCloser(bytes.NewReader(body))},
 				},
 				BodyReader: ioutil.ReadAll,
-				GetRequest: http.NewRequest,
+				GetRequest: http.NewRequestWithContext,
 			}
-			r, err := c.PostBody("https://yippie.com", nil, body)
+			r, err := c.PostBody(context.Background(), "https://yippie.com", nil, body)
 			So(err, ShouldBeNil)
 			So(r, ShouldResemble, resp)
 		})
@@ -194,11 +211,11 @@ func TestClientImpl_PostBody(t *testing.T) {
 					DoResponse: http.Response{StatusCode: http.StatusOK, Status: "a-ok", Body: ioutil.NopCloser(bytes.NewReader(body))},
 				},
 				BodyReader: ioutil.ReadAll,
-				GetRequest: func(method, url string, body io.Reader) (*http.Request, error) {
+				GetRequest: func(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
 					return nil, e
 				},
 			}
-			_, err := c.PostBody(url, nil, body)
+			_, err := c.PostBody(context.Background(), url, nil, body)
 			So(err, ShouldResemble, &genErr.GenError{Messages: []string{"ClientImpl:PostBody:failed to get new request with url: " + url + "with error: " + e.Error()}})
 		})
 	})
@@ -215,9 +232,9 @@ func TestClientImpl_PostUrl(t *testing.T) {
 					DoResponse: http.Response{StatusCode: http.StatusOK, Status: "a-ok", Body: ioutil.NopCloser(bytes.NewReader(body))},
 				},
 				BodyReader: ioutil.ReadAll,
-				GetRequest: http.NewRequest,
+				GetRequest: http.NewRequestWithContext,
 			}
-			r, err := c.PostUrl("https://yippie.com", nil, models.UrlParams{models.UrlParam{LVal: "beach", Compare: models.UrlParamTypeEqual, RVal: "ball"}})
+			r, err := c.PostUrl(context.Background(), "https://yippie.com", nil, models.UrlParams{models.UrlParam{LVal: "beach", Compare: models.UrlParamTypeEqual, RVal: "ball"}})
 			So(err, ShouldBeNil)
 			So(r, ShouldResemble, resp)
 		})
@@ -232,12 +249,152 @@ func TestClientImpl_PostUrl(t *testing.T) {
 					DoResponse: http.Response{StatusCode: http.StatusOK, Status: "a-ok", Body: ioutil.NopCloser(bytes.NewReader(body))},
 				},
 				BodyReader: ioutil.ReadAll,
-				GetRequest: func(method, url string, body io.Reader) (*http.Request, error) {
+				GetRequest: func(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
 					return nil, e
 				},
 			}
-			_, err := c.PostUrl(url, nil, models.UrlParams{models.UrlParam{LVal: "beach", Compare: models.UrlParamTypeEqual, RVal: "ball"}})
+			_, err := c.PostUrl(context.Background(), url, nil, models.UrlParams{models.UrlParam{LVal: "beach", Compare: models.UrlParamTypeEqual, RVal: "ball"}})
 			So(err, ShouldResemble, &genErr.GenError{Messages: []string{"ClientImpl:PostUrl:failed to get new request with url: " + url + "with error: " + e.Error()}})
 		})
 	})
 }
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	Convey("RetryPolicy_Backoff", t, func() {
+		rp := models.RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+		So(rp.Backoff(1), ShouldEqual, time.Second)
+		So(rp.Backoff(2), ShouldEqual, 2*time.Second)
+		So(rp.Backoff(3), ShouldEqual, 4*time.Second)
+		So(rp.Backoff(4), ShouldEqual, 5*time.Second)
+	})
+}
+
+func TestResponse_RetryAfter(t *testing.T) {
+	Convey("Response_RetryAfter", t, func() {
+		now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		Convey("Response_RetryAfter reads seconds", func() {
+			d, ok := models.Response{Header: http.Header{"Retry-After": []string{"7"}}}.RetryAfter(now)
+			So(ok, ShouldBeTrue)
+			So(d, ShouldEqual, 7*time.Second)
+		})
+
+		Convey("Response_RetryAfter reads an http date", func() {
+			d, ok := models.Response{Header: http.Header{"Retry-After": []string{now.Add(time.Minute).Format(http.TimeFormat)}}}.RetryAfter(now)
+			So(ok, ShouldBeTrue)
+			So(d, ShouldEqual, time.Minute)
+		})
+
+		Convey("Response_RetryAfter is not set", func() {
+			_, ok := models.Response{}.RetryAfter(now)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+func newRetryClient(hc *mocks.HttpClientMock, attempts int, sleeps *[]time.Duration, l *logMocks.LoggerMock) Client {
+	return Client{
+		HttpClient:  hc,
+		BodyReader:  ioutil.ReadAll,
+		GetRequest:  http.NewRequestWithContext,
+		RetryPolicy: &models.RetryPolicy{MaxAttempts: attempts, BaseDelay: time.Second, MaxDelay: time.Minute},
+		Logger:      l,
+		jitter:      func(d time.Duration) time.Duration { return d },
+		sleep: func(ctx context.Context, d time.Duration) error {
+			*sleeps = append(*sleeps, d)
+			return nil
+		},
+	}
+}
+
+func scriptedResponse(code int, header http.Header, body string) mocks.HttpClientMockResponse {
+	return mocks.HttpClientMockResponse{Response: &http.Response{StatusCode: code, Status: http.StatusText(code), Header: header, Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}}
+}
+
+func TestClientImpl_Retry(t *testing.T) {
+	Convey("ClientImpl_Retry", t, func() {
+		var sleeps []time.Duration
+		l := &logMocks.LoggerMock{}
+
+		Convey("ClientImpl_Retry retries 5xx and 429 honouring Retry-After", func() {
+			hc := &mocks.HttpClientMock{Responses: []mocks.HttpClientMockResponse{
+				scriptedResponse(http.StatusServiceUnavailable, nil, ""),
+				scriptedResponse(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"7"}}, ""),
+				scriptedResponse(http.StatusOK, nil, "yay"),
+			}}
+			c := newRetryClient(hc, 4, &sleeps, l)
+			r, err := c.Get(context.Background(), "https://yippie.com", nil, models.UrlParams{})
+			So(err, ShouldBeNil)
+			So(r.StatusCode, ShouldEqual, http.StatusOK)
+			So(r.Body, ShouldResemble, []byte("yay"))
+			So(sleeps, ShouldResemble, []time.Duration{time.Second, 7 * time.Second})
+			So(len(hc.Requests), ShouldEqual, 3)
+			So(len(l.Messages), ShouldEqual, 2)
+		})
+
+		Convey("ClientImpl_Retry caps Retry-After at the max delay", func() {
+			hc := &mocks.HttpClientMock{Responses: []mocks.HttpClientMockResponse{
+				scriptedResponse(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"86400"}}, ""),
+				scriptedResponse(http.StatusOK, nil, ""),
+			}}
+			c := newRetryClient(hc, 2, &sleeps, l)
+			_, err := c.Get(context.Background(), "https://yippie.com", nil, models.UrlParams{})
+			So(err, ShouldBeNil)
+			So(sleeps, ShouldResemble, []time.Duration{time.Minute})
+		})
+
+		Convey("ClientImpl_Retry retries connection errors until attempts are exhausted", func() {
+			e := errors.New("connection reset")
+			hc := &mocks.HttpClientMock{DoError: e}
+			c := newRetryClient(hc, 3, &sleeps, l)
+			_, err := c.Get(context.Background(), "https://yippie.com", nil, models.UrlParams{})
+			So(err, ShouldResemble, &genErr.GenError{Messages: []string{"ClientImpl:makeRequest:failed to make request with error: connection reset"}})
+			So(sleeps, ShouldResemble, []time.Duration{time.Second, 2 * time.Second})
+			So(len(hc.Requests), ShouldEqual, 3)
+		})
+
+		Convey("ClientImpl_Retry returns the last response when retries are exhausted", func() {
+			hc := &mocks.HttpClientMock{Responses: []mocks.HttpClientMockResponse{
+				scriptedResponse(http.StatusBadGateway, nil, ""),
+				scriptedResponse(http.StatusBadGateway, nil, ""),
+			}}
+			c := newRetryClient(hc, 2, &sleeps, l)
+			r, err := c.Get(context.Background(), "https://yippie.com", nil, models.UrlParams{})
+			So(err, ShouldBeNil)
+			So(r.StatusCode, ShouldEqual, http.StatusBadGateway)
+			So(len(hc.Requests), ShouldEqual, 2)
+		})
+
+		Convey("ClientImpl_Retry does not retry client errors", func() {
+			hc := &mocks.HttpClientMock{Responses: []mocks.HttpClientMockResponse{scriptedResponse(http.StatusBadRequest, nil, "")}}
+			c := newRetryClient(hc, 4, &sleeps, l)
+			r, err := c.Get(context.Background(), "https://yippie.com", nil, models.UrlParams{})
+			So(err, ShouldBeNil)
+			So(r.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(sleeps, ShouldBeEmpty)
+		})
+
+		Convey("ClientImpl_Retry stops retrying when the context is done", func() {
+			hc := &mocks.HttpClientMock{DoResponse: http.Response{StatusCode: http.StatusServiceUnavailable}}
+			c := newRetryClient(hc, 4, &sleeps, l)
+			c.sleep = nil
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := c.Get(ctx, "https://yippie.com", nil, models.UrlParams{})
+			So(err, ShouldResemble, &genErr.GenError{Messages: []string{"ClientImpl:makeRequest:stopped retrying with error: context canceled"}})
+			So(len(hc.Requests), ShouldEqual, 1)
+		})
+
+		Convey("ClientImpl_Retry resends the body of a post", func() {
+			hc := &mocks.HttpClientMock{Responses: []mocks.HttpClientMockResponse{
+				scriptedResponse(http.StatusInternalServerError, nil, ""),
+				scriptedResponse(http.StatusOK, nil, ""),
+			}}
+			c := newRetryClient(hc, 2, &sleeps, l)
+			_, err := c.PostBody(context.Background(), "https://yippie.com", nil, []byte("payload"))
+			So(err, ShouldBeNil)
+			b, _ := ioutil.ReadAll(hc.Requests[1].Body)
+			So(b, ShouldResemble, []byte("payload"))
+		})
+	})
+}
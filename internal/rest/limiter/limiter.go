@@ -0,0 +1,210 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	genErr "github.com/greenac/chaching/internal/error"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+type BudgetBehavior string
+
+const (
+	// BudgetBehaviorStop returns a BudgetExhaustedError once the daily budget is used up
+	BudgetBehaviorStop BudgetBehavior = "stop"
+	// BudgetBehaviorWait blocks until the budget resets at the start of the next day
+	BudgetBehaviorWait BudgetBehavior = "wait"
+)
+
+// PerMinute converts a per minute quota into the per second rate the limiter uses
+func PerMinute(requests int) float64 {
+	return float64(requests) / 60
+}
+
+type ILimiter interface {
+	Wait(ctx context.Context) error
+}
+
+var _ genErr.IGenError = (*BudgetExhaustedError)(nil)
+
+type BudgetExhaustedError struct {
+	Budget   int
+	ResetsAt time.Time
+	Messages []string
+}
+
+func (e BudgetExhaustedError) Error() string {
+	msg := fmt.Sprintf("daily request budget of %d exhausted, resets at: %s", e.Budget, e.ResetsAt.Format(time.RFC3339))
+	if len(e.Messages) == 0 {
+		return msg
+	}
+
+	return msg + "->" + strings.Join(e.Messages, "->")
+}
+
+func (e BudgetExhaustedError) AddMsg(msg string) genErr.IGenError {
+	e.Messages = append(e.Messages, msg)
+	return &e
+}
+
+type Config struct {
+	Rate           float64 // requests per second, zero disables the token bucket
+	Burst          int     // number of requests that can be made at once
+	DailyBudget    int     // requests allowed per day, zero disables the budget
+	BudgetBehavior BudgetBehavior
+	Location       *time.Location // location used to decide when a day starts, defaults to UTC
+}
+
+func NewLimiter(config Config) *Limiter {
+	loc := config.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	burst := config.Burst
+	if burst < 1 {
+		burst = 1
+	}
+
+	behavior := config.BudgetBehavior
+	if behavior == "" {
+		behavior = BudgetBehaviorStop
+	}
+
+	return &Limiter{
+		rate:           config.Rate,
+		burst:          float64(burst),
+		tokens:         float64(burst),
+		dailyBudget:    config.DailyBudget,
+		budgetBehavior: behavior,
+		location:       loc,
+		now:            time.Now,
+		sleep:          sleep,
+	}
+}
+
+var _ ILimiter = (*Limiter)(nil)
+
+// Limiter is a token bucket that also tracks a daily request budget. It is safe to share across goroutines
+type Limiter struct {
+	mu             sync.Mutex
+	rate           float64
+	burst          float64
+	tokens         float64
+	last           time.Time
+	dailyBudget    int
+	used           int
+	budgetDay      time.Time
+	budgetBehavior BudgetBehavior
+	location       *time.Location
+	now            func() time.Time
+	sleep          func(ctx context.Context, d time.Duration) error
+}
+
+// Wait blocks until a request may be made. It returns a BudgetExhaustedError when the daily budget is used
+// up and the limiter is configured to stop, or the context's error if it is done while waiting
+func (l *Limiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n tokens may be taken at once, such as the write capacity units of a batch. n larger than the
+// burst waits for a full bucket and leaves it in debt, so later callers wait until the rate has paid it back
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	for {
+		d, err := l.reserve(n)
+		if err != nil {
+			return err
+		}
+
+		if d == 0 {
+			return nil
+		}
+
+		err = l.sleep(ctx, d)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Used returns the number of requests made against today's budget
+func (l *Limiter) Used() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.resetBudget(l.now())
+
+	return l.used
+}
+
+// Remaining returns the number of requests left in today's budget, or -1 when there is no budget
+func (l *Limiter) Remaining() int {
+	if l.dailyBudget <= 0 {
+		return -1
+	}
+
+	return l.dailyBudget - l.Used()
+}
+
+// reserve takes n tokens when they are available. Otherwise, it returns how long to wait before trying again
+func (l *Limiter) reserve(n int) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.resetBudget(now)
+
+	if l.dailyBudget > 0 && l.used >= l.dailyBudget {
+		resetsAt := l.budgetDay.AddDate(0, 0, 1)
+		if l.budgetBehavior == BudgetBehaviorStop {
+			return 0, &BudgetExhaustedError{Budget: l.dailyBudget, ResetsAt: resetsAt}
+		}
+
+		return resetsAt.Sub(now), nil
+	}
+
+	if l.rate > 0 {
+		if !l.last.IsZero() {
+			l.tokens += now.Sub(l.last).Seconds() * l.rate
+			if l.tokens > l.burst {
+				l.tokens = l.burst
+			}
+		}
+		l.last = now
+
+		need := math.Min(float64(n), l.burst)
+		if l.tokens < need {
+			return time.Duration((need - l.tokens) / l.rate * float64(time.Second)), nil
+		}
+
+		l.tokens -= float64(n)
+	}
+
+	l.used += n
+
+	return 0, nil
+}
+
+func (l *Limiter) resetBudget(now time.Time) {
+	local := now.In(l.location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, l.location)
+	if !day.Equal(l.budgetDay) {
+		l.budgetDay = day
+		l.used = 0
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
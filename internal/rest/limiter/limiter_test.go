@@ -0,0 +1,107 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+func (fc *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.sleeps = append(fc.sleeps, d)
+	fc.now = fc.now.Add(d)
+	return nil
+}
+
+func newTestLimiter(config Config, clock *fakeClock) *Limiter {
+	l := NewLimiter(config)
+	l.now = clock.Now
+	l.sleep = clock.Sleep
+	return l
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	Convey("TestLimiter_Wait", t, func() {
+		clock := &fakeClock{now: time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)}
+
+		Convey("TestLimiter_Wait should allow a burst then wait for tokens", func() {
+			l := newTestLimiter(Config{Rate: PerMinute(60), Burst: 2}, clock)
+			for i := 0; i < 3; i += 1 {
+				So(l.Wait(context.Background()), ShouldBeNil)
+			}
+
+			So(clock.sleeps, ShouldResemble, []time.Duration{time.Second})
+			So(l.Used(), ShouldEqual, 3)
+		})
+
+		Convey("TestLimiter_Wait should return a budget error when the budget is exhausted", func() {
+			l := newTestLimiter(Config{DailyBudget: 2, BudgetBehavior: BudgetBehaviorStop}, clock)
+			So(l.Wait(context.Background()), ShouldBeNil)
+			So(l.Wait(context.Background()), ShouldBeNil)
+			So(l.Remaining(), ShouldEqual, 0)
+
+			err := l.Wait(context.Background())
+			So(err, ShouldResemble, &BudgetExhaustedError{Budget: 2, ResetsAt: time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC)})
+		})
+
+		Convey("TestLimiter_Wait should wait for the budget to reset", func() {
+			l := newTestLimiter(Config{DailyBudget: 1, BudgetBehavior: BudgetBehaviorWait}, clock)
+			So(l.Wait(context.Background()), ShouldBeNil)
+			So(l.Wait(context.Background()), ShouldBeNil)
+
+			So(clock.sleeps, ShouldResemble, []time.Duration{12 * time.Hour})
+			So(l.Used(), ShouldEqual, 1)
+		})
+
+		Convey("TestLimiter_Wait should return the context error while waiting", func() {
+			l := NewLimiter(Config{Rate: PerMinute(1), Burst: 1})
+			So(l.Wait(context.Background()), ShouldBeNil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			So(l.Wait(ctx), ShouldEqual, context.Canceled)
+		})
+	})
+}
+
+func TestLimiter_WaitN(t *testing.T) {
+	Convey("TestLimiter_WaitN", t, func() {
+		clock := &fakeClock{now: time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)}
+
+		Convey("TestLimiter_WaitN should take n tokens at once and let requests over the burst run into debt", func() {
+			l := newTestLimiter(Config{Rate: 10, Burst: 10}, clock)
+			So(l.WaitN(context.Background(), 4), ShouldBeNil)
+			So(l.WaitN(context.Background(), 10), ShouldBeNil)
+			So(l.WaitN(context.Background(), 25), ShouldBeNil)
+			So(l.WaitN(context.Background(), 1), ShouldBeNil)
+
+			So(clock.sleeps, ShouldResemble, []time.Duration{400 * time.Millisecond, time.Second, 1600 * time.Millisecond})
+			So(l.Used(), ShouldEqual, 40)
+		})
+	})
+}
+
+func TestBudgetExhaustedError_AddMsg(t *testing.T) {
+	Convey("TestBudgetExhaustedError_AddMsg", t, func() {
+		e := BudgetExhaustedError{Budget: 5, ResetsAt: time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC)}
+		ge := e.AddMsg("one").AddMsg("two")
+		So(ge, ShouldHaveSameTypeAs, &BudgetExhaustedError{})
+		So(ge.Error(), ShouldEqual, "daily request budget of 5 exhausted, resets at: 2023-03-02T00:00:00Z->one->two")
+	})
+}
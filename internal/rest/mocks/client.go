@@ -1,26 +1,45 @@
 package mocks
 
 import (
+	"context"
 	genErr "github.com/greenac/chaching/internal/error"
 	"github.com/greenac/chaching/internal/rest/models"
 	"net/http"
+	"sync"
 )
 
 var _ models.IHttpClient = (*HttpClientMock)(nil)
 
+type HttpClientMockResponse struct {
+	Response *http.Response
+	Error    error
+}
+
 type HttpClientMock struct {
 	DoResponse http.Response
 	DoError    error
+	Responses  []HttpClientMockResponse // scripted responses returned in order before falling back to DoResponse
+	Requests   []*http.Request
 }
 
 func (c *HttpClientMock) Do(req *http.Request) (*http.Response, error) {
+	c.Requests = append(c.Requests, req)
+	if len(c.Responses) > 0 {
+		r := c.Responses[0]
+		c.Responses = c.Responses[1:]
+		return r.Response, r.Error
+	}
+
 	return &c.DoResponse, c.DoError
 }
 
 var _ models.IClient = (*ClientMock)(nil)
 
 type ClientMock struct {
+	mu               sync.Mutex
 	GetResponse      models.Response
+	GetResponses     []models.Response
+	GetUrls          []string
 	GetError         *genErr.GenError
 	PostBodyResponse models.Response
 	PostBodyError    *genErr.GenError
@@ -28,14 +47,33 @@ type ClientMock struct {
 	PostUrlError     *genErr.GenError
 }
 
-func (cm *ClientMock) Get(url string, headers *models.Headers, params models.UrlParams) (models.Response, genErr.IGenError) {
-	return cm.GetResponse, cm.GetError
+func (cm *ClientMock) Get(ctx context.Context, url string, headers *models.Headers, params models.UrlParams) (models.Response, genErr.IGenError) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.GetUrls = append(cm.GetUrls, url)
+	if len(cm.GetResponses) > 0 {
+		resp := cm.GetResponses[0]
+		cm.GetResponses = cm.GetResponses[1:]
+		return resp, nil
+	}
+
+	return cm.GetResponse, toGenError(cm.GetError)
+}
+
+func (cm *ClientMock) PostBody(ctx context.Context, url string, headers *models.Headers, body []byte) (models.Response, genErr.IGenError) {
+	return cm.PostBodyResponse, toGenError(cm.PostBodyError)
 }
 
-func (cm *ClientMock) PostBody(url string, headers *models.Headers, body []byte) (models.Response, genErr.IGenError) {
-	return cm.PostBodyResponse, cm.PostBodyError
+func (cm *ClientMock) PostUrl(ctx context.Context, url string, headers *models.Headers, params models.UrlParams) (models.Response, genErr.IGenError) {
+	return cm.PostUrlResponse, toGenError(cm.PostUrlError)
 }
 
-func (cm *ClientMock) PostUrl(url string, headers *models.Headers, params models.UrlParams) (models.Response, genErr.IGenError) {
-	return cm.PostUrlResponse, cm.PostUrlError
+// toGenError keeps a nil *GenError from being returned as a non-nil IGenError
+func toGenError(ge *genErr.GenError) genErr.IGenError {
+	if ge == nil {
+		return nil
+	}
+
+	return ge
 }
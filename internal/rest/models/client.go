@@ -1,9 +1,12 @@
 package models
 
 import (
+	"context"
 	genErr "github.com/greenac/chaching/internal/error"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type HeaderValue []string
@@ -52,6 +55,61 @@ type Response struct {
 	StatusCode int
 	Status     string
 	Body       []byte
+	Header     http.Header
+}
+
+// RetryAfter returns how long the server asked us to wait before retrying, read from the `Retry-After` header
+func (r Response) RetryAfter(now time.Time) (time.Duration, bool) {
+	v := r.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if t.Before(now) {
+			return 0, true
+		}
+
+		return t.Sub(now), true
+	}
+
+	return 0, false
+}
+
+// RetryPolicy configures how the client retries connection errors, 429s and 5xx responses
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first, values below 2 disable retries
+	BaseDelay   time.Duration // delay before the first retry, doubled for every attempt after it
+	MaxDelay    time.Duration // upper bound for the backoff and for the wait a `Retry-After` header asks for
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 4, BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second}
+}
+
+func (rp RetryPolicy) ShouldRetryStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// Backoff returns the exponential delay before the given retry attempt, starting at 1, without jitter
+func (rp RetryPolicy) Backoff(attempt int) time.Duration {
+	d := rp.BaseDelay
+	for i := 1; i < attempt; i += 1 {
+		d *= 2
+		if rp.MaxDelay > 0 && d >= rp.MaxDelay {
+			return rp.MaxDelay
+		}
+	}
+
+	if rp.MaxDelay > 0 && d > rp.MaxDelay {
+		return rp.MaxDelay
+	}
+
+	return d
 }
 
 type IHttpClient interface {
@@ -59,7 +117,7 @@ type IHttpClient interface {
 }
 
 type IClient interface {
-	Get(url string, headers *Headers, params UrlParams) (Response, genErr.IGenError)
-	PostBody(url string, headers *Headers, body []byte) (Response, genErr.IGenError)
-	PostUrl(url string, headers *Headers, params UrlParams) (Response, genErr.IGenError)
+	Get(ctx context.Context, url string, headers *Headers, params UrlParams) (Response, genErr.IGenError)
+	PostBody(ctx context.Context, url string, headers *Headers, body []byte) (Response, genErr.IGenError)
+	PostUrl(ctx context.Context, url string, headers *Headers, params UrlParams) (Response, genErr.IGenError)
 }
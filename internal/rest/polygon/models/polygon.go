@@ -2,12 +2,14 @@ package model
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
 type PolygonAggregateTimespan string
 
 const (
+	PolygonAggregateTimespanSecond  PolygonAggregateTimespan = "second"
 	PolygonAggregateTimespanMinute  PolygonAggregateTimespan = "minute"
 	PolygonAggregateTimespanHour    PolygonAggregateTimespan = "hour"
 	PolygonAggregateTimespanDay     PolygonAggregateTimespan = "day"
@@ -17,6 +19,38 @@ const (
 	PolygonAggregateTimespanYear    PolygonAggregateTimespan = "year"
 )
 
+func AllPolygonAggregateTimespans() []PolygonAggregateTimespan {
+	return []PolygonAggregateTimespan{
+		PolygonAggregateTimespanSecond,
+		PolygonAggregateTimespanMinute,
+		PolygonAggregateTimespanHour,
+		PolygonAggregateTimespanDay,
+		PolygonAggregateTimespanWeek,
+		PolygonAggregateTimespanMonth,
+		PolygonAggregateTimespanQuarter,
+		PolygonAggregateTimespanYear,
+	}
+}
+
+func (t PolygonAggregateTimespan) IsValid() bool {
+	for _, ts := range AllPolygonAggregateTimespans() {
+		if t == ts {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ParsePolygonAggregateTimespan(s string) (PolygonAggregateTimespan, error) {
+	t := PolygonAggregateTimespan(strings.ToLower(strings.TrimSpace(s)))
+	if !t.IsValid() {
+		return "", fmt.Errorf("invalid timespan: %q, expected one of: %v", s, AllPolygonAggregateTimespans())
+	}
+
+	return t, nil
+}
+
 type PolygonAggregateSortDirection string
 
 const (
@@ -32,12 +66,13 @@ type PolygonAggregateRequestParams struct {
 	To            time.Time
 	SortDirection PolygonAggregateSortDirection
 	Limit         int
+	Adjusted      bool // request bars adjusted for splits
 }
 
 func (pr PolygonAggregateRequestParams) Url() string {
 	return fmt.Sprintf(
-		"%s/range/%d/%s/%d/%d?adjusted=false&sort=%s&limit=%d",
-		pr.CompanyName, pr.Multiplier, pr.Timespan, pr.From.UnixMilli(), pr.To.UnixMilli(), pr.SortDirection, pr.Limit,
+		"%s/range/%d/%s/%d/%d?adjusted=%t&sort=%s&limit=%d",
+		pr.CompanyName, pr.Multiplier, pr.Timespan, pr.From.UnixMilli(), pr.To.UnixMilli(), pr.Adjusted, pr.SortDirection, pr.Limit,
 	)
 }
 
@@ -60,4 +95,35 @@ type PolygonAggregateResponse struct {
 	Status       string             `json:"status"`
 	Ticker       string             `json:"ticker"`
 	DataPoints   []PolygonDataPoint `json:"results"`
+	NextUrl      string             `json:"next_url"` // cursor to the next page of results, empty on the last page
+}
+
+func (pr PolygonAggregateResponse) HasNextPage() bool {
+	return pr.NextUrl != ""
+}
+
+// PolygonGroupedDailyRequestParams requests the day bar of every ticker in the US stock market for one date.
+// Its Url is relative to polygon's `/v2/aggs` base
+type PolygonGroupedDailyRequestParams struct {
+	Date       time.Time // formatted as a day, so it should be midnight in the exchange's time zone
+	Adjusted   bool
+	IncludeOtc bool
+}
+
+func (pr PolygonGroupedDailyRequestParams) Url() string {
+	return fmt.Sprintf("grouped/locale/us/market/stocks/%s?adjusted=%t&include_otc=%t", pr.Date.Format("2006-01-02"), pr.Adjusted, pr.IncludeOtc)
+}
+
+type PolygonGroupedDataPoint struct {
+	Ticker string `json:"T"`
+	PolygonDataPoint
+}
+
+type PolygonGroupedDailyResponse struct {
+	Adjusted     bool                      `json:"adjusted"`
+	QueryCount   int                       `json:"queryCount"`
+	RequestID    string                    `json:"request_id"`
+	ResultsCount int                       `json:"resultsCount"`
+	Status       string                    `json:"status"`
+	DataPoints   []PolygonGroupedDataPoint `json:"results"`
 }
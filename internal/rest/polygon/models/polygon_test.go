@@ -15,7 +15,7 @@ func TestPolygonAggregateRequestParams_Url(t *testing.T) {
 		to := from.Add(30 * time.Second)
 		url := fmt.Sprintf("rabbits/range/1/minute/%d/%d?adjusted=false&sort=asc&limit=120", from.UnixMilli(), to.UnixMilli())
 		rp := PolygonAggregateRequestParams{
-			Name:          "rabbits",
+			CompanyName:   "rabbits",
 			Multiplier:    1,
 			Timespan:      PolygonAggregateTimespanMinute,
 			From:          from,
@@ -26,5 +26,20 @@ func TestPolygonAggregateRequestParams_Url(t *testing.T) {
 		uri, _ := url2.JoinPath("https://something", rp.Url())
 		fmt.Println("url is:", rp.Url(), "full url:", uri)
 		So(rp.Url(), ShouldEqual, url)
+
+		rp.Adjusted = true
+		So(rp.Url(), ShouldEqual, fmt.Sprintf("rabbits/range/1/minute/%d/%d?adjusted=true&sort=asc&limit=120", from.UnixMilli(), to.UnixMilli()))
+	})
+}
+
+func TestParsePolygonAggregateTimespan(t *testing.T) {
+	Convey("TestParsePolygonAggregateTimespan", t, func() {
+		ts, err := ParsePolygonAggregateTimespan(" Minute")
+		So(err, ShouldBeNil)
+		So(ts, ShouldEqual, PolygonAggregateTimespanMinute)
+
+		_, err = ParsePolygonAggregateTimespan("fortnight")
+		So(err.Error(), ShouldEqual, `invalid timespan: "fortnight", expected one of: [second minute hour day week month quarter year]`)
+		So(PolygonAggregateTimespan("fortnight").IsValid(), ShouldBeFalse)
 	})
 }
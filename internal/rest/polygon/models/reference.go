@@ -0,0 +1,126 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+)
+
+type PolygonReferenceType string
+
+const (
+	PolygonReferenceTypeSplits    PolygonReferenceType = "splits"
+	PolygonReferenceTypeDividends PolygonReferenceType = "dividends"
+)
+
+// PolygonReferenceRequestParams builds requests against polygon's `/v3/reference` endpoints
+type PolygonReferenceRequestParams struct {
+	Type   PolygonReferenceType
+	Ticker string
+	Limit  int
+}
+
+func (pr PolygonReferenceRequestParams) Url() string {
+	return fmt.Sprintf("%s?ticker=%s&limit=%d", pr.Type, url.QueryEscape(pr.Ticker), pr.Limit)
+}
+
+type PolygonSplit struct {
+	Id            string  `json:"id"`
+	Ticker        string  `json:"ticker"`
+	ExecutionDate string  `json:"execution_date"` // YYYY-MM-DD
+	SplitFrom     float64 `json:"split_from"`
+	SplitTo       float64 `json:"split_to"`
+}
+
+type PolygonDividend struct {
+	Id              string  `json:"id"`
+	Ticker          string  `json:"ticker"`
+	CashAmount      float64 `json:"cash_amount"`
+	Currency        string  `json:"currency"`
+	DividendType    string  `json:"dividend_type"`
+	ExDividendDate  string  `json:"ex_dividend_date"` // YYYY-MM-DD
+	PayDate         string  `json:"pay_date"`
+	RecordDate      string  `json:"record_date"`
+	DeclarationDate string  `json:"declaration_date"`
+	Frequency       int     `json:"frequency"`
+}
+
+type PolygonSplitsResponse struct {
+	Status    string         `json:"status"`
+	RequestID string         `json:"request_id"`
+	Results   []PolygonSplit `json:"results"`
+	NextUrl   string         `json:"next_url"`
+}
+
+type PolygonDividendsResponse struct {
+	Status    string            `json:"status"`
+	RequestID string            `json:"request_id"`
+	Results   []PolygonDividend `json:"results"`
+	NextUrl   string            `json:"next_url"`
+}
+
+// PolygonTickersRequestParams lists tickers from `/v3/reference/tickers`, polygon only returns active tickers
+// when Active is true and only inactive ones when it is false
+type PolygonTickersRequestParams struct {
+	Market   string
+	Exchange string
+	Type     string
+	Active   bool
+	Limit    int
+}
+
+func (pr PolygonTickersRequestParams) Url() string {
+	q := url.Values{}
+	q.Set("market", pr.Market)
+	q.Set("active", fmt.Sprintf("%t", pr.Active))
+	q.Set("order", "asc")
+	q.Set("sort", "ticker")
+	q.Set("limit", fmt.Sprintf("%d", pr.Limit))
+	if pr.Exchange != "" {
+		q.Set("exchange", pr.Exchange)
+	}
+
+	if pr.Type != "" {
+		q.Set("type", pr.Type)
+	}
+
+	return "tickers?" + q.Encode()
+}
+
+// PolygonTickerDetailsRequestParams fetches one ticker's details, which unlike the list include its listing date
+type PolygonTickerDetailsRequestParams struct {
+	Ticker string
+}
+
+func (pr PolygonTickerDetailsRequestParams) Url() string {
+	return "tickers/" + url.PathEscape(pr.Ticker)
+}
+
+type PolygonTicker struct {
+	Ticker          string `json:"ticker"`
+	Name            string `json:"name"`
+	Market          string `json:"market"`
+	Locale          string `json:"locale"`
+	PrimaryExchange string `json:"primary_exchange"`
+	Type            string `json:"type"`
+	Active          bool   `json:"active"`
+	CurrencyName    string `json:"currency_name"`
+	Cik             string `json:"cik"`
+	CompositeFigi   string `json:"composite_figi"`
+	ListDate        string `json:"list_date"` // YYYY-MM-DD, only returned by the details endpoint
+	DelistedUtc     string `json:"delisted_utc"`
+	LastUpdatedUtc  string `json:"last_updated_utc"`
+}
+
+type PolygonTickersResponse struct {
+	Status    string          `json:"status"`
+	RequestID string          `json:"request_id"`
+	Count     int             `json:"count"`
+	Results   []PolygonTicker `json:"results"`
+	NextUrl   string          `json:"next_url"`
+}
+
+type PolygonTickerDetailsResponse struct {
+	Status    string        `json:"status"`
+	RequestID string        `json:"request_id"`
+	Results   PolygonTicker `json:"results"`
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PolygonStreamEvent is the `ev` of a websocket message, and the channel prefix used to subscribe to it
+type PolygonStreamEvent string
+
+const (
+	PolygonStreamEventMinuteAggregate PolygonStreamEvent = "AM"
+	PolygonStreamEventSecondAggregate PolygonStreamEvent = "A"
+	PolygonStreamEventTrade           PolygonStreamEvent = "T"
+	PolygonStreamEventStatus          PolygonStreamEvent = "status"
+)
+
+func AllPolygonStreamChannels() []PolygonStreamEvent {
+	return []PolygonStreamEvent{PolygonStreamEventMinuteAggregate, PolygonStreamEventSecondAggregate, PolygonStreamEventTrade}
+}
+
+func ParsePolygonStreamChannel(s string) (PolygonStreamEvent, error) {
+	e := PolygonStreamEvent(strings.ToUpper(strings.TrimSpace(s)))
+	for _, c := range AllPolygonStreamChannels() {
+		if e == c {
+			return e, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid stream channel: %q, expected one of: %v", s, AllPolygonStreamChannels())
+}
+
+// Subscription is the param that subscribes to the event for ticker, for example `AM.AAPL`
+func (e PolygonStreamEvent) Subscription(ticker string) string {
+	return string(e) + "." + ticker
+}
+
+type PolygonStreamAction string
+
+const (
+	PolygonStreamActionAuth        PolygonStreamAction = "auth"
+	PolygonStreamActionSubscribe   PolygonStreamAction = "subscribe"
+	PolygonStreamActionUnsubscribe PolygonStreamAction = "unsubscribe"
+)
+
+type PolygonStreamRequest struct {
+	Action PolygonStreamAction `json:"action"`
+	Params string              `json:"params"`
+}
+
+type PolygonStreamStatus string
+
+const (
+	PolygonStreamStatusConnected   PolygonStreamStatus = "connected"
+	PolygonStreamStatusAuthSuccess PolygonStreamStatus = "auth_success"
+	PolygonStreamStatusAuthFailed  PolygonStreamStatus = "auth_failed"
+	PolygonStreamStatusSuccess     PolygonStreamStatus = "success"
+	PolygonStreamStatusError       PolygonStreamStatus = "error"
+)
+
+// PolygonStreamMessage is the envelope of every event. Messages arrive as arrays of mixed events, so each
+// event is decoded as an envelope first and then as the type its Event names
+type PolygonStreamMessage struct {
+	Event   PolygonStreamEvent  `json:"ev"`
+	Status  PolygonStreamStatus `json:"status,omitempty"`
+	Message string              `json:"message,omitempty"`
+}
+
+// PolygonStreamAggregate is an `AM` or `A` bar
+type PolygonStreamAggregate struct {
+	Event               PolygonStreamEvent `json:"ev"`
+	Symbol              string             `json:"sym"`
+	Volume              float64            `json:"v"`
+	AccumulatedVolume   float64            `json:"av"`
+	OfficialOpenPrice   float64            `json:"op"`
+	VolumeWeightedPrice float64            `json:"vw"`
+	OpenPrice           float64            `json:"o"`
+	ClosePrice          float64            `json:"c"`
+	HighestPrice        float64            `json:"h"`
+	LowestPrice         float64            `json:"l"`
+	DayVolumeWeighted   float64            `json:"a"`
+	AverageTradeSize    float64            `json:"z"`
+	StartTime           int64              `json:"s"` // bar start unix time stamp (millis)
+	EndTime             int64              `json:"e"` // bar end unix time stamp (millis)
+}
+
+// PolygonStreamTrade is a `T` trade
+type PolygonStreamTrade struct {
+	Event          PolygonStreamEvent `json:"ev"`
+	Symbol         string             `json:"sym"`
+	Id             string             `json:"i"`
+	Exchange       int                `json:"x"`
+	Price          float64            `json:"p"`
+	Size           float64            `json:"s"`
+	Conditions     []int              `json:"c,omitempty"`
+	Timestamp      int64              `json:"t"` // SIP unix time stamp (millis)
+	SequenceNumber int64              `json:"q"`
+	Tape           int                `json:"z"`
+	TrfId          int                `json:"trfi,omitempty"`
+	TrfTimestamp   int64              `json:"trft,omitempty"`
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+type PolygonTickType string
+
+const (
+	PolygonTickTypeTrades PolygonTickType = "trades"
+	PolygonTickTypeQuotes PolygonTickType = "quotes"
+)
+
+// PolygonTickRequestParams builds requests against polygon's `/v3/trades` and `/v3/quotes` endpoints.
+// The range is half open, ticks at From are included and ticks at To are not
+type PolygonTickRequestParams struct {
+	Type   PolygonTickType
+	Ticker string
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+func (pr PolygonTickRequestParams) Url() string {
+	return fmt.Sprintf(
+		"%s/%s?timestamp.gte=%d&timestamp.lt=%d&order=asc&sort=timestamp&limit=%d",
+		pr.Type, url.PathEscape(pr.Ticker), pr.From.UnixNano(), pr.To.UnixNano(), pr.Limit,
+	)
+}
+
+type PolygonTrade struct {
+	Id                   string  `json:"id" dynamodbav:"id"`
+	Conditions           []int   `json:"conditions,omitempty" dynamodbav:"conditions,omitempty"`
+	Correction           int     `json:"correction,omitempty" dynamodbav:"correction,omitempty"`
+	Exchange             int     `json:"exchange" dynamodbav:"exchange"`
+	Price                float64 `json:"price" dynamodbav:"price"`
+	Size                 float64 `json:"size" dynamodbav:"size"`
+	SequenceNumber       int64   `json:"sequence_number" dynamodbav:"sequenceNumber"`
+	Tape                 int     `json:"tape" dynamodbav:"tape"`
+	TrfId                int     `json:"trf_id,omitempty" dynamodbav:"trfId,omitempty"`
+	SipTimestamp         int64   `json:"sip_timestamp" dynamodbav:"sipTimestamp"`                 // time the SIP received the trade (nanos)
+	ParticipantTimestamp int64   `json:"participant_timestamp" dynamodbav:"participantTimestamp"` // time the exchange created the trade (nanos)
+	TrfTimestamp         int64   `json:"trf_timestamp,omitempty" dynamodbav:"trfTimestamp,omitempty"`
+}
+
+type PolygonQuote struct {
+	AskExchange          int     `json:"ask_exchange" dynamodbav:"askExchange"`
+	AskPrice             float64 `json:"ask_price" dynamodbav:"askPrice"`
+	AskSize              float64 `json:"ask_size" dynamodbav:"askSize"`
+	BidExchange          int     `json:"bid_exchange" dynamodbav:"bidExchange"`
+	BidPrice             float64 `json:"bid_price" dynamodbav:"bidPrice"`
+	BidSize              float64 `json:"bid_size" dynamodbav:"bidSize"`
+	Conditions           []int   `json:"conditions,omitempty" dynamodbav:"conditions,omitempty"`
+	Indicators           []int   `json:"indicators,omitempty" dynamodbav:"indicators,omitempty"`
+	SequenceNumber       int64   `json:"sequence_number" dynamodbav:"sequenceNumber"`
+	Tape                 int     `json:"tape" dynamodbav:"tape"`
+	SipTimestamp         int64   `json:"sip_timestamp" dynamodbav:"sipTimestamp"`                 // time the SIP received the quote (nanos)
+	ParticipantTimestamp int64   `json:"participant_timestamp" dynamodbav:"participantTimestamp"` // time the exchange created the quote (nanos)
+	TrfTimestamp         int64   `json:"trf_timestamp,omitempty" dynamodbav:"trfTimestamp,omitempty"`
+}
+
+type PolygonTradesResponse struct {
+	Status    string         `json:"status"`
+	RequestID string         `json:"request_id"`
+	Results   []PolygonTrade `json:"results"`
+	NextUrl   string         `json:"next_url"`
+}
+
+type PolygonQuotesResponse struct {
+	Status    string         `json:"status"`
+	RequestID string         `json:"request_id"`
+	Results   []PolygonQuote `json:"results"`
+	NextUrl   string         `json:"next_url"`
+}
@@ -0,0 +1,119 @@
+package adjustment
+
+import (
+	"github.com/greenac/chaching/internal/database/models"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+type AdjustOptions struct {
+	Dividends bool // also adjust for cash dividends, polygon's adjusted bars only account for splits
+}
+
+// Factor scales every bar that starts before ExStart (millis)
+type Factor struct {
+	ExStart int64
+	Price   float64
+	Volume  float64
+}
+
+type IAdjustmentService interface {
+	Adjust(dps []models.DataPoint, actions []models.CorporateAction, opts AdjustOptions) []models.DataPoint
+	Factors(actions []models.CorporateAction, opts AdjustOptions, prevClose func(exDate time.Time) (float64, bool)) []Factor
+	Apply(dps []models.DataPoint, factors []Factor) []models.DataPoint
+}
+
+var _ IAdjustmentService = (*AdjustmentService)(nil)
+
+// NewAdjustmentService creates a service that reads ex dates as days in loc, the exchange's time zone
+func NewAdjustmentService(loc *time.Location) IAdjustmentService {
+	return &AdjustmentService{location: loc}
+}
+
+type AdjustmentService struct {
+	location *time.Location
+}
+
+// Adjust returns a copy of the unadjusted series adjusted for the actions, taking the close before each
+// dividend's ex date from the series itself
+func (as *AdjustmentService) Adjust(dps []models.DataPoint, actions []models.CorporateAction, opts AdjustOptions) []models.DataPoint {
+	return as.Apply(dps, as.Factors(actions, opts, func(exDate time.Time) (float64, bool) {
+		return LastCloseBefore(dps, exDate)
+	}))
+}
+
+// Factors converts actions into the factors that make a series continuous with today's prices. Splits divide
+// prices and multiply volume by the split ratio. Dividends multiply prices by one minus the dividend over
+// the close before the ex date, and are skipped when prevClose can not find that close
+func (as *AdjustmentService) Factors(actions []models.CorporateAction, opts AdjustOptions, prevClose func(exDate time.Time) (float64, bool)) []Factor {
+	var factors []Factor
+	for _, a := range actions {
+		exDate, err := time.ParseInLocation(dateLayout, a.ExDate, as.location)
+		if err != nil {
+			continue
+		}
+
+		switch a.Type {
+		case models.CorporateActionTypeSplit:
+			ratio := a.SplitRatio()
+			if ratio <= 0 {
+				continue
+			}
+			factors = append(factors, Factor{ExStart: exDate.UnixMilli(), Price: 1 / ratio, Volume: ratio})
+		case models.CorporateActionTypeDividend:
+			if !opts.Dividends {
+				continue
+			}
+			close, ok := prevClose(exDate)
+			if !ok || close <= a.CashAmount {
+				continue
+			}
+			factors = append(factors, Factor{ExStart: exDate.UnixMilli(), Price: 1 - a.CashAmount/close, Volume: 1})
+		}
+	}
+
+	return factors
+}
+
+// Apply returns a copy of the series with the factors applied and every bar marked as adjusted
+func (as *AdjustmentService) Apply(dps []models.DataPoint, factors []Factor) []models.DataPoint {
+	adjusted := make([]models.DataPoint, len(dps))
+	for i, dp := range dps {
+		pf := 1.0
+		vf := 1.0
+		for _, f := range factors {
+			if dp.StartTime < f.ExStart {
+				pf *= f.Price
+				vf *= f.Volume
+			}
+		}
+
+		dp.OpenPrice *= pf
+		dp.ClosePrice *= pf
+		dp.HighestPrice *= pf
+		dp.LowestPrice *= pf
+		dp.VolumeWeightedPrice *= pf
+		dp.Volume *= vf
+		dp.Adjusted = true
+		adjusted[i] = dp
+	}
+
+	return adjusted
+}
+
+// LastCloseBefore returns the close of the latest bar starting before t
+func LastCloseBefore(dps []models.DataPoint, t time.Time) (float64, bool) {
+	var latest int64
+	var close float64
+	found := false
+	for _, dp := range dps {
+		if dp.StartTime < t.UnixMilli() && (!found || dp.StartTime > latest) {
+			latest = dp.StartTime
+			close = dp.ClosePrice
+			found = true
+		}
+	}
+
+	return close, found
+}
@@ -0,0 +1,74 @@
+package adjustment
+
+import (
+	"github.com/greenac/chaching/internal/database/models"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func bar(t time.Time, price float64, volume float64) models.DataPoint {
+	return models.DataPoint{CompanyName: "AAPL", PolygonDataPoint: model.PolygonDataPoint{
+		StartTime:           t.UnixMilli(),
+		OpenPrice:           price,
+		ClosePrice:          price,
+		HighestPrice:        price,
+		LowestPrice:         price,
+		VolumeWeightedPrice: price,
+		Volume:              volume,
+	}}
+}
+
+func TestAdjustmentService_Adjust(t *testing.T) {
+	Convey("TestAdjustmentService_Adjust", t, func() {
+		loc, _ := time.LoadLocation("America/New_York")
+		as := NewAdjustmentService(loc)
+		dps := []models.DataPoint{
+			bar(time.Date(2020, 8, 28, 15, 59, 0, 0, loc), 400, 100),
+			bar(time.Date(2020, 8, 31, 9, 30, 0, 0, loc), 100, 400),
+			bar(time.Date(2020, 9, 1, 9, 30, 0, 0, loc), 100, 400),
+		}
+		split := models.CorporateAction{Id: "s", CompanyName: "AAPL", Type: models.CorporateActionTypeSplit, ExDate: "2020-08-31", SplitFrom: 1, SplitTo: 4}
+		dividend := models.CorporateAction{Id: "d", CompanyName: "AAPL", Type: models.CorporateActionTypeDividend, ExDate: "2020-09-01", CashAmount: 1}
+
+		Convey("TestAdjustmentService_Adjust should scale bars before a split by its ratio", func() {
+			adjusted := as.Adjust(dps, []models.CorporateAction{split}, AdjustOptions{})
+			So(adjusted[0].ClosePrice, ShouldEqual, 100)
+			So(adjusted[0].HighestPrice, ShouldEqual, 100)
+			So(adjusted[0].Volume, ShouldEqual, 400)
+			So(adjusted[1].ClosePrice, ShouldEqual, 100)
+			So(adjusted[2].Volume, ShouldEqual, 400)
+			So(adjusted[0].Adjusted, ShouldBeTrue)
+			So(dps[0].ClosePrice, ShouldEqual, 400)
+		})
+
+		Convey("TestAdjustmentService_Adjust should ignore dividends unless asked", func() {
+			adjusted := as.Adjust(dps, []models.CorporateAction{dividend}, AdjustOptions{})
+			So(adjusted[1].ClosePrice, ShouldEqual, 100)
+		})
+
+		Convey("TestAdjustmentService_Adjust should scale bars before a dividend by the close before its ex date", func() {
+			adjusted := as.Adjust(dps, []models.CorporateAction{split, dividend}, AdjustOptions{Dividends: true})
+			So(adjusted[0].ClosePrice, ShouldAlmostEqual, 99)
+			So(adjusted[0].Volume, ShouldEqual, 400)
+			So(adjusted[1].ClosePrice, ShouldAlmostEqual, 99)
+			So(adjusted[1].Volume, ShouldEqual, 400)
+			So(adjusted[2].ClosePrice, ShouldEqual, 100)
+		})
+
+		Convey("TestAdjustmentService_Adjust should leave bars after every action alone", func() {
+			later := models.CorporateAction{Id: "l", CompanyName: "AAPL", Type: models.CorporateActionTypeSplit, ExDate: "2019-06-03", SplitFrom: 1, SplitTo: 2}
+			adjusted := as.Adjust(dps, []models.CorporateAction{later}, AdjustOptions{})
+			So(adjusted[0].ClosePrice, ShouldEqual, 400)
+			So(adjusted[0].Volume, ShouldEqual, 100)
+		})
+
+		Convey("TestAdjustmentService_Adjust should skip a dividend without a prior close", func() {
+			early := models.CorporateAction{Id: "e", CompanyName: "AAPL", Type: models.CorporateActionTypeDividend, ExDate: "2020-08-28", CashAmount: 1}
+			adjusted := as.Adjust(dps, []models.CorporateAction{early}, AdjustOptions{Dividends: true})
+			So(adjusted[0].ClosePrice, ShouldEqual, 400)
+		})
+	})
+}
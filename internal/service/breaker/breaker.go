@@ -0,0 +1,185 @@
+package breaker
+
+import (
+	"fmt"
+	genErr "github.com/greenac/chaching/internal/error"
+	"strings"
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half-open"
+)
+
+type Transition struct {
+	From   State
+	To     State
+	Reason string
+	At     time.Time
+}
+
+func (t Transition) String() string {
+	return fmt.Sprintf("%s -> %s at: %s because: %s", t.From, t.To, t.At.Format(time.RFC3339), t.Reason)
+}
+
+var _ genErr.IGenError = (*OpenError)(nil)
+
+// OpenError is returned without calling through while the breaker is open
+type OpenError struct {
+	Reason   string
+	RetryAt  time.Time
+	Messages []string
+}
+
+func (e OpenError) Error() string {
+	msg := fmt.Sprintf("circuit breaker is open until: %s because: %s", e.RetryAt.Format(time.RFC3339), e.Reason)
+	if len(e.Messages) == 0 {
+		return msg
+	}
+
+	return msg + "->" + strings.Join(e.Messages, "->")
+}
+
+func (e OpenError) AddMsg(msg string) genErr.IGenError {
+	e.Messages = append(e.Messages, msg)
+	return &e
+}
+
+type Config struct {
+	FailureThreshold int                // consecutive failures that open the breaker
+	SuccessThreshold int                // consecutive successful probes while half-open that close the breaker
+	CoolDown         time.Duration      // how long the breaker stays open before letting a probe through
+	OnStateChange    func(t Transition) // called with the breaker locked, so it must not call back into the breaker
+}
+
+func NewCircuitBreaker(config Config) *CircuitBreaker {
+	if config.FailureThreshold < 1 {
+		config.FailureThreshold = 1
+	}
+
+	if config.SuccessThreshold < 1 {
+		config.SuccessThreshold = 1
+	}
+
+	return &CircuitBreaker{config: config, state: StateClosed, now: time.Now}
+}
+
+// CircuitBreaker is safe to share across goroutines. While half-open, a single probe is let through at a time
+type CircuitBreaker struct {
+	mu          sync.Mutex
+	config      Config
+	state       State
+	failures    int
+	successes   int
+	probing     bool
+	lastReason  string
+	openedAt    time.Time
+	transitions []Transition
+	now         func() time.Time
+}
+
+// Allow returns an OpenError if a call should fail fast. Every allowed call must be followed by
+// RecordSuccess, RecordFailure or Release
+func (cb *CircuitBreaker) Allow() genErr.IGenError {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.now()
+	if cb.state == StateOpen {
+		retryAt := cb.openedAt.Add(cb.config.CoolDown)
+		if now.Before(retryAt) {
+			return &OpenError{Reason: cb.lastReason, RetryAt: retryAt}
+		}
+
+		cb.transition(StateHalfOpen, "cool down elapsed", now)
+	}
+
+	if cb.state == StateHalfOpen {
+		if cb.probing {
+			return &OpenError{Reason: "waiting on half-open probe, last failure: " + cb.lastReason, RetryAt: now.Add(cb.config.CoolDown)}
+		}
+
+		cb.probing = true
+	}
+
+	return nil
+}
+
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures = 0
+	if cb.state != StateHalfOpen {
+		return
+	}
+
+	cb.probing = false
+	cb.successes += 1
+	if cb.successes >= cb.config.SuccessThreshold {
+		cb.transition(StateClosed, fmt.Sprintf("%d successful probes", cb.successes), cb.now())
+	}
+}
+
+func (cb *CircuitBreaker) RecordFailure(reason string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.lastReason = reason
+	now := cb.now()
+	switch cb.state {
+	case StateHalfOpen:
+		cb.probing = false
+		cb.openedAt = now
+		cb.transition(StateOpen, "half-open probe failed: "+reason, now)
+	case StateClosed:
+		cb.failures += 1
+		if cb.failures >= cb.config.FailureThreshold {
+			cb.openedAt = now
+			cb.transition(StateOpen, fmt.Sprintf("%d consecutive failures, last: %s", cb.failures, reason), now)
+		}
+	}
+}
+
+// Release ends an allowed call whose outcome says nothing about the health of what the breaker protects
+func (cb *CircuitBreaker) Release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+}
+
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state
+}
+
+// Transitions returns every state change the breaker has made, oldest first
+func (cb *CircuitBreaker) Transitions() []Transition {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	ts := make([]Transition, len(cb.transitions))
+	copy(ts, cb.transitions)
+
+	return ts
+}
+
+func (cb *CircuitBreaker) transition(to State, reason string, at time.Time) {
+	t := Transition{From: cb.state, To: to, Reason: reason, At: at}
+	cb.state = to
+	cb.failures = 0
+	cb.successes = 0
+	cb.transitions = append(cb.transitions, t)
+
+	if cb.config.OnStateChange != nil {
+		cb.config.OnStateChange(t)
+	}
+}
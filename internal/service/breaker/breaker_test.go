@@ -0,0 +1,82 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	Convey("TestCircuitBreaker", t, func() {
+		now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+		var changes []Transition
+		cb := NewCircuitBreaker(Config{
+			FailureThreshold: 2,
+			SuccessThreshold: 1,
+			CoolDown:         time.Minute,
+			OnStateChange:    func(t Transition) { changes = append(changes, t) },
+		})
+		cb.now = func() time.Time { return now }
+
+		Convey("TestCircuitBreaker should open after consecutive failures", func() {
+			So(cb.Allow(), ShouldBeNil)
+			cb.RecordFailure("boom")
+			So(cb.State(), ShouldEqual, StateClosed)
+
+			So(cb.Allow(), ShouldBeNil)
+			cb.RecordFailure("boom")
+			So(cb.State(), ShouldEqual, StateOpen)
+
+			So(cb.Allow(), ShouldResemble, &OpenError{Reason: "boom", RetryAt: now.Add(time.Minute)})
+			So(changes, ShouldResemble, []Transition{{From: StateClosed, To: StateOpen, Reason: "2 consecutive failures, last: boom", At: now}})
+		})
+
+		Convey("TestCircuitBreaker should reset the failure count on success", func() {
+			So(cb.Allow(), ShouldBeNil)
+			cb.RecordFailure("boom")
+			So(cb.Allow(), ShouldBeNil)
+			cb.RecordSuccess()
+			So(cb.Allow(), ShouldBeNil)
+			cb.RecordFailure("boom")
+			So(cb.State(), ShouldEqual, StateClosed)
+		})
+
+		Convey("TestCircuitBreaker should let a single probe through after the cool down", func() {
+			cb.RecordFailure("boom")
+			cb.RecordFailure("boom")
+			now = now.Add(time.Minute)
+
+			So(cb.Allow(), ShouldBeNil)
+			So(cb.State(), ShouldEqual, StateHalfOpen)
+			So(cb.Allow(), ShouldHaveSameTypeAs, &OpenError{})
+
+			Convey("TestCircuitBreaker should close when the probe succeeds", func() {
+				cb.RecordSuccess()
+				So(cb.State(), ShouldEqual, StateClosed)
+				So(len(cb.Transitions()), ShouldEqual, 3)
+			})
+
+			Convey("TestCircuitBreaker should reopen when the probe fails", func() {
+				cb.RecordFailure("still down")
+				So(cb.State(), ShouldEqual, StateOpen)
+				So(cb.Allow(), ShouldResemble, &OpenError{Reason: "still down", RetryAt: now.Add(time.Minute)})
+			})
+
+			Convey("TestCircuitBreaker should let another probe through when released", func() {
+				cb.Release()
+				So(cb.State(), ShouldEqual, StateHalfOpen)
+				So(cb.Allow(), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestOpenError_AddMsg(t *testing.T) {
+	Convey("TestOpenError_AddMsg", t, func() {
+		e := OpenError{Reason: "boom", RetryAt: time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)}
+		ge := e.AddMsg("failing fast")
+		So(ge, ShouldHaveSameTypeAs, &OpenError{})
+		So(ge.Error(), ShouldEqual, "circuit breaker is open until: 2023-03-01T12:00:00Z because: boom->failing fast")
+	})
+}
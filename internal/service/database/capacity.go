@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strings"
+)
+
+// writeUnitSize is the item size one write capacity unit covers
+const writeUnitSize = 1024
+
+// IWriteLimiter meters the write capacity units batch writes consume. limiter.Limiter satisfies it
+type IWriteLimiter interface {
+	WaitN(ctx context.Context, n int) error
+}
+
+// writeUnits estimates the write capacity units a batch consumes, a unit per started KB of each put item. Deletes
+// are counted as one unit since the size of the item they remove isn't known
+func writeUnits(requests []types.WriteRequest) int {
+	units := 0
+	for _, r := range requests {
+		if r.PutRequest == nil {
+			units += 1
+			continue
+		}
+
+		size := itemSize(r.PutRequest.Item)
+		units += (size + writeUnitSize - 1) / writeUnitSize
+		if size == 0 {
+			units += 1
+		}
+	}
+
+	return units
+}
+
+// itemSize follows dynamo's item size rules: attribute names and values by their length in bytes, numbers by
+// their significant digits and lists, maps and sets by their elements plus a few bytes of overhead
+func itemSize(item map[string]types.AttributeValue) int {
+	size := 0
+	for name, av := range item {
+		size += len(name) + valueSize(av)
+	}
+
+	return size
+}
+
+func valueSize(av types.AttributeValue) int {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value)
+	case *types.AttributeValueMemberN:
+		return numberSize(v.Value)
+	case *types.AttributeValueMemberB:
+		return len(v.Value)
+	case *types.AttributeValueMemberSS:
+		size := 0
+		for _, s := range v.Value {
+			size += len(s)
+		}
+		return size
+	case *types.AttributeValueMemberNS:
+		size := 0
+		for _, n := range v.Value {
+			size += numberSize(n)
+		}
+		return size
+	case *types.AttributeValueMemberBS:
+		size := 0
+		for _, b := range v.Value {
+			size += len(b)
+		}
+		return size
+	case *types.AttributeValueMemberL:
+		size := 3
+		for _, e := range v.Value {
+			size += 1 + valueSize(e)
+		}
+		return size
+	case *types.AttributeValueMemberM:
+		return 3 + len(v.Value) + itemSize(v.Value)
+	}
+
+	// booleans and nulls
+	return 1
+}
+
+func numberSize(n string) int {
+	digits := strings.Trim(strings.NewReplacer("-", "", ".", "").Replace(strings.SplitN(strings.ToLower(n), "e", 2)[0]), "0")
+	if digits == "" {
+		return 1
+	}
+
+	return (len(digits)+1)/2 + 1
+}
@@ -8,13 +8,89 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"reflect"
 	"strings"
+	"sync"
+	"time"
 )
 
 // This is the maximum allowed by dynamodb
 const maxRecordsToInsertOnBatchOperation = 25
+const maxKeysOnBatchGet = 100
 const batchWriteErrorMessage = "batch item failed to write"
 
+// ErrUnprocessedItem is wrapped by the failures of items dynamo still left unprocessed after the last attempt
+var ErrUnprocessedItem = errors.New(batchWriteErrorMessage)
+
+// BatchRetry is how BatchWrite resubmits the items dynamo leaves unprocessed and the batches it throttles
+type BatchRetry struct {
+	MaxAttempts int           // calls per batch including the first, 1 never resubmits
+	BaseDelay   time.Duration // wait before the first resubmit, doubled for each one after it
+	MaxDelay    time.Duration // longest wait between attempts
+	Sleep       func(ctx context.Context, d time.Duration) error
+}
+
+func DefaultBatchRetry() BatchRetry {
+	return BatchRetry{MaxAttempts: 8, BaseDelay: 50 * time.Millisecond, MaxDelay: 5 * time.Second}
+}
+
+// BatchConfig is how BatchWrite sends its batches
+type BatchConfig struct {
+	Retry       BatchRetry
+	Concurrency int           // batches written at once, 0 and 1 write them one after another
+	Limiter     IWriteLimiter // meters write capacity units across every batch written with it, nil doesn't limit
+}
+
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{Retry: DefaultBatchRetry(), Concurrency: 1}
+}
+
+func (br BatchRetry) delay(attempt int) time.Duration {
+	d := br.BaseDelay
+	for i := 1; i < attempt && d < br.MaxDelay; i += 1 {
+		d *= 2
+	}
+
+	if br.MaxDelay > 0 && d > br.MaxDelay {
+		d = br.MaxDelay
+	}
+
+	return d
+}
+
+func (br BatchRetry) sleep(ctx context.Context, d time.Duration) error {
+	if br.Sleep != nil {
+		return br.Sleep(ctx, d)
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// isThrottled reports whether dynamo refused a call for the table's or account's throughput, which is worth retrying
+func isThrottled(err error) bool {
+	var pte *types.ProvisionedThroughputExceededException
+	if errors.As(err, &pte) {
+		return true
+	}
+
+	var rle *types.RequestLimitExceeded
+	if errors.As(err, &rle) {
+		return true
+	}
+
+	var ae smithy.APIError
+	return errors.As(err, &ae) && ae.ErrorCode() == "ThrottlingException"
+}
+
 type BatchWriteErrorResult[T any] struct {
 	Error error
 	Input T
@@ -62,7 +138,31 @@ func NewDatabase[T any](
 	am func(in interface{}) (map[string]types.AttributeValue, error),
 	aum func(map[string]types.AttributeValue, interface{}) error,
 ) IDatabase[T] {
-	return &Database[T]{client: c, numToBatchInsert: bi, tableName: tn, attributeMarshaller: am, attributeUnmarshaler: aum}
+	return NewDatabaseWithBatchConfig[T](c, bi, tn, am, aum, DefaultBatchConfig())
+}
+
+// NewDatabaseWithBatchRetry returns a database whose batch writes resubmit unprocessed items as `retry` describes
+func NewDatabaseWithBatchRetry[T any](
+	c IDatabaseClient,
+	bi int,
+	tn string,
+	am func(in interface{}) (map[string]types.AttributeValue, error),
+	aum func(map[string]types.AttributeValue, interface{}) error,
+	retry BatchRetry,
+) IDatabase[T] {
+	return NewDatabaseWithBatchConfig[T](c, bi, tn, am, aum, BatchConfig{Retry: retry, Concurrency: 1})
+}
+
+// NewDatabaseWithBatchConfig returns a database whose batch writes are sent as `config` describes
+func NewDatabaseWithBatchConfig[T any](
+	c IDatabaseClient,
+	bi int,
+	tn string,
+	am func(in interface{}) (map[string]types.AttributeValue, error),
+	aum func(map[string]types.AttributeValue, interface{}) error,
+	config BatchConfig,
+) IDatabase[T] {
+	return &Database[T]{client: c, numToBatchInsert: bi, tableName: tn, attributeMarshaller: am, attributeUnmarshaler: aum, batchConfig: config}
 }
 
 var _ IDatabase[any] = (*Database[any])(nil)
@@ -73,6 +173,7 @@ type Database[T any] struct {
 	numToBatchInsert     int
 	attributeMarshaller  func(in interface{}) (map[string]types.AttributeValue, error)
 	attributeUnmarshaler func(map[string]types.AttributeValue, interface{}) error
+	batchConfig          BatchConfig
 	getLock              func() (*dynamolock.Client, error)
 }
 
@@ -113,6 +214,155 @@ func (db *Database[T]) GetItem(ctx context.Context, key map[string]types.Attribu
 	return item, nil
 }
 
+// UpsertOneIf puts the item when the condition holds for the item it replaces, failing with a ConditionFailedError
+// when it doesn't
+func (db *Database[T]) UpsertOneIf(ctx context.Context, m T, condition Condition) error {
+	md, err := db.attributeMarshaller(m)
+	if err != nil {
+		return err
+	}
+
+	e := expression{}
+	ce := condition.render(&e)
+	if e.err != nil {
+		return e.err
+	}
+
+	_, err = db.client.PutItem(ctx, &dynamodb.PutItemInput{
+		Item:                      md,
+		TableName:                 aws.String(db.tableName),
+		ConditionExpression:       aws.String(ce),
+		ExpressionAttributeNames:  e.names,
+		ExpressionAttributeValues: e.values,
+	})
+
+	return conditionFailed(err, &condition)
+}
+
+// BatchGet gets the items of the keys in chunks of up to 100, retrying the keys dynamo leaves unprocessed with the
+// backoff of BatchConfig.Retry. Items are returned in the order of their keys and keys with no item are left out
+func (db *Database[T]) BatchGet(ctx context.Context, keys []map[string]types.AttributeValue) ([]T, error) {
+	retry := db.batchConfig.Retry
+	if retry.MaxAttempts < 1 {
+		retry.MaxAttempts = 1
+	}
+
+	found := make([]map[string]types.AttributeValue, len(keys))
+	offset := 0
+	for _, c := range chunk(keys, maxKeysOnBatchGet) {
+		pending := c
+		for attempt := 1; ; attempt += 1 {
+			res, err := db.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: map[string]types.KeysAndAttributes{db.tableName: {Keys: pending}},
+			})
+			if err != nil {
+				if !isThrottled(err) || attempt >= retry.MaxAttempts {
+					return []T{}, err
+				}
+			} else {
+				for _, item := range res.Responses[db.tableName] {
+					if i := keyIndex(c, item); i >= 0 {
+						found[offset+i] = item
+					}
+				}
+
+				pending = res.UnprocessedKeys[db.tableName].Keys
+				if len(pending) == 0 {
+					break
+				}
+
+				if attempt >= retry.MaxAttempts {
+					return []T{}, fmt.Errorf("%d keys still unprocessed after %d attempts", len(pending), attempt)
+				}
+			}
+
+			if err := retry.sleep(ctx, retry.delay(attempt)); err != nil {
+				return []T{}, err
+			}
+		}
+
+		offset += len(c)
+	}
+
+	var items []T
+	for _, f := range found {
+		if f == nil {
+			continue
+		}
+
+		var item T
+		err := db.attributeUnmarshaler(f, &item)
+		if err != nil {
+			return []T{}, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// keyIndex returns the index of the key the item has, or -1 when it has none of them
+func keyIndex(keys []map[string]types.AttributeValue, item map[string]types.AttributeValue) int {
+	for i, k := range keys {
+		matches := true
+		for name, av := range k {
+			if !reflect.DeepEqual(item[name], av) {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// UpdateItem applies the update to the item of the key, creating it when there is none, and returns the item as it
+// is after the update. A conditional update fails with a ConditionFailedError when its condition doesn't hold
+func (db *Database[T]) UpdateItem(ctx context.Context, key map[string]types.AttributeValue, update Update) (T, error) {
+	var item T
+	e := expression{}
+	input := dynamodb.UpdateItemInput{
+		Key:              key,
+		TableName:        aws.String(db.tableName),
+		UpdateExpression: aws.String(update.render(&e)),
+		ReturnValues:     types.ReturnValueAllNew,
+	}
+
+	if update.condition != nil {
+		input.ConditionExpression = aws.String(update.condition.render(&e))
+	}
+
+	if e.err != nil {
+		return item, e.err
+	}
+
+	input.ExpressionAttributeNames = e.names
+	input.ExpressionAttributeValues = e.values
+
+	res, err := db.client.UpdateItem(ctx, &input)
+	if err != nil {
+		return item, conditionFailed(err, update.condition)
+	}
+
+	err = db.attributeUnmarshaler(res.Attributes, &item)
+
+	return item, err
+}
+
+func (db *Database[T]) DeleteItem(ctx context.Context, key map[string]types.AttributeValue) error {
+	_, err := db.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		Key:       key,
+		TableName: aws.String(db.tableName),
+	})
+
+	return err
+}
+
 func (db *Database[T]) Query(ctx context.Context, key map[string]types.Condition, index string) ([]T, error) {
 	var items []T
 	var startKey map[string]types.AttributeValue
@@ -168,14 +418,19 @@ func (db *Database[T]) QueryWithLimit(ctx context.Context, key map[string]types.
 // BatchWrite
 // This function inserts items of the database type into the database in batches
 //
+// Batches are written by up to the database's BatchConfig.Concurrency workers, each call waiting on its Limiter for
+// the write capacity units it consumes. Items dynamo leaves unprocessed are resubmitted, and throttled batches
+// retried, with exponential backoff as BatchConfig.Retry describes.
+//
 // Errors:
 //
 // error # 1: a systematic failure error.
 //
-// error #2: a batch error. This can occur for two reasons.
-// 1. There is an error inserting an entire batch
-// 2. An item or items fails to be insert in the batch operation. This result would be returned in `BatchItemOutput`
-// We can return an error that maps failed insertions to the original input item.
+// error #2: a `BatchWriteError` mapping each item that finally failed to its input, in the order of the items. This
+// can occur for three reasons.
+// 1. A batch failed with an error that isn't throttling
+// 2. A batch was still throttled, or the context was done, before it was written
+// 3. An item was still unprocessed after the last attempt, its error wraps `ErrUnprocessedItem`
 //
 // It will be up to the caller to check what type of error is returned and to implement appropriate error handling
 func (db *Database[T]) BatchWrite(ctx context.Context, items []T) error {
@@ -184,74 +439,233 @@ func (db *Database[T]) BatchWrite(ctx context.Context, items []T) error {
 		return err
 	}
 
-	batchErr := BatchWriteError[T]{}
-	// TODO: we can run this concurrently if we need to speed this up,
-	// but I think we should only do this if we notice a bottleneck here
-	for _, batch := range batches {
-		requests := make([]types.WriteRequest, len(batch))
-		for i, b := range batch {
+	failed := writeBatches(ctx, db.batchWriter(), batches, func(r types.WriteRequest) (T, error) {
+		var item T
+		err := db.attributeUnmarshaler(writeRequestItem(r), &item)
+		return item, err
+	})
+
+	if len(failed) > 0 {
+		return BatchWriteError[T]{FailedWrites: failed}
+	}
+
+	return nil
+}
+
+// BatchDelete deletes items by key in batches the way BatchWrite writes them, failing with a BatchWriteError of the
+// keys that weren't deleted
+func (db *Database[T]) BatchDelete(ctx context.Context, keys []map[string]types.AttributeValue) error {
+	requests := make([]batchItemWriteRequest[map[string]types.AttributeValue], len(keys))
+	for i, k := range keys {
+		requests[i] = batchItemWriteRequest[map[string]types.AttributeValue]{WriteRequest: types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: k}}, Item: k}
+	}
+
+	failed := writeBatches(ctx, db.batchWriter(), chunk(requests, db.batchSize()), func(r types.WriteRequest) (map[string]types.AttributeValue, error) {
+		return writeRequestItem(r), nil
+	})
+
+	if len(failed) > 0 {
+		return BatchWriteError[map[string]types.AttributeValue]{FailedWrites: failed}
+	}
+
+	return nil
+}
+
+// batchWriter sends batches of write requests to a table, whatever the inputs the requests were made from
+type batchWriter struct {
+	client    IDatabaseClient
+	tableName string
+	config    BatchConfig
+}
+
+func (db *Database[T]) batchWriter() batchWriter {
+	return batchWriter{client: db.client, tableName: db.tableName, config: db.batchConfig}
+}
+
+// writeBatches writes the batches with up to the config's concurrency workers and returns the inputs that failed,
+// in the order of the batches. decode turns a request dynamo hands back that matches no input into one
+func writeBatches[I any](ctx context.Context, bw batchWriter, batches [][]batchItemWriteRequest[I], decode func(types.WriteRequest) (I, error)) []BatchWriteErrorResult[I] {
+	workers := bw.config.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	if workers > len(batches) {
+		workers = len(batches)
+	}
+
+	failed := make([][]BatchWriteErrorResult[I], len(batches))
+	next := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				failed[i] = writeBatch(ctx, bw, batches[i], decode)
+			}
+		}()
+	}
+
+	for i := range batches {
+		next <- i
+	}
+
+	close(next)
+	wg.Wait()
+
+	var all []BatchWriteErrorResult[I]
+	for _, f := range failed {
+		all = append(all, f...)
+	}
+
+	return all
+}
+
+// writeBatch writes a batch, resubmitting what dynamo leaves unprocessed, and returns the inputs that failed
+func writeBatch[I any](ctx context.Context, bw batchWriter, batch []batchItemWriteRequest[I], decode func(types.WriteRequest) (I, error)) []BatchWriteErrorResult[I] {
+	retry := bw.config.Retry
+	if retry.MaxAttempts < 1 {
+		retry.MaxAttempts = 1
+	}
+
+	var failed []BatchWriteErrorResult[I]
+	pending := batch
+	for attempt := 1; ; attempt += 1 {
+		if ctx.Err() != nil {
+			return append(failed, failAll(pending, ctx.Err())...)
+		}
+
+		requests := make([]types.WriteRequest, len(pending))
+		for i, b := range pending {
 			requests[i] = b.WriteRequest
 		}
 
-		input := dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{db.tableName: requests}}
-		output, err := db.client.BatchWriteItem(ctx, &input)
+		if bw.config.Limiter != nil {
+			if err := bw.config.Limiter.WaitN(ctx, writeUnits(requests)); err != nil {
+				return append(failed, failAll(pending, err)...)
+			}
+		}
+
+		input := dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{bw.tableName: requests}}
+		output, err := bw.client.BatchWriteItem(ctx, &input)
 		if err != nil {
-			fmt.Printf("batch write error: %#v %s\n", err, err.Error())
-			for _, b := range batch {
-				batchErr.FailedWrites = append(batchErr.FailedWrites, BatchWriteErrorResult[T]{Error: err, Input: b.Item})
+			if !isThrottled(err) {
+				return append(failed, failAll(pending, err)...)
+			}
+
+			if attempt >= retry.MaxAttempts {
+				return append(failed, failAll(pending, fmt.Errorf("throttled after %d attempts: %w", attempt, err))...)
+			}
+		} else {
+			var unmapped []BatchWriteErrorResult[I]
+			pending, unmapped = unprocessed(pending, output.UnprocessedItems[bw.tableName], decode)
+			failed = append(failed, unmapped...)
+			if len(pending) == 0 {
+				return failed
+			}
+
+			if attempt >= retry.MaxAttempts {
+				return append(failed, failAll(pending, fmt.Errorf("%w: still unprocessed after %d attempts", ErrUnprocessedItem, attempt))...)
 			}
-			continue
 		}
 
-		failedWrites, has := output.UnprocessedItems[db.tableName]
-		if has {
-			for _, f := range failedWrites {
-				var v T
-				err := db.attributeUnmarshaler(f.PutRequest.Item, &v)
-				if err != nil {
-					// TODO: 🤷‍♀️
-				}
+		if err := retry.sleep(ctx, retry.delay(attempt)); err != nil {
+			return append(failed, failAll(pending, err)...)
+		}
+	}
+}
 
-				batchErr.FailedWrites = append(batchErr.FailedWrites, BatchWriteErrorResult[T]{Error: errors.New(batchWriteErrorMessage), Input: v})
+// unprocessed maps the requests dynamo handed back to the batch inputs they were made from. A request matching no
+// input is resubmitted with the input it decodes to, or fails if it can't be decoded
+func unprocessed[I any](pending []batchItemWriteRequest[I], requests []types.WriteRequest, decode func(types.WriteRequest) (I, error)) ([]batchItemWriteRequest[I], []BatchWriteErrorResult[I]) {
+	var left []batchItemWriteRequest[I]
+	var failed []BatchWriteErrorResult[I]
+	matched := make([]bool, len(pending))
+	for _, r := range requests {
+		found := false
+		for i, b := range pending {
+			if !matched[i] && sameWriteRequest(b.WriteRequest, r) {
+				matched[i] = true
+				found = true
+				left = append(left, b)
+				break
 			}
 		}
+
+		if found {
+			continue
+		}
+
+		input, err := decode(r)
+		if err != nil {
+			failed = append(failed, BatchWriteErrorResult[I]{Error: fmt.Errorf("%w: unprocessed item matches no input and failed to unmarshal with error: %s", ErrUnprocessedItem, err.Error())})
+			continue
+		}
+
+		left = append(left, batchItemWriteRequest[I]{WriteRequest: r, Item: input})
+	}
+
+	return left, failed
+}
+
+func sameWriteRequest(a types.WriteRequest, b types.WriteRequest) bool {
+	return (a.PutRequest == nil) == (b.PutRequest == nil) && reflect.DeepEqual(writeRequestItem(a), writeRequestItem(b))
+}
+
+func writeRequestItem(r types.WriteRequest) map[string]types.AttributeValue {
+	if r.PutRequest != nil {
+		return r.PutRequest.Item
 	}
 
-	if len(batchErr.FailedWrites) > 0 {
-		return batchErr
+	if r.DeleteRequest != nil {
+		return r.DeleteRequest.Key
 	}
 
 	return nil
 }
 
-func (db *Database[T]) createBatches(items []T) ([][]batchItemWriteRequest[T], error) {
-	numToBatch := maxRecordsToInsertOnBatchOperation
-	if db.numToBatchInsert > 0 || db.numToBatchInsert < maxRecordsToInsertOnBatchOperation {
-		numToBatch = db.numToBatchInsert
+func failAll[I any](batch []batchItemWriteRequest[I], err error) []BatchWriteErrorResult[I] {
+	failed := make([]BatchWriteErrorResult[I], len(batch))
+	for i, b := range batch {
+		failed[i] = BatchWriteErrorResult[I]{Error: err, Input: b.Item}
 	}
 
-	bins := len(items) / numToBatch
-	if len(items)%numToBatch != 0 {
-		bins += 1
+	return failed
+}
+
+func (db *Database[T]) batchSize() int {
+	if db.numToBatchInsert > 0 && db.numToBatchInsert < maxRecordsToInsertOnBatchOperation {
+		return db.numToBatchInsert
 	}
 
-	toInsert := make([][]batchItemWriteRequest[T], bins)
-	j := 0
+	return maxRecordsToInsertOnBatchOperation
+}
+
+func (db *Database[T]) createBatches(items []T) ([][]batchItemWriteRequest[T], error) {
+	requests := make([]batchItemWriteRequest[T], len(items))
 	for i, it := range items {
 		marItem, err := db.attributeMarshaller(it)
 		if err != nil {
 			return [][]batchItemWriteRequest[T]{}, err
 		}
-		wr := types.WriteRequest{PutRequest: &types.PutRequest{Item: marItem}}
-		if i%numToBatch == 0 && i > 0 {
-			j += 1
-			toInsert[j] = []batchItemWriteRequest[T]{{WriteRequest: wr, Item: it}}
-		} else {
-			sub := toInsert[j]
-			sub = append(sub, batchItemWriteRequest[T]{WriteRequest: wr, Item: it})
-			toInsert[j] = sub
+
+		requests[i] = batchItemWriteRequest[T]{WriteRequest: types.WriteRequest{PutRequest: &types.PutRequest{Item: marItem}}, Item: it}
+	}
+
+	return chunk(requests, db.batchSize()), nil
+}
+
+func chunk[I any](items []I, size int) [][]I {
+	var chunks [][]I
+	for start := 0; start < len(items); start += size {
+		end := start + size
+		if end > len(items) {
+			end = len(items)
 		}
+
+		chunks = append(chunks, items[start:end])
 	}
 
-	return toInsert, nil
+	return chunks
 }
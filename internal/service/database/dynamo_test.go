@@ -0,0 +1,390 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/greenac/chaching/internal/database/mocks"
+	"github.com/greenac/chaching/internal/database/models"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testItem struct {
+	Pk      string `dynamodbav:"pk"`
+	Sk      string `dynamodbav:"sk"`
+	Value   int    `dynamodbav:"value"`
+	Version int64  `dynamodbav:"version,omitempty"`
+	Count   int    `dynamodbav:"count,omitempty"`
+}
+
+func testKey(pk string, sk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"pk": &types.AttributeValueMemberS{Value: pk}, "sk": &types.AttributeValueMemberS{Value: sk}}
+}
+
+func TestDatabase_BatchWrite(t *testing.T) {
+	Convey("TestDatabase_BatchWrite", t, func() {
+		ctx := context.Background()
+		fake := mocks.NewDynamoFake(mocks.ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+		var sleeps []time.Duration
+		retry := BatchRetry{MaxAttempts: 4, BaseDelay: 50 * time.Millisecond, MaxDelay: time.Second, Sleep: func(ctx context.Context, d time.Duration) error {
+			sleeps = append(sleeps, d)
+			return nil
+		}}
+		newDb := func() IDatabase[testItem] {
+			return NewDatabaseWithBatchRetry[testItem](fake, 25, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap, retry)
+		}
+
+		var items []testItem
+		for i := 0; i < 5; i += 1 {
+			items = append(items, testItem{Pk: "AAPL", Sk: fmt.Sprintf("timeStamp#%d", i), Value: i})
+		}
+
+		Convey("TestDatabase_BatchWrite should resubmit unprocessed items with backoff until they are written", func() {
+			fake.Unprocessed = mocks.LeaveUnprocessed(2, 2)
+
+			So(newDb().BatchWrite(ctx, items), ShouldBeNil)
+			So(fake.Calls(mocks.OperationBatchWriteItem), ShouldEqual, 3)
+			So(sleeps, ShouldResemble, []time.Duration{50 * time.Millisecond, 100 * time.Millisecond})
+			So(len(fake.Items("Chaching")), ShouldEqual, 5)
+		})
+
+		Convey("TestDatabase_BatchWrite should map items still unprocessed after the last attempt to their inputs", func() {
+			retry.MaxAttempts = 3
+			fake.Unprocessed = mocks.LeaveUnprocessed(2, 10)
+
+			err := newDb().BatchWrite(ctx, items)
+			var bwe BatchWriteError[testItem]
+			So(errors.As(err, &bwe), ShouldBeTrue)
+			So(len(bwe.FailedWrites), ShouldEqual, 2)
+			So(bwe.FailedWrites[0].Input, ShouldResemble, items[3])
+			So(bwe.FailedWrites[1].Input, ShouldResemble, items[4])
+			So(errors.Is(bwe.FailedWrites[0].Error, ErrUnprocessedItem), ShouldBeTrue)
+			So(bwe.FailedWrites[0].Error.Error(), ShouldEqual, "batch item failed to write: still unprocessed after 3 attempts")
+			So(len(fake.Items("Chaching")), ShouldEqual, 3)
+		})
+
+		Convey("TestDatabase_BatchWrite should retry throttled batches", func() {
+			fake.Errors = map[mocks.Operation][]error{mocks.OperationBatchWriteItem: {mocks.ThrottlingError(), mocks.ThrottlingError()}}
+
+			So(newDb().BatchWrite(ctx, items), ShouldBeNil)
+			So(fake.Calls(mocks.OperationBatchWriteItem), ShouldEqual, 3)
+			So(len(fake.Items("Chaching")), ShouldEqual, 5)
+		})
+
+		Convey("TestDatabase_BatchWrite should fail a batch that is still throttled after the last attempt", func() {
+			retry.MaxAttempts = 2
+			fake.Errors = map[mocks.Operation][]error{mocks.OperationBatchWriteItem: {mocks.ThrottlingError(), mocks.ThrottlingError()}}
+
+			err := newDb().BatchWrite(ctx, items[:2])
+			bwe := err.(BatchWriteError[testItem])
+			So(len(bwe.FailedWrites), ShouldEqual, 2)
+			So(bwe.FailedWrites[1].Input, ShouldResemble, items[1])
+
+			var pte *types.ProvisionedThroughputExceededException
+			So(errors.As(bwe.FailedWrites[0].Error, &pte), ShouldBeTrue)
+			So(bwe.FailedWrites[0].Error.Error(), ShouldStartWith, "throttled after 2 attempts: ")
+		})
+
+		Convey("TestDatabase_BatchWrite should not retry other errors", func() {
+			fake.Errors = map[mocks.Operation][]error{mocks.OperationBatchWriteItem: {errors.New("access denied")}}
+
+			err := newDb().BatchWrite(ctx, items)
+			So(err.Error(), ShouldEqual, "access denied,access denied,access denied,access denied,access denied")
+			So(fake.Calls(mocks.OperationBatchWriteItem), ShouldEqual, 1)
+			So(sleeps, ShouldBeEmpty)
+		})
+
+		Convey("TestDatabase_BatchWrite should stop resubmitting when the wait is cut short", func() {
+			fake.Unprocessed = mocks.LeaveUnprocessed(1, 10)
+			retry.Sleep = func(ctx context.Context, d time.Duration) error {
+				return context.Canceled
+			}
+
+			err := newDb().BatchWrite(ctx, items)
+			bwe := err.(BatchWriteError[testItem])
+			So(len(bwe.FailedWrites), ShouldEqual, 1)
+			So(bwe.FailedWrites[0], ShouldResemble, BatchWriteErrorResult[testItem]{Error: context.Canceled, Input: items[4]})
+		})
+
+		Convey("TestDatabase_BatchWrite should write batches no bigger than the batch size", func() {
+			db := NewDatabaseWithBatchRetry[testItem](fake, 2, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap, retry)
+
+			So(db.BatchWrite(ctx, items), ShouldBeNil)
+			So(fake.Calls(mocks.OperationBatchWriteItem), ShouldEqual, 3)
+		})
+	})
+}
+
+func TestDatabase_ConditionalWrites(t *testing.T) {
+	Convey("TestDatabase_ConditionalWrites", t, func() {
+		ctx := context.Background()
+		fake := mocks.NewDynamoFake(mocks.ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+		db := NewDatabase[testItem](fake, 25, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap)
+
+		Convey("TestDatabase_ConditionalWrites should only create an item that doesn't exist", func() {
+			item := testItem{Pk: "AAPL", Sk: "watermark#minute", Value: 1}
+			So(db.UpsertOneIf(ctx, item, AttributeNotExists("pk")), ShouldBeNil)
+
+			item.Value = 2
+			err := db.UpsertOneIf(ctx, item, AttributeNotExists("pk"))
+			var cfe ConditionFailedError
+			So(errors.As(err, &cfe), ShouldBeTrue)
+			So(cfe.Condition, ShouldEqual, "attribute_not_exists(pk)")
+			So(err.Error(), ShouldEqual, "condition failed: attribute_not_exists(pk)")
+
+			var ccf *types.ConditionalCheckFailedException
+			So(errors.As(err, &ccf), ShouldBeTrue)
+
+			got, err := db.GetItem(ctx, testKey("AAPL", "watermark#minute"))
+			So(err, ShouldBeNil)
+			So(got.Value, ShouldEqual, 1)
+		})
+
+		Convey("TestDatabase_ConditionalWrites should write against the version that was read", func() {
+			So(db.UpsertOneIf(ctx, testItem{Pk: "AAPL", Sk: "watermark#minute", Version: 1}, VersionIs("version", 0)), ShouldBeNil)
+			So(db.UpsertOneIf(ctx, testItem{Pk: "AAPL", Sk: "watermark#minute", Value: 5, Version: 2}, VersionIs("version", 1)), ShouldBeNil)
+
+			err := db.UpsertOneIf(ctx, testItem{Pk: "AAPL", Sk: "watermark#minute", Value: 6, Version: 2}, VersionIs("version", 1))
+			So(errors.As(err, &ConditionFailedError{}), ShouldBeTrue)
+			So(err.Error(), ShouldEqual, "condition failed: version = 1")
+		})
+
+		Convey("TestDatabase_ConditionalWrites should update an item and return it as it is after the update", func() {
+			key := testKey("AAPL", "watermark#minute")
+			got, err := db.UpdateItem(ctx, key, Update{}.Add("count", 1).Set("value", 7).SetIfNotExists("version", 1))
+			So(err, ShouldBeNil)
+			So(got, ShouldResemble, testItem{Pk: "AAPL", Sk: "watermark#minute", Value: 7, Version: 1, Count: 1})
+
+			got, err = db.UpdateItem(ctx, key, Update{}.Add("count", 2).Set("version", 2).If(VersionIs("version", 1)))
+			So(err, ShouldBeNil)
+			So(got.Count, ShouldEqual, 3)
+			So(got.Version, ShouldEqual, 2)
+
+			_, err = db.UpdateItem(ctx, key, Update{}.Set("value", 8).If(VersionIs("version", 1)))
+			var cfe ConditionFailedError
+			So(errors.As(err, &cfe), ShouldBeTrue)
+			So(cfe.Condition, ShouldEqual, "version = 1")
+		})
+
+		Convey("TestDatabase_ConditionalWrites should not wrap other errors", func() {
+			fake.Errors = map[mocks.Operation][]error{mocks.OperationPutItem: {errors.New("access denied")}}
+
+			err := db.UpsertOneIf(ctx, testItem{Pk: "AAPL", Sk: "watermark#minute"}, AttributeNotExists("pk"))
+			So(err.Error(), ShouldEqual, "access denied")
+		})
+
+		Convey("TestDatabase_ConditionalWrites should delete an item", func() {
+			So(db.UpsertOne(ctx, testItem{Pk: "AAPL", Sk: "watermark#minute"}), ShouldBeNil)
+			So(db.DeleteItem(ctx, testKey("AAPL", "watermark#minute")), ShouldBeNil)
+			So(fake.Items("Chaching"), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestDatabase_BatchGet(t *testing.T) {
+	Convey("TestDatabase_BatchGet", t, func() {
+		ctx := context.Background()
+		fake := mocks.NewDynamoFake(mocks.ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+		var sleeps []time.Duration
+		retry := BatchRetry{MaxAttempts: 3, BaseDelay: 50 * time.Millisecond, MaxDelay: time.Second, Sleep: func(ctx context.Context, d time.Duration) error {
+			sleeps = append(sleeps, d)
+			return nil
+		}}
+		db := NewDatabaseWithBatchRetry[testItem](fake, 25, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap, retry)
+
+		var items []testItem
+		var keys []map[string]types.AttributeValue
+		for i := 0; i < 250; i += 1 {
+			items = append(items, testItem{Pk: "AAPL", Sk: fmt.Sprintf("timeStamp#%03d", i), Value: i})
+			keys = append(keys, testKey("AAPL", fmt.Sprintf("timeStamp#%03d", 249-i)))
+		}
+		So(db.BatchWrite(ctx, items), ShouldBeNil)
+
+		Convey("TestDatabase_BatchGet should get the keys in chunks of 100 and return the items in key order", func() {
+			got, err := db.BatchGet(ctx, keys)
+			So(err, ShouldBeNil)
+			So(len(got), ShouldEqual, 250)
+			So(got[0].Value, ShouldEqual, 249)
+			So(got[249].Value, ShouldEqual, 0)
+			So(fake.Calls(mocks.OperationBatchGetItem), ShouldEqual, 3)
+		})
+
+		Convey("TestDatabase_BatchGet should retry unprocessed keys and leave out keys with no item", func() {
+			fake.UnprocessedKeys = func(call int, table string, keys []map[string]types.AttributeValue) []map[string]types.AttributeValue {
+				if call > 1 {
+					return nil
+				}
+
+				return keys[:2]
+			}
+
+			got, err := db.BatchGet(ctx, []map[string]types.AttributeValue{keys[0], testKey("AAPL", "missing"), keys[1], keys[2]})
+			So(err, ShouldBeNil)
+			So(got, ShouldResemble, []testItem{items[249], items[248], items[247]})
+			So(fake.Calls(mocks.OperationBatchGetItem), ShouldEqual, 2)
+			So(sleeps, ShouldResemble, []time.Duration{50 * time.Millisecond})
+		})
+
+		Convey("TestDatabase_BatchGet should fail when keys are still unprocessed after the last attempt", func() {
+			fake.UnprocessedKeys = func(call int, table string, keys []map[string]types.AttributeValue) []map[string]types.AttributeValue {
+				return keys[:1]
+			}
+
+			_, err := db.BatchGet(ctx, keys[:5])
+			So(err.Error(), ShouldEqual, "1 keys still unprocessed after 3 attempts")
+		})
+
+		Convey("TestDatabase_BatchGet should retry throttled calls", func() {
+			fake.Errors = map[mocks.Operation][]error{mocks.OperationBatchGetItem: {mocks.ThrottlingError()}}
+
+			got, err := db.BatchGet(ctx, keys[:5])
+			So(err, ShouldBeNil)
+			So(len(got), ShouldEqual, 5)
+		})
+	})
+}
+
+func TestDatabase_BatchDelete(t *testing.T) {
+	Convey("TestDatabase_BatchDelete", t, func() {
+		ctx := context.Background()
+		fake := mocks.NewDynamoFake(mocks.ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+		retry := BatchRetry{MaxAttempts: 2, Sleep: func(ctx context.Context, d time.Duration) error { return nil }}
+		db := NewDatabaseWithBatchRetry[testItem](fake, 25, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap, retry)
+
+		var keys []map[string]types.AttributeValue
+		for i := 0; i < 30; i += 1 {
+			So(db.UpsertOne(ctx, testItem{Pk: "AAPL", Sk: fmt.Sprintf("timeStamp#%02d", i)}), ShouldBeNil)
+			keys = append(keys, testKey("AAPL", fmt.Sprintf("timeStamp#%02d", i)))
+		}
+
+		Convey("TestDatabase_BatchDelete should delete the keys in batches", func() {
+			So(db.BatchDelete(ctx, keys[:28]), ShouldBeNil)
+			So(fake.Calls(mocks.OperationBatchWriteItem), ShouldEqual, 2)
+			So(len(fake.Items("Chaching")), ShouldEqual, 2)
+		})
+
+		Convey("TestDatabase_BatchDelete should map the keys still unprocessed after the last attempt to their inputs", func() {
+			fake.Unprocessed = mocks.LeaveUnprocessed(1, 10)
+
+			err := db.BatchDelete(ctx, keys[:3])
+			var bwe BatchWriteError[map[string]types.AttributeValue]
+			So(errors.As(err, &bwe), ShouldBeTrue)
+			So(len(bwe.FailedWrites), ShouldEqual, 1)
+			So(bwe.FailedWrites[0].Input, ShouldResemble, keys[2])
+			So(errors.Is(bwe.FailedWrites[0].Error, ErrUnprocessedItem), ShouldBeTrue)
+		})
+	})
+}
+
+type writeLimiterMock struct {
+	mu    sync.Mutex
+	units []int
+	err   error
+}
+
+func (wl *writeLimiterMock) WaitN(ctx context.Context, n int) error {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	wl.units = append(wl.units, n)
+	return wl.err
+}
+
+func TestDatabase_BatchWriteConcurrently(t *testing.T) {
+	Convey("TestDatabase_BatchWriteConcurrently", t, func() {
+		ctx := context.Background()
+		fake := mocks.NewDynamoFake(mocks.ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+		wl := &writeLimiterMock{}
+		config := BatchConfig{Retry: BatchRetry{MaxAttempts: 1}, Concurrency: 4, Limiter: wl}
+		var items []testItem
+		for i := 0; i < 10; i += 1 {
+			items = append(items, testItem{Pk: "AAPL", Sk: fmt.Sprintf("timeStamp#%d", i), Value: i})
+		}
+
+		Convey("TestDatabase_BatchWriteConcurrently should write every batch and meter its write units", func() {
+			db := NewDatabaseWithBatchConfig[testItem](fake, 3, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap, config)
+
+			So(db.BatchWrite(ctx, items), ShouldBeNil)
+			So(len(fake.Items("Chaching")), ShouldEqual, 10)
+			sort.Ints(wl.units)
+			So(wl.units, ShouldResemble, []int{1, 3, 3, 3})
+		})
+
+		Convey("TestDatabase_BatchWriteConcurrently should aggregate the failures of every batch in the order of the items", func() {
+			fake.Unprocessed = mocks.LeaveUnprocessed(1, 100)
+			db := NewDatabaseWithBatchConfig[testItem](fake, 2, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap, config)
+
+			err := db.BatchWrite(ctx, items)
+			bwe := err.(BatchWriteError[testItem])
+			var failed []int
+			for _, fw := range bwe.FailedWrites {
+				failed = append(failed, fw.Input.Value)
+			}
+			So(failed, ShouldResemble, []int{1, 3, 5, 7, 9})
+		})
+
+		Convey("TestDatabase_BatchWriteConcurrently should fail the batches the limiter refuses", func() {
+			wl.err = context.DeadlineExceeded
+			db := NewDatabaseWithBatchConfig[testItem](fake, 5, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap, config)
+
+			err := db.BatchWrite(ctx, items)
+			So(len(err.(BatchWriteError[testItem]).FailedWrites), ShouldEqual, 10)
+			So(fake.Calls(mocks.OperationBatchWriteItem), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestWriteUnits(t *testing.T) {
+	Convey("TestWriteUnits should count a unit per started KB of each put and one per delete", t, func() {
+		small := map[string]types.AttributeValue{"pk": &types.AttributeValueMemberS{Value: "AAPL"}, "n": &types.AttributeValueMemberN{Value: "146.83"}}
+		large := map[string]types.AttributeValue{"pk": &types.AttributeValueMemberS{Value: strings.Repeat("a", 2500)}}
+
+		So(itemSize(small), ShouldEqual, 2+4+1+4)
+		So(writeUnits([]types.WriteRequest{
+			{PutRequest: &types.PutRequest{Item: small}},
+			{PutRequest: &types.PutRequest{Item: large}},
+			{DeleteRequest: &types.DeleteRequest{Key: small}},
+		}), ShouldEqual, 5)
+	})
+}
+
+// BenchmarkDatabase_BatchWrite writes a day of minute bars, 40 batches, against a client taking 2ms a call
+func BenchmarkDatabase_BatchWrite(b *testing.B) {
+	var items []testItem
+	for i := 0; i < 1000; i += 1 {
+		items = append(items, testItem{Pk: "AAPL", Sk: fmt.Sprintf("timeStamp#%04d", i), Value: i})
+	}
+
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			fake := mocks.NewDynamoFake(mocks.ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+			fake.Latency = 2 * time.Millisecond
+			db := NewDatabaseWithBatchConfig[testItem](fake, 25, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap, BatchConfig{Retry: DefaultBatchRetry(), Concurrency: concurrency})
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i += 1 {
+				if err := db.BatchWrite(context.Background(), items); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchRetry_delay(t *testing.T) {
+	Convey("TestBatchRetry_delay should double the wait up to the max", t, func() {
+		br := BatchRetry{BaseDelay: 50 * time.Millisecond, MaxDelay: 150 * time.Millisecond}
+
+		So(br.delay(1), ShouldEqual, 50*time.Millisecond)
+		So(br.delay(2), ShouldEqual, 100*time.Millisecond)
+		So(br.delay(3), ShouldEqual, 150*time.Millisecond)
+		So(br.delay(10), ShouldEqual, 150*time.Millisecond)
+	})
+}
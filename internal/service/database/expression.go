@@ -0,0 +1,275 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strings"
+)
+
+// ConditionFailedError is returned by a conditional write whose condition didn't hold for the item
+type ConditionFailedError struct {
+	Condition string // the condition that failed, with names and values in place of their placeholders
+	Err       error
+}
+
+func (e ConditionFailedError) Error() string {
+	return "condition failed: " + e.Condition
+}
+
+func (e ConditionFailedError) Unwrap() error {
+	return e.Err
+}
+
+// conditionFailed wraps the error dynamo returns for a failed condition in a ConditionFailedError
+func conditionFailed(err error, c *Condition) error {
+	var ccf *types.ConditionalCheckFailedException
+	if c == nil || !errors.As(err, &ccf) {
+		return err
+	}
+
+	return ConditionFailedError{Condition: c.String(), Err: err}
+}
+
+// Condition is a typed condition expression. Its names and values become placeholders when a request is built
+type Condition struct {
+	render func(e *expression) string
+}
+
+func (c Condition) String() string {
+	return c.render(&expression{readable: true})
+}
+
+func AttributeExists(name string) Condition {
+	return Condition{render: func(e *expression) string {
+		return "attribute_exists(" + e.name(name) + ")"
+	}}
+}
+
+func AttributeNotExists(name string) Condition {
+	return Condition{render: func(e *expression) string {
+		return "attribute_not_exists(" + e.name(name) + ")"
+	}}
+}
+
+func BeginsWith(name string, prefix string) Condition {
+	return Condition{render: func(e *expression) string {
+		return "begins_with(" + e.name(name) + ", " + e.value(prefix) + ")"
+	}}
+}
+
+func Equal(name string, value any) Condition {
+	return compare(name, "=", value)
+}
+
+func NotEqual(name string, value any) Condition {
+	return compare(name, "<>", value)
+}
+
+func LessThan(name string, value any) Condition {
+	return compare(name, "<", value)
+}
+
+func LessThanOrEqual(name string, value any) Condition {
+	return compare(name, "<=", value)
+}
+
+func GreaterThan(name string, value any) Condition {
+	return compare(name, ">", value)
+}
+
+func GreaterThanOrEqual(name string, value any) Condition {
+	return compare(name, ">=", value)
+}
+
+// VersionIs holds when the item's version attribute is `version`, or for version 0 when the item has none, so
+// writes made with it only succeed against the version they read
+func VersionIs(name string, version int64) Condition {
+	if version == 0 {
+		return AttributeNotExists(name)
+	}
+
+	return Equal(name, version)
+}
+
+func And(conditions ...Condition) Condition {
+	return join(" AND ", conditions)
+}
+
+func Or(conditions ...Condition) Condition {
+	return join(" OR ", conditions)
+}
+
+func Not(c Condition) Condition {
+	return Condition{render: func(e *expression) string {
+		return "NOT (" + c.render(e) + ")"
+	}}
+}
+
+func compare(name string, op string, value any) Condition {
+	return Condition{render: func(e *expression) string {
+		return e.name(name) + " " + op + " " + e.value(value)
+	}}
+}
+
+func join(op string, conditions []Condition) Condition {
+	return Condition{render: func(e *expression) string {
+		parts := make([]string, len(conditions))
+		for i, c := range conditions {
+			parts[i] = "(" + c.render(e) + ")"
+		}
+
+		return strings.Join(parts, op)
+	}}
+}
+
+// Update is a typed update expression, optionally conditional
+type Update struct {
+	sets      []func(e *expression) string
+	removes   []string
+	adds      []func(e *expression) string
+	condition *Condition
+}
+
+// Set sets an attribute to a value
+func (u Update) Set(name string, value any) Update {
+	u.sets = append(append([]func(e *expression) string{}, u.sets...), func(e *expression) string {
+		return e.name(name) + " = " + e.value(value)
+	})
+
+	return u
+}
+
+// SetIfNotExists sets an attribute unless the item already has it, such as a created at time
+func (u Update) SetIfNotExists(name string, value any) Update {
+	u.sets = append(append([]func(e *expression) string{}, u.sets...), func(e *expression) string {
+		n := e.name(name)
+		return n + " = if_not_exists(" + n + ", " + e.value(value) + ")"
+	})
+
+	return u
+}
+
+// Add adds a number to a numeric attribute, or elements to a set, creating it when the item doesn't have it
+func (u Update) Add(name string, value any) Update {
+	u.adds = append(append([]func(e *expression) string{}, u.adds...), func(e *expression) string {
+		return e.name(name) + " " + e.value(value)
+	})
+
+	return u
+}
+
+func (u Update) Remove(name string) Update {
+	u.removes = append(append([]string{}, u.removes...), name)
+
+	return u
+}
+
+// If makes the update conditional, failing with a ConditionFailedError when the condition doesn't hold
+func (u Update) If(c Condition) Update {
+	u.condition = &c
+
+	return u
+}
+
+func (u Update) render(e *expression) string {
+	var clauses []string
+	if len(u.sets) > 0 {
+		parts := make([]string, len(u.sets))
+		for i, s := range u.sets {
+			parts[i] = s(e)
+		}
+
+		clauses = append(clauses, "SET "+strings.Join(parts, ", "))
+	}
+
+	if len(u.removes) > 0 {
+		parts := make([]string, len(u.removes))
+		for i, r := range u.removes {
+			parts[i] = e.name(r)
+		}
+
+		clauses = append(clauses, "REMOVE "+strings.Join(parts, ", "))
+	}
+
+	if len(u.adds) > 0 {
+		parts := make([]string, len(u.adds))
+		for i, a := range u.adds {
+			parts[i] = a(e)
+		}
+
+		clauses = append(clauses, "ADD "+strings.Join(parts, ", "))
+	}
+
+	return strings.Join(clauses, " ")
+}
+
+func (u Update) String() string {
+	s := u.render(&expression{readable: true})
+	if u.condition != nil {
+		s += " IF " + u.condition.String()
+	}
+
+	return s
+}
+
+// expression collects the placeholders of the expressions in one request. A readable expression writes names and
+// values in place instead
+type expression struct {
+	readable bool
+	names    map[string]string
+	values   map[string]types.AttributeValue
+	err      error
+}
+
+// name returns the placeholder of an attribute name, a dotted name being a path into maps
+func (e *expression) name(name string) string {
+	if e.readable {
+		return name
+	}
+
+	if e.names == nil {
+		e.names = map[string]string{}
+	}
+
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		placeholder := ""
+		for k, v := range e.names {
+			if v == p {
+				placeholder = k
+				break
+			}
+		}
+
+		if placeholder == "" {
+			placeholder = fmt.Sprintf("#n%d", len(e.names))
+			e.names[placeholder] = p
+		}
+
+		parts[i] = placeholder
+	}
+
+	return strings.Join(parts, ".")
+}
+
+func (e *expression) value(value any) string {
+	if e.readable {
+		return fmt.Sprintf("%v", value)
+	}
+
+	if e.values == nil {
+		e.values = map[string]types.AttributeValue{}
+	}
+
+	av, err := attributevalue.Marshal(value)
+	if err != nil && e.err == nil {
+		e.err = errors.New("failed to marshal expression value: " + fmt.Sprintf("%v", value) + " with error: " + err.Error())
+	}
+
+	placeholder := fmt.Sprintf(":v%d", len(e.values))
+	e.values[placeholder] = av
+
+	return placeholder
+}
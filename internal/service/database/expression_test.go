@@ -0,0 +1,52 @@
+package database
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type unmarshalable struct{}
+
+func (u unmarshalable) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	return nil, errors.New("not a value")
+}
+
+func TestCondition(t *testing.T) {
+	Convey("TestCondition", t, func() {
+		Convey("TestCondition should render names and values as placeholders, reusing a name's placeholder", func() {
+			c := And(VersionIs("version", 3), Or(AttributeNotExists("meta.source"), Not(BeginsWith("meta.source", "poly"))))
+			e := expression{}
+
+			So(c.render(&e), ShouldEqual, "(#n0 = :v0) AND ((attribute_not_exists(#n1.#n2)) OR (NOT (begins_with(#n1.#n2, :v1))))")
+			So(e.names, ShouldResemble, map[string]string{"#n0": "version", "#n1": "meta", "#n2": "source"})
+			So(e.values, ShouldResemble, map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "3"}, ":v1": &types.AttributeValueMemberS{Value: "poly"}})
+			So(e.err, ShouldBeNil)
+		})
+
+		Convey("TestCondition should read with names and values in place", func() {
+			So(VersionIs("version", 0).String(), ShouldEqual, "attribute_not_exists(version)")
+			So(Or(LessThan("close", 10.5), GreaterThanOrEqual("volume", 100)).String(), ShouldEqual, "(close < 10.5) OR (volume >= 100)")
+		})
+
+		Convey("TestCondition should record values that fail to marshal", func() {
+			e := expression{}
+			Equal("at", unmarshalable{}).render(&e)
+
+			So(e.err.Error(), ShouldEqual, "failed to marshal expression value: {} with error: not a value")
+		})
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	Convey("TestUpdate should render its clauses in order and read with its condition", t, func() {
+		u := Update{}.Add("count", 1).Set("close", 146.83).Remove("stale").SetIfNotExists("createdAt", "2023-04-06").If(AttributeExists("pk"))
+		e := expression{}
+
+		So(u.render(&e), ShouldEqual, "SET #n0 = :v0, #n1 = if_not_exists(#n1, :v1) REMOVE #n2 ADD #n3 :v2")
+		So(e.names, ShouldResemble, map[string]string{"#n0": "close", "#n1": "createdAt", "#n2": "stale", "#n3": "count"})
+		So(u.String(), ShouldEqual, "SET close = 146.83, createdAt = if_not_exists(createdAt, 2023-04-06) REMOVE stale ADD count 1 IF attribute_exists(pk)")
+	})
+}
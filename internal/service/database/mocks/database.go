@@ -0,0 +1,167 @@
+package mocks
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/service/database"
+)
+
+var _ database.IDatabase[models.DbCompany] = (*DatabaseMock[models.DbCompany])(nil)
+
+// DatabaseMock is an IDatabase that holds its items in memory, one per pk and sk. It records the keys it is asked
+// for so tests can check them. Conditions are not evaluated, and updates return the item without applying them
+type DatabaseMock[T any] struct {
+	Items      []T
+	Written    []T // every item put or batch written, in order
+	QueryKey   map[string]types.Condition
+	QueryIndex string
+	GetKey     map[string]types.AttributeValue
+	GetError   error
+	PutError   error
+}
+
+func (dm *DatabaseMock[T]) UpsertOne(ctx context.Context, m T) error {
+	if dm.PutError != nil {
+		return dm.PutError
+	}
+
+	return dm.put(m)
+}
+
+func (dm *DatabaseMock[T]) UpsertOneIf(ctx context.Context, m T, condition database.Condition) error {
+	return dm.UpsertOne(ctx, m)
+}
+
+func (dm *DatabaseMock[T]) GetItem(ctx context.Context, key map[string]types.AttributeValue) (T, error) {
+	dm.GetKey = key
+	if dm.GetError != nil {
+		var t T
+		return t, dm.GetError
+	}
+
+	t, _ := dm.find(key)
+	return t, nil
+}
+
+func (dm *DatabaseMock[T]) BatchGet(ctx context.Context, keys []map[string]types.AttributeValue) ([]T, error) {
+	if dm.GetError != nil {
+		return nil, dm.GetError
+	}
+
+	var items []T
+	for _, k := range keys {
+		if t, i := dm.find(k); i >= 0 {
+			items = append(items, t)
+		}
+	}
+
+	return items, nil
+}
+
+func (dm *DatabaseMock[T]) Query(ctx context.Context, key map[string]types.Condition, index string) ([]T, error) {
+	dm.QueryKey = key
+	dm.QueryIndex = index
+	return dm.Items, nil
+}
+
+func (dm *DatabaseMock[T]) QueryWithLimit(ctx context.Context, key map[string]types.Condition, startKey map[string]types.AttributeValue, index string, limit *int32) ([]T, map[string]types.AttributeValue, error) {
+	items, err := dm.Query(ctx, key, index)
+	return items, nil, err
+}
+
+func (dm *DatabaseMock[T]) UpdateItem(ctx context.Context, key map[string]types.AttributeValue, update database.Update) (T, error) {
+	return dm.GetItem(ctx, key)
+}
+
+func (dm *DatabaseMock[T]) DeleteItem(ctx context.Context, key map[string]types.AttributeValue) error {
+	if _, i := dm.find(key); i >= 0 {
+		dm.Items = append(dm.Items[:i], dm.Items[i+1:]...)
+	}
+
+	return nil
+}
+
+func (dm *DatabaseMock[T]) BatchWrite(ctx context.Context, items []T) error {
+	if dm.PutError != nil {
+		return dm.PutError
+	}
+
+	for _, m := range items {
+		if err := dm.put(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (dm *DatabaseMock[T]) BatchDelete(ctx context.Context, keys []map[string]types.AttributeValue) error {
+	for _, k := range keys {
+		_ = dm.DeleteItem(ctx, k)
+	}
+
+	return nil
+}
+
+// Item returns the item stored with the pk and sk
+func (dm *DatabaseMock[T]) Item(pk string, sk string) (T, bool) {
+	t, i := dm.find(map[string]types.AttributeValue{
+		models.DbPartitionKey: &types.AttributeValueMemberS{Value: pk},
+		models.DbSearchKey:    &types.AttributeValueMemberS{Value: sk},
+	})
+
+	return t, i >= 0
+}
+
+func (dm *DatabaseMock[T]) put(m T) error {
+	dm.Written = append(dm.Written, m)
+
+	key, err := keyOf(m)
+	if err != nil {
+		return err
+	}
+
+	if _, i := dm.find(key); i >= 0 {
+		dm.Items[i] = m
+		return nil
+	}
+
+	dm.Items = append(dm.Items, m)
+	return nil
+}
+
+// find returns the item with the key and its index, or -1 when there is none
+func (dm *DatabaseMock[T]) find(key map[string]types.AttributeValue) (T, int) {
+	for i, t := range dm.Items {
+		k, err := keyOf(t)
+		if err == nil && sameKey(k, key) {
+			return t, i
+		}
+	}
+
+	var t T
+	return t, -1
+}
+
+func keyOf(m any) (map[string]types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMap(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]types.AttributeValue{models.DbPartitionKey: item[models.DbPartitionKey], models.DbSearchKey: item[models.DbSearchKey]}, nil
+}
+
+func sameKey(a map[string]types.AttributeValue, b map[string]types.AttributeValue) bool {
+	for _, name := range []string{models.DbPartitionKey, models.DbSearchKey} {
+		as, aok := a[name].(*types.AttributeValueMemberS)
+		bs, bok := b[name].(*types.AttributeValueMemberS)
+		if aok != bok || (aok && as.Value != bs.Value) {
+			return false
+		}
+	}
+
+	return true
+}
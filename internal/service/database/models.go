@@ -15,15 +15,39 @@ type IDatabaseClient interface {
 	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
 	DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error)
 	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
 }
 
-// IDatabase
-// TODO: fill this out as needs arise. Currently we only need to insert a record.
-// we'll probably want to add a batch update as well, and a query
+// IDatabase is a typed view of a table's items. Keys are the item's key attributes, such as pk and sk
 type IDatabase[T interface{}] interface {
 	UpsertOne(ctx context.Context, m T) error
+	// UpsertOneIf writes the item when the condition holds for the item it replaces, returning a ConditionFailedError when it doesn't
+	UpsertOneIf(ctx context.Context, m T, condition Condition) error
 	GetItem(ctx context.Context, key map[string]types.AttributeValue) (T, error)
+	// BatchGet returns the items with the keys in the order of the keys, leaving out keys with no item
+	BatchGet(ctx context.Context, keys []map[string]types.AttributeValue) ([]T, error)
 	Query(ctx context.Context, key map[string]types.Condition, index string) ([]T, error)
 	QueryWithLimit(ctx context.Context, key map[string]types.Condition, startKey map[string]types.AttributeValue, index string, limit *int32) ([]T, map[string]types.AttributeValue, error)
+	// UpdateItem applies the update to the item with the key, creating it when there is none, and returns the item after it
+	UpdateItem(ctx context.Context, key map[string]types.AttributeValue, update Update) (T, error)
+	DeleteItem(ctx context.Context, key map[string]types.AttributeValue) error
 	BatchWrite(ctx context.Context, items []T) error
+	// BatchDelete deletes the items with the keys, failing with a BatchWriteError of the keys that weren't deleted
+	BatchDelete(ctx context.Context, keys []map[string]types.AttributeValue) error
+}
+
+// ITransaction collects writes across model types that are made all together or not at all. Conditions and updates
+// are the typed expressions of IDatabase, keys are an item's key attributes
+type ITransaction interface {
+	Put(m any) ITransaction
+	// PutIf puts the item when the condition holds for the item it replaces
+	PutIf(m any, condition Condition) ITransaction
+	Update(key map[string]types.AttributeValue, update Update) ITransaction
+	Delete(key map[string]types.AttributeValue) ITransaction
+	DeleteIf(key map[string]types.AttributeValue, condition Condition) ITransaction
+	// ConditionCheck fails the transaction unless the condition holds for the item with the key, without writing it
+	ConditionCheck(key map[string]types.AttributeValue, condition Condition) ITransaction
+	// Execute makes the writes, returning a TransactionCanceledError with an error per operation when dynamo cancels them
+	Execute(ctx context.Context) error
 }
@@ -0,0 +1,246 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strings"
+)
+
+// maxTransactItems is the most operations dynamo takes in one transaction
+const maxTransactItems = 100
+
+// cancellationReasonNone is the reason code of operations that didn't cause a transaction to be canceled
+const cancellationReasonNone = "None"
+
+// cancellationReasonConditionalCheckFailed is the reason code of operations whose condition didn't hold
+const cancellationReasonConditionalCheckFailed = "ConditionalCheckFailed"
+
+type TransactionOperation string
+
+const (
+	TransactionOperationPut            TransactionOperation = "put"
+	TransactionOperationUpdate         TransactionOperation = "update"
+	TransactionOperationDelete         TransactionOperation = "delete"
+	TransactionOperationConditionCheck TransactionOperation = "condition check"
+)
+
+// TransactionOperationError is why dynamo canceled a transaction for one of its operations
+type TransactionOperationError struct {
+	Index     int // of the operation in the order it was added
+	Operation TransactionOperation
+	Code      string                          // such as ConditionalCheckFailed, TransactionConflict or ValidationError
+	Message   string                          // dynamo's explanation, which can be empty
+	Item      map[string]types.AttributeValue // the item as it was when the operation's condition failed
+}
+
+func (e TransactionOperationError) Error() string {
+	msg := fmt.Sprintf("%s #%d failed with %s", e.Operation, e.Index, e.Code)
+	if e.Message == "" {
+		return msg
+	}
+
+	return msg + ": " + e.Message
+}
+
+// TransactionCanceledError is returned when dynamo cancels a transaction. Errors holds an error per operation in the
+// order they were added, nil for operations that didn't cause the cancellation. The error of an operation whose
+// condition failed is a ConditionFailedError wrapping its TransactionOperationError
+type TransactionCanceledError struct {
+	Errors []error
+	Err    error
+}
+
+func (e TransactionCanceledError) Error() string {
+	var msgs []string
+	for _, err := range e.Errors {
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+
+	if len(msgs) == 0 {
+		return "transaction canceled: " + e.Err.Error()
+	}
+
+	return "transaction canceled: " + strings.Join(msgs, ", ")
+}
+
+func (e TransactionCanceledError) Unwrap() error {
+	return e.Err
+}
+
+func NewTransaction(c IDatabaseClient, tn string, am func(in interface{}) (map[string]types.AttributeValue, error)) ITransaction {
+	return &Transaction{client: c, tableName: tn, attributeMarshaller: am}
+}
+
+var _ ITransaction = (*Transaction)(nil)
+
+// Transaction builds a TransactWriteItems call on one table. Items of any model type can be put in it, so a fetch
+// result can be stored together with the records it advances. The first operation that fails to build fails Execute
+type Transaction struct {
+	client              IDatabaseClient
+	tableName           string
+	attributeMarshaller func(in interface{}) (map[string]types.AttributeValue, error)
+	items               []types.TransactWriteItem
+	operations          []transactionOperation
+	err                 error
+}
+
+type transactionOperation struct {
+	kind      TransactionOperation
+	condition *Condition
+}
+
+func (tx *Transaction) Put(m any) ITransaction {
+	return tx.put(m, nil)
+}
+
+func (tx *Transaction) PutIf(m any, condition Condition) ITransaction {
+	return tx.put(m, &condition)
+}
+
+func (tx *Transaction) put(m any, condition *Condition) ITransaction {
+	item, err := tx.attributeMarshaller(m)
+	if err != nil {
+		return tx.fail(TransactionOperationPut, err)
+	}
+
+	put := types.Put{Item: item, TableName: aws.String(tx.tableName)}
+	if condition != nil {
+		e := expression{}
+		put.ConditionExpression = aws.String(condition.render(&e))
+		put.ExpressionAttributeNames = e.names
+		put.ExpressionAttributeValues = e.values
+		put.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+		if e.err != nil {
+			return tx.fail(TransactionOperationPut, e.err)
+		}
+	}
+
+	return tx.add(TransactionOperationPut, condition, types.TransactWriteItem{Put: &put})
+}
+
+func (tx *Transaction) Update(key map[string]types.AttributeValue, update Update) ITransaction {
+	e := expression{}
+	u := types.Update{Key: key, TableName: aws.String(tx.tableName), UpdateExpression: aws.String(update.render(&e))}
+	if update.condition != nil {
+		u.ConditionExpression = aws.String(update.condition.render(&e))
+		u.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+	}
+
+	if e.err != nil {
+		return tx.fail(TransactionOperationUpdate, e.err)
+	}
+
+	u.ExpressionAttributeNames = e.names
+	u.ExpressionAttributeValues = e.values
+
+	return tx.add(TransactionOperationUpdate, update.condition, types.TransactWriteItem{Update: &u})
+}
+
+func (tx *Transaction) Delete(key map[string]types.AttributeValue) ITransaction {
+	return tx.delete(key, nil)
+}
+
+func (tx *Transaction) DeleteIf(key map[string]types.AttributeValue, condition Condition) ITransaction {
+	return tx.delete(key, &condition)
+}
+
+func (tx *Transaction) delete(key map[string]types.AttributeValue, condition *Condition) ITransaction {
+	d := types.Delete{Key: key, TableName: aws.String(tx.tableName)}
+	if condition != nil {
+		e := expression{}
+		d.ConditionExpression = aws.String(condition.render(&e))
+		d.ExpressionAttributeNames = e.names
+		d.ExpressionAttributeValues = e.values
+		d.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+		if e.err != nil {
+			return tx.fail(TransactionOperationDelete, e.err)
+		}
+	}
+
+	return tx.add(TransactionOperationDelete, condition, types.TransactWriteItem{Delete: &d})
+}
+
+func (tx *Transaction) ConditionCheck(key map[string]types.AttributeValue, condition Condition) ITransaction {
+	e := expression{}
+	cc := types.ConditionCheck{
+		Key:                                 key,
+		TableName:                           aws.String(tx.tableName),
+		ConditionExpression:                 aws.String(condition.render(&e)),
+		ExpressionAttributeNames:            e.names,
+		ExpressionAttributeValues:           e.values,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+
+	if e.err != nil {
+		return tx.fail(TransactionOperationConditionCheck, e.err)
+	}
+
+	return tx.add(TransactionOperationConditionCheck, &condition, types.TransactWriteItem{ConditionCheck: &cc})
+}
+
+// Execute makes every write or none of them. A transaction with no operations does nothing
+func (tx *Transaction) Execute(ctx context.Context) error {
+	if tx.err != nil {
+		return tx.err
+	}
+
+	if len(tx.items) == 0 {
+		return nil
+	}
+
+	if len(tx.items) > maxTransactItems {
+		return fmt.Errorf("transaction has %d operations, dynamo takes at most %d", len(tx.items), maxTransactItems)
+	}
+
+	_, err := tx.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: tx.items})
+
+	var tce *types.TransactionCanceledException
+	if errors.As(err, &tce) {
+		return tx.canceled(tce)
+	}
+
+	return err
+}
+
+// canceled decodes the reasons dynamo gives for canceling a transaction, one per operation, into their errors
+func (tx *Transaction) canceled(tce *types.TransactionCanceledException) error {
+	errs := make([]error, len(tx.operations))
+	for i, r := range tce.CancellationReasons {
+		code := aws.ToString(r.Code)
+		if i >= len(errs) || code == "" || code == cancellationReasonNone {
+			continue
+		}
+
+		op := tx.operations[i]
+		ope := TransactionOperationError{Index: i, Operation: op.kind, Code: code, Message: aws.ToString(r.Message), Item: r.Item}
+		if code == cancellationReasonConditionalCheckFailed && op.condition != nil {
+			errs[i] = ConditionFailedError{Condition: op.condition.String(), Err: ope}
+			continue
+		}
+
+		errs[i] = ope
+	}
+
+	return TransactionCanceledError{Errors: errs, Err: tce}
+}
+
+func (tx *Transaction) add(kind TransactionOperation, condition *Condition, item types.TransactWriteItem) ITransaction {
+	tx.items = append(tx.items, item)
+	tx.operations = append(tx.operations, transactionOperation{kind: kind, condition: condition})
+
+	return tx
+}
+
+func (tx *Transaction) fail(kind TransactionOperation, err error) ITransaction {
+	if tx.err == nil {
+		tx.err = fmt.Errorf("failed to build %s #%d with error: %w", kind, len(tx.operations), err)
+	}
+
+	return tx
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/greenac/chaching/internal/database/mocks"
+	"github.com/greenac/chaching/internal/database/models"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testJob struct {
+	Pk      string `dynamodbav:"pk"`
+	Sk      string `dynamodbav:"sk"`
+	Status  string `dynamodbav:"status"`
+	Version int64  `dynamodbav:"version"`
+	Bars    int    `dynamodbav:"bars,omitempty"`
+}
+
+func TestTransaction(t *testing.T) {
+	Convey("TestTransaction", t, func() {
+		ctx := context.Background()
+		fake := mocks.NewDynamoFake(mocks.ChachingTable(models.DynamoConfig{MainTable: "Chaching"}))
+		jobs := NewDatabase[testJob](fake, 25, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap)
+		bars := NewDatabase[testItem](fake, 25, "Chaching", attributevalue.MarshalMap, attributevalue.UnmarshalMap)
+		So(jobs.UpsertOne(ctx, testJob{Pk: "job", Sk: "AAPL", Status: "running", Version: 1}), ShouldBeNil)
+		So(bars.UpsertOne(ctx, testItem{Pk: "AAPL", Sk: "stale"}), ShouldBeNil)
+
+		tx := func() ITransaction {
+			return NewTransaction(fake, "Chaching", attributevalue.MarshalMap)
+		}
+
+		Convey("TestTransaction should write items of every model type together", func() {
+			err := tx().
+				Put(testItem{Pk: "AAPL", Sk: "timeStamp#1", Value: 1}).
+				PutIf(testItem{Pk: "AAPL", Sk: "timeStamp#2", Value: 2}, AttributeNotExists("pk")).
+				Update(testKey("job", "AAPL"), Update{}.Set("status", "done").Add("bars", 2).Set("version", 2).If(VersionIs("version", 1))).
+				Delete(testKey("AAPL", "stale")).
+				ConditionCheck(testKey("lock", "AAPL"), AttributeNotExists("pk")).
+				Execute(ctx)
+			So(err, ShouldBeNil)
+
+			job, err := jobs.GetItem(ctx, testKey("job", "AAPL"))
+			So(err, ShouldBeNil)
+			So(job, ShouldResemble, testJob{Pk: "job", Sk: "AAPL", Status: "done", Version: 2, Bars: 2})
+			So(len(fake.Items("Chaching")), ShouldEqual, 3)
+			So(fake.Calls(mocks.OperationTransactWriteItems), ShouldEqual, 1)
+		})
+
+		Convey("TestTransaction should make no write and decode an error per operation when it is canceled", func() {
+			err := tx().
+				Put(testItem{Pk: "AAPL", Sk: "timeStamp#1", Value: 1}).
+				Update(testKey("job", "AAPL"), Update{}.Set("status", "done").If(VersionIs("version", 3))).
+				DeleteIf(testKey("AAPL", "stale"), AttributeExists("version")).
+				Execute(ctx)
+
+			var tce TransactionCanceledError
+			So(errors.As(err, &tce), ShouldBeTrue)
+			So(len(tce.Errors), ShouldEqual, 3)
+			So(tce.Errors[0], ShouldBeNil)
+
+			var cfe ConditionFailedError
+			So(errors.As(tce.Errors[1], &cfe), ShouldBeTrue)
+			So(cfe.Condition, ShouldEqual, "version = 3")
+
+			var ope TransactionOperationError
+			So(errors.As(tce.Errors[1], &ope), ShouldBeTrue)
+			So(ope.Operation, ShouldEqual, TransactionOperationUpdate)
+			So(ope.Item["status"], ShouldResemble, &types.AttributeValueMemberS{Value: "running"})
+			So(err.Error(), ShouldEqual, "transaction canceled: condition failed: version = 3, condition failed: attribute_exists(version)")
+
+			var canceled *types.TransactionCanceledException
+			So(errors.As(err, &canceled), ShouldBeTrue)
+
+			job, _ := jobs.GetItem(ctx, testKey("job", "AAPL"))
+			So(job.Status, ShouldEqual, "running")
+			So(len(fake.Items("Chaching")), ShouldEqual, 2)
+		})
+
+		Convey("TestTransaction should decode reasons other than a failed condition", func() {
+			fake.Errors = map[mocks.Operation][]error{mocks.OperationTransactWriteItems: {&types.TransactionCanceledException{
+				CancellationReasons: []types.CancellationReason{{Code: aws.String("None")}, {Code: aws.String("TransactionConflict"), Message: aws.String("Transaction is ongoing for the item")}},
+			}}}
+
+			err := tx().Put(testItem{Pk: "AAPL", Sk: "timeStamp#1"}).PutIf(testItem{Pk: "AAPL", Sk: "timeStamp#2"}, AttributeNotExists("pk")).Execute(ctx)
+			tce := err.(TransactionCanceledError)
+			So(tce.Errors[1], ShouldResemble, TransactionOperationError{Index: 1, Operation: TransactionOperationPut, Code: "TransactionConflict", Message: "Transaction is ongoing for the item"})
+			So(errors.As(tce.Errors[1], &ConditionFailedError{}), ShouldBeFalse)
+		})
+
+		Convey("TestTransaction should fail before calling dynamo when an operation doesn't build", func() {
+			err := tx().Put(testItem{}).ConditionCheck(testKey("job", "AAPL"), Equal("at", unmarshalable{})).Execute(ctx)
+			So(err.Error(), ShouldEqual, "failed to build condition check #1 with error: failed to marshal expression value: {} with error: not a value")
+			So(fake.Calls(mocks.OperationTransactWriteItems), ShouldEqual, 0)
+		})
+
+		Convey("TestTransaction should take at most 100 operations", func() {
+			t := tx()
+			for i := 0; i < 101; i += 1 {
+				t = t.Put(testItem{Pk: "AAPL", Sk: fmt.Sprintf("timeStamp#%d", i)})
+			}
+
+			So(t.Execute(ctx).Error(), ShouldEqual, "transaction has 101 operations, dynamo takes at most 100")
+			So(tx().Execute(ctx), ShouldBeNil)
+			So(fake.Calls(mocks.OperationTransactWriteItems), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,64 @@
+package fetch
+
+import (
+	"context"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/rest/limiter"
+	"github.com/greenac/chaching/internal/rest/models"
+	"github.com/greenac/chaching/internal/service/breaker"
+	"net/http"
+)
+
+func NewCircuitBreakerFetchService(fs IFetchService, cb *breaker.CircuitBreaker) *CircuitBreakerFetchService {
+	return &CircuitBreakerFetchService{FetchService: fs, Breaker: cb}
+}
+
+var _ IFetchService = (*CircuitBreakerFetchService)(nil)
+
+// CircuitBreakerFetchService fails fast with a breaker.OpenError while the wrapped service keeps failing
+type CircuitBreakerFetchService struct {
+	FetchService IFetchService
+	Breaker      *breaker.CircuitBreaker
+}
+
+func (cs *CircuitBreakerFetchService) Fetch(ctx context.Context, params models.UrlParams) ([]byte, genErr.IGenError) {
+	return cs.call(ctx, "CircuitBreakerFetchService:Fetch", func() ([]byte, genErr.IGenError) {
+		return cs.FetchService.Fetch(ctx, params)
+	})
+}
+
+func (cs *CircuitBreakerFetchService) FetchWithFetchData(ctx context.Context, fetchData IFetchData) ([]byte, genErr.IGenError) {
+	return cs.call(ctx, "CircuitBreakerFetchService:FetchWithFetchData", func() ([]byte, genErr.IGenError) {
+		return cs.FetchService.FetchWithFetchData(ctx, fetchData)
+	})
+}
+
+func (cs *CircuitBreakerFetchService) FetchUrl(ctx context.Context, url string) ([]byte, genErr.IGenError) {
+	return cs.call(ctx, "CircuitBreakerFetchService:FetchUrl", func() ([]byte, genErr.IGenError) {
+		return cs.FetchService.FetchUrl(ctx, url)
+	})
+}
+
+func (cs *CircuitBreakerFetchService) call(ctx context.Context, name string, f func() ([]byte, genErr.IGenError)) ([]byte, genErr.IGenError) {
+	if ge := cs.Breaker.Allow(); ge != nil {
+		return []byte{}, ge.AddMsg(name + ":failing fast")
+	}
+
+	b, ge := f()
+	if ge == nil {
+		cs.Breaker.RecordSuccess()
+		return b, nil
+	}
+
+	// running out of our own request budget or being cancelled says nothing about polygon's health, and a client
+	// error such as a 404 for a delisted ticker means polygon answered. Only transport errors, 5xx and 429 count
+	if se, ok := ge.(*StatusError); ok && se.StatusCode >= 400 && se.StatusCode < 500 && se.StatusCode != http.StatusTooManyRequests {
+		cs.Breaker.RecordSuccess()
+	} else if _, ok := ge.(*limiter.BudgetExhaustedError); ok || ctx.Err() != nil {
+		cs.Breaker.Release()
+	} else {
+		cs.Breaker.RecordFailure(ge.Error())
+	}
+
+	return b, ge
+}
@@ -0,0 +1,68 @@
+package fetch
+
+import (
+	"context"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/rest/mocks"
+	"github.com/greenac/chaching/internal/rest/models"
+	"github.com/greenac/chaching/internal/service/breaker"
+	"github.com/greenac/chaching/internal/utils"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCircuitBreakerFetchService(t *testing.T) {
+	Convey("TestCircuitBreakerFetchService", t, func() {
+		cb := breaker.NewCircuitBreaker(breaker.Config{FailureThreshold: 2, CoolDown: time.Minute})
+
+		Convey("TestCircuitBreakerFetchService should pass through successful fetches", func() {
+			body := []byte("body bytes")
+			c := mocks.ClientMock{GetResponse: models.Response{StatusCode: http.StatusOK, Body: body}}
+			fs := NewCircuitBreakerFetchService(NewFetchService("http://someurl.com", &c, utils.JoinUrl), cb)
+			b, err := fs.FetchUrl(context.Background(), "http://someurl.com/next")
+			So(err, ShouldBeNil)
+			So(b, ShouldResemble, body)
+			So(cb.State(), ShouldEqual, breaker.StateClosed)
+		})
+
+		Convey("TestCircuitBreakerFetchService should fail fast once the breaker opens", func() {
+			c := mocks.ClientMock{GetError: &genErr.GenError{Messages: []string{"bad bad thing"}}}
+			fs := NewCircuitBreakerFetchService(NewFetchService("http://someurl.com", &c, utils.JoinUrl), cb)
+			for i := 0; i < 2; i += 1 {
+				_, err := fs.Fetch(context.Background(), models.UrlParams{})
+				So(err, ShouldHaveSameTypeAs, &genErr.GenError{})
+			}
+
+			_, err := fs.Fetch(context.Background(), models.UrlParams{})
+			So(err, ShouldHaveSameTypeAs, &breaker.OpenError{})
+			So(len(c.GetUrls), ShouldEqual, 2)
+			So(cb.State(), ShouldEqual, breaker.StateOpen)
+		})
+
+		Convey("TestCircuitBreakerFetchService should stay closed on client errors for a ticker", func() {
+			c := mocks.ClientMock{GetResponse: models.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found"}}
+			fs := NewCircuitBreakerFetchService(NewFetchService("http://someurl.com", &c, utils.JoinUrl), cb)
+			for i := 0; i < 5; i += 1 {
+				_, err := fs.Fetch(context.Background(), models.UrlParams{})
+				So(err, ShouldHaveSameTypeAs, &StatusError{})
+			}
+
+			So(len(c.GetUrls), ShouldEqual, 5)
+			So(cb.State(), ShouldEqual, breaker.StateClosed)
+		})
+
+		Convey("TestCircuitBreakerFetchService should open on server errors and rate limits", func() {
+			c := mocks.ClientMock{GetResponses: []models.Response{{StatusCode: http.StatusTooManyRequests}, {StatusCode: http.StatusBadGateway}}}
+			fs := NewCircuitBreakerFetchService(NewFetchService("http://someurl.com", &c, utils.JoinUrl), cb)
+			for i := 0; i < 2; i += 1 {
+				_, err := fs.Fetch(context.Background(), models.UrlParams{})
+				So(err, ShouldHaveSameTypeAs, &StatusError{})
+			}
+
+			So(cb.State(), ShouldEqual, breaker.StateOpen)
+		})
+	})
+}
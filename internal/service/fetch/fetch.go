@@ -1,24 +1,44 @@
 package fetch
 
 import (
+	"context"
 	"fmt"
 	genErr "github.com/greenac/chaching/internal/error"
 	"github.com/greenac/chaching/internal/rest/models"
 	"github.com/greenac/chaching/internal/utils"
 	"net/http"
+	"strings"
 )
 
 type IFetchData interface {
 	Url() string
 }
 
+var _ genErr.IGenError = (*StatusError)(nil)
+
+// StatusError is a response polygon answered with a status other than success
+type StatusError struct {
+	StatusCode int
+	Messages   []string
+}
+
+func (e StatusError) Error() string {
+	return strings.Join(e.Messages, "->")
+}
+
+func (e StatusError) AddMsg(msg string) genErr.IGenError {
+	e.Messages = append(e.Messages, msg)
+	return &e
+}
+
 func NewFetchService(url string, rc models.IClient, joiner func(base string, add string) (result string, err genErr.IGenError)) *FetchService {
 	return &FetchService{Url: url, RestClient: rc, PathJoiner: joiner}
 }
 
 type IFetchService interface {
-	Fetch(params models.UrlParams) ([]byte, genErr.IGenError)
-	FetchWithFetchData(fetchData IFetchData) ([]byte, genErr.IGenError)
+	Fetch(ctx context.Context, params models.UrlParams) ([]byte, genErr.IGenError)
+	FetchWithFetchData(ctx context.Context, fetchData IFetchData) ([]byte, genErr.IGenError)
+	FetchUrl(ctx context.Context, url string) ([]byte, genErr.IGenError)
 }
 
 var _ IFetchService = (*FetchService)(nil)
@@ -29,8 +49,8 @@ type FetchService struct {
 	PathJoiner func(base string, add string) (string, genErr.IGenError)
 }
 
-func (fc *FetchService) Fetch(params models.UrlParams) ([]byte, genErr.IGenError) {
-	resp, err := fc.RestClient.Get(fc.Url, nil, params)
+func (fc *FetchService) Fetch(ctx context.Context, params models.UrlParams) ([]byte, genErr.IGenError) {
+	resp, err := fc.RestClient.Get(ctx, fc.Url, nil, params)
 	if err != nil {
 		return []byte{}, err.AddMsg("FetchService:Fetch:failed to get")
 	}
@@ -38,13 +58,13 @@ func (fc *FetchService) Fetch(params models.UrlParams) ([]byte, genErr.IGenError
 	return fc.handleResponse(resp)
 }
 
-func (fc *FetchService) FetchWithFetchData(fetchData IFetchData) ([]byte, genErr.IGenError) {
+func (fc *FetchService) FetchWithFetchData(ctx context.Context, fetchData IFetchData) ([]byte, genErr.IGenError) {
 	uri, ge := fc.PathJoiner(fc.Url, fetchData.Url())
 	if ge != nil {
 		return []byte{}, ge.AddMsg("FetchService:FetchWithFetchData failed to join urls: " + fc.Url + " and " + fetchData.Url())
 	}
 
-	resp, ge := fc.RestClient.Get(uri, nil, models.UrlParams{})
+	resp, ge := fc.RestClient.Get(ctx, uri, nil, models.UrlParams{})
 	if ge != nil {
 		return []byte{}, ge.AddMsg("FetchService:Fetch:failed to get")
 	}
@@ -52,10 +72,20 @@ func (fc *FetchService) FetchWithFetchData(fetchData IFetchData) ([]byte, genErr
 	return fc.handleResponse(resp)
 }
 
+// FetchUrl fetches an absolute url, such as the `next_url` cursor polygon returns for paginated results
+func (fc *FetchService) FetchUrl(ctx context.Context, url string) ([]byte, genErr.IGenError) {
+	resp, ge := fc.RestClient.Get(ctx, url, nil, models.UrlParams{})
+	if ge != nil {
+		return []byte{}, ge.AddMsg("FetchService:FetchUrl:failed to get: " + url)
+	}
+
+	return fc.handleResponse(resp)
+}
+
 func (fc *FetchService) handleResponse(resp models.Response) ([]byte, genErr.IGenError) {
 	if !utils.SliceContains([]int{http.StatusOK, http.StatusCreated, http.StatusAccepted}, resp.StatusCode) {
-		ge := genErr.GenError{}
-		return []byte{}, ge.AddMsg(fmt.Sprintf("FetchService:handleResponse failed with code: %d and status %s for url: %s", resp.StatusCode, resp.Status, fc.Url))
+		se := StatusError{StatusCode: resp.StatusCode}
+		return []byte{}, se.AddMsg(fmt.Sprintf("FetchService:handleResponse failed with code: %d and status %s for url: %s", resp.StatusCode, resp.Status, fc.Url))
 	}
 
 	return resp.Body, nil
@@ -1,6 +1,7 @@
 package fetch
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	genErr "github.com/greenac/chaching/internal/error"
@@ -32,17 +33,17 @@ func TestFetchService_Fetch(t *testing.T) {
 			body := []byte("body bytes")
 			c := mocks.ClientMock{GetResponse: models.Response{Status: "", StatusCode: http.StatusOK, Body: body}}
 			fs := NewFetchService(uri, &c, utils.JoinUrl)
-			b, err := fs.Fetch(models.UrlParams{})
+			b, err := fs.Fetch(context.Background(), models.UrlParams{})
 			So(err, ShouldBeNil)
 			So(b, ShouldResemble, body)
 		})
 
 		Convey("TestFetchService_Fetch should fail with get error", func() {
-			ge := &genErr.GenError{Messages: []string{"FetchService:Fetch:failed to get"}}
+			ge := &genErr.GenError{Messages: []string{"bad bad thing"}}
 			c := mocks.ClientMock{GetError: ge}
 			fs := NewFetchService("http://someurl.com", &c, utils.JoinUrl)
-			_, err := fs.Fetch(models.UrlParams{})
-			So(err, ShouldResemble, ge)
+			_, err := fs.Fetch(context.Background(), models.UrlParams{})
+			So(err, ShouldResemble, &genErr.GenError{Messages: []string{"bad bad thing", "FetchService:Fetch:failed to get"}})
 		})
 	})
 }
@@ -52,7 +53,7 @@ func TestFetchService_FetchWithFetchData(t *testing.T) {
 		from := time.Now()
 		to := from.Add(30 * time.Second)
 		fd := polygonModels.PolygonAggregateRequestParams{
-			Name:          "rabbits",
+			CompanyName:   "rabbits",
 			Multiplier:    1,
 			Timespan:      polygonModels.PolygonAggregateTimespanMinute,
 			From:          from,
@@ -66,7 +67,7 @@ func TestFetchService_FetchWithFetchData(t *testing.T) {
 			body := []byte("body bytes")
 			c := mocks.ClientMock{GetResponse: models.Response{Status: "", StatusCode: http.StatusOK, Body: body}}
 			fs := NewFetchService(uri, &c, utils.JoinUrl)
-			b, err := fs.FetchWithFetchData(fd)
+			b, err := fs.FetchWithFetchData(context.Background(), fd)
 			So(err, ShouldBeNil)
 			So(b, ShouldResemble, body)
 		})
@@ -80,7 +81,7 @@ func TestFetchService_FetchWithFetchData(t *testing.T) {
 			fs := NewFetchService(uri, &c, func(base string, add string) (result string, ge genErr.IGenError) {
 				return "", &e
 			})
-			_, err := fs.FetchWithFetchData(fd)
+			_, err := fs.FetchWithFetchData(context.Background(), fd)
 			So(err, ShouldResemble, &genErr.GenError{Messages: []string{"bad bad thing", "FetchService:FetchWithFetchData failed to join urls: http://someurl.com and " + expUrl}})
 		})
 
@@ -89,11 +90,35 @@ func TestFetchService_FetchWithFetchData(t *testing.T) {
 			uri := "http://someurl.com"
 			c := mocks.ClientMock{GetError: &genErr.GenError{Messages: []string{e.Error()}}}
 			fs := NewFetchService(uri, &c, utils.JoinUrl)
-			_, err := fs.FetchWithFetchData(fd)
+			_, err := fs.FetchWithFetchData(context.Background(), fd)
 			So(err, ShouldResemble, &genErr.GenError{Messages: []string{e.Error(), "FetchService:Fetch:failed to get"}})
 		})
 	})
 }
+
+func TestFetchService_FetchUrl(t *testing.T) {
+	Convey("TestFetchService_FetchUrl", t, func() {
+		next := "https://api.polygon.io/v2/aggs/ticker/rabbits/range/1/minute/1/2?cursor=abc"
+
+		Convey("TestFetchService_FetchUrl should fetch the absolute url", func() {
+			body := []byte("body bytes")
+			c := mocks.ClientMock{GetResponse: models.Response{StatusCode: http.StatusOK, Body: body}}
+			fs := NewFetchService("http://someurl.com", &c, utils.JoinUrl)
+			b, err := fs.FetchUrl(context.Background(), next)
+			So(err, ShouldBeNil)
+			So(b, ShouldResemble, body)
+			So(c.GetUrls, ShouldResemble, []string{next})
+		})
+
+		Convey("TestFetchService_FetchUrl should fail when client gets", func() {
+			c := mocks.ClientMock{GetError: &genErr.GenError{Messages: []string{"bad bad thing"}}}
+			fs := NewFetchService("http://someurl.com", &c, utils.JoinUrl)
+			_, err := fs.FetchUrl(context.Background(), next)
+			So(err, ShouldResemble, &genErr.GenError{Messages: []string{"bad bad thing", "FetchService:FetchUrl:failed to get: " + next}})
+		})
+	})
+}
+
 func TestFetchService_handleResponse(t *testing.T) {
 	Convey("TestFetchService_handleResponse", t, func() {
 		Convey("TestFetchService_handleResponse should succeed", func() {
@@ -112,7 +137,7 @@ func TestFetchService_handleResponse(t *testing.T) {
 			So(
 				err,
 				ShouldResemble,
-				&genErr.GenError{Messages: []string{fmt.Sprintf("FetchService:handleResponse failed with code: %d and status %s for url: %s", http.StatusBadRequest, "bad", uri)}},
+				&StatusError{StatusCode: http.StatusBadRequest, Messages: []string{fmt.Sprintf("FetchService:handleResponse failed with code: %d and status %s for url: %s", http.StatusBadRequest, "bad", uri)}},
 			)
 		})
 	})
@@ -0,0 +1,112 @@
+package gaps
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/greenac/chaching/internal/calendar"
+	"github.com/greenac/chaching/internal/database/models"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// Gap is a run of consecutive missing bars. From and To are the start times of the first and last missing bar,
+// which is the inclusive range polygon's aggregates endpoint expects
+type Gap struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	Bars int       `json:"bars"`
+}
+
+type DayGaps struct {
+	CompanyName string `json:"companyName"`
+	Date        string `json:"date"`
+	Expected    int    `json:"expected"`
+	Found       int    `json:"found"`
+	Empty       int    `json:"empty"` // bars polygon was asked for and had none, such as minutes without trades
+	Gaps        []Gap  `json:"gaps"`
+}
+
+func (dg DayGaps) Missing() int {
+	return dg.Expected - dg.Found - dg.Empty
+}
+
+// FindGaps compares the bars stored for a session with the bars a session of that length should have, one starting
+// every step until the close. Polygon starts bars on step boundaries counted from midnight, so the first expected bar
+// is the one covering the open, 09:00 for hour bars. Polygon has no bar for a minute without trades, so bars starting
+// at or before fetchedThrough, the ticker's watermark, were fetched and found empty. They are counted as empty rather
+// than reported as gaps
+func FindGaps(companyName string, session calendar.Session, step time.Duration, dps []models.DataPoint, fetchedThrough time.Time) DayGaps {
+	found := map[int64]bool{}
+	for _, dp := range dps {
+		found[dp.StartTime] = true
+	}
+
+	dg := DayGaps{CompanyName: companyName, Date: session.Date.Format(dateLayout), Gaps: []Gap{}}
+	var gap *Gap
+	for t := FirstBar(session, step); t.Before(session.Close); t = t.Add(step) {
+		dg.Expected += 1
+		if found[t.UnixMilli()] {
+			dg.Found += 1
+			gap = nil
+			continue
+		}
+
+		if !t.After(fetchedThrough) {
+			dg.Empty += 1
+			gap = nil
+			continue
+		}
+
+		if gap == nil {
+			dg.Gaps = append(dg.Gaps, Gap{From: t})
+			gap = &dg.Gaps[len(dg.Gaps)-1]
+		}
+
+		gap.To = t
+		gap.Bars += 1
+	}
+
+	return dg
+}
+
+// FirstBar is the start of the bar covering the session's open
+func FirstBar(session calendar.Session, step time.Duration) time.Time {
+	if step <= 0 {
+		return session.Open
+	}
+
+	return session.Date.Add(session.Open.Sub(session.Date) / step * step)
+}
+
+func WriteJSON(w io.Writer, days []DayGaps) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(days)
+}
+
+func WriteTable(w io.Writer, days []DayGaps) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, err := fmt.Fprintln(tw, "TICKER\tDATE\tEXPECTED\tFOUND\tEMPTY\tMISSING\tGAPS")
+	if err != nil {
+		return err
+	}
+
+	for _, dg := range days {
+		ranges := make([]string, len(dg.Gaps))
+		for i, g := range dg.Gaps {
+			ranges[i] = fmt.Sprintf("%s-%s", g.From.Format("15:04"), g.To.Format("15:04"))
+		}
+
+		_, err = fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n", dg.CompanyName, dg.Date, dg.Expected, dg.Found, dg.Empty, dg.Missing(), strings.Join(ranges, " "))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,88 @@
+package gaps
+
+import (
+	"bytes"
+	"github.com/greenac/chaching/internal/calendar"
+	"github.com/greenac/chaching/internal/database/models"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFindGaps(t *testing.T) {
+	Convey("TestFindGaps", t, func() {
+		cal := calendar.NewNyseCalendar()
+		session, _ := cal.Session(time.Date(2023, 11, 24, 0, 0, 0, 0, cal.Location()))
+
+		var dps []models.DataPoint
+		for t := session.Open; t.Before(session.Close); t = t.Add(time.Minute) {
+			if t.Equal(session.Open.Add(time.Minute)) || t.Equal(session.Open.Add(2*time.Minute)) || t.Equal(session.Close.Add(-time.Minute)) {
+				continue
+			}
+			dps = append(dps, models.DataPoint{CompanyName: "AAPL", PolygonDataPoint: model.PolygonDataPoint{StartTime: t.UnixMilli()}})
+		}
+
+		Convey("TestFindGaps should merge consecutive missing bars up to the early close", func() {
+			dg := FindGaps("AAPL", session, time.Minute, dps, time.Time{})
+			So(dg.Date, ShouldEqual, "2023-11-24")
+			So(dg.Expected, ShouldEqual, 210)
+			So(dg.Found, ShouldEqual, 207)
+			So(dg.Missing(), ShouldEqual, 3)
+			So(dg.Gaps, ShouldResemble, []Gap{
+				{From: session.Open.Add(time.Minute), To: session.Open.Add(2 * time.Minute), Bars: 2},
+				{From: session.Close.Add(-time.Minute), To: session.Close.Add(-time.Minute), Bars: 1},
+			})
+		})
+
+		Convey("TestFindGaps should report the whole session when nothing is stored", func() {
+			dg := FindGaps("AAPL", session, 30*time.Minute, nil, time.Time{})
+			So(dg.Expected, ShouldEqual, 7)
+			So(dg.Gaps, ShouldResemble, []Gap{{From: session.Open, To: session.Open.Add(3 * time.Hour), Bars: 7}})
+		})
+
+		Convey("TestFindGaps should expect hour bars on the hour from the bar covering the open", func() {
+			var hours []models.DataPoint
+			for h := 9; h < 13; h++ {
+				if h == 11 {
+					continue
+				}
+				hours = append(hours, models.DataPoint{CompanyName: "AAPL", PolygonDataPoint: model.PolygonDataPoint{StartTime: time.Date(2023, 11, 24, h, 0, 0, 0, cal.Location()).UnixMilli()}})
+			}
+
+			eleven := time.Date(2023, 11, 24, 11, 0, 0, 0, cal.Location())
+			dg := FindGaps("AAPL", session, time.Hour, hours, time.Time{})
+			So(dg.Expected, ShouldEqual, 4)
+			So(dg.Found, ShouldEqual, 3)
+			So(dg.Gaps, ShouldResemble, []Gap{{From: eleven, To: eleven, Bars: 1}})
+		})
+
+		Convey("TestFindGaps should count bars up to the watermark as empty", func() {
+			dg := FindGaps("AAPL", session, time.Minute, dps, session.Open.Add(time.Minute))
+			So(dg.Found, ShouldEqual, 207)
+			So(dg.Empty, ShouldEqual, 1)
+			So(dg.Missing(), ShouldEqual, 2)
+			So(dg.Gaps, ShouldResemble, []Gap{
+				{From: session.Open.Add(2 * time.Minute), To: session.Open.Add(2 * time.Minute), Bars: 1},
+				{From: session.Close.Add(-time.Minute), To: session.Close.Add(-time.Minute), Bars: 1},
+			})
+
+			dg = FindGaps("AAPL", session, time.Minute, dps, session.Close)
+			So(dg.Empty, ShouldEqual, 3)
+			So(dg.Gaps, ShouldBeEmpty)
+		})
+
+		Convey("TestFindGaps should write a table and json", func() {
+			days := []DayGaps{FindGaps("AAPL", session, time.Minute, dps, time.Time{})}
+
+			b := &bytes.Buffer{}
+			So(WriteTable(b, days), ShouldBeNil)
+			So(b.String(), ShouldEqual, "TICKER  DATE        EXPECTED  FOUND  EMPTY  MISSING  GAPS\nAAPL    2023-11-24  210       207    0      3        09:31-09:32 12:59-12:59\n")
+
+			b.Reset()
+			So(WriteJSON(b, days), ShouldBeNil)
+			So(b.String(), ShouldContainSubstring, `"from": "2023-11-24T09:31:00-05:00"`)
+		})
+	})
+}
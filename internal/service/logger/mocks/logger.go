@@ -0,0 +1,56 @@
+package mocks
+
+import (
+	"fmt"
+	"github.com/greenac/chaching/internal/service/logger"
+	"sync"
+)
+
+var _ logger.ILogger = (*LoggerMock)(nil)
+
+type LoggerMock struct {
+	mu       sync.Mutex
+	Messages []string
+}
+
+func (l *LoggerMock) Info(msg string) {
+	l.add(msg)
+}
+
+func (l *LoggerMock) InfoFmt(msg string, args ...any) {
+	l.add(fmt.Sprintf(msg, args...))
+}
+
+func (l *LoggerMock) Warn(msg string) {
+	l.add(msg)
+}
+
+func (l *LoggerMock) WarnFmt(msg string, args ...any) {
+	l.add(fmt.Sprintf(msg, args...))
+}
+
+func (l *LoggerMock) Error(msg string) {
+	l.add(msg)
+}
+
+func (l *LoggerMock) ErrorFmt(msg string, args ...any) {
+	l.add(fmt.Sprintf(msg, args...))
+}
+
+func (l *LoggerMock) Debug(msg string) {
+	l.add(msg)
+}
+
+func (l *LoggerMock) DebugFmt(msg string, args ...any) {
+	l.add(fmt.Sprintf(msg, args...))
+}
+
+func (l *LoggerMock) SubLogger(props map[string]string) logger.ILogger {
+	return l
+}
+
+func (l *LoggerMock) add(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Messages = append(l.Messages, msg)
+}
@@ -0,0 +1,230 @@
+package provider
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type csvColumn string
+
+const (
+	csvColumnTime         csvColumn = "time"
+	csvColumnOpen         csvColumn = "open"
+	csvColumnHigh         csvColumn = "high"
+	csvColumnLow          csvColumn = "low"
+	csvColumnClose        csvColumn = "close"
+	csvColumnVolume       csvColumn = "volume"
+	csvColumnVwap         csvColumn = "vwap"
+	csvColumnTransactions csvColumn = "transactions"
+)
+
+// csvHeaders maps the header names found in vendor exports, lower cased, to the column they hold
+var csvHeaders = map[string]csvColumn{
+	"time": csvColumnTime, "timestamp": csvColumnTime, "date": csvColumnTime, "datetime": csvColumnTime, "t": csvColumnTime,
+	"open": csvColumnOpen, "o": csvColumnOpen,
+	"high": csvColumnHigh, "h": csvColumnHigh,
+	"low": csvColumnLow, "l": csvColumnLow,
+	"close": csvColumnClose, "c": csvColumnClose, "adj close": csvColumnClose,
+	"volume": csvColumnVolume, "v": csvColumnVolume,
+	"vwap": csvColumnVwap, "vw": csvColumnVwap,
+	"transactions": csvColumnTransactions, "trades": csvColumnTransactions, "n": csvColumnTransactions,
+}
+
+var requiredCsvColumns = []csvColumn{csvColumnTime, csvColumnOpen, csvColumnHigh, csvColumnLow, csvColumnClose, csvColumnVolume}
+
+var csvTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+var _ IProvider = (*CsvProvider)(nil)
+
+// NewCsvProvider creates a provider that reads each ticker's bars from `<TICKER>.csv` in files. Times without
+// a zone are read in loc, the exchange's time zone
+func NewCsvProvider(files fs.FS, loc *time.Location) IProvider {
+	return &CsvProvider{files: files, location: loc}
+}
+
+// CsvProvider reads OHLCV exports or archives. A file holds a single series with a header row naming its
+// columns, which must include time, open, high, low, close and volume, and may include vwap and transactions.
+// Times are unix seconds, millis or nanos, RFC3339, or a date and time. The file is trusted to hold bars of the
+// requested timespan, since it carries no timespan of its own
+type CsvProvider struct {
+	files    fs.FS
+	location *time.Location
+}
+
+func (cp *CsvProvider) Name() ProviderName {
+	return ProviderNameCsv
+}
+
+func (cp *CsvProvider) Bars(ctx context.Context, req BarRequest) ([]models.DataPoint, int, genErr.IGenError) {
+	path := CsvPath(req.CompanyName)
+	f, err := cp.files.Open(path)
+	if err != nil {
+		return []models.DataPoint{}, 0, &genErr.GenError{Messages: []string{"CsvProvider:Bars:failed to open: " + path + " with error: " + err.Error()}}
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return []models.DataPoint{}, 0, &genErr.GenError{Messages: []string{"CsvProvider:Bars:failed to read header of: " + path + " with error: " + err.Error()}}
+	}
+
+	columns, ge := csvColumns(header)
+	if ge != nil {
+		return []models.DataPoint{}, 0, ge.AddMsg("CsvProvider:Bars:invalid header in: " + path)
+	}
+
+	from, to := cp.bounds(req)
+
+	var dps []models.DataPoint
+	for line := 2; ; line++ {
+		if ctx.Err() != nil {
+			return []models.DataPoint{}, 0, &genErr.GenError{Messages: []string{"CsvProvider:Bars:cancelled reading: " + path + " with: " + ctx.Err().Error()}}
+		}
+
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return []models.DataPoint{}, 0, &genErr.GenError{Messages: []string{"CsvProvider:Bars:failed to read: " + path + " with error: " + err.Error()}}
+		}
+
+		dp, ge := cp.dataPoint(record, columns)
+		if ge != nil {
+			return []models.DataPoint{}, 0, ge.AddMsg(fmt.Sprintf("CsvProvider:Bars:invalid bar on line: %d of: %s", line, path))
+		}
+
+		if dp.StartTime < from || dp.StartTime > to {
+			continue
+		}
+
+		dps = append(dps, req.dataPoint(dp))
+	}
+
+	sort.SliceStable(dps, func(i, j int) bool { return dps[i].StartTime < dps[j].StartTime })
+
+	return dps, 1, nil
+}
+
+// bounds is the request's range in unix millis. Bars of a day or longer start at midnight, so their range
+// covers the whole days of From and To
+func (cp *CsvProvider) bounds(req BarRequest) (int64, int64) {
+	switch req.Timespan {
+	case "", model.PolygonAggregateTimespanSecond, model.PolygonAggregateTimespanMinute, model.PolygonAggregateTimespanHour:
+		return req.From.UnixMilli(), req.To.UnixMilli()
+	}
+
+	from := req.From.In(cp.location)
+	to := req.To.In(cp.location)
+
+	return time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, cp.location).UnixMilli(),
+		time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, cp.location).AddDate(0, 0, 1).UnixMilli() - 1
+}
+
+// CsvPath is the file in a CsvProvider's files that holds companyName's bars
+func CsvPath(companyName string) string {
+	return strings.ToUpper(companyName) + ".csv"
+}
+
+func csvColumns(header []string) (map[csvColumn]int, genErr.IGenError) {
+	columns := map[csvColumn]int{}
+	for i, h := range header {
+		if c, ok := csvHeaders[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))]; ok {
+			if _, seen := columns[c]; !seen {
+				columns[c] = i
+			}
+		}
+	}
+
+	var missing []string
+	for _, c := range requiredCsvColumns {
+		if _, ok := columns[c]; !ok {
+			missing = append(missing, string(c))
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, &genErr.GenError{Messages: []string{"csvColumns:missing columns: " + strings.Join(missing, ",")}}
+	}
+
+	return columns, nil
+}
+
+func (cp *CsvProvider) dataPoint(record []string, columns map[csvColumn]int) (model.PolygonDataPoint, genErr.IGenError) {
+	value := func(c csvColumn) string {
+		i, ok := columns[c]
+		if !ok || i >= len(record) {
+			return ""
+		}
+
+		return strings.TrimSpace(record[i])
+	}
+
+	startTime, ge := parseCsvTime(value(csvColumnTime), cp.location)
+	if ge != nil {
+		return model.PolygonDataPoint{}, ge
+	}
+
+	prices := map[csvColumn]float64{}
+	for _, c := range []csvColumn{csvColumnOpen, csvColumnHigh, csvColumnLow, csvColumnClose, csvColumnVolume, csvColumnVwap, csvColumnTransactions} {
+		v := value(c)
+		if v == "" {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return model.PolygonDataPoint{}, &genErr.GenError{Messages: []string{"dataPoint:invalid " + string(c) + ": " + v}}
+		}
+
+		prices[c] = f
+	}
+
+	return model.PolygonDataPoint{
+		StartTime:           startTime,
+		OpenPrice:           prices[csvColumnOpen],
+		HighestPrice:        prices[csvColumnHigh],
+		LowestPrice:         prices[csvColumnLow],
+		ClosePrice:          prices[csvColumnClose],
+		Volume:              prices[csvColumnVolume],
+		VolumeWeightedPrice: prices[csvColumnVwap],
+		NumOfTxs:            int(prices[csvColumnTransactions]),
+	}, nil
+}
+
+// parseCsvTime returns the unix millis of value, reading integers as seconds, millis or nanos by their size
+func parseCsvTime(value string, loc *time.Location) (int64, genErr.IGenError) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		switch {
+		case n >= 1e17:
+			return n / int64(time.Millisecond), nil
+		case n >= 1e11:
+			return n, nil
+		default:
+			return n * 1000, nil
+		}
+	}
+
+	for _, layout := range csvTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.UnixMilli(), nil
+		}
+	}
+
+	return 0, &genErr.GenError{Messages: []string{"parseCsvTime:unrecognized time: " + value}}
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+	"github.com/greenac/chaching/internal/database/models"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCsvProvider_Bars(t *testing.T) {
+	Convey("TestCsvProvider_Bars", t, func() {
+		ny, err := time.LoadLocation("America/New_York")
+		So(err, ShouldBeNil)
+
+		files := fstest.MapFS{
+			"AAPL.csv": {Data: []byte("\ufeffTimestamp,Open,High,Low,Close,Volume,VWAP,Trades\n" +
+				"2023-03-01 09:31:00,147.1,147.5,147.0,147.4,1200,147.3,15\n" +
+				"2023-03-01 09:30:00,147.0,147.2,146.9,147.1,1000,147.05,12\n" +
+				"2023-03-01 09:32:00,147.4,147.6,147.3,147.5,900,147.45,9\n" +
+				"2023-03-01 09:40:00,148.0,148.0,148.0,148.0,1,,\n")},
+			"MSFT.csv": {Data: []byte("date,open,high,low,close,volume\n2023-03-01,250,255,249,253,100\n2023-03-02,253,254,250,251,90\n1677870000,251,252,250,251,80\n")},
+			"BAD.csv":  {Data: []byte("time,open,high,low,close\n")},
+			"NAN.csv":  {Data: []byte("time,open,high,low,close,volume\n2023-03-01 09:30:00,abc,1,1,1,1\n")},
+		}
+		cp := NewCsvProvider(files, ny)
+		from := time.Date(2023, 3, 1, 9, 30, 0, 0, ny)
+
+		Convey("TestCsvProvider_Bars should return the bars in range sorted by start time", func() {
+			dps, pages, ge := cp.Bars(context.Background(), BarRequest{CompanyName: "aapl", Timespan: model.PolygonAggregateTimespanMinute, Multiplier: 1, From: from, To: from.Add(2 * time.Minute)})
+			So(ge, ShouldBeNil)
+			So(pages, ShouldEqual, 1)
+			So(len(dps), ShouldEqual, 3)
+			So(dps[0], ShouldResemble, models.DataPoint{
+				CompanyName: "aapl",
+				Timespan:    model.PolygonAggregateTimespanMinute,
+				PolygonDataPoint: model.PolygonDataPoint{
+					StartTime:           from.UnixMilli(),
+					OpenPrice:           147.0,
+					HighestPrice:        147.2,
+					LowestPrice:         146.9,
+					ClosePrice:          147.1,
+					Volume:              1000,
+					VolumeWeightedPrice: 147.05,
+					NumOfTxs:            12,
+				},
+			})
+			So(dps[2].StartTime, ShouldEqual, from.Add(2*time.Minute).UnixMilli())
+		})
+
+		Convey("TestCsvProvider_Bars should read day bars across the whole days of the range", func() {
+			dps, _, ge := cp.Bars(context.Background(), BarRequest{CompanyName: "MSFT", Timespan: model.PolygonAggregateTimespanDay, Multiplier: 1, From: from, To: from.Add(30 * time.Hour), Adjusted: true})
+			So(ge, ShouldBeNil)
+			So(len(dps), ShouldEqual, 2)
+			So(dps[0].StartTime, ShouldEqual, time.Date(2023, 3, 1, 0, 0, 0, 0, ny).UnixMilli())
+			So(dps[1].StartTime, ShouldEqual, time.Date(2023, 3, 2, 0, 0, 0, 0, ny).UnixMilli())
+			So(dps[1].Adjusted, ShouldBeTrue)
+		})
+
+		Convey("TestCsvProvider_Bars should read unix seconds", func() {
+			dps, _, ge := cp.Bars(context.Background(), BarRequest{CompanyName: "MSFT", Timespan: model.PolygonAggregateTimespanMinute, From: time.Unix(1677870000, 0), To: time.Unix(1677870000, 0)})
+			So(ge, ShouldBeNil)
+			So(len(dps), ShouldEqual, 1)
+			So(dps[0].OpenPrice, ShouldEqual, 251)
+		})
+
+		Convey("TestCsvProvider_Bars should fail for missing files, columns and bad values", func() {
+			_, _, ge := cp.Bars(context.Background(), BarRequest{CompanyName: "AMZN", From: from, To: from})
+			So(ge, ShouldNotBeNil)
+			So(ge.Error(), ShouldStartWith, "CsvProvider:Bars:failed to open: AMZN.csv")
+
+			_, _, ge = cp.Bars(context.Background(), BarRequest{CompanyName: "BAD", From: from, To: from})
+			So(ge.Error(), ShouldEqual, "csvColumns:missing columns: volume->CsvProvider:Bars:invalid header in: BAD.csv")
+
+			_, _, ge = cp.Bars(context.Background(), BarRequest{CompanyName: "NAN", From: from, To: from})
+			So(ge.Error(), ShouldEqual, "dataPoint:invalid open: abc->CsvProvider:Bars:invalid bar on line: 2 of: NAN.csv")
+		})
+	})
+}
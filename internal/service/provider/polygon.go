@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/fetch"
+	"strings"
+	"time"
+)
+
+var _ IProvider = (*PolygonProvider)(nil)
+
+// NewPolygonProvider creates a provider for polygon's aggregates, fs must point at the `/v2/aggs` base url
+func NewPolygonProvider(fs fetch.IFetchService, unmarshaler func(data []byte, v any) error) IProvider {
+	return &PolygonProvider{fetchService: fs, unmarshaler: unmarshaler}
+}
+
+type PolygonProvider struct {
+	fetchService fetch.IFetchService
+	unmarshaler  func(data []byte, v any) error
+}
+
+func (pp *PolygonProvider) Name() ProviderName {
+	return ProviderNamePolygon
+}
+
+// Bars fetches the aggregates for a single ticker, following polygon's `next_url` cursor until the range is exhausted
+func (pp *PolygonProvider) Bars(ctx context.Context, req BarRequest) ([]models.DataPoint, int, genErr.IGenError) {
+	rps := model.PolygonAggregateRequestParams{
+		CompanyName:   req.CompanyName,
+		Multiplier:    req.Multiplier,
+		Timespan:      req.Timespan,
+		From:          req.From,
+		To:            req.To,
+		SortDirection: model.PolygonAggregateSortDirectionAsc,
+		Limit:         req.Limit,
+		Adjusted:      req.Adjusted,
+	}
+
+	body, ge := pp.fetchService.FetchWithFetchData(ctx, rps)
+	if ge != nil {
+		return []models.DataPoint{}, 0, ge
+	}
+
+	var dps []models.DataPoint
+	pages := 0
+	for {
+		pr := model.PolygonAggregateResponse{}
+		err := pp.unmarshaler(body, &pr)
+		if err != nil {
+			return []models.DataPoint{}, pages, &genErr.GenError{Messages: []string{"PolygonProvider:Bars:failed to unmarshall with err: " + err.Error() + " for: " + req.CompanyName}}
+		}
+
+		if strings.ToLower(pr.Status) != "ok" {
+			return []models.DataPoint{}, pages, &genErr.GenError{Messages: []string{"PolygonProvider:Bars:failed for: " + req.CompanyName + " with status: " + pr.Status + " at time: " + req.From.Format(time.RFC3339)}}
+		}
+
+		pages += 1
+		for _, dp := range pr.DataPoints {
+			dps = append(dps, req.dataPoint(dp))
+		}
+
+		if !pr.HasNextPage() {
+			break
+		}
+
+		body, ge = pp.fetchService.FetchUrl(ctx, pr.NextUrl)
+		if ge != nil {
+			return []models.DataPoint{}, pages, ge.AddMsg(fmt.Sprintf("PolygonProvider:Bars:failed to fetch page: %d for: %s", pages+1, req.CompanyName))
+		}
+	}
+
+	return dps, pages, nil
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"strings"
+	"time"
+)
+
+type ProviderName string
+
+const (
+	ProviderNamePolygon ProviderName = "polygon"
+	ProviderNameCsv     ProviderName = "csv"
+)
+
+func AllProviderNames() []ProviderName {
+	return []ProviderName{ProviderNamePolygon, ProviderNameCsv}
+}
+
+func ParseProviderName(s string) (ProviderName, error) {
+	n := ProviderName(strings.ToLower(strings.TrimSpace(s)))
+	for _, pn := range AllProviderNames() {
+		if n == pn {
+			return n, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid provider: %q, expected one of: %v", s, AllProviderNames())
+}
+
+// BarRequest asks for the bars of one ticker starting between From and To, both inclusive
+type BarRequest struct {
+	CompanyName string
+	Timespan    model.PolygonAggregateTimespan
+	Multiplier  int
+	From        time.Time
+	To          time.Time
+	Limit       int // page size for providers that page their results
+	Adjusted    bool
+}
+
+// dataPoint labels a bar with the series of the request. Bars of a single timespan leave the multiplier empty
+func (r BarRequest) dataPoint(dp model.PolygonDataPoint) models.DataPoint {
+	multiplier := 0
+	if r.Multiplier > 1 {
+		multiplier = r.Multiplier
+	}
+
+	return models.DataPoint{CompanyName: r.CompanyName, Timespan: r.Timespan, Multiplier: multiplier, Adjusted: r.Adjusted, PolygonDataPoint: dp}
+}
+
+// IProvider is a source of market data bars. Bars are returned in ascending start time with the request's
+// ticker, timespan, multiplier and adjusted flag set, along with the number of pages the provider read to build them
+type IProvider interface {
+	Name() ProviderName
+	Bars(ctx context.Context, req BarRequest) ([]models.DataPoint, int, genErr.IGenError)
+}
@@ -0,0 +1,401 @@
+package quality
+
+import (
+	"fmt"
+	"github.com/greenac/chaching/internal/calendar"
+	"github.com/greenac/chaching/internal/database/models"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rule is a check every bar goes through before it is saved
+type Rule string
+
+const (
+	RulePriceRange Rule = "priceRange" // low <= open, close <= high
+	RuleVolume     Rule = "volume"     // volume is not negative
+	RuleWindow     Rule = "window"     // the bar overlaps the window that was requested
+	RuleSession    Rule = "session"    // intraday bars overlap a trading session, day bars fall on a trading day
+	RuleDuplicate  Rule = "duplicate"  // no other bar of the series starts at the same time
+	RuleJump       Rule = "jump"       // the close moved no more than MaxJump from the previous bar's close
+)
+
+func AllRules() []Rule {
+	return []Rule{RulePriceRange, RuleVolume, RuleWindow, RuleSession, RuleDuplicate, RuleJump}
+}
+
+func ParseRule(s string) (Rule, error) {
+	for _, r := range AllRules() {
+		if strings.EqualFold(strings.TrimSpace(s), string(r)) {
+			return r, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid quality rule: %q, expected one of: %v", s, AllRules())
+}
+
+// Action is what happens to a bar that breaks a rule. A bar breaking several rules gets the most severe action
+type Action string
+
+const (
+	ActionOff        Action = "off"        // the rule is not checked
+	ActionWarn       Action = "warn"       // the bar is saved and reported
+	ActionQuarantine Action = "quarantine" // the bar is saved to its series' quarantine partition with the reasons
+	ActionReject     Action = "reject"     // the bar is dropped
+)
+
+func AllActions() []Action {
+	return []Action{ActionOff, ActionWarn, ActionQuarantine, ActionReject}
+}
+
+func ParseAction(s string) (Action, error) {
+	for _, a := range AllActions() {
+		if strings.EqualFold(strings.TrimSpace(s), string(a)) {
+			return a, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid quality action: %q, expected one of: %v", s, AllActions())
+}
+
+func (a Action) severity() int {
+	switch a {
+	case ActionWarn:
+		return 1
+	case ActionQuarantine:
+		return 2
+	case ActionReject:
+		return 3
+	}
+
+	return 0
+}
+
+type Config struct {
+	Actions map[Rule]Action // rules missing from the map are not checked
+	MaxJump float64         // largest fraction a close may move from the previous close, 0.1 is 10%
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Actions: map[Rule]Action{
+			RulePriceRange: ActionQuarantine,
+			RuleVolume:     ActionReject,
+			RuleWindow:     ActionReject,
+			RuleSession:    ActionWarn,
+			RuleDuplicate:  ActionReject,
+			RuleJump:       ActionWarn,
+		},
+		MaxJump: 0.1,
+	}
+}
+
+// WithActions returns a copy of the config with the actions of the given rules replaced
+func (c Config) WithActions(actions map[string]string) (Config, error) {
+	merged := Config{Actions: map[Rule]Action{}, MaxJump: c.MaxJump}
+	for r, a := range c.Actions {
+		merged.Actions[r] = a
+	}
+
+	for rs, as := range actions {
+		r, err := ParseRule(rs)
+		if err != nil {
+			return c, err
+		}
+
+		a, err := ParseAction(as)
+		if err != nil {
+			return c, err
+		}
+
+		merged.Actions[r] = a
+	}
+
+	return merged, nil
+}
+
+func (c Config) action(r Rule) Action {
+	if a, ok := c.Actions[r]; ok {
+		return a
+	}
+
+	return ActionOff
+}
+
+type Violation struct {
+	Rule   Rule
+	Action Action
+	Reason string
+}
+
+func (v Violation) String() string {
+	return string(v.Rule) + ": " + v.Reason
+}
+
+// FlaggedBar is a bar that broke at least one rule, with the action taken on it
+type FlaggedBar struct {
+	DataPoint  models.DataPoint
+	Action     Action
+	Violations []Violation
+}
+
+func (fb FlaggedBar) Reasons() []string {
+	reasons := make([]string, len(fb.Violations))
+	for i, v := range fb.Violations {
+		reasons[i] = v.String()
+	}
+
+	return reasons
+}
+
+func (fb FlaggedBar) String() string {
+	return fmt.Sprintf("%s %s bar at: %s, %s", fb.Action, fb.DataPoint.CompanyName, time.UnixMilli(fb.DataPoint.StartTime).UTC().Format(time.RFC3339), strings.Join(fb.Reasons(), ", "))
+}
+
+// Report counts the bars a validation run checked and what happened to them
+type Report struct {
+	Checked     int
+	Accepted    int
+	Warned      int
+	Quarantined int
+	Rejected    int
+	Violations  map[Rule]int
+}
+
+func (r *Report) Merge(o Report) {
+	r.Checked += o.Checked
+	r.Accepted += o.Accepted
+	r.Warned += o.Warned
+	r.Quarantined += o.Quarantined
+	r.Rejected += o.Rejected
+
+	for rule, n := range o.Violations {
+		if r.Violations == nil {
+			r.Violations = map[Rule]int{}
+		}
+
+		r.Violations[rule] += n
+	}
+}
+
+func (r Report) String() string {
+	s := fmt.Sprintf("checked %d bars, accepted %d with %d warned, quarantined %d, rejected %d", r.Checked, r.Accepted, r.Warned, r.Quarantined, r.Rejected)
+	if len(r.Violations) == 0 {
+		return s
+	}
+
+	var counts []string
+	for _, rule := range AllRules() {
+		if n := r.Violations[rule]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%s: %d", rule, n))
+		}
+	}
+
+	return s + ", violations: " + strings.Join(counts, ", ")
+}
+
+type Result struct {
+	Accepted    []models.DataPoint // bars to save, in the order they were given
+	Quarantined []models.QuarantinedDataPoint
+	Flagged     []FlaggedBar
+	Report      Report
+}
+
+type IValidator interface {
+	Validate(dps []models.DataPoint, from time.Time, to time.Time) Result
+}
+
+var _ IValidator = (*Validator)(nil)
+
+func NewValidator(config Config, cal calendar.ICalendar) IValidator {
+	return &Validator{config: config, calendar: cal}
+}
+
+type Validator struct {
+	config   Config
+	calendar calendar.ICalendar
+}
+
+// Validate checks bars fetched for the window from `from` to `to`. Each series is walked in time order, so
+// duplicates and jumps are judged against the bars before them. Zero times skip the window rule
+func (v *Validator) Validate(dps []models.DataPoint, from time.Time, to time.Time) Result {
+	res := Result{Report: Report{Checked: len(dps), Violations: map[Rule]int{}}}
+
+	order := make([]int, len(dps))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		return dps[order[a]].StartTime < dps[order[b]].StartTime
+	})
+
+	actions := make([]Action, len(dps))
+	series := map[string]*seriesState{}
+	for _, i := range order {
+		dp := dps[i]
+		key := models.DataPointPk(dp.CompanyName, dp.Timespan, dp.Multiplier, dp.Adjusted)
+		state, ok := series[key]
+		if !ok {
+			state = &seriesState{seen: map[int64]bool{}}
+			series[key] = state
+		}
+
+		violations := v.check(dp, from, to, state)
+		state.seen[dp.StartTime] = true
+
+		action := ActionOff
+		for _, vl := range violations {
+			res.Report.Violations[vl.Rule] += 1
+			if vl.Action.severity() > action.severity() {
+				action = vl.Action
+			}
+		}
+
+		actions[i] = action
+		if action.severity() < ActionQuarantine.severity() {
+			state.prevClose = dp.ClosePrice
+		}
+
+		if len(violations) > 0 {
+			res.Flagged = append(res.Flagged, FlaggedBar{DataPoint: dp, Action: action, Violations: violations})
+		}
+	}
+
+	for i, dp := range dps {
+		switch actions[i] {
+		case ActionReject:
+			res.Report.Rejected += 1
+		case ActionQuarantine:
+			res.Report.Quarantined += 1
+		default:
+			if actions[i] == ActionWarn {
+				res.Report.Warned += 1
+			}
+
+			res.Report.Accepted += 1
+			res.Accepted = append(res.Accepted, dp)
+		}
+	}
+
+	for _, fb := range res.Flagged {
+		if fb.Action == ActionQuarantine {
+			res.Quarantined = append(res.Quarantined, models.QuarantinedDataPoint{DataPoint: fb.DataPoint, Reasons: fb.Reasons()})
+		}
+	}
+
+	if len(res.Report.Violations) == 0 {
+		res.Report.Violations = nil
+	}
+
+	return res
+}
+
+type seriesState struct {
+	seen      map[int64]bool
+	prevClose float64
+}
+
+func (v *Validator) check(dp models.DataPoint, from time.Time, to time.Time, state *seriesState) []Violation {
+	var violations []Violation
+	add := func(r Rule, reason string) {
+		if a := v.config.action(r); a != ActionOff {
+			violations = append(violations, Violation{Rule: r, Action: a, Reason: reason})
+		}
+	}
+
+	start := time.UnixMilli(dp.StartTime)
+	end := start.Add(barDuration(dp.Timespan))
+
+	if dp.LowestPrice > dp.HighestPrice {
+		add(RulePriceRange, fmt.Sprintf("low %g is above high %g", dp.LowestPrice, dp.HighestPrice))
+	} else {
+		if dp.OpenPrice < dp.LowestPrice || dp.OpenPrice > dp.HighestPrice {
+			add(RulePriceRange, fmt.Sprintf("open %g is outside low %g and high %g", dp.OpenPrice, dp.LowestPrice, dp.HighestPrice))
+		}
+
+		if dp.ClosePrice < dp.LowestPrice || dp.ClosePrice > dp.HighestPrice {
+			add(RulePriceRange, fmt.Sprintf("close %g is outside low %g and high %g", dp.ClosePrice, dp.LowestPrice, dp.HighestPrice))
+		}
+	}
+
+	if dp.Volume < 0 {
+		add(RuleVolume, fmt.Sprintf("volume %g is negative", dp.Volume))
+	}
+
+	if !from.IsZero() && !to.IsZero() && (!end.After(from) || start.After(to)) {
+		add(RuleWindow, fmt.Sprintf("bar is outside the window %s to %s", from.Format(time.RFC3339), to.Format(time.RFC3339)))
+	}
+
+	if reason, ok := v.outsideSession(dp.Timespan, start, end); ok {
+		add(RuleSession, reason)
+	}
+
+	if state.seen[dp.StartTime] {
+		add(RuleDuplicate, "another bar starts at the same time")
+	}
+
+	if v.config.MaxJump > 0 && state.prevClose > 0 {
+		jump := math.Abs(dp.ClosePrice-state.prevClose) / state.prevClose
+		if jump > v.config.MaxJump {
+			add(RuleJump, fmt.Sprintf("close %g moved %.1f%% from the previous close %g", dp.ClosePrice, jump*100, state.prevClose))
+		}
+	}
+
+	return violations
+}
+
+func (v *Validator) outsideSession(timespan model.PolygonAggregateTimespan, start time.Time, end time.Time) (string, bool) {
+	if v.calendar == nil {
+		return "", false
+	}
+
+	return OutsideSession(v.calendar, timespan, start, end)
+}
+
+// OutsideSession reports why an intraday bar misses every session or a day bar is not on a trading day. Bars
+// of a week or longer can start on any day, so they are not checked
+func OutsideSession(cal calendar.ICalendar, timespan model.PolygonAggregateTimespan, start time.Time, end time.Time) (string, bool) {
+	switch timespan {
+	case model.PolygonAggregateTimespanDay:
+		if !cal.IsTradingDay(start) {
+			return "bar is not on a trading day", true
+		}
+	case "", model.PolygonAggregateTimespanSecond, model.PolygonAggregateTimespanMinute, model.PolygonAggregateTimespanHour:
+		s, ok := cal.Session(start)
+		if !ok {
+			return "bar is not on a trading day", true
+		}
+
+		if !start.Before(s.Close) || !end.After(s.Open) {
+			return fmt.Sprintf("bar is outside the session %s to %s", s.Open.Format(time.RFC3339), s.Close.Format(time.RFC3339)), true
+		}
+	}
+
+	return "", false
+}
+
+// barDuration is the length of a single timespan, bars without a timespan are minute bars
+func barDuration(timespan model.PolygonAggregateTimespan) time.Duration {
+	switch timespan {
+	case model.PolygonAggregateTimespanSecond:
+		return time.Second
+	case model.PolygonAggregateTimespanHour:
+		return time.Hour
+	case model.PolygonAggregateTimespanDay:
+		return 24 * time.Hour
+	case model.PolygonAggregateTimespanWeek:
+		return 7 * 24 * time.Hour
+	case model.PolygonAggregateTimespanMonth:
+		return 31 * 24 * time.Hour
+	case model.PolygonAggregateTimespanQuarter:
+		return 92 * 24 * time.Hour
+	case model.PolygonAggregateTimespanYear:
+		return 366 * 24 * time.Hour
+	}
+
+	return time.Minute
+}
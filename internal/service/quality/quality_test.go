@@ -0,0 +1,159 @@
+package quality
+
+import (
+	"github.com/greenac/chaching/internal/calendar"
+	"github.com/greenac/chaching/internal/database/models"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func bar(start time.Time, open, high, low, close, volume float64) models.DataPoint {
+	return models.DataPoint{
+		CompanyName:      "AAPL",
+		Timespan:         model.PolygonAggregateTimespanMinute,
+		PolygonDataPoint: model.PolygonDataPoint{StartTime: start.UnixMilli(), OpenPrice: open, HighestPrice: high, LowestPrice: low, ClosePrice: close, Volume: volume},
+	}
+}
+
+func TestValidator_Validate(t *testing.T) {
+	Convey("TestValidator_Validate", t, func() {
+		cal := calendar.NewNyseCalendar()
+		open := time.Date(2023, 3, 1, 9, 30, 0, 0, cal.Location())
+		from, to := open, open.Add(10*time.Minute)
+		v := NewValidator(DefaultConfig(), cal)
+
+		Convey("TestValidator_Validate should accept clean bars untouched", func() {
+			dps := []models.DataPoint{bar(open, 146.83, 147.16, 146.71, 147.04, 1245337), bar(open.Add(time.Minute), 147.04, 147.1, 146.83, 146.9, 519781)}
+
+			res := v.Validate(dps, from, to)
+			So(res.Accepted, ShouldResemble, dps)
+			So(res.Flagged, ShouldBeEmpty)
+			So(res.Report, ShouldResemble, Report{Checked: 2, Accepted: 2})
+			So(res.Report.String(), ShouldEqual, "checked 2 bars, accepted 2 with 0 warned, quarantined 0, rejected 0")
+		})
+
+		Convey("TestValidator_Validate should quarantine bars whose open or close is outside their range", func() {
+			bad := bar(open.Add(time.Minute), 150, 147, 146, 146.5, 10)
+
+			res := v.Validate([]models.DataPoint{bar(open, 146, 147, 145, 146.5, 10), bad}, from, to)
+			So(len(res.Accepted), ShouldEqual, 1)
+			So(res.Quarantined, ShouldResemble, []models.QuarantinedDataPoint{{DataPoint: bad, Reasons: []string{"priceRange: open 150 is outside low 146 and high 147"}}})
+			So(res.Report.Quarantined, ShouldEqual, 1)
+			So(res.Report.Violations, ShouldResemble, map[Rule]int{RulePriceRange: 1})
+		})
+
+		Convey("TestValidator_Validate should reject negative volumes, bars outside the window and duplicates", func() {
+			res := v.Validate([]models.DataPoint{
+				bar(open, 146, 147, 145, 146.5, 10),
+				bar(open.Add(time.Minute), 146, 147, 145, 146.5, -1),
+				bar(open.Add(20*time.Minute), 146, 147, 145, 146.5, 10),
+				bar(open, 146, 147, 145, 146.6, 12),
+			}, from, to)
+
+			So(len(res.Accepted), ShouldEqual, 1)
+			So(res.Accepted[0].ClosePrice, ShouldEqual, 146.5)
+			So(res.Report.Rejected, ShouldEqual, 3)
+			So(res.Report.Violations, ShouldResemble, map[Rule]int{RuleVolume: 1, RuleWindow: 1, RuleDuplicate: 1})
+			So(res.Flagged[2].String(), ShouldEqual, "reject AAPL bar at: 2023-03-01T14:50:00Z, window: bar is outside the window 2023-03-01T09:30:00-05:00 to 2023-03-01T09:40:00-05:00")
+		})
+
+		Convey("TestValidator_Validate should warn about bars outside the session and keep them", func() {
+			premarket := bar(open.Add(-time.Hour), 146, 147, 145, 146.5, 10)
+			weekend := bar(open.AddDate(0, 0, 3), 146, 147, 145, 146.5, 10)
+
+			res := v.Validate([]models.DataPoint{premarket, weekend}, time.Time{}, time.Time{})
+			So(len(res.Accepted), ShouldEqual, 2)
+			So(res.Report.Warned, ShouldEqual, 2)
+			So(res.Flagged[0].Reasons(), ShouldResemble, []string{"session: bar is outside the session 2023-03-01T09:30:00-05:00 to 2023-03-01T16:00:00-05:00"})
+			So(res.Flagged[1].Reasons(), ShouldResemble, []string{"session: bar is not on a trading day"})
+		})
+
+		Convey("TestValidator_Validate should judge day bars by their trading day", func() {
+			day := models.DataPoint{CompanyName: "AAPL", Timespan: model.PolygonAggregateTimespanDay, PolygonDataPoint: model.PolygonDataPoint{StartTime: time.Date(2023, 3, 1, 0, 0, 0, 0, cal.Location()).UnixMilli(), OpenPrice: 1, HighestPrice: 1, LowestPrice: 1, ClosePrice: 1}}
+
+			res := v.Validate([]models.DataPoint{day}, from, to)
+			So(res.Flagged, ShouldBeEmpty)
+		})
+
+		Convey("TestValidator_Validate should flag jumps against the previous bar in time order", func() {
+			res := v.Validate([]models.DataPoint{
+				bar(open.Add(2*time.Minute), 100, 101, 99, 100, 10),
+				bar(open, 100, 101, 99, 100, 10),
+				bar(open.Add(time.Minute), 100, 130, 99, 125, 10),
+			}, from, to)
+
+			So(len(res.Accepted), ShouldEqual, 3)
+			So(res.Report.Violations, ShouldResemble, map[Rule]int{RuleJump: 2})
+			So(res.Flagged[0].Reasons(), ShouldResemble, []string{"jump: close 125 moved 25.0% from the previous close 100"})
+			So(res.Flagged[1].Reasons(), ShouldResemble, []string{"jump: close 100 moved 20.0% from the previous close 125"})
+		})
+
+		Convey("TestValidator_Validate should not measure jumps from quarantined bars", func() {
+			res := v.Validate([]models.DataPoint{
+				bar(open, 100, 101, 99, 100, 10),
+				bar(open.Add(time.Minute), 100, 101, 99, 150, 10),
+				bar(open.Add(2*time.Minute), 100, 101, 99, 100.5, 10),
+			}, from, to)
+
+			So(res.Report.Quarantined, ShouldEqual, 1)
+			So(res.Report.Violations, ShouldResemble, map[Rule]int{RulePriceRange: 1, RuleJump: 1})
+		})
+
+		Convey("TestValidator_Validate should apply the most severe action of the broken rules", func() {
+			config, err := DefaultConfig().WithActions(map[string]string{"jump": "reject", "priceRange": "warn"})
+			So(err, ShouldBeNil)
+
+			res := NewValidator(config, cal).Validate([]models.DataPoint{
+				bar(open, 100, 101, 99, 100, 10),
+				bar(open.Add(time.Minute), 100, 101, 99, 150, 10),
+			}, from, to)
+
+			So(res.Flagged[0].Action, ShouldEqual, ActionReject)
+			So(res.Report.Rejected, ShouldEqual, 1)
+		})
+
+		Convey("TestValidator_Validate should skip rules that are off", func() {
+			config, err := DefaultConfig().WithActions(map[string]string{"window": "off"})
+			So(err, ShouldBeNil)
+
+			res := NewValidator(config, cal).Validate([]models.DataPoint{bar(open.Add(20*time.Minute), 146, 147, 145, 146.5, 10)}, from, to)
+			So(res.Flagged, ShouldBeEmpty)
+			So(len(res.Accepted), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestConfig_WithActions(t *testing.T) {
+	Convey("TestConfig_WithActions", t, func() {
+		Convey("TestConfig_WithActions should leave the original config alone", func() {
+			base := DefaultConfig()
+			config, err := base.WithActions(map[string]string{"Session": "QUARANTINE"})
+			So(err, ShouldBeNil)
+			So(config.Actions[RuleSession], ShouldEqual, ActionQuarantine)
+			So(base.Actions[RuleSession], ShouldEqual, ActionWarn)
+		})
+
+		Convey("TestConfig_WithActions should reject unknown rules and actions", func() {
+			_, err := DefaultConfig().WithActions(map[string]string{"spread": "warn"})
+			So(err.Error(), ShouldEqual, `invalid quality rule: "spread", expected one of: [priceRange volume window session duplicate jump]`)
+
+			_, err = DefaultConfig().WithActions(map[string]string{"jump": "ignore"})
+			So(err.Error(), ShouldEqual, `invalid quality action: "ignore", expected one of: [off warn quarantine reject]`)
+		})
+	})
+}
+
+func TestReport_Merge(t *testing.T) {
+	Convey("TestReport_Merge", t, func() {
+		Convey("TestReport_Merge should add up counts and violations", func() {
+			r := Report{}
+			r.Merge(Report{Checked: 3, Accepted: 2, Rejected: 1, Violations: map[Rule]int{RuleVolume: 1}})
+			r.Merge(Report{Checked: 2, Accepted: 1, Warned: 1, Quarantined: 1, Violations: map[Rule]int{RuleJump: 1, RulePriceRange: 1}})
+
+			So(r.String(), ShouldEqual, "checked 5 bars, accepted 3 with 1 warned, quarantined 1, rejected 1, violations: priceRange: 1, volume: 1, jump: 1")
+		})
+	})
+}
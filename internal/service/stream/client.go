@@ -0,0 +1,479 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	restModels "github.com/greenac/chaching/internal/rest/models"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	"github.com/greenac/chaching/internal/service/logger"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dayLayout           = "2006-01-02"
+	writeWait           = 10 * time.Second
+	defaultPingInterval = 30 * time.Second
+)
+
+type Config struct {
+	Url               string // feed url, for example: wss://socket.polygon.io/stocks
+	ApiKey            string
+	Location          *time.Location // exchange time zone, trades are partitioned by their day in it
+	PingInterval      time.Duration  // how often the client pings the server, 30s by default
+	PongWait          time.Duration  // how long the connection may stay silent before it is dropped, two ping intervals by default
+	ReconnectDelay    time.Duration  // delay before the first reconnect, doubled for every consecutive failure
+	MaxReconnectDelay time.Duration
+	MaxReconnects     int // consecutive failed connections before Run gives up, 0 reconnects forever
+	Dialer            *websocket.Dialer
+}
+
+var _ genErr.IGenError = (*AuthError)(nil)
+
+// AuthError is returned by Run when the server rejects the api key, which reconnecting would not fix
+type AuthError struct {
+	Message  string
+	Messages []string
+}
+
+func (e AuthError) Error() string {
+	msg := "stream authentication failed: " + e.Message
+	if len(e.Messages) == 0 {
+		return msg
+	}
+
+	return msg + "->" + strings.Join(e.Messages, "->")
+}
+
+func (e AuthError) AddMsg(msg string) genErr.IGenError {
+	e.Messages = append(e.Messages, msg)
+	return &e
+}
+
+type IClient interface {
+	Run(ctx context.Context) genErr.IGenError
+	Subscribe(channel model.PolygonStreamEvent, tickers ...string) genErr.IGenError
+	Unsubscribe(channel model.PolygonStreamEvent, tickers ...string) genErr.IGenError
+	Subscriptions() []string
+}
+
+var _ IClient = (*Client)(nil)
+
+// NewClient creates a client for polygon's websocket feed that hands every decoded bar and trade to sink
+func NewClient(config Config, sink ISink, l logger.ILogger) IClient {
+	return &Client{config: config, sink: sink, logger: l, subscriptions: map[string]bool{}}
+}
+
+// Client keeps one authenticated connection open, reconnecting and resubscribing whenever it drops
+type Client struct {
+	config        Config
+	sink          ISink
+	logger        logger.ILogger
+	mu            sync.Mutex // guards conn, subscriptions and writes to conn
+	conn          *websocket.Conn
+	subscriptions map[string]bool
+}
+
+// Run streams until ctx is done, which returns nil, the api key is rejected, or MaxReconnects consecutive
+// connections fail
+func (c *Client) Run(ctx context.Context) genErr.IGenError {
+	failures := 0
+	for {
+		authenticated, ge := c.session(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if _, ok := ge.(*AuthError); ok {
+			return ge.AddMsg("Client:Run:not reconnecting")
+		}
+
+		if authenticated {
+			failures = 0
+		}
+
+		failures += 1
+		if c.config.MaxReconnects > 0 && failures > c.config.MaxReconnects {
+			return ge.AddMsg(fmt.Sprintf("Client:Run:giving up after %d failed connections", failures))
+		}
+
+		delay := restModels.RetryPolicy{BaseDelay: c.config.ReconnectDelay, MaxDelay: c.config.MaxReconnectDelay}.Backoff(failures)
+		c.logger.Warn(fmt.Sprintf("Client:Run:reconnecting in %s after error: %s", delay, ge.Error()))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// Subscribe adds the tickers' channel to the subscriptions, sending it right away when connected and on every
+// connection after
+func (c *Client) Subscribe(channel model.PolygonStreamEvent, tickers ...string) genErr.IGenError {
+	params := subscriptionParams(channel, tickers)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, p := range params {
+		c.subscriptions[p] = true
+	}
+
+	if c.conn == nil || len(params) == 0 {
+		return nil
+	}
+
+	if ge := c.write(c.conn, model.PolygonStreamActionSubscribe, params); ge != nil {
+		return ge.AddMsg("Client:Subscribe:failed to subscribe")
+	}
+
+	return nil
+}
+
+func (c *Client) Unsubscribe(channel model.PolygonStreamEvent, tickers ...string) genErr.IGenError {
+	params := subscriptionParams(channel, tickers)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, p := range params {
+		delete(c.subscriptions, p)
+	}
+
+	if c.conn == nil || len(params) == 0 {
+		return nil
+	}
+
+	if ge := c.write(c.conn, model.PolygonStreamActionUnsubscribe, params); ge != nil {
+		return ge.AddMsg("Client:Unsubscribe:failed to unsubscribe")
+	}
+
+	return nil
+}
+
+// Subscriptions returns the current subscription params, sorted
+func (c *Client) Subscriptions() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.subscriptionList()
+}
+
+// session connects, authenticates and resubscribes, then reads until the connection fails. It reports whether
+// the connection was authenticated, so Run can tell a dropped stream from a server it can not reach
+func (c *Client) session(ctx context.Context) (bool, genErr.IGenError) {
+	conn, _, err := c.dialer().DialContext(ctx, c.config.Url, nil)
+	if err != nil {
+		return false, &genErr.GenError{Messages: []string{"Client:session:failed to dial: " + c.config.Url + " with error: " + err.Error()}}
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	c.heartbeat(conn, done)
+
+	if ge := c.authenticate(conn); ge != nil {
+		return false, ge
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	subs := c.subscriptionList()
+	var ge genErr.IGenError
+	if len(subs) > 0 {
+		ge = c.write(conn, model.PolygonStreamActionSubscribe, subs)
+	}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+	}()
+
+	if ge != nil {
+		return true, ge.AddMsg("Client:session:failed to resubscribe")
+	}
+
+	c.logger.Info(fmt.Sprintf("Client:session:connected to: %s with %d subscriptions", c.config.Url, len(subs)))
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return true, &genErr.GenError{Messages: []string{"Client:session:connection lost with error: " + err.Error()}}
+		}
+
+		c.extendDeadline(conn)
+		c.handle(ctx, data)
+	}
+}
+
+// heartbeat pings the server every PingInterval and drops the connection when neither a message, a ping nor
+// a pong has arrived within PongWait
+func (c *Client) heartbeat(conn *websocket.Conn, done chan struct{}) {
+	c.extendDeadline(conn)
+
+	conn.SetPongHandler(func(string) error {
+		c.extendDeadline(conn)
+		return nil
+	})
+
+	conn.SetPingHandler(func(data string) error {
+		c.extendDeadline(conn)
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(writeWait))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+
+		return err
+	})
+
+	go func() {
+		ticker := time.NewTicker(c.pingInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+					c.logger.Warn("Client:heartbeat:failed to ping with error: " + err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// authenticate waits for the server's connected status, sends the api key and waits for the verdict
+func (c *Client) authenticate(conn *websocket.Conn) genErr.IGenError {
+	if ge := c.awaitStatus(conn, model.PolygonStreamStatusConnected); ge != nil {
+		return ge.AddMsg("Client:authenticate:never connected")
+	}
+
+	if ge := c.write(conn, model.PolygonStreamActionAuth, []string{c.config.ApiKey}); ge != nil {
+		return ge.AddMsg("Client:authenticate:failed to send auth")
+	}
+
+	if ge := c.awaitStatus(conn, model.PolygonStreamStatusAuthSuccess); ge != nil {
+		return ge.AddMsg("Client:authenticate:failed")
+	}
+
+	return nil
+}
+
+func (c *Client) awaitStatus(conn *websocket.Conn, want model.PolygonStreamStatus) genErr.IGenError {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return &genErr.GenError{Messages: []string{"Client:awaitStatus:failed to read " + string(want) + " with error: " + err.Error()}}
+		}
+
+		var msgs []model.PolygonStreamMessage
+		if err := json.Unmarshal(data, &msgs); err != nil {
+			return &genErr.GenError{Messages: []string{"Client:awaitStatus:failed to unmarshal with error: " + err.Error()}}
+		}
+
+		for _, m := range msgs {
+			if m.Event != model.PolygonStreamEventStatus {
+				continue
+			}
+
+			switch m.Status {
+			case want:
+				return nil
+			case model.PolygonStreamStatusAuthFailed:
+				return &AuthError{Message: m.Message}
+			}
+		}
+	}
+}
+
+// handle decodes a message's events, handing its bars and trades to the sink. Sink errors are logged so one
+// bad batch does not drop the stream
+func (c *Client) handle(ctx context.Context, data []byte) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		c.logger.Error("Client:handle:failed to unmarshal message with error: " + err.Error())
+		return
+	}
+
+	var bars []models.DataPoint
+	var trades []models.Trade
+	for _, raw := range raws {
+		var env model.PolygonStreamMessage
+		if err := json.Unmarshal(raw, &env); err != nil {
+			c.logger.Error("Client:handle:failed to unmarshal event with error: " + err.Error())
+			continue
+		}
+
+		switch env.Event {
+		case model.PolygonStreamEventMinuteAggregate, model.PolygonStreamEventSecondAggregate:
+			var agg model.PolygonStreamAggregate
+			if err := json.Unmarshal(raw, &agg); err != nil {
+				c.logger.Error("Client:handle:failed to unmarshal aggregate with error: " + err.Error())
+				continue
+			}
+
+			bars = append(bars, DataPointFromAggregate(agg))
+		case model.PolygonStreamEventTrade:
+			var t model.PolygonStreamTrade
+			if err := json.Unmarshal(raw, &t); err != nil {
+				c.logger.Error("Client:handle:failed to unmarshal trade with error: " + err.Error())
+				continue
+			}
+
+			trades = append(trades, TradeFromStream(t, c.config.Location))
+		case model.PolygonStreamEventStatus:
+			if env.Status == model.PolygonStreamStatusError {
+				c.logger.Error("Client:handle:server error: " + env.Message)
+			} else {
+				c.logger.Info("Client:handle:status " + string(env.Status) + ": " + env.Message)
+			}
+		default:
+			c.logger.Debug("Client:handle:ignoring event: " + string(env.Event))
+		}
+	}
+
+	if len(bars) > 0 {
+		if ge := c.sink.HandleBars(ctx, bars); ge != nil {
+			c.logger.Error(fmt.Sprintf("Client:handle:sink failed to handle %d bars with error: %s", len(bars), ge.Error()))
+		}
+	}
+
+	if len(trades) > 0 {
+		if ge := c.sink.HandleTrades(ctx, trades); ge != nil {
+			c.logger.Error(fmt.Sprintf("Client:handle:sink failed to handle %d trades with error: %s", len(trades), ge.Error()))
+		}
+	}
+}
+
+// write sends a request, callers other than session's own setup must hold mu
+func (c *Client) write(conn *websocket.Conn, action model.PolygonStreamAction, params []string) genErr.IGenError {
+	if err := conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+		return &genErr.GenError{Messages: []string{"Client:write:failed to set deadline with error: " + err.Error()}}
+	}
+
+	if err := conn.WriteJSON(model.PolygonStreamRequest{Action: action, Params: strings.Join(params, ",")}); err != nil {
+		return &genErr.GenError{Messages: []string{"Client:write:failed to send: " + string(action) + " with error: " + err.Error()}}
+	}
+
+	return nil
+}
+
+func (c *Client) extendDeadline(conn *websocket.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(c.pongWait()))
+}
+
+func (c *Client) subscriptionList() []string {
+	subs := make([]string, 0, len(c.subscriptions))
+	for s := range c.subscriptions {
+		subs = append(subs, s)
+	}
+
+	sort.Strings(subs)
+
+	return subs
+}
+
+func (c *Client) dialer() *websocket.Dialer {
+	if c.config.Dialer != nil {
+		return c.config.Dialer
+	}
+
+	return websocket.DefaultDialer
+}
+
+func (c *Client) pingInterval() time.Duration {
+	if c.config.PingInterval > 0 {
+		return c.config.PingInterval
+	}
+
+	return defaultPingInterval
+}
+
+func (c *Client) pongWait() time.Duration {
+	if c.config.PongWait > 0 {
+		return c.config.PongWait
+	}
+
+	return 2 * c.pingInterval()
+}
+
+func subscriptionParams(channel model.PolygonStreamEvent, tickers []string) []string {
+	tickers = models.NormalizeTickers(tickers)
+	params := make([]string, len(tickers))
+	for i, t := range tickers {
+		params[i] = channel.Subscription(t)
+	}
+
+	return params
+}
+
+// DataPointFromAggregate converts a streamed bar into the data point the REST aggregates would have produced
+func DataPointFromAggregate(agg model.PolygonStreamAggregate) models.DataPoint {
+	timespan := model.PolygonAggregateTimespanMinute
+	if agg.Event == model.PolygonStreamEventSecondAggregate {
+		timespan = model.PolygonAggregateTimespanSecond
+	}
+
+	return models.DataPoint{
+		CompanyName: agg.Symbol,
+		Timespan:    timespan,
+		PolygonDataPoint: model.PolygonDataPoint{
+			StartTime:           agg.StartTime,
+			OpenPrice:           agg.OpenPrice,
+			HighestPrice:        agg.HighestPrice,
+			LowestPrice:         agg.LowestPrice,
+			ClosePrice:          agg.ClosePrice,
+			Volume:              agg.Volume,
+			VolumeWeightedPrice: agg.VolumeWeightedPrice,
+		},
+	}
+}
+
+// TradeFromStream converts a streamed trade into the trade the REST trades would have produced. The stream
+// only has millisecond timestamps, so the SIP timestamp is truncated to the millisecond
+func TradeFromStream(t model.PolygonStreamTrade, loc *time.Location) models.Trade {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	sip := t.Timestamp * int64(time.Millisecond)
+
+	return models.Trade{
+		CompanyName: t.Symbol,
+		Day:         time.Unix(0, sip).In(loc).Format(dayLayout),
+		PolygonTrade: model.PolygonTrade{
+			Id:             t.Id,
+			Conditions:     t.Conditions,
+			Exchange:       t.Exchange,
+			Price:          t.Price,
+			Size:           t.Size,
+			SequenceNumber: t.SequenceNumber,
+			Tape:           t.Tape,
+			TrfId:          t.TrfId,
+			SipTimestamp:   sip,
+			TrfTimestamp:   t.TrfTimestamp * int64(time.Millisecond),
+		},
+	}
+}
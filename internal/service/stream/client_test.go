@@ -0,0 +1,296 @@
+package stream
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	model "github.com/greenac/chaching/internal/rest/polygon/models"
+	logMocks "github.com/greenac/chaching/internal/service/logger/mocks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testApiKey = "secret"
+
+// stubServer speaks enough of polygon's protocol to test the client. After each subscribe it sends the events
+// scripted for that connection, numbered from 1, then drops the connection when told to
+type stubServer struct {
+	mu          sync.Mutex
+	connections int
+	pings       int
+	requests    map[int][]model.PolygonStreamRequest
+	events      map[int][]any
+	drop        map[int]bool
+	silent      map[int]bool // stops reading after auth, so the client's pings go unanswered
+}
+
+func newStubServer() *stubServer {
+	return &stubServer{requests: map[int][]model.PolygonStreamRequest{}, events: map[int][]any{}, drop: map[int]bool{}, silent: map[int]bool{}}
+}
+
+func (s *stubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	s.mu.Lock()
+	s.connections += 1
+	n := s.connections
+	s.mu.Unlock()
+
+	conn.SetPingHandler(func(data string) error {
+		s.mu.Lock()
+		s.pings += 1
+		s.mu.Unlock()
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+
+	_ = conn.WriteJSON([]model.PolygonStreamMessage{{Event: model.PolygonStreamEventStatus, Status: model.PolygonStreamStatusConnected}})
+
+	for {
+		var req model.PolygonStreamRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.requests[n] = append(s.requests[n], req)
+		events, drop, silent := s.events[n], s.drop[n], s.silent[n]
+		s.mu.Unlock()
+
+		switch req.Action {
+		case model.PolygonStreamActionAuth:
+			if req.Params != testApiKey {
+				_ = conn.WriteJSON([]model.PolygonStreamMessage{{Event: model.PolygonStreamEventStatus, Status: model.PolygonStreamStatusAuthFailed, Message: "authentication failed"}})
+				return
+			}
+
+			_ = conn.WriteJSON([]model.PolygonStreamMessage{{Event: model.PolygonStreamEventStatus, Status: model.PolygonStreamStatusAuthSuccess}})
+			if silent {
+				time.Sleep(time.Second)
+				return
+			}
+		case model.PolygonStreamActionSubscribe:
+			_ = conn.WriteJSON([]model.PolygonStreamMessage{{Event: model.PolygonStreamEventStatus, Status: model.PolygonStreamStatusSuccess, Message: "subscribed to: " + req.Params}})
+			if len(events) > 0 {
+				_ = conn.WriteJSON(events)
+			}
+
+			if drop {
+				return
+			}
+		}
+	}
+}
+
+func (s *stubServer) snapshot() (int, int, map[int][]model.PolygonStreamRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := map[int][]model.PolygonStreamRequest{}
+	for n, reqs := range s.requests {
+		requests[n] = append([]model.PolygonStreamRequest{}, reqs...)
+	}
+
+	return s.connections, s.pings, requests
+}
+
+type sinkRecorder struct {
+	mu     sync.Mutex
+	bars   []models.DataPoint
+	trades []models.Trade
+}
+
+func (sr *sinkRecorder) sink() ISink {
+	return SinkFuncs{
+		Bars: func(ctx context.Context, dps []models.DataPoint) genErr.IGenError {
+			sr.mu.Lock()
+			defer sr.mu.Unlock()
+			sr.bars = append(sr.bars, dps...)
+			return nil
+		},
+		Trades: func(ctx context.Context, trades []models.Trade) genErr.IGenError {
+			sr.mu.Lock()
+			defer sr.mu.Unlock()
+			sr.trades = append(sr.trades, trades...)
+			return nil
+		},
+	}
+}
+
+func (sr *sinkRecorder) counts() (int, int) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	return len(sr.bars), len(sr.trades)
+}
+
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return false
+}
+
+func minuteBar(sym string, start int64) model.PolygonStreamAggregate {
+	return model.PolygonStreamAggregate{Event: model.PolygonStreamEventMinuteAggregate, Symbol: sym, OpenPrice: 1, HighestPrice: 2, LowestPrice: 0.5, ClosePrice: 1.5, Volume: 100, VolumeWeightedPrice: 1.2, StartTime: start, EndTime: start + 60000}
+}
+
+func TestClient(t *testing.T) {
+	Convey("TestClient", t, func() {
+		ny, err := time.LoadLocation("America/New_York")
+		So(err, ShouldBeNil)
+
+		stub := newStubServer()
+		server := httptest.NewServer(stub)
+		defer server.Close()
+
+		config := Config{
+			Url:            "ws" + strings.TrimPrefix(server.URL, "http"),
+			ApiKey:         testApiKey,
+			Location:       ny,
+			PingInterval:   20 * time.Millisecond,
+			PongWait:       200 * time.Millisecond,
+			ReconnectDelay: 10 * time.Millisecond,
+			MaxReconnects:  3,
+		}
+		recorder := &sinkRecorder{}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		run := func(c IClient) chan genErr.IGenError {
+			result := make(chan genErr.IGenError, 1)
+			go func() { result <- c.Run(ctx) }()
+			return result
+		}
+
+		Convey("TestClient should authenticate, subscribe and hand decoded events to the sink", func() {
+			stub.events[1] = []any{
+				minuteBar("AAPL", 1677681000000),
+				model.PolygonStreamTrade{Event: model.PolygonStreamEventTrade, Symbol: "AAPL", Id: "42", Exchange: 4, Price: 147.5, Size: 10, Conditions: []int{12}, Timestamp: 1677681001234, SequenceNumber: 7, Tape: 3},
+				map[string]any{"ev": "Q", "sym": "AAPL"},
+			}
+
+			c := NewClient(config, recorder.sink(), &logMocks.LoggerMock{})
+			So(c.Subscribe(model.PolygonStreamEventMinuteAggregate, "aapl"), ShouldBeNil)
+			So(c.Subscribe(model.PolygonStreamEventTrade, "AAPL"), ShouldBeNil)
+			result := run(c)
+
+			So(waitFor(func() bool { b, t := recorder.counts(); return b == 1 && t == 1 }), ShouldBeTrue)
+			So(recorder.bars[0], ShouldResemble, models.DataPoint{
+				CompanyName:      "AAPL",
+				Timespan:         model.PolygonAggregateTimespanMinute,
+				PolygonDataPoint: model.PolygonDataPoint{StartTime: 1677681000000, OpenPrice: 1, HighestPrice: 2, LowestPrice: 0.5, ClosePrice: 1.5, Volume: 100, VolumeWeightedPrice: 1.2},
+			})
+			So(recorder.trades[0].CompanyName, ShouldEqual, "AAPL")
+			So(recorder.trades[0].Day, ShouldEqual, "2023-03-01")
+			So(recorder.trades[0].SipTimestamp, ShouldEqual, int64(1677681001234000000))
+			So(recorder.trades[0].Conditions, ShouldResemble, []int{12})
+
+			_, _, requests := stub.snapshot()
+			So(requests[1], ShouldResemble, []model.PolygonStreamRequest{
+				{Action: model.PolygonStreamActionAuth, Params: testApiKey},
+				{Action: model.PolygonStreamActionSubscribe, Params: "AM.AAPL,T.AAPL"},
+			})
+
+			cancel()
+			So(<-result, ShouldBeNil)
+		})
+
+		Convey("TestClient should subscribe and unsubscribe while connected", func() {
+			stub.events[1] = []any{minuteBar("AAPL", 1677681000000)}
+
+			c := NewClient(config, recorder.sink(), &logMocks.LoggerMock{})
+			So(c.Subscribe(model.PolygonStreamEventMinuteAggregate, "AAPL"), ShouldBeNil)
+			result := run(c)
+			So(waitFor(func() bool { b, _ := recorder.counts(); return b == 1 }), ShouldBeTrue)
+
+			So(c.Subscribe(model.PolygonStreamEventSecondAggregate, "MSFT", "AMZN"), ShouldBeNil)
+			So(c.Unsubscribe(model.PolygonStreamEventMinuteAggregate, "AAPL"), ShouldBeNil)
+			So(c.Subscriptions(), ShouldResemble, []string{"A.AMZN", "A.MSFT"})
+
+			So(waitFor(func() bool { _, _, r := stub.snapshot(); return len(r[1]) == 4 }), ShouldBeTrue)
+			_, _, requests := stub.snapshot()
+			So(requests[1][2:], ShouldResemble, []model.PolygonStreamRequest{
+				{Action: model.PolygonStreamActionSubscribe, Params: "A.MSFT,A.AMZN"},
+				{Action: model.PolygonStreamActionUnsubscribe, Params: "AM.AAPL"},
+			})
+
+			cancel()
+			So(<-result, ShouldBeNil)
+		})
+
+		Convey("TestClient should reconnect and resubscribe after the connection drops", func() {
+			stub.drop[1] = true
+			stub.events[2] = []any{minuteBar("AAPL", 1677681060000)}
+
+			c := NewClient(config, recorder.sink(), &logMocks.LoggerMock{})
+			So(c.Subscribe(model.PolygonStreamEventMinuteAggregate, "AAPL"), ShouldBeNil)
+			result := run(c)
+
+			So(waitFor(func() bool { b, _ := recorder.counts(); return b == 1 }), ShouldBeTrue)
+			connections, _, requests := stub.snapshot()
+			So(connections, ShouldEqual, 2)
+			So(requests[2], ShouldResemble, []model.PolygonStreamRequest{
+				{Action: model.PolygonStreamActionAuth, Params: testApiKey},
+				{Action: model.PolygonStreamActionSubscribe, Params: "AM.AAPL"},
+			})
+
+			cancel()
+			So(<-result, ShouldBeNil)
+		})
+
+		Convey("TestClient should ping the server and reconnect when the pongs stop", func() {
+			stub.silent[1] = true
+			stub.events[2] = []any{minuteBar("AAPL", 1677681000000)}
+
+			c := NewClient(config, recorder.sink(), &logMocks.LoggerMock{})
+			So(c.Subscribe(model.PolygonStreamEventMinuteAggregate, "AAPL"), ShouldBeNil)
+			result := run(c)
+
+			So(waitFor(func() bool { b, _ := recorder.counts(); return b == 1 }), ShouldBeTrue)
+			So(waitFor(func() bool { _, pings, _ := stub.snapshot(); return pings > 0 }), ShouldBeTrue)
+			connections, _, _ := stub.snapshot()
+			So(connections, ShouldEqual, 2)
+
+			cancel()
+			So(<-result, ShouldBeNil)
+		})
+
+		Convey("TestClient should stop without reconnecting when authentication fails", func() {
+			config.ApiKey = "wrong"
+			c := NewClient(config, recorder.sink(), &logMocks.LoggerMock{})
+
+			ge := <-run(c)
+			So(ge, ShouldNotBeNil)
+			So(ge.Error(), ShouldEqual, "stream authentication failed: authentication failed->Client:authenticate:failed->Client:Run:not reconnecting")
+
+			connections, _, _ := stub.snapshot()
+			So(connections, ShouldEqual, 1)
+		})
+
+		Convey("TestClient should give up after MaxReconnects failed connections", func() {
+			config.Url = "ws://127.0.0.1:1"
+			c := NewClient(config, recorder.sink(), &logMocks.LoggerMock{})
+
+			ge := <-run(c)
+			So(ge, ShouldNotBeNil)
+			So(ge.Error(), ShouldEndWith, "Client:Run:giving up after 4 failed connections")
+		})
+	})
+}
@@ -0,0 +1,158 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/greenac/chaching/internal/consts"
+	"github.com/greenac/chaching/internal/database/models"
+	"github.com/greenac/chaching/internal/database/service"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/service/chaching_kafka"
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+// ISink receives the bars and trades of each streamed message
+type ISink interface {
+	HandleBars(ctx context.Context, dps []models.DataPoint) genErr.IGenError
+	HandleTrades(ctx context.Context, trades []models.Trade) genErr.IGenError
+}
+
+var _ ISink = (*SinkFuncs)(nil)
+
+// SinkFuncs adapts callbacks to a sink, events without a callback are dropped
+type SinkFuncs struct {
+	Bars   func(ctx context.Context, dps []models.DataPoint) genErr.IGenError
+	Trades func(ctx context.Context, trades []models.Trade) genErr.IGenError
+}
+
+func (sf SinkFuncs) HandleBars(ctx context.Context, dps []models.DataPoint) genErr.IGenError {
+	if sf.Bars == nil {
+		return nil
+	}
+
+	return sf.Bars(ctx, dps)
+}
+
+func (sf SinkFuncs) HandleTrades(ctx context.Context, trades []models.Trade) genErr.IGenError {
+	if sf.Trades == nil {
+		return nil
+	}
+
+	return sf.Trades(ctx, trades)
+}
+
+var _ ISink = (*Sinks)(nil)
+
+// Sinks hands every event to each of its sinks, in order
+type Sinks []ISink
+
+func (ss Sinks) HandleBars(ctx context.Context, dps []models.DataPoint) genErr.IGenError {
+	for _, s := range ss {
+		if ge := s.HandleBars(ctx, dps); ge != nil {
+			return ge.AddMsg("Sinks:HandleBars:failed")
+		}
+	}
+
+	return nil
+}
+
+func (ss Sinks) HandleTrades(ctx context.Context, trades []models.Trade) genErr.IGenError {
+	for _, s := range ss {
+		if ge := s.HandleTrades(ctx, trades); ge != nil {
+			return ge.AddMsg("Sinks:HandleTrades:failed")
+		}
+	}
+
+	return nil
+}
+
+var _ ISink = (*DatabaseSink)(nil)
+
+// DatabaseSink saves bars beside the fetched ones and trades beside the synced ones. Trades are dropped
+// without a TickService
+type DatabaseSink struct {
+	DatabaseService service.IDatabaseService
+	TickService     service.ITickService
+}
+
+func (ds *DatabaseSink) HandleBars(ctx context.Context, dps []models.DataPoint) genErr.IGenError {
+	errs := ds.DatabaseService.SaveDataPoints(ctx, dps)
+	if errs == nil || len(*errs) == 0 {
+		return nil
+	}
+
+	return (*errs)[0].AddMsg(fmt.Sprintf("DatabaseSink:HandleBars:failed to save bars with %d errors", len(*errs)))
+}
+
+func (ds *DatabaseSink) HandleTrades(ctx context.Context, trades []models.Trade) genErr.IGenError {
+	if ds.TickService == nil {
+		return nil
+	}
+
+	if ge := ds.TickService.SaveTrades(ctx, trades); ge != nil {
+		return ge.AddMsg("DatabaseSink:HandleTrades:failed to save trades")
+	}
+
+	return nil
+}
+
+var _ ISink = (*KafkaSink)(nil)
+
+// KafkaSink publishes each bar and trade to its topic keyed by ticker, so a ticker's events stay in order.
+// Its producer must not have a topic of its own
+type KafkaSink struct {
+	Producer    chaching_kafka.IProducer
+	BarsTopic   consts.TopicName
+	TradesTopic consts.TopicName
+}
+
+func (ks *KafkaSink) HandleBars(ctx context.Context, dps []models.DataPoint) genErr.IGenError {
+	msgs := make([]kafka.Message, len(dps))
+	for i, dp := range dps {
+		m, ge := kafkaMessage(ks.BarsTopic, dp.CompanyName, dp)
+		if ge != nil {
+			return ge.AddMsg("KafkaSink:HandleBars:failed to build message")
+		}
+
+		msgs[i] = m
+	}
+
+	if err := ks.Producer.WriteMessages(ctx, msgs...); err != nil {
+		return &genErr.GenError{Messages: []string{"KafkaSink:HandleBars:failed to write messages with error: " + err.Error()}}
+	}
+
+	return nil
+}
+
+func (ks *KafkaSink) HandleTrades(ctx context.Context, trades []models.Trade) genErr.IGenError {
+	msgs := make([]kafka.Message, len(trades))
+	for i, t := range trades {
+		m, ge := kafkaMessage(ks.TradesTopic, t.CompanyName, t)
+		if ge != nil {
+			return ge.AddMsg("KafkaSink:HandleTrades:failed to build message")
+		}
+
+		msgs[i] = m
+	}
+
+	if err := ks.Producer.WriteMessages(ctx, msgs...); err != nil {
+		return &genErr.GenError{Messages: []string{"KafkaSink:HandleTrades:failed to write messages with error: " + err.Error()}}
+	}
+
+	return nil
+}
+
+func kafkaMessage[T any](topic consts.TopicName, key string, payload T) (kafka.Message, genErr.IGenError) {
+	value, err := json.Marshal(chaching_kafka.Message[T]{
+		KafkaMessage: chaching_kafka.KafkaMessage[T]{Payload: payload, Headers: chaching_kafka.KafkaHeaders{Nonce: uuid.New()}},
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		return kafka.Message{}, &genErr.GenError{Messages: []string{"kafkaMessage:failed to marshal with error: " + err.Error()}}
+	}
+
+	return kafka.Message{Topic: topic.String(), Key: []byte(key), Value: value}, nil
+}
@@ -0,0 +1,86 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/greenac/chaching/internal/database/models"
+	genErr "github.com/greenac/chaching/internal/error"
+	"github.com/greenac/chaching/internal/service/chaching_kafka"
+	"github.com/segmentio/kafka-go"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type producerMock struct {
+	messages []kafka.Message
+	err      error
+}
+
+func (pm *producerMock) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if pm.err != nil {
+		return pm.err
+	}
+
+	pm.messages = append(pm.messages, msgs...)
+	return nil
+}
+
+func TestKafkaSink(t *testing.T) {
+	Convey("TestKafkaSink", t, func() {
+		pm := &producerMock{}
+		ks := &KafkaSink{Producer: pm, BarsTopic: "bars", TradesTopic: "trades"}
+
+		Convey("TestKafkaSink should publish each event to its topic keyed by ticker", func() {
+			So(ks.HandleBars(context.Background(), []models.DataPoint{{CompanyName: "AAPL"}, {CompanyName: "MSFT"}}), ShouldBeNil)
+			So(ks.HandleTrades(context.Background(), []models.Trade{{CompanyName: "AMZN", Day: "2023-03-01"}}), ShouldBeNil)
+			So(len(pm.messages), ShouldEqual, 3)
+			So(pm.messages[1].Topic, ShouldEqual, "bars")
+			So(string(pm.messages[1].Key), ShouldEqual, "MSFT")
+			So(pm.messages[2].Topic, ShouldEqual, "trades")
+
+			var m chaching_kafka.Message[models.Trade]
+			So(json.Unmarshal(pm.messages[2].Value, &m), ShouldBeNil)
+			So(m.KafkaMessage.Payload.CompanyName, ShouldEqual, "AMZN")
+			So(m.KafkaMessage.Payload.Day, ShouldEqual, "2023-03-01")
+		})
+
+		Convey("TestKafkaSink should return the producer's error", func() {
+			pm.err = errors.New("broker down")
+
+			ge := ks.HandleBars(context.Background(), []models.DataPoint{{CompanyName: "AAPL"}})
+			So(ge, ShouldNotBeNil)
+			So(ge.Error(), ShouldEqual, "KafkaSink:HandleBars:failed to write messages with error: broker down")
+		})
+	})
+}
+
+func TestSinks(t *testing.T) {
+	Convey("TestSinks", t, func() {
+		var calls []string
+		record := func(name string, ge genErr.IGenError) ISink {
+			return SinkFuncs{Bars: func(ctx context.Context, dps []models.DataPoint) genErr.IGenError {
+				calls = append(calls, name)
+				return ge
+			}}
+		}
+
+		Convey("TestSinks should hand events to each sink in order", func() {
+			So(Sinks{record("first", nil), record("second", nil)}.HandleBars(context.Background(), []models.DataPoint{{}}), ShouldBeNil)
+			So(calls, ShouldResemble, []string{"first", "second"})
+		})
+
+		Convey("TestSinks should stop at the first failing sink", func() {
+			ge := Sinks{record("first", &genErr.GenError{Messages: []string{"first failed"}}), record("second", nil)}.HandleBars(context.Background(), []models.DataPoint{{}})
+			So(ge, ShouldNotBeNil)
+			So(ge.Error(), ShouldEqual, "first failed->Sinks:HandleBars:failed")
+			So(calls, ShouldResemble, []string{"first"})
+		})
+
+		Convey("TestSinks should drop trades the sinks have no callback for", func() {
+			So(Sinks{record("first", nil)}.HandleTrades(context.Background(), []models.Trade{{}}), ShouldBeNil)
+			So(calls, ShouldBeEmpty)
+		})
+	})
+}
@@ -1,29 +1,71 @@
 package worker
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"time"
 )
 
+var ErrWorkerClosed = errors.New("worker is closed")
+
 type Message[T any] struct {
 	WorkerId int
 	Duration time.Duration
 	Result   T
+	Panic    any // the recovered value when the task panicked, Result is then T's zero value
 }
 
 type Task[T any] func() T
 
+type WorkerStats struct {
+	WorkerId      int
+	Tasks         int
+	Panics        int
+	TotalDuration time.Duration
+	MaxDuration   time.Duration
+}
+
+func (ws WorkerStats) AverageDuration() time.Duration {
+	if ws.Tasks == 0 {
+		return 0
+	}
+
+	return ws.TotalDuration / time.Duration(ws.Tasks)
+}
+
 type IWorker[T any] interface {
 	Work()
-	Stop()
+	Submit(ctx context.Context, t Task[T]) error
 	AddTask(t Task[T])
+	Close()
+	Wait()
+	Stats() []WorkerStats
 }
 
-func NewWorker[T any](numberOfWorkers int, messageChan chan Message[T]) IWorker[T] {
+// NewWorker creates a pool of numberOfWorkers long-lived workers reading from a queue that holds up to queueSize tasks.
+// Results are sent on messageChan, which is closed once the pool is closed and every queued task has run
+func NewWorker[T any](numberOfWorkers int, queueSize int, messageChan chan Message[T]) IWorker[T] {
+	if numberOfWorkers < 1 {
+		numberOfWorkers = 1
+	}
+
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	stats := make([]WorkerStats, numberOfWorkers)
+	for i := range stats {
+		stats[i].WorkerId = i + 1
+	}
+
 	return &Worker[T]{
 		NumberOfWorkers: numberOfWorkers,
 		messageChan:     messageChan,
-		taskChan:        make(chan Task[T]),
-		stopWorkerChan:  make(chan bool, numberOfWorkers),
+		taskChan:        make(chan Task[T], queueSize),
+		done:            make(chan struct{}),
+		closing:         make(chan struct{}),
+		stats:           stats,
 	}
 }
 
@@ -33,30 +75,125 @@ type Worker[T any] struct {
 	NumberOfWorkers int
 	taskChan        chan Task[T]
 	messageChan     chan Message[T]
-	stopWorkerChan  chan bool
+	done            chan struct{}
+	closing         chan struct{} // closed by Close to release submits blocked on a full queue
+	startOnce       sync.Once
+	closeMu         sync.RWMutex
+	closed          bool
+	senders         sync.WaitGroup // submits that may still send on taskChan
+	statsMu         sync.Mutex
+	stats           []WorkerStats
 }
 
+// Work starts the workers. Calling it more than once has no effect
 func (w *Worker[T]) Work() {
-	for i := 1; i <= w.NumberOfWorkers; i += 1 {
-		go func(workerId int) {
-			select {
-			case t := <-w.taskChan:
-				st := time.Now()
-				r := t()
-				w.messageChan <- Message[T]{WorkerId: workerId, Duration: time.Now().Sub(st), Result: r}
-			case <-w.stopWorkerChan:
-				break
-			}
-		}(i)
-	}
+	w.startOnce.Do(func() {
+		wg := sync.WaitGroup{}
+		for i := 1; i <= w.NumberOfWorkers; i += 1 {
+			wg.Add(1)
+			go func(workerId int) {
+				defer wg.Done()
+				for t := range w.taskChan {
+					w.messageChan <- w.run(workerId, t)
+				}
+			}(i)
+		}
+
+		go func() {
+			wg.Wait()
+			close(w.messageChan)
+			close(w.done)
+		}()
+	})
 }
 
-func (w *Worker[T]) Stop() {
-	for i := 0; i < w.NumberOfWorkers; i += 1 {
-		w.stopWorkerChan <- true
+// Submit queues a task, blocking while the queue is full. It returns the context's error if the
+// context is done first, or ErrWorkerClosed if the pool is closed before the task is queued
+func (w *Worker[T]) Submit(ctx context.Context, t Task[T]) error {
+	w.closeMu.RLock()
+	if w.closed {
+		w.closeMu.RUnlock()
+		return ErrWorkerClosed
+	}
+	w.senders.Add(1)
+	w.closeMu.RUnlock()
+	defer w.senders.Done()
+
+	select {
+	case w.taskChan <- t:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.closing:
+		return ErrWorkerClosed
 	}
 }
 
 func (w *Worker[T]) AddTask(t Task[T]) {
-	w.taskChan <- t
+	_ = w.Submit(context.Background(), t)
+}
+
+// Close stops the pool from accepting tasks without waiting for the queue to drain. Queued tasks still run, after
+// which the message channel is closed. Workers that were never started are started so Wait returns
+func (w *Worker[T]) Close() {
+	w.closeMu.Lock()
+	if w.closed {
+		w.closeMu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.closing)
+	w.closeMu.Unlock()
+
+	go func() {
+		w.senders.Wait()
+		close(w.taskChan)
+	}()
+
+	w.Work()
+}
+
+// Wait blocks until the pool is closed and drained. The message channel must be read for it to return
+func (w *Worker[T]) Wait() {
+	<-w.done
+}
+
+func (w *Worker[T]) Stats() []WorkerStats {
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+
+	stats := make([]WorkerStats, len(w.stats))
+	copy(stats, w.stats)
+
+	return stats
+}
+
+func (w *Worker[T]) run(workerId int, t Task[T]) (msg Message[T]) {
+	st := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			msg = Message[T]{WorkerId: workerId, Duration: time.Now().Sub(st), Panic: r}
+		}
+		w.record(msg)
+	}()
+
+	r := t()
+
+	return Message[T]{WorkerId: workerId, Duration: time.Now().Sub(st), Result: r}
+}
+
+func (w *Worker[T]) record(msg Message[T]) {
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+
+	s := &w.stats[msg.WorkerId-1]
+	s.Tasks += 1
+	s.TotalDuration += msg.Duration
+	if msg.Duration > s.MaxDuration {
+		s.MaxDuration = msg.Duration
+	}
+
+	if msg.Panic != nil {
+		s.Panics += 1
+	}
 }
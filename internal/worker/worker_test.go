@@ -1,7 +1,10 @@
 package worker
 
 import (
+	"context"
+	"sync"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -12,7 +15,7 @@ func TestWorker(t *testing.T) {
 		expResult2 := "bandits"
 
 		c := make(chan Message[string], 2)
-		w := NewWorker[string](5, c)
+		w := NewWorker[string](5, 0, c)
 		w.Work()
 
 		w.AddTask(func() string {
@@ -31,3 +34,137 @@ func TestWorker(t *testing.T) {
 		So(results, ShouldContainKey, expResult2)
 	})
 }
+
+func TestWorker_Close(t *testing.T) {
+	Convey("TestWorker_Close should run every task with long-lived workers and close the message channel", t, func() {
+		c := make(chan Message[int])
+		w := NewWorker[int](3, 2, c)
+		w.Work()
+
+		var submitErrs []error
+		go func() {
+			defer w.Close()
+			for i := 0; i < 20; i += 1 {
+				n := i
+				if err := w.Submit(context.Background(), func() int { return n }); err != nil {
+					submitErrs = append(submitErrs, err)
+				}
+			}
+		}()
+
+		total := 0
+		count := 0
+		for m := range c {
+			total += m.Result
+			count += 1
+		}
+		w.Wait()
+
+		So(submitErrs, ShouldBeEmpty)
+		So(count, ShouldEqual, 20)
+		So(total, ShouldEqual, 190)
+
+		tasks := 0
+		for _, s := range w.Stats() {
+			tasks += s.Tasks
+		}
+		So(tasks, ShouldEqual, 20)
+		So(len(w.Stats()), ShouldEqual, 3)
+	})
+}
+
+func TestWorker_CloseWhileBlocked(t *testing.T) {
+	Convey("TestWorker_CloseWhileBlocked", t, func() {
+		Convey("TestWorker_CloseWhileBlocked should not wait on a submit blocked on a full queue", func() {
+			w := NewWorker[int](1, 1, make(chan Message[int]))
+			w.Work()
+			So(w.Submit(context.Background(), func() int { return 1 }), ShouldBeNil)
+
+			errs := make(chan error, 2)
+			for i := 0; i < 2; i += 1 {
+				go func() {
+					errs <- w.Submit(context.Background(), func() int { return 2 })
+				}()
+			}
+
+			// one of the submits is queued and the other blocks, as the first result is never read
+			queued := <-errs
+			closed := make(chan struct{})
+			go func() {
+				w.Close()
+				close(closed)
+			}()
+
+			select {
+			case <-closed:
+			case <-time.After(time.Second):
+				t.Fatal("close blocked on a full queue")
+			}
+
+			So(queued, ShouldBeNil)
+			So(<-errs, ShouldEqual, ErrWorkerClosed)
+		})
+
+		Convey("TestWorker_CloseWhileBlocked should let wait return when closed before working", func() {
+			w := NewWorker[int](1, 0, make(chan Message[int]))
+			w.Close()
+
+			waited := make(chan struct{})
+			go func() {
+				w.Wait()
+				close(waited)
+			}()
+
+			select {
+			case <-waited:
+			case <-time.After(time.Second):
+				t.Fatal("wait blocked after close without work")
+			}
+		})
+	})
+}
+
+func TestWorker_Submit(t *testing.T) {
+	Convey("TestWorker_Submit", t, func() {
+		Convey("TestWorker_Submit should fail after the worker is closed", func() {
+			w := NewWorker[int](1, 1, make(chan Message[int], 1))
+			w.Close()
+			So(w.Submit(context.Background(), func() int { return 1 }), ShouldEqual, ErrWorkerClosed)
+		})
+
+		Convey("TestWorker_Submit should return when the context is done while the queue is full", func() {
+			w := NewWorker[int](1, 0, make(chan Message[int]))
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			So(w.Submit(ctx, func() int { return 1 }), ShouldEqual, context.Canceled)
+		})
+	})
+}
+
+func TestWorker_Panic(t *testing.T) {
+	Convey("TestWorker_Panic", t, func() {
+		c := make(chan Message[string], 2)
+		w := NewWorker[string](1, 2, c)
+		w.Work()
+
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.AddTask(func() string { panic("oh no") })
+			w.AddTask(func() string { return "still working" })
+			w.Close()
+		}()
+
+		m1 := <-c
+		m2 := <-c
+		wg.Wait()
+		w.Wait()
+
+		So(m1.Panic, ShouldEqual, "oh no")
+		So(m1.Result, ShouldEqual, "")
+		So(m2.Result, ShouldEqual, "still working")
+		So(w.Stats()[0].Panics, ShouldEqual, 1)
+		So(w.Stats()[0].Tasks, ShouldEqual, 2)
+	})
+}